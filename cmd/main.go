@@ -1,13 +1,28 @@
 package main
 
 import (
+	"blogAggregator/internal/auth"
 	"blogAggregator/internal/config"
 	"blogAggregator/internal/database"
+	"blogAggregator/internal/events"
+	"blogAggregator/internal/grpcapi"
+	"blogAggregator/internal/handlers"
+	"blogAggregator/internal/imageproxy"
 	"blogAggregator/internal/jobs"
+	"blogAggregator/internal/mailer"
+	"blogAggregator/internal/miniflux"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/oidc"
+	"blogAggregator/internal/retention"
+	"blogAggregator/internal/rss"
+	"blogAggregator/internal/safehttp"
 	"blogAggregator/internal/server"
+	"blogAggregator/internal/websub"
 	"blogAggregator/docs"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"time"
 )
 
@@ -20,7 +35,33 @@ import (
 
 func main() {
 
+	// Migrations only need the database, so they run before the server's
+	// settings and keys are required
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		database.ConnectDatabase(config.LoadDSN())
+		migrate(os.Args[2:])
+		return
+	}
+
 	cfg := config.LoadConfig()
+	if err := auth.Init(cfg); err != nil {
+		log.Fatal("failed to load JWT keys: ", err)
+	}
+	if err := safehttp.Configure(cfg.OutboundAllowlist, cfg.OutboundPorts); err != nil {
+		log.Fatal("invalid OUTBOUND_ALLOWLIST: ", err)
+	}
+	rss.ConfigureFetcher(rss.FetcherOptions{
+		UserAgent:      cfg.FetchUserAgent,
+		ConnectTimeout: cfg.FetchConnectTimeout,
+		ReadTimeout:    cfg.FetchReadTimeout,
+		Timeout:        cfg.FetchTimeout,
+		MaxBytes:       int64(cfg.FetchMaxMB) << 20,
+	})
+	retention.Configure(retention.Policy{
+		MaxAge:      time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		MaxPosts:    cfg.RetentionMaxPosts,
+		UnreadGrace: time.Duration(cfg.RetentionGraceDays) * 24 * time.Hour,
+	})
 
 	// Swagger metadata
 	docs.SwaggerInfo.Title = "Blog Aggregator API"
@@ -32,11 +73,163 @@ func main() {
 
 	database.ConnectDatabase(cfg.DBPath)
 
+	if cfg.MigrateOnStart {
+		applied, err := database.MigrateUp()
+		if err != nil {
+			log.Fatal("failed to migrate database: ", err)
+		}
+		for _, m := range applied {
+			fmt.Printf("applied migration %d_%s\n", m.Version, m.Name)
+		}
+	}
+	if err := database.CheckSchema(); err != nil {
+		log.Fatal(err)
+	}
+
+	// One-off maintenance commands run instead of the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "resanitize":
+			n, err := rss.ResanitizeAll()
+			if err != nil {
+				log.Fatal("resanitize failed: ", err)
+			}
+			fmt.Printf("resanitized %d posts\n", n)
+		default:
+			log.Fatalf("unknown command %q", os.Args[1])
+		}
+		return
+	}
+
+	if cfg.OIDCIssuer != "" {
+		handlers.ConfigureOIDC(oidc.NewProvider(cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL, cfg.OIDCScopes), cfg.OIDCLinkByEmail)
+	}
+
+	if cfg.MinifluxAPI {
+		miniflux.Enable()
+	}
+
+	if cfg.ImageProxyKey != "" {
+		err := imageproxy.Enable(imageproxy.Options{
+			Key:        cfg.ImageProxyKey,
+			BaseURL:    cfg.ImageProxyBaseURL,
+			CacheDir:   cfg.ImageProxyCacheDir,
+			CacheBytes: int64(cfg.ImageProxyCacheMB) << 20,
+		})
+		if err != nil {
+			log.Fatal("failed to enable image proxy: ", err)
+		}
+	}
+
+	if cfg.WebSubCallbackURL != "" {
+		websub.Enable(cfg.WebSubCallbackURL)
+	}
+
+	if cfg.NewsletterSMTPAddr != "" {
+		if cfg.NewsletterDomain == "" {
+			log.Fatal("NEWSLETTER_DOMAIN is required when NEWSLETTER_SMTP_ADDR is set")
+		}
+		newsletter.Configure(cfg.NewsletterDomain)
+		go func() {
+			if err := newsletter.ListenAndServe(cfg.NewsletterSMTPAddr); err != nil {
+				log.Fatal("newsletter SMTP receiver failed: ", err)
+			}
+		}()
+	}
+
 	r := server.NewRouter()
 	go jobs.StartFeedUpdater(5 * time.Minute)
+	go jobs.StartWebhookDispatcher(5 * time.Second)
+	go jobs.StartFullContentWorker(5 * time.Second)
+	go jobs.StartRetentionPruner(cfg.RetentionInterval)
+	if websub.Enabled() {
+		go jobs.StartWebSubManager(time.Minute)
+	}
+	go events.StartClusterFanout(cfg.DBPath)
+	go func() {
+		if err := grpcapi.Serve(":" + cfg.GRPCPort); err != nil {
+			log.Fatal("gRPC server failed: ", err)
+		}
+	}()
+
+	m, err := mailer.New(mailer.Config{
+		Transport:    cfg.Mailer,
+		From:         cfg.MailFrom,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+		SinkDir:      cfg.MailSinkDir,
+	})
+	if err != nil {
+		log.Fatal("failed to configure mailer: ", err)
+	}
+	if m != nil {
+		go jobs.StartDigestScheduler(5*time.Minute, m)
+	}
+
 	fmt.Println("server is running :8080")
-	err := r.Run(":" + cfg.Port)
+	err = r.Run(":" + cfg.Port)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+const migrateUsage = "usage: blogAggregator migrate up | down [steps] | status"
+
+// migrate runs the "migrate" command: up applies pending migrations, down
+// reverts the newest one (or steps of them) and status lists them all
+func migrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("migrate up failed: ", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatal(migrateUsage)
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("migrate down failed: ", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migrations to revert")
+		}
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			log.Fatal("migrate status failed: ", err)
+		}
+		for _, s := range states {
+			status := "pending"
+			if s.AppliedAt != nil {
+				status = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				status += " (unknown to this build)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, status)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
@@ -15,28 +15,49 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/feeds": {
+        "/.well-known/jwks.json": {
             "get": {
+                "description": "Public keys for verifying tokens issued by this server",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "feeds"
+                    "auth"
                 ],
-                "summary": "List feeds",
+                "summary": "JSON Web Key Set",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_auth.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/api-keys": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List API keys",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/blogAggregator_internal_models.Feed"
+                                "$ref": "#/definitions/blogAggregator_internal_models.APIKey"
                             }
                         }
                     }
                 }
             },
             "post": {
+                "description": "Issues a long-lived token for scripts and API clients, sent as X-Auth-Token. The token is only returned here.",
                 "consumes": [
                     "application/json"
                 ],
@@ -44,17 +65,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "feeds"
+                    "auth"
                 ],
-                "summary": "Create feed",
+                "summary": "Create API key",
                 "parameters": [
                     {
-                        "description": "Feed",
+                        "description": "API key",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.FeedCreateInput"
+                            "$ref": "#/definitions/internal_handlers.APIKeyInput"
                         }
                     }
                 ],
@@ -62,7 +83,8 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/blogAggregator_internal_models.Feed"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
@@ -77,27 +99,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/feeds/refresh": {
-            "post": {
-                "consumes": [
-                    "application/json"
-                ],
+        "/api-keys/{id}": {
+            "delete": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "feeds"
+                    "auth"
                 ],
-                "summary": "Refresh a feed",
+                "summary": "Revoke API key",
                 "parameters": [
                     {
-                        "description": "Feed to refresh",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/internal_handlers.RefreshFeedInput"
-                        }
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -110,8 +127,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -122,27 +139,30 @@ const docTemplate = `{
                 }
             }
         },
-        "/login": {
-            "post": {
-                "consumes": [
-                    "application/json"
-                ],
+        "/auth/oidc/callback": {
+            "get": {
+                "description": "Exchanges the authorization code, verifies the ID token and returns a session token like /login, or links the identity when the flow was started by /users/oidc/link",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "auth"
                 ],
-                "summary": "User login",
+                "summary": "Complete single sign-on",
                 "parameters": [
                     {
-                        "description": "Credentials",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/internal_handlers.LoginInput"
-                        }
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -153,8 +173,26 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -165,7 +203,30 @@ const docTemplate = `{
                 }
             }
         },
-        "/posts": {
+        "/auth/oidc/login": {
+            "get": {
+                "description": "Redirects to the configured OpenID Connect provider (authorization-code flow with PKCE)",
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Start single sign-on",
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/extraction-rules": {
             "get": {
                 "produces": [
                     "application/json"
@@ -173,22 +234,21 @@ const docTemplate = `{
                 "tags": [
                     "posts"
                 ],
-                "summary": "List latest posts",
+                "summary": "List site extraction rules",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/blogAggregator_internal_models.Post"
+                                "$ref": "#/definitions/blogAggregator_internal_models.ExtractionRule"
                             }
                         }
                     }
                 }
-            }
-        },
-        "/subscriptions": {
+            },
             "post": {
+                "description": "Pages on the host are extracted with content_selector instead of the readability heuristic. Rules are shared by all users, so only administrators may change them; a www. host also matches the bare domain rule.",
                 "consumes": [
                     "application/json"
                 ],
@@ -196,29 +256,38 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "posts"
                 ],
-                "summary": "Subscribe to a feed",
+                "summary": "Create or replace a site extraction rule",
                 "parameters": [
                     {
-                        "description": "Subscription",
+                        "description": "Rule",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.SubscribeInput"
+                            "$ref": "#/definitions/internal_handlers.ExtractionRuleInput"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/blogAggregator_internal_models.Subscription"
+                            "$ref": "#/definitions/blogAggregator_internal_models.ExtractionRule"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -227,27 +296,25 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/extraction-rules/{id}": {
             "delete": {
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Only administrators may delete rules.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "posts"
                 ],
-                "summary": "Unsubscribe from a feed",
+                "summary": "Delete a site extraction rule",
                 "parameters": [
                     {
-                        "description": "Subscription",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/internal_handlers.SubscribeInput"
-                        }
+                        "type": "integer",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -260,8 +327,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -272,7 +348,27 @@ const docTemplate = `{
                 }
             }
         },
-        "/users": {
+        "/feeds": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feeds"
+                ],
+                "summary": "List feeds",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.Feed"
+                            }
+                        }
+                    }
+                }
+            },
             "post": {
                 "consumes": [
                     "application/json"
@@ -281,17 +377,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "feeds"
                 ],
-                "summary": "Create user (internal/demo)",
+                "summary": "Create feed",
                 "parameters": [
                     {
-                        "description": "User",
+                        "description": "Feed",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.CreateUserInput"
+                            "$ref": "#/definitions/internal_handlers.FeedCreateInput"
                         }
                     }
                 ],
@@ -299,15 +395,24 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/blogAggregator_internal_models.User"
+                            "$ref": "#/definitions/blogAggregator_internal_models.Feed"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/users/register": {
+        "/feeds/preview": {
             "post": {
-                "description": "Create a new user account",
+                "description": "Fetches a feed definition and returns the items it would produce, without saving anything. Useful to try out scrape selectors and JSONPath mappings.",
                 "consumes": [
                     "application/json"
                 ],
@@ -315,25 +420,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "feeds"
                 ],
-                "summary": "Register user",
+                "summary": "Preview a feed",
                 "parameters": [
                     {
-                        "description": "User payload",
+                        "description": "Feed definition; title is optional",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.RegisterInput"
+                            "$ref": "#/definitions/internal_handlers.FeedCreateInput"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/blogAggregator_internal_models.User"
+                            "$ref": "#/definitions/internal_handlers.FeedPreviewResponse"
                         }
                     },
                     "400": {
@@ -344,50 +449,1458 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
-        "/users/{id}/feed": {
-            "get": {
+        "/feeds/refresh": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "feeds"
                 ],
-                "summary": "Get personalized feed",
+                "summary": "Refresh a feed",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Limit",
-                        "name": "limit",
-                        "in": "query"
+                        "description": "Feed to refresh",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.RefreshFeedInput"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feeds/{id}/diagnose": {
+            "post": {
+                "description": "Fetches the feed now and reports the response headers, redirects, detected format, parse warnings and which items would be new. Nothing is stored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feeds"
+                ],
+                "summary": "Diagnose a feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Feed ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_rss.Diagnosis"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feeds/{id}/fetches": {
+            "get": {
+                "description": "Recent fetch attempts, newest first. The latest 100 attempts of each feed are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feeds"
+                ],
+                "summary": "Fetch log of a feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Feed ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.FetchLog"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feeds/{id}/retention": {
+            "put": {
+                "description": "Posts older than retention_days, or beyond the newest retention_max_posts of the feed, are deleted by the pruner unless starred or still unread within the grace period. 0 keeps posts; null or omitted values follow the server-wide policy. Feeds are shared by all their subscribers, so only administrators may change this.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feeds"
+                ],
+                "summary": "Set a feed's retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Feed ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Retention overrides",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.FeedRetentionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Feed"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/folders": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "List folders",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.Folder"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Create folder",
+                "parameters": [
+                    {
+                        "description": "Folder",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.FolderInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Folder"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/folders/{id}": {
+            "delete": {
+                "description": "Subscriptions in the folder are kept and become unfiled",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Delete folder",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Returns a session token, or a challenge token for /login/2fa when two-factor authentication is enabled",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "User login",
+                "parameters": [
+                    {
+                        "description": "Credentials",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.LoginInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/login/2fa": {
+            "post": {
+                "description": "Exchanges the challenge token from /login plus a TOTP or recovery code for a session token. A challenge allows 5 attempts; 10 wrong codes in a row block the user for 15 minutes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete two-factor login",
+                "parameters": [
+                    {
+                        "description": "Challenge",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.LoginTwoFactorInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/posts": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "posts"
+                ],
+                "summary": "List latest posts",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.Post"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/posts/stream": {
+            "get": {
+                "description": "Server-Sent Events stream of new posts from the caller's subscriptions. Each event id is the post id; reconnecting with Last-Event-ID or last_event_id replays posts stored after it. EventSource clients authenticate with a ticket from /posts/stream/ticket.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "posts"
+                ],
+                "summary": "Stream new posts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Last post id received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last post id received, for clients that cannot set headers",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Single-use stream ticket",
+                        "name": "ticket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/posts/stream/ticket": {
+            "post": {
+                "description": "Issues a single-use ticket that opens /posts/stream within 30 seconds, for EventSource clients that cannot send the Authorization header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "posts"
+                ],
+                "summary": "Create stream ticket",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/posts/{id}/full-content": {
+            "post": {
+                "description": "Returns the stored article, extracting it from the post's link first when there is none yet or refresh is set",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "posts"
+                ],
+                "summary": "Fetch the full article of a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Extract again even if an article is stored",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.FullContentResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions": {
+            "put": {
+                "description": "A null folder_id removes the subscription from its folder",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Move subscription to a folder",
+                "parameters": [
+                    {
+                        "description": "Subscription",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.MoveSubscriptionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Subscribe to a feed",
+                "parameters": [
+                    {
+                        "description": "Subscription",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.SubscribeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Subscription"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Unsubscribe from a feed",
+                "parameters": [
+                    {
+                        "description": "Subscription",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.SubscribeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/full-content": {
+            "put": {
+                "description": "New posts of the feed get their linked page fetched and the main article stored in full_content",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Toggle full-article extraction for a subscription",
+                "parameters": [
+                    {
+                        "description": "Setting",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.FullContentSettingInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create user (internal/demo)",
+                "parameters": [
+                    {
+                        "description": "User",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.CreateUserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.User"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/2fa/confirm": {
+            "post": {
+                "description": "Verifies a code from the authenticator app, enables 2FA and returns single-use recovery codes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Confirm TOTP enrollment",
+                "parameters": [
+                    {
+                        "description": "Code",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.TOTPCodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/2fa/disable": {
+            "post": {
+                "description": "Turns off 2FA after confirming the account password and removes recovery codes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "description": "Password",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.PasswordConfirmInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/2fa/enroll": {
+            "post": {
+                "description": "Generates a new TOTP secret and provisioning URI to render as a QR code. 2FA stays disabled until confirmed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Start TOTP enrollment",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/digest": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "digests"
+                ],
+                "summary": "Get email digest settings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.DigestSetting"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Frequency is daily or weekly; hour (0-23) and weekday (0 = Sunday) are in the given IANA timezone. Empty feed_ids and folder_ids include every subscription.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "digests"
+                ],
+                "summary": "Update email digest settings",
+                "parameters": [
+                    {
+                        "description": "Settings",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.DigestSettingInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.DigestSetting"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/fever": {
+            "put": {
+                "description": "Enables the Fever API for the caller. Fever clients sign in with the username and this password, which is kept apart from the account password because Fever sends an unsalted MD5 of it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Set Fever API password",
+                "parameters": [
+                    {
+                        "description": "Fever password",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.FeverPasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Disable Fever API",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/newsletter": {
+            "get": {
+                "description": "Returns the caller's address for email newsletters. Mail sent to it becomes posts on a feed of the caller's per sender, which the caller is subscribed to automatically.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get newsletter address",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.NewsletterAddressResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Generates a new newsletter address for the caller. Any previous address stops accepting mail.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Create newsletter address",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.NewsletterAddressResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Mail to the old address is rejected; feeds and posts already received are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Delete newsletter address",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/oidc/link": {
+            "post": {
+                "description": "Returns the identity provider URL to open in the browser. Its callback links the identity to the signed in user instead of logging in.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Link single sign-on to the account",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/register": {
+            "post": {
+                "description": "Create a new user account",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "User payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.RegisterInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/feed": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get personalized feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.Webhook"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Registers an endpoint for post.created events. The signing secret is only returned here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.WebhookInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "put": {
+                "description": "Replaces URL, filters and active flag; the secret is only changed when given",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Update webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.WebhookInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.Webhook"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Webhook delivery log",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/blogAggregator_internal_models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/test": {
+            "post": {
+                "description": "Sends a ping event right away and returns the logged delivery. Failed pings are retried like any delivery.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Send test event",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/blogAggregator_internal_models.WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -397,45 +1910,499 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "blogAggregator_internal_models.Feed": {
+        }
+    },
+    "definitions": {
+        "blogAggregator_internal_auth.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_auth.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/blogAggregator_internal_auth.JWK"
+                    }
+                }
+            }
+        },
+        "blogAggregator_internal_models.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_models.DigestSetting": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "feed_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "folder_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "frequency": {
+                    "type": "string"
+                },
+                "hour": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_sent_at": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "weekday": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_models.ExtractionRule": {
+            "type": "object",
+            "properties": {
+                "content_selector": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "host": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "remove_selectors": {
+                    "description": "Removed from the extracted content, e.g. share buttons",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.Feed": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "description": "Disabled feeds are not fetched on schedule, e.g. after 410 Gone",
+                    "type": "boolean"
+                },
+                "error_count": {
+                    "description": "Failed fetches push NextFetchAt back exponentially until one succeeds",
+                    "type": "integer"
+                },
+                "fetch_full_content": {
+                    "description": "Fetch each new item's linked page and extract the full article",
+                    "type": "boolean"
+                },
+                "hub_url": {
+                    "description": "WebSub hub and topic advertised by the feed, empty when it has none",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_fetched": {
+                    "type": "string"
+                },
+                "next_fetch_at": {
+                    "type": "string"
+                },
+                "retention_days": {
+                    "description": "Retention overrides; nil follows the global policy, 0 keeps posts",
+                    "type": "integer"
+                },
+                "retention_max_posts": {
+                    "type": "integer"
+                },
+                "source_config": {
+                    "$ref": "#/definitions/blogAggregator_internal_models.SourceConfig"
+                },
+                "source_type": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.FetchLog": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "feed_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "item_count": {
+                    "type": "integer"
+                },
+                "new_count": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status_code": {
+                    "description": "StatusCode is the HTTP status of the feed document, 0 when no\nresponse arrived",
+                    "type": "integer"
+                },
+                "updated_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_models.Folder": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_models.JSONConfig": {
+            "type": "object",
+            "properties": {
+                "body_path": {
+                    "type": "string"
+                },
+                "date_formats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "date_path": {
+                    "type": "string"
+                },
+                "id_path": {
+                    "type": "string"
+                },
+                "items_path": {
+                    "type": "string"
+                },
+                "link_path": {
+                    "type": "string"
+                },
+                "title_path": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.Post": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "content": {
+                    "description": "Content is sanitized HTML; ContentText is its plain text rendering\nfor previews and search",
+                    "type": "string"
+                },
+                "content_text": {
+                    "type": "string"
+                },
+                "feed_id": {
+                    "type": "integer"
+                },
+                "first_seen_at": {
+                    "description": "When the fetcher first stored the item",
+                    "type": "string"
+                },
+                "full_content": {
+                    "description": "Article extracted from the linked page, kept apart from the feed content",
+                    "type": "string"
+                },
+                "full_content_fetched_at": {
+                    "type": "string"
+                },
+                "guid": {
+                    "description": "Source specific item id, used instead of the link to deduplicate",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "Source specific extras such as score, comment count or release tag",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "published": {
+                    "description": "Published falls back to FirstSeenAt for items without a usable date",
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.ScrapeConfig": {
+            "type": "object",
+            "properties": {
+                "content_selector": {
+                    "type": "string"
+                },
+                "date_formats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "date_selector": {
+                    "type": "string"
+                },
+                "item_selector": {
+                    "type": "string"
+                },
+                "link_selector": {
+                    "type": "string"
+                },
+                "title_selector": {
+                    "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.SourceConfig": {
+            "type": "object",
+            "properties": {
+                "json": {
+                    "$ref": "#/definitions/blogAggregator_internal_models.JSONConfig"
+                },
+                "scrape": {
+                    "$ref": "#/definitions/blogAggregator_internal_models.ScrapeConfig"
+                }
+            }
+        },
+        "blogAggregator_internal_models.Subscription": {
+            "type": "object",
+            "properties": {
+                "feed_id": {
+                    "type": "integer"
+                },
+                "fetch_full_content": {
+                    "description": "Ask for full articles of this feed even when the feed does not",
+                    "type": "boolean"
+                },
+                "folder_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_models.User": {
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
+                "email": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "last_fetched": {
+                "totp_enabled": {
+                    "type": "boolean"
+                },
+                "username": {
                     "type": "string"
+                }
+            }
+        },
+        "blogAggregator_internal_models.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
                 },
-                "title": {
+                "created_at": {
                     "type": "string"
                 },
+                "feed_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "folder_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "keywords": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "url": {
                     "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
                 }
             }
         },
-        "blogAggregator_internal_models.Post": {
+        "blogAggregator_internal_models.WebhookDelivery": {
             "type": "object",
             "properties": {
-                "content": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "feed_id": {
-                    "type": "integer"
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
+                "last_attempt_at": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "response_code": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "blogAggregator_internal_rss.DiagnosedItem": {
+            "type": "object",
+            "properties": {
+                "guid": {
+                    "type": "string"
+                },
                 "link": {
                     "type": "string"
                 },
+                "new": {
+                    "type": "boolean"
+                },
+                "pruned": {
+                    "type": "boolean"
+                },
                 "published": {
+                    "description": "Published is null for items without a usable date",
                     "type": "string"
                 },
                 "title": {
@@ -443,48 +2410,163 @@ const docTemplate = `{
                 }
             }
         },
-        "blogAggregator_internal_models.Subscription": {
+        "blogAggregator_internal_rss.Diagnosis": {
             "type": "object",
             "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
                 "feed_id": {
                     "type": "integer"
                 },
-                "id": {
+                "final_url": {
+                    "type": "string"
+                },
+                "format": {
+                    "description": "Format is the detected document format, e.g. \"rss 2.0\" or \"atom 1.0\"",
+                    "type": "string"
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/blogAggregator_internal_rss.DiagnosedItem"
+                    }
+                },
+                "redirects": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/blogAggregator_internal_rss.Redirect"
+                    }
+                },
+                "status_code": {
                     "type": "integer"
                 },
-                "user_id": {
+                "url": {
+                    "type": "string"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "blogAggregator_internal_rss.Redirect": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "integer"
+                },
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "blogAggregator_internal_models.User": {
+        "internal_handlers.APIKeyInput": {
             "type": "object",
             "properties": {
-                "created_at": {
+                "description": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.CreateUserInput": {
+            "type": "object",
+            "properties": {
+                "username": {
                     "type": "string"
+                }
+            }
+        },
+        "internal_handlers.DigestSettingInput": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
                 },
-                "email": {
+                "feed_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "folder_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "frequency": {
                     "type": "string"
                 },
-                "id": {
+                "hour": {
                     "type": "integer"
                 },
-                "username": {
+                "timezone": {
                     "type": "string"
+                },
+                "weekday": {
+                    "type": "integer"
                 }
             }
         },
-        "internal_handlers.CreateUserInput": {
+        "internal_handlers.ExtractionRuleInput": {
             "type": "object",
             "properties": {
-                "username": {
+                "content_selector": {
+                    "type": "string"
+                },
+                "host": {
                     "type": "string"
+                },
+                "remove_selectors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
         "internal_handlers.FeedCreateInput": {
             "type": "object",
             "properties": {
+                "fetch_full_content": {
+                    "description": "Extract the full article behind each new item's link",
+                    "type": "boolean"
+                },
+                "source_config": {
+                    "$ref": "#/definitions/blogAggregator_internal_models.SourceConfig"
+                },
+                "source_headers": {
+                    "description": "Sent with every fetch of a json feed, e.g. Authorization",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "source_type": {
+                    "description": "\"rss\" (default), \"scrape\", \"json\", \"github\", \"reddit\" or \"hackernews\"",
+                    "type": "string"
+                },
                 "title": {
                     "type": "string"
                 },
@@ -493,6 +2575,69 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_handlers.FeedPreviewResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/blogAggregator_internal_models.Post"
+                    }
+                }
+            }
+        },
+        "internal_handlers.FeedRetentionInput": {
+            "type": "object",
+            "properties": {
+                "retention_days": {
+                    "type": "integer"
+                },
+                "retention_max_posts": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_handlers.FeverPasswordInput": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.FolderInput": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.FullContentResponse": {
+            "type": "object",
+            "properties": {
+                "fetched_at": {
+                    "type": "string"
+                },
+                "full_content": {
+                    "type": "string"
+                },
+                "post_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_handlers.FullContentSettingInput": {
+            "type": "object",
+            "properties": {
+                "feed_id": {
+                    "type": "integer"
+                },
+                "fetch_full_content": {
+                    "type": "boolean"
+                }
+            }
+        },
         "internal_handlers.LoginInput": {
             "type": "object",
             "properties": {
@@ -504,6 +2649,44 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_handlers.LoginTwoFactorInput": {
+            "type": "object",
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.MoveSubscriptionInput": {
+            "type": "object",
+            "properties": {
+                "feed_id": {
+                    "type": "integer"
+                },
+                "folder_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_handlers.NewsletterAddressResponse": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.PasswordConfirmInput": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
         "internal_handlers.RefreshFeedInput": {
             "type": "object",
             "properties": {
@@ -532,10 +2715,56 @@ const docTemplate = `{
                 "feed_id": {
                     "type": "integer"
                 },
+                "fetch_full_content": {
+                    "type": "boolean"
+                },
+                "folder_id": {
+                    "type": "integer"
+                },
                 "user_id": {
                     "type": "integer"
                 }
             }
+        },
+        "internal_handlers.TOTPCodeInput": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.WebhookInput": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "feed_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "folder_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "keywords": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
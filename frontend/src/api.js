@@ -17,6 +17,11 @@ export const login = async (username, password) => {
   return data
 }
 
+export const loginTwoFactor = async (challenge_token, code) => {
+  const { data } = await api.post('/login/2fa', { challenge_token, code })
+  return data
+}
+
 export const register = async ({ username, email, password }) => {
   const { data } = await api.post('/users/register', { username, email, password })
   return data
@@ -57,6 +62,24 @@ export const userFeed = async ({ user_id, page = 1, limit = 10 }) => {
   return data
 }
 
+// streamTicket returns a single-use ticket for opening /posts/stream with
+// EventSource, which cannot send the Authorization header
+export const streamTicket = async () => {
+  const { data } = await api.post('/posts/stream/ticket')
+  return data.ticket
+}
+
+export const graphql = async (query, variables = {}) => {
+  const { data } = await api.post('/graphql', { query, variables })
+  if (data.errors?.length) throw new Error(data.errors[0].message)
+  return data.data
+}
+
+export const subscribedFeedIds = async () => {
+  const data = await graphql('{ subscriptions { feed { id } } }')
+  return data.subscriptions.map(s => Number(s.feed.id))
+}
+
 export default api
 
 
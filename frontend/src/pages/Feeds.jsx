@@ -1,5 +1,5 @@
 import { useEffect, useMemo, useState } from 'react'
-import { listFeeds, createFeed, refreshFeed, subscribe, unsubscribe, userFeed } from '../api.js'
+import { listFeeds, createFeed, refreshFeed, subscribe, unsubscribe, subscribedFeedIds } from '../api.js'
 import { useAuth } from '../context/AuthContext.jsx'
 
 export default function Feeds() {
@@ -21,9 +21,7 @@ export default function Feeds() {
     const loadSubscribed = async () => {
       if (!isAuthenticated || !user) return
       try {
-        const data = await userFeed({ user_id: user.id, page: 1, limit: 50 })
-        const ids = new Set((data.posts || []).map(p => p.feed_id))
-        setSubscribedIds(ids)
+        setSubscribedIds(new Set(await subscribedFeedIds()))
       } catch {}
     }
     loadSubscribed()
@@ -1,11 +1,13 @@
 import { useState } from 'react'
-import { login } from '../api.js'
+import { login, loginTwoFactor } from '../api.js'
 import { useAuth } from '../context/AuthContext.jsx'
 import { useNavigate, Link } from 'react-router-dom'
 
 export default function Login() {
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
+  const [challenge, setChallenge] = useState('')
+  const [code, setCode] = useState('')
   const [error, setError] = useState('')
   const navigate = useNavigate()
   const { login: setSession } = useAuth()
@@ -14,7 +16,13 @@ export default function Login() {
     e.preventDefault()
     setError('')
     try {
-      const data = await login(username, password)
+      const data = challenge
+        ? await loginTwoFactor(challenge, code)
+        : await login(username, password)
+      if (data.two_factor_required) {
+        setChallenge(data.challenge_token)
+        return
+      }
       setSession({ token: data.token, user: data.user })
       navigate('/me')
     } catch (err) {
@@ -26,9 +34,15 @@ export default function Login() {
     <div style={{ maxWidth: 420, margin: '24px auto' }}>
       <h2>Login</h2>
       <form onSubmit={onSubmit} style={{ display: 'grid', gap: 12 }}>
-        <input placeholder="Username" value={username} onChange={(e) => setUsername(e.target.value)} />
-        <input type="password" placeholder="Password" value={password} onChange={(e) => setPassword(e.target.value)} />
-        <button type="submit">Login</button>
+        {!challenge ? (
+          <>
+            <input placeholder="Username" value={username} onChange={(e) => setUsername(e.target.value)} />
+            <input type="password" placeholder="Password" value={password} onChange={(e) => setPassword(e.target.value)} />
+          </>
+        ) : (
+          <input placeholder="Authenticator or recovery code" value={code} onChange={(e) => setCode(e.target.value)} autoComplete="one-time-code" />
+        )}
+        <button type="submit">{challenge ? 'Verify' : 'Login'}</button>
       </form>
       {error && <p style={{ color: 'red' }}>{error}</p>}
       <p>Need an account? <Link to="/register">Register</Link></p>
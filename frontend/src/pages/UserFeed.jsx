@@ -1,5 +1,6 @@
 import { useEffect, useState } from 'react'
-import { userFeed, listFeeds, subscribe, unsubscribe, createFeed } from '../api.js'
+import { userFeed, listFeeds, subscribe, unsubscribe, createFeed, streamTicket } from '../api.js'
+import { openPostStream } from '../stream.js'
 import { useAuth } from '../context/AuthContext.jsx'
 
 export default function UserFeed() {
@@ -10,7 +11,7 @@ export default function UserFeed() {
   const [title, setTitle] = useState('')
   const [url, setUrl] = useState('')
   const [message, setMessage] = useState('')
-  const { isAuthenticated, user } = useAuth()
+  const { isAuthenticated, user, token } = useAuth()
   const [subscribedIds, setSubscribedIds] = useState(() => new Set())
   const [loading, setLoading] = useState(true)
 
@@ -32,6 +33,18 @@ export default function UserFeed() {
   useEffect(() => { load() }, [page])
   useEffect(() => { loadFeeds() }, [])
 
+  // New posts arrive over SSE instead of polling; each connection uses a
+  // fresh ticket and resumes after the last post received.
+  useEffect(() => {
+    if (!token || page !== 1) return
+    return openPostStream({
+      getTicket: streamTicket,
+      onPost: (post) => {
+        setPosts(prev => prev.some(p => p.id === post.id) ? prev : [post, ...prev].slice(0, limit))
+      },
+    })
+  }, [token, page])
+
   const onSubscribe = async (feedId) => {
     try {
       setMessage('')
@@ -0,0 +1,46 @@
+// openPostStream follows /posts/stream and calls onPost for every new post.
+// Stream tickets work only once, so EventSource's own retry (same URL, same
+// ticket) cannot succeed: on error the source is closed and reopened with a
+// fresh ticket, resuming after the last post received. Returns a function
+// that closes the stream.
+export function openPostStream({ getTicket, onPost, retryDelay = 5000, EventSourceImpl = globalThis.EventSource }) {
+  let source = null
+  let timer = null
+  let closed = false
+  let lastEventId = ''
+
+  const retry = () => {
+    if (!closed && !timer) timer = setTimeout(connect, retryDelay)
+  }
+
+  const connect = async () => {
+    timer = null
+    let ticket
+    try {
+      ticket = await getTicket()
+    } catch {
+      retry()
+      return
+    }
+    if (closed) return
+    const params = new URLSearchParams({ ticket })
+    if (lastEventId) params.set('last_event_id', lastEventId)
+    source = new EventSourceImpl(`/api/posts/stream?${params}`)
+    source.addEventListener('post', (e) => {
+      if (e.lastEventId) lastEventId = e.lastEventId
+      onPost(JSON.parse(e.data))
+    })
+    source.onerror = () => {
+      source.close()
+      source = null
+      retry()
+    }
+  }
+
+  connect()
+  return () => {
+    closed = true
+    clearTimeout(timer)
+    source?.close()
+  }
+}
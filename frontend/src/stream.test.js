@@ -0,0 +1,83 @@
+import { test } from 'node:test'
+import assert from 'node:assert/strict'
+import { openPostStream } from './stream.js'
+
+class FakeEventSource {
+  static instances = []
+
+  constructor(url) {
+    this.url = url
+    this.closed = false
+    this.listeners = {}
+    FakeEventSource.instances.push(this)
+  }
+
+  addEventListener(type, fn) {
+    this.listeners[type] = fn
+  }
+
+  close() {
+    this.closed = true
+  }
+
+  emit(id, post) {
+    this.listeners.post({ lastEventId: id, data: JSON.stringify(post) })
+  }
+}
+
+const tick = () => new Promise((resolve) => setTimeout(resolve, 5))
+
+const open = (tickets, onPost = () => {}) => {
+  FakeEventSource.instances = []
+  let n = 0
+  return openPostStream({
+    getTicket: async () => {
+      const ticket = tickets[n++]
+      if (ticket instanceof Error) throw ticket
+      return ticket
+    },
+    onPost,
+    retryDelay: 0,
+    EventSourceImpl: FakeEventSource,
+  })
+}
+
+test('reconnects with a new ticket after the last post', async () => {
+  const posts = []
+  const close = open(['first', 'second'], (post) => posts.push(post.id))
+  await tick()
+  const [first] = FakeEventSource.instances
+  assert.equal(first.url, '/api/posts/stream?ticket=first')
+
+  first.emit('41', { id: 41 })
+  first.emit('42', { id: 42 })
+  first.onerror()
+  assert.ok(first.closed, 'the failed source is closed instead of retrying its used ticket')
+  await tick()
+
+  const second = FakeEventSource.instances[1]
+  assert.equal(second.url, '/api/posts/stream?ticket=second&last_event_id=42')
+  second.emit('43', { id: 43 })
+  assert.deepEqual(posts, [41, 42, 43])
+  close()
+  assert.ok(second.closed)
+})
+
+test('retries when no ticket can be had', async () => {
+  const close = open([new Error('offline'), 'ticket'])
+  await tick()
+  await tick()
+  assert.equal(FakeEventSource.instances.length, 1)
+  assert.equal(FakeEventSource.instances[0].url, '/api/posts/stream?ticket=ticket')
+  close()
+})
+
+test('does not reconnect once closed', async () => {
+  const close = open(['first', 'second'])
+  await tick()
+  const [first] = FakeEventSource.instances
+  first.onerror()
+  close()
+  await tick()
+  assert.equal(FakeEventSource.instances.length, 1)
+})
@@ -0,0 +1,43 @@
+// Package apikeys issues and checks long-lived API tokens.
+package apikeys
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Create issues a new key for the user. The plain token is only returned
+// here; the database keeps its hash.
+func Create(userID uint, description string) (models.APIKey, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return models.APIKey{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	key := models.APIKey{UserID: userID, Description: description, TokenHash: hash(token)}
+	err := database.DB.Create(&key).Error
+	return key, token, err
+}
+
+// Authenticate returns the owner of a token and records its use
+func Authenticate(token string) (uint, bool) {
+	if token == "" {
+		return 0, false
+	}
+	var key models.APIKey
+	if err := database.DB.Where("token_hash = ?", hash(token)).First(&key).Error; err != nil {
+		return 0, false
+	}
+	database.DB.Model(&key).Update("last_used_at", time.Now().UTC())
+	return key.UserID, true
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,43 +2,209 @@ package auth
 
 import (
 	"blogAggregator/internal/config"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var cfg = config.LoadConfig()
-var jwtSecret = []byte(cfg.JWTSecret)
+var (
+	jwtSecret []byte
+	// acceptHS256 is false once a signing key replaces JWT_SECRET
+	acceptHS256 bool
+	issuer      string
+	audience    string
+	signer      *signingKey
+	verifiers   = map[string]*verificationKey{}
+	admins      = map[string]bool{}
+)
+
+// challengePurpose marks tokens that only prove the password step of a
+// two-factor login and must not be accepted as session tokens.
+const challengePurpose = "2fa_challenge"
+
+// oidcLinkPurpose marks tokens that carry a signed in user through the
+// single sign-on redirects to link an identity to the account.
+const oidcLinkPurpose = "oidc_link"
+
+// Init loads the signing and verification keys configured at startup.
+// With JWT_SIGNING_KEY_FILE set tokens are signed with that RSA (RS256) or
+// Ed25519 (EdDSA) key; otherwise they fall back to HS256 with JWT_SECRET.
+// JWT_VERIFICATION_KEY_FILES stay accepted for verification so existing
+// sessions survive a key rotation; HS256 tokens are only accepted next to a
+// signing key with JWT_ACCEPT_HS256. ADMIN_USERS names the administrators.
+func Init(cfg config.Config) error {
+	jwtSecret = []byte(cfg.JWTSecret)
+	issuer = cfg.JWTIssuer
+	audience = cfg.JWTAudience
+	signer = nil
+	verifiers = map[string]*verificationKey{}
+	admins = map[string]bool{}
+	for _, username := range cfg.AdminUsers {
+		admins[username] = true
+	}
+
+	if cfg.JWTSigningKeyFile != "" {
+		key, err := loadSigningKey(cfg.JWTSigningKeyFile)
+		if err != nil {
+			return err
+		}
+		signer = key
+		verifiers[key.kid] = &key.verificationKey
+	}
+	for _, path := range cfg.JWTVerificationKeyFiles {
+		key, err := loadVerificationKey(path)
+		if err != nil {
+			return err
+		}
+		verifiers[key.kid] = key
+	}
+
+	if signer == nil && len(jwtSecret) == 0 {
+		return errors.New("either JWT_SIGNING_KEY_FILE or JWT_SECRET must be set")
+	}
+	acceptHS256 = len(jwtSecret) > 0 && (signer == nil || cfg.JWTAcceptHS256)
+	return nil
+}
+
+// IsAdmin reports whether the user may change settings shared by everyone
+func IsAdmin(username string) bool {
+	return admins[username]
+}
 
 func GenerateToken(userID uint) (string, error) {
-	claims := jwt.MapClaims{
+	return signToken(jwt.MapClaims{"user_id": userID}, 24*time.Hour)
+}
+
+// ChallengeTTL is how long the second login step may take
+const ChallengeTTL = 5 * time.Minute
+
+// GenerateChallengeToken issues a short-lived token for the second login
+// step. It returns the token and its random id, under which the caller
+// counts failed codes.
+func GenerateChallengeToken(userID uint) (string, string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+	token, err := signToken(jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"purpose": challengePurpose,
+		"jti":     id,
+	}, ChallengeTTL)
+	return token, id, err
+}
+
+// GenerateOIDCLinkToken issues a short-lived token for linking an identity
+// provider account to userID
+func GenerateOIDCLinkToken(userID uint) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"purpose": oidcLinkPurpose,
+	}, 10*time.Minute)
+}
+
+// signToken adds the registered claims and signs with the active key
+func signToken(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims["sub"] = fmt.Sprint(claims["user_id"])
+	claims["iss"] = issuer
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	if signer != nil {
+		token := jwt.NewWithClaims(signer.method, claims)
+		token.Header["kid"] = signer.kid
+		return token.SignedString(signer.private)
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
+// JWKS returns the public verification keys for other services
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range verifiers {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
 // ParseToken validates and parses JWT token
 func ParseToken(tokenStr string) (uint, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return jwtSecret, nil
-	})
+	userID, _, err := parseToken(tokenStr, "")
+	return userID, err
+}
+
+// ParseChallengeToken validates a token issued by GenerateChallengeToken
+// and returns its user and challenge id
+func ParseChallengeToken(tokenStr string) (uint, string, error) {
+	userID, claims, err := parseToken(tokenStr, challengePurpose)
+	if err != nil {
+		return 0, "", err
+	}
+	id, _ := claims["jti"].(string)
+	if id == "" {
+		return 0, "", jwt.ErrTokenInvalidClaims
+	}
+	return userID, id, nil
+}
+
+// ParseOIDCLinkToken validates a token issued by GenerateOIDCLinkToken
+func ParseOIDCLinkToken(tokenStr string) (uint, error) {
+	userID, _, err := parseToken(tokenStr, oidcLinkPurpose)
+	return userID, err
+}
+
+func parseToken(tokenStr, purpose string) (uint, jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, lookupKey,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil || !token.Valid {
-		return 0, err
+		return 0, nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if p, _ := claims["purpose"].(string); p != purpose {
+			return 0, nil, jwt.ErrTokenInvalidClaims
+		}
 		if userID, ok := claims["user_id"].(float64); ok {
-			return uint(userID), nil
+			return uint(userID), claims, nil
+		}
+	}
+	return 0, nil, jwt.ErrInvalidKey
+}
+
+// lookupKey selects the verification key by kid, making sure the token's
+// alg matches the key type so an RSA public key can never be used as an
+// HMAC secret.
+func lookupKey(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if !acceptHS256 {
+			return nil, jwt.ErrSignatureInvalid
 		}
+		return jwtSecret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	key, ok := verifiers[kid]
+	if !ok || key.method.Alg() != t.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
 	}
-	return 0, jwt.ErrInvalidKey
+	return key.public, nil
 }
 
 // HashPassword hashes a password using bcrypt
@@ -54,4 +220,4 @@ func HashPassword(password string) (string, error) {
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
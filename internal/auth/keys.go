@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationKey is a public key tokens may be signed with, identified by kid
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// signingKey is the private key new tokens are signed with
+type signingKey struct {
+	verificationKey
+	private crypto.Signer
+}
+
+// JWK is a single public key in a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// loadSigningKey reads a PEM encoded RSA or Ed25519 private key
+func loadSigningKey(path string) (*signingKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type", path)
+	}
+	vk, err := newVerificationKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &signingKey{verificationKey: *vk, private: signer}, nil
+}
+
+// loadVerificationKey reads a PEM encoded public key, or the public half of a private key
+func loadVerificationKey(path string) (*verificationKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		sk, err := loadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return &sk.verificationKey, nil
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	vk, err := newVerificationKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vk, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+// newVerificationKey picks the signing method for a public key and derives
+// its kid from the RFC 7638 thumbprint, so the same key always gets the same id.
+func newVerificationKey(public crypto.PublicKey) (*verificationKey, error) {
+	vk := &verificationKey{public: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		vk.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		vk.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	jwk := vk.jwk()
+	var thumbprintInput []byte
+	if jwk.Kty == "RSA" {
+		thumbprintInput, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	} else {
+		thumbprintInput, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+	sum := sha256.Sum256(thumbprintInput)
+	vk.kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return vk, nil
+}
+
+func (k *verificationKey) jwk() JWK {
+	jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"blogAggregator/internal/config"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePEM stores a DER block in a temporary file and returns its path
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func rsaKeyFile(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), key
+}
+
+func ed25519KeyFile(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "PRIVATE KEY", der), key
+}
+
+// publicKeyFile stores only the public half, as used during a rotation
+func publicKeyFile(t *testing.T, public interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "PUBLIC KEY", der)
+}
+
+func initAuth(t *testing.T, cfg config.Config) {
+	t.Helper()
+	cfg.JWTIssuer, cfg.JWTAudience = "test", "test"
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// header returns the decoded header of a token without verifying it
+func header(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Header
+}
+
+// forge signs claims that would pass every check but the signature
+func forge(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"user_id": 7,
+		"sub":     "7",
+		"iss":     "test",
+		"aud":     "test",
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSigningKeys(t *testing.T) {
+	rsaPath, _ := rsaKeyFile(t)
+	edPath, _ := ed25519KeyFile(t)
+	tests := []struct {
+		path string
+		alg  string
+	}{
+		{rsaPath, "RS256"},
+		{edPath, "EdDSA"},
+	}
+	for _, tt := range tests {
+		initAuth(t, config.Config{JWTSigningKeyFile: tt.path})
+		token, err := GenerateToken(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := header(t, token)
+		if h["alg"] != tt.alg || h["kid"] != signer.kid {
+			t.Errorf("%s: header = %v, want kid %s", tt.alg, h, signer.kid)
+		}
+		if id, err := ParseToken(token); err != nil || id != 7 {
+			t.Errorf("%s: ParseToken = %d, %v", tt.alg, id, err)
+		}
+		if keys := JWKS().Keys; len(keys) != 1 || keys[0].Kid != signer.kid || keys[0].Alg != tt.alg {
+			t.Errorf("%s: JWKS = %+v", tt.alg, keys)
+		}
+	}
+}
+
+func TestKidIsStable(t *testing.T) {
+	path, key := rsaKeyFile(t)
+	private, err := loadSigningKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := loadVerificationKey(publicKeyFile(t, &key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private.kid != public.kid || private.kid == "" {
+		t.Fatalf("kid %q for the private key, %q for the public key", private.kid, public.kid)
+	}
+}
+
+func TestRotatedOutKey(t *testing.T) {
+	oldPath, oldKey := ed25519KeyFile(t)
+	newPath, _ := rsaKeyFile(t)
+
+	initAuth(t, config.Config{JWTSigningKeyFile: oldPath})
+	oldToken, err := GenerateToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKid := signer.kid
+
+	// The old key only verifies now
+	initAuth(t, config.Config{
+		JWTSigningKeyFile:       newPath,
+		JWTVerificationKeyFiles: []string{publicKeyFile(t, oldKey.Public())},
+	})
+	if id, err := ParseToken(oldToken); err != nil || id != 7 {
+		t.Fatalf("token of the old key: %d, %v", id, err)
+	}
+	newToken, err := GenerateToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := header(t, newToken)["kid"]; kid == oldKid || kid != signer.kid {
+		t.Fatalf("new token signed with kid %v", kid)
+	}
+	if keys := JWKS().Keys; len(keys) != 2 {
+		t.Fatalf("JWKS has %d keys", len(keys))
+	}
+
+	// Once the old key is dropped its tokens stop working
+	initAuth(t, config.Config{JWTSigningKeyFile: newPath})
+	if _, err := ParseToken(oldToken); err == nil {
+		t.Fatal("token of a removed key accepted")
+	}
+}
+
+func TestRejectsForgedTokens(t *testing.T) {
+	rsaPath, rsaKey := rsaKeyFile(t)
+	_, otherKey := ed25519KeyFile(t)
+	initAuth(t, config.Config{JWTSigningKeyFile: rsaPath})
+	kid := signer.kid
+	publicPEM, err := os.ReadFile(publicKeyFile(t, &rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"alg none", forge(t, jwt.SigningMethodNone, kid, jwt.UnsafeAllowNoneSignatureType)},
+		{"HS256 keyed with the public key", forge(t, jwt.SigningMethodHS256, kid, publicPEM)},
+		{"HS256 keyed with the public key bytes", forge(t, jwt.SigningMethodHS256, kid, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))},
+		{"EdDSA under the RSA kid", forge(t, jwt.SigningMethodEdDSA, kid, otherKey)},
+		{"unknown kid", forge(t, jwt.SigningMethodEdDSA, "unknown", otherKey)},
+		{"RS256 without kid", forge(t, jwt.SigningMethodRS256, "", rsaKey)},
+		{"RS512", forge(t, jwt.SigningMethodRS512, kid, rsaKey)},
+	}
+	for _, tt := range tests {
+		if _, err := ParseToken(tt.token); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+	// The same claims signed properly pass, so the checks above failed on
+	// the signature
+	if _, err := ParseToken(forge(t, jwt.SigningMethodRS256, kid, rsaKey)); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+}
+
+func TestHS256NextToSigningKey(t *testing.T) {
+	path, _ := rsaKeyFile(t)
+	secret := []byte("test-secret")
+	legacy := forge(t, jwt.SigningMethodHS256, "", secret)
+
+	initAuth(t, config.Config{JWTSecret: string(secret)})
+	if _, err := ParseToken(legacy); err != nil {
+		t.Fatalf("HS256 without a signing key: %v", err)
+	}
+
+	initAuth(t, config.Config{JWTSecret: string(secret), JWTSigningKeyFile: path})
+	if _, err := ParseToken(legacy); err == nil {
+		t.Fatal("HS256 accepted next to a signing key")
+	}
+
+	initAuth(t, config.Config{JWTSecret: string(secret), JWTSigningKeyFile: path, JWTAcceptHS256: true})
+	if _, err := ParseToken(legacy); err != nil {
+		t.Fatalf("HS256 with JWT_ACCEPT_HS256: %v", err)
+	}
+	// New tokens still use the key
+	token, err := GenerateToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg := header(t, token)["alg"]; alg != "RS256" {
+		t.Fatalf("new token signed with %v", alg)
+	}
+}
+
+func TestInitRequiresAKey(t *testing.T) {
+	if err := Init(config.Config{}); err == nil {
+		t.Fatal("Init without a secret or key succeeded")
+	}
+	if err := Init(config.Config{JWTSigningKeyFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatal("Init with a missing key file succeeded")
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, base32 encoded
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfcSecret, tt.code, at)
+		if !ok || step != tt.unix/30 {
+			t.Errorf("ValidateTOTP(%s at %d) = %d, %v", tt.code, tt.unix, step, ok)
+		}
+		// Lowercase secrets and surrounding spaces are accepted
+		if _, ok := ValidateTOTP(strings.ToLower(rfcSecret), " "+tt.code+" ", at); !ok {
+			t.Errorf("%s not accepted with a lowercase secret", tt.code)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	// 287082 is the code for step 1, 30s to 59s
+	tests := []struct {
+		unix int64
+		ok   bool
+	}{
+		{0, true},   // one step early
+		{29, true},  // still one step early
+		{45, true},  // current step
+		{60, true},  // one step late
+		{89, true},  // still one step late
+		{90, false}, // two steps late
+		{120, false},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, "287082", time.Unix(tt.unix, 0))
+		if ok != tt.ok || ok && step != 1 {
+			t.Errorf("at %d: step %d, ok %v", tt.unix, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := ValidateTOTP(rfcSecret, code, at); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", at); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 9 || code[4] != '-' || code != strings.ToLower(code) || seen[code] {
+			t.Fatalf("code %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != 10 {
+		t.Fatalf("got %d codes", len(seen))
+	}
+}
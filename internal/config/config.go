@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +14,80 @@ type Config struct {
 	Port      string
 	DBPath    string
 	JWTSecret string
+	// Asymmetric signing: the key tokens are signed with, plus any extra
+	// public keys still accepted while rotating away from an old key.
+	JWTSigningKeyFile       string
+	JWTVerificationKeyFiles []string
+	// JWTAcceptHS256 keeps tokens signed with JWTSecret valid next to a
+	// signing key while moving off HS256. Without a signing key HS256 is
+	// always used.
+	JWTAcceptHS256 bool
+	JWTIssuer      string
+	JWTAudience    string
+	// OpenID Connect single sign-on, disabled when OIDCIssuer is empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	// OIDCLinkByEmail links a first SSO login to the existing account with
+	// the same verified email instead of refusing it
+	OIDCLinkByEmail bool
+	// AdminUsers are the usernames allowed to change settings shared by all
+	// users, such as site extraction rules and feed retention
+	AdminUsers []string
+	// Outgoing mail for digests: MAILER is "smtp", "file" or empty to disable
+	Mailer       string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	MailSinkDir  string
+	// MinifluxAPI registers the Miniflux compatible /v1/ routes
+	MinifluxAPI bool
+	// GRPCPort is where the gRPC API for internal services listens
+	GRPCPort string
+	// Newsletter SMTP receiver, disabled when NewsletterSMTPAddr is empty
+	NewsletterSMTPAddr string
+	NewsletterDomain   string
+	// WebSubCallbackURL is the server's public base URL hubs call back on;
+	// WebSub is disabled when it is empty
+	WebSubCallbackURL string
+	// Image proxy for post content, disabled when ImageProxyKey is empty.
+	// ImageProxyBaseURL is the server's public URL for proxied image links.
+	ImageProxyKey      string
+	ImageProxyBaseURL  string
+	ImageProxyCacheDir string
+	ImageProxyCacheMB  int
+	// Outbound fetches may reach these hosts or CIDR ranges even when they
+	// are private, e.g. intranet feeds, and only use OutboundPorts
+	OutboundAllowlist []string
+	OutboundPorts     []int
+	// Feed fetching: User-Agent (with a contact URL), timeouts and the
+	// largest document accepted
+	FetchUserAgent      string
+	FetchConnectTimeout time.Duration
+	FetchReadTimeout    time.Duration
+	FetchTimeout        time.Duration
+	FetchMaxMB          int
+	// Post retention: age in days and posts per feed to keep (0 keeps
+	// everything), how long unread posts are kept regardless, and how often
+	// the pruner runs
+	RetentionDays      int
+	RetentionMaxPosts  int
+	RetentionGraceDays int
+	RetentionInterval  time.Duration
+	// MigrateOnStart applies pending schema migrations at startup instead
+	// of refusing to start
+	MigrateOnStart bool
+}
+
+// LoadDSN reads only the database DSN, for commands such as migrate that
+// must work before the rest of the configuration is in place
+func LoadDSN() string {
+	godotenv.Load()
+	return getEnv("dsn")
 }
 
 func LoadConfig() Config {
@@ -20,9 +97,49 @@ func LoadConfig() Config {
 	}
 
 	return Config{
-		Port:      getEnv("PORT"),
-		DBPath:    getEnv("dsn"),
-		JWTSecret: getEnv("JWT_SECRET"),
+		Port:                    getEnv("PORT"),
+		DBPath:                  getEnv("dsn"),
+		JWTSecret:               getEnvDefault("JWT_SECRET", ""),
+		JWTSigningKeyFile:       getEnvDefault("JWT_SIGNING_KEY_FILE", ""),
+		JWTVerificationKeyFiles: getEnvList("JWT_VERIFICATION_KEY_FILES"),
+		JWTAcceptHS256:          getEnvDefault("JWT_ACCEPT_HS256", "false") == "true",
+		JWTIssuer:               getEnvDefault("JWT_ISSUER", "blog-aggregator"),
+		JWTAudience:             getEnvDefault("JWT_AUDIENCE", "blog-aggregator"),
+		OIDCIssuer:              getEnvDefault("OIDC_ISSUER", ""),
+		OIDCClientID:            getEnvDefault("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:        getEnvDefault("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:         getEnvDefault("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:              getEnvList("OIDC_SCOPES"),
+		OIDCLinkByEmail:         getEnvDefault("OIDC_LINK_BY_EMAIL", "false") == "true",
+		AdminUsers:              getEnvList("ADMIN_USERS"),
+		Mailer:                  getEnvDefault("MAILER", ""),
+		MailFrom:                getEnvDefault("MAIL_FROM", ""),
+		SMTPHost:                getEnvDefault("SMTP_HOST", ""),
+		SMTPPort:                getEnvDefault("SMTP_PORT", "587"),
+		SMTPUsername:            getEnvDefault("SMTP_USERNAME", ""),
+		SMTPPassword:            getEnvDefault("SMTP_PASSWORD", ""),
+		MailSinkDir:             getEnvDefault("MAIL_SINK_DIR", "mail"),
+		MinifluxAPI:             getEnvDefault("MINIFLUX_API", "false") == "true",
+		GRPCPort:                getEnvDefault("GRPC_PORT", "9090"),
+		NewsletterSMTPAddr:      getEnvDefault("NEWSLETTER_SMTP_ADDR", ""),
+		NewsletterDomain:        getEnvDefault("NEWSLETTER_DOMAIN", ""),
+		WebSubCallbackURL:       getEnvDefault("WEBSUB_CALLBACK_URL", ""),
+		ImageProxyKey:           getEnvDefault("IMAGE_PROXY_KEY", ""),
+		ImageProxyBaseURL:       getEnvDefault("IMAGE_PROXY_BASE_URL", ""),
+		ImageProxyCacheDir:      getEnvDefault("IMAGE_PROXY_CACHE_DIR", "imagecache"),
+		ImageProxyCacheMB:       getEnvInt("IMAGE_PROXY_CACHE_MB", 512),
+		OutboundAllowlist:       getEnvList("OUTBOUND_ALLOWLIST"),
+		OutboundPorts:           getEnvIntList("OUTBOUND_PORTS"),
+		FetchUserAgent:          getEnvDefault("FETCH_USER_AGENT", ""),
+		FetchConnectTimeout:     getEnvDuration("FETCH_CONNECT_TIMEOUT", 10*time.Second),
+		FetchReadTimeout:        getEnvDuration("FETCH_READ_TIMEOUT", 20*time.Second),
+		FetchTimeout:            getEnvDuration("FETCH_TIMEOUT", 60*time.Second),
+		FetchMaxMB:              getEnvInt("FETCH_MAX_MB", 10),
+		RetentionDays:           getEnvInt("RETENTION_DAYS", 0),
+		RetentionMaxPosts:       getEnvInt("RETENTION_MAX_POSTS", 0),
+		RetentionGraceDays:      getEnvInt("RETENTION_UNREAD_GRACE_DAYS", 14),
+		RetentionInterval:       getEnvDuration("RETENTION_INTERVAL", time.Hour),
+		MigrateOnStart:          getEnvDefault("MIGRATE_ON_START", "false") == "true",
 	}
 }
 
@@ -30,6 +147,62 @@ func getEnv(key string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
 	}
-	log.Fatalf("required enviornment variable %s is not set",key)
+	log.Fatalf("required enviornment variable %s is not set", key)
 	return ""
 }
+
+func getEnvDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := getEnvDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("enviornment variable %s must be a number, got %q", key, value)
+	}
+	return n
+}
+
+// getEnvDuration reads a duration such as "30s" or "2m"
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnvDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("enviornment variable %s must be a duration such as 30s, got %q", key, value)
+	}
+	return d
+}
+
+// getEnvIntList is getEnvList for numbers
+func getEnvIntList(key string) []int {
+	var values []int
+	for _, v := range getEnvList(key) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("enviornment variable %s must list numbers, got %q", key, v)
+		}
+		values = append(values, n)
+	}
+	return values
+}
+
+// getEnvList splits a comma separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -1,7 +1,6 @@
 package database
 
 import (
-	"blogAggregator/internal/models"
 	"fmt"
 	"log"
    "gorm.io/driver/postgres"
@@ -22,9 +21,5 @@ func ConnectDatabase(dsn string){
 	if err!=nil{
 		log.Fatal("failed to connect database :",err)
 	}
-	err= DB.AutoMigrate(&models.User{},&models.Feed{},&models.Post{},&models.Subscription{})
-	if err!=nil{
-		log.Fatal("failed to migrate database:",err)
-	}
-	fmt.Println("database connected & migrated successfully")
+	fmt.Println("database connected successfully")
 }
\ No newline at end of file
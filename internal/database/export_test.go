@@ -0,0 +1,17 @@
+package database
+
+import (
+	"io/fs"
+	"testing"
+)
+
+// UseMigrations makes the runner read files instead of the embedded
+// migrations until the test ends
+func UseMigrations(t *testing.T, files fs.FS) {
+	old := migrationSource
+	migrationSource = files
+	t.Cleanup(func() { migrationSource = old })
+}
+
+// EmbeddedMigrations are the migrations this build ships
+var EmbeddedMigrations fs.FS = migrationFiles
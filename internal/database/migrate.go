@@ -0,0 +1,268 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations are pairs of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Each runs in its own transaction, so a file
+// may hold several statements but not ones Postgres refuses to run in a
+// transaction, like CREATE INDEX CONCURRENTLY.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationSource holds the migrations directory the runner uses; tests
+// replace it
+var migrationSource fs.FS = migrationFiles
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// migrationLock is the advisory lock key that serialises migrations when
+// several instances start at once
+const migrationLock = 7270591
+
+const createVersionTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationState is a migration and when it was applied, nil if pending.
+// Migrations applied by a newer build are listed with Unknown set.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// loadMigrations reads the migrations directory of files in version order
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, m[2])
+		}
+		body, err := fs.ReadFile(files, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if m[3] == "up" {
+			migration.up = string(body)
+		} else {
+			migration.down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations reads the version table, which does not exist before
+// the first migration ran
+func appliedMigrations(db *gorm.DB) (map[int64]appliedMigration, error) {
+	applied := map[int64]appliedMigration{}
+	if !db.Migrator().HasTable("schema_migrations") {
+		return applied, nil
+	}
+	var rows []appliedMigration
+	if err := db.Table("schema_migrations").Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// lockedMigration runs fn in a transaction holding the migration lock. fn
+// gets the versions applied so far, read after the lock was taken.
+func lockedMigration(fn func(tx *gorm.DB, applied map[int64]appliedMigration) error) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(createVersionTable).Error; err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		return fn(tx, applied)
+	})
+}
+
+// MigrateUp applies the pending migrations in order and returns the ones
+// it applied. It stops at the first failure, which is rolled back.
+func MigrateUp() ([]Migration, error) {
+	migrations, err := loadMigrations(migrationSource)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range migrations {
+		ran := false
+		err := lockedMigration(func(tx *gorm.DB, applied map[int64]appliedMigration) error {
+			if _, ok := applied[migration.Version]; ok {
+				return nil
+			}
+			if err := tx.Exec(migration.up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the newest steps applied migrations and returns the
+// ones it reverted
+func MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := loadMigrations(migrationSource)
+	if err != nil {
+		return nil, err
+	}
+	known := map[int64]Migration{}
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+	var done []Migration
+	for len(done) < steps {
+		var reverted *Migration
+		err := lockedMigration(func(tx *gorm.DB, applied map[int64]appliedMigration) error {
+			newest := int64(-1)
+			for version := range applied {
+				if version > newest {
+					newest = version
+				}
+			}
+			if newest < 0 {
+				return nil
+			}
+			migration, ok := known[newest]
+			if !ok {
+				return fmt.Errorf("migration %d_%s was applied by a newer build, which has to revert it", newest, applied[newest].Name)
+			}
+			if err := tx.Exec(migration.down).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = &migration
+			return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return done, err
+		}
+		if reverted == nil {
+			break
+		}
+		done = append(done, *reverted)
+	}
+	return done, nil
+}
+
+// MigrationStatus lists every migration this build knows, and any the
+// database has from a newer build, in version order
+func MigrationStatus() ([]MigrationState, error) {
+	migrations, err := loadMigrations(migrationSource)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(DB)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		state := MigrationState{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			state.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		states = append(states, state)
+	}
+	for _, row := range applied {
+		row := row
+		states = append(states, MigrationState{
+			Migration: Migration{Version: row.Version, Name: row.Name},
+			AppliedAt: &row.AppliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// CheckSchema fails unless the database has exactly the migrations this
+// build knows. It never changes the schema.
+func CheckSchema() error {
+	states, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+	pending, unknown := 0, 0
+	for _, state := range states {
+		switch {
+		case state.Unknown:
+			unknown++
+		case state.AppliedAt == nil:
+			pending++
+		}
+	}
+	if unknown > 0 {
+		return fmt.Errorf("the database has %d migrations this build does not know; run a newer build or revert them with it", unknown)
+	}
+	if pending > 0 {
+		return fmt.Errorf("the database schema is out of date, %d migrations are pending; run \"blogAggregator migrate up\" or set MIGRATE_ON_START=true", pending)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package database_test
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// withExtraMigration returns the shipped migrations plus one more
+func withExtraMigration(t *testing.T, version, up, down string) fstest.MapFS {
+	t.Helper()
+	files := fstest.MapFS{}
+	err := fs.WalkDir(database.EmbeddedMigrations, "migrations", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(database.EmbeddedMigrations, path)
+		files[path] = &fstest.MapFile{Data: body}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["migrations/"+version+".up.sql"] = &fstest.MapFile{Data: []byte(up)}
+	files["migrations/"+version+".down.sql"] = &fstest.MapFile{Data: []byte(down)}
+	return files
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	testdb.Open(t)
+	if err := database.CheckSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	database.UseMigrations(t, withExtraMigration(t, "9000_widgets",
+		"-- Widgets.\nCREATE TABLE widgets (id bigserial PRIMARY KEY);",
+		"DROP TABLE widgets;"))
+	if err := database.CheckSchema(); err == nil || !strings.Contains(err.Error(), "1 migrations are pending") {
+		t.Fatalf("pending migration: %v", err)
+	}
+	states, err := database.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := states[len(states)-1]; last.Version != 9000 || last.AppliedAt != nil || last.Unknown {
+		t.Fatalf("status = %+v", last)
+	}
+
+	done, err := database.MigrateUp()
+	if err != nil || len(done) != 1 || done[0].Name != "widgets" {
+		t.Fatalf("up applied %+v: %v", done, err)
+	}
+	if !database.DB.Migrator().HasTable("widgets") || database.CheckSchema() != nil {
+		t.Fatal("migration not applied")
+	}
+	// Nothing left to do
+	if done, err := database.MigrateUp(); err != nil || len(done) != 0 {
+		t.Fatalf("second up applied %+v: %v", done, err)
+	}
+
+	done, err = database.MigrateDown(1)
+	if err != nil || len(done) != 1 || done[0].Version != 9000 {
+		t.Fatalf("down reverted %+v: %v", done, err)
+	}
+	if database.DB.Migrator().HasTable("widgets") {
+		t.Fatal("down left the table")
+	}
+}
+
+func TestMigrateUpRollsBackFailures(t *testing.T) {
+	testdb.Open(t)
+	database.UseMigrations(t, withExtraMigration(t, "9001_broken",
+		"-- Half works.\nCREATE TABLE gadgets (id bigserial PRIMARY KEY);\nALTER TABLE missing ADD COLUMN x text;",
+		"DROP TABLE gadgets;"))
+
+	if _, err := database.MigrateUp(); err == nil || !strings.Contains(err.Error(), "9001_broken") {
+		t.Fatalf("error = %v", err)
+	}
+	if database.DB.Migrator().HasTable("gadgets") {
+		t.Fatal("failed migration was not rolled back")
+	}
+	if err := database.CheckSchema(); err == nil {
+		t.Fatal("failed migration recorded as applied")
+	}
+}
+
+func TestCheckSchemaRefusesNewerDatabase(t *testing.T) {
+	testdb.Open(t)
+	database.DB.Exec("INSERT INTO schema_migrations (version, name) VALUES (9999, 'future')")
+	t.Cleanup(func() { database.DB.Exec("DELETE FROM schema_migrations WHERE version = 9999") })
+
+	if err := database.CheckSchema(); err == nil || !strings.Contains(err.Error(), "does not know") {
+		t.Fatalf("check: %v", err)
+	}
+	if _, err := database.MigrateDown(1); err == nil || !strings.Contains(err.Error(), "newer build") {
+		t.Fatalf("down: %v", err)
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	testdb.Open(t)
+	for _, model := range []interface{}{
+		&models.User{}, &models.RecoveryCode{}, &models.LoginChallenge{}, &models.StreamTicket{},
+		&models.Feed{}, &models.Post{}, &models.Subscription{}, &models.ExtractionRule{},
+		&models.ExtractionJob{}, &models.PostTombstone{}, &models.FetchLog{}, &models.Folder{},
+		&models.Webhook{}, &models.WebhookDelivery{}, &models.DigestSetting{}, &models.Digest{},
+		&models.DigestItem{}, &models.PostState{}, &models.APIKey{}, &models.WebSubSubscription{},
+	} {
+		stmt := &gorm.Statement{DB: database.DB}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if !database.DB.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("table %s is missing", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !database.DB.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+// Tables as AutoMigrate created them in the first release
+type baselineUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"unique;not null"`
+	Email     string `gorm:"uniqueIndex;not null"`
+	Password  string
+	CreatedAt time.Time
+}
+
+type baselineFeed struct {
+	ID          uint `gorm:"primaryKey"`
+	Title       string
+	URL         string `gorm:"uniqueIndex;not null"`
+	CreatedAt   time.Time
+	LastFetched *time.Time
+}
+
+type baselinePost struct {
+	ID        uint `gorm:"primaryKey"`
+	Title     string
+	Link      string `gorm:"uniqueIndex;not null"`
+	Content   string
+	Published time.Time
+	FeedId    uint
+}
+
+type baselineSubscription struct {
+	ID     uint `gorm:"primaryKey"`
+	UserID uint
+	FeedID uint
+}
+
+func (baselineUser) TableName() string         { return "users" }
+func (baselineFeed) TableName() string         { return "feeds" }
+func (baselinePost) TableName() string         { return "posts" }
+func (baselineSubscription) TableName() string { return "subscriptions" }
+
+func TestMigrateUpFromFirstRelease(t *testing.T) {
+	dsn := testdb.DSN(t)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so the search path set below holds for every query
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA IF EXISTS "baseline_upgrade" CASCADE`)
+		sqlDB.Close()
+	})
+	for _, stmt := range []string{
+		`DROP SCHEMA IF EXISTS "baseline_upgrade" CASCADE`,
+		`CREATE SCHEMA "baseline_upgrade"`,
+		`SET search_path TO "baseline_upgrade"`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AutoMigrate(&baselineUser{}, &baselineFeed{}, &baselinePost{}, &baselineSubscription{}); err != nil {
+		t.Fatal(err)
+	}
+	feed := baselineFeed{Title: "Old", URL: "https://example.com/feed"}
+	db.Create(&feed)
+	db.Create(&baselinePost{Title: "Kept", Link: "https://example.com/1", Published: time.Now(), FeedId: feed.ID})
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CheckSchema(); err != nil {
+		t.Fatal(err)
+	}
+	for table, columns := range map[string][]string{
+		"users":         {"totp_secret", "totp_failures", "oidc_issuer", "oidc_subject", "fever_api_key", "newsletter_token"},
+		"feeds":         {"source_type", "error_count", "next_fetch_at", "hub_url", "retention_days", "retention_max_posts"},
+		"posts":         {"guid", "metadata", "content_text", "full_content", "first_seen_at"},
+		"subscriptions": {"folder_id", "fetch_full_content"},
+	} {
+		for _, column := range columns {
+			if !db.Migrator().HasColumn(table, column) {
+				t.Errorf("%s.%s is missing", table, column)
+			}
+		}
+	}
+
+	// Existing rows get the defaults of the new columns
+	var row struct {
+		SourceType string
+		ErrorCount int
+		Disabled   bool
+	}
+	db.Table("feeds").Select("source_type", "error_count", "disabled").Where("id = ?", feed.ID).Scan(&row)
+	if row.SourceType != "rss" || row.ErrorCount != 0 || row.Disabled {
+		t.Fatalf("feed = %+v", row)
+	}
+	var posts int64
+	db.Table("posts").Count(&posts)
+	if posts != 1 {
+		t.Fatalf("%d posts after upgrading", posts)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_posts.up.sql":   {Data: []byte("CREATE TABLE posts ();")},
+		"migrations/0002_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+		"migrations/0010_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"migrations/0010_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+		"migrations/0001_init.down.sql":  {Data: []byte("SELECT 2;")},
+	}
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ordered by version, not by name
+	if len(migrations) != 3 || migrations[0].Name != "init" || migrations[1].Version != 2 || migrations[2].Version != 10 {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	if migrations[1].up != "CREATE TABLE posts ();" || migrations[1].down != "DROP TABLE posts;" {
+		t.Fatalf("posts migration = %+v", migrations[1])
+	}
+}
+
+func TestLoadMigrationsRejectsBrokenSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{"missing down", fstest.MapFS{
+			"migrations/0001_init.up.sql": {Data: []byte("SELECT 1;")},
+		}, "needs both"},
+		{"two names", fstest.MapFS{
+			"migrations/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+			"migrations/0001_start.down.sql": {Data: []byte("SELECT 1;")},
+		}, "two names"},
+		{"bad name", fstest.MapFS{
+			"migrations/init.sql": {Data: []byte("SELECT 1;")},
+		}, "name must be"},
+		{"no directory", fstest.MapFS{}, "migrations"},
+	}
+	for _, tt := range tests {
+		if _, err := loadMigrations(tt.files); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("migration %d_%s breaks the version sequence", migration.Version, migration.Name)
+		}
+		if !strings.HasPrefix(migration.up, "-- ") {
+			t.Errorf("migration %d_%s does not say what it does", migration.Version, migration.Name)
+		}
+	}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS "(\w+)"`)
+	dropTablePattern   = regexp.MustCompile(`(?i)DROP TABLE IF EXISTS "(\w+)"`)
+)
+
+func tableNames(pattern *regexp.Regexp, sql string) []string {
+	var names []string
+	for _, m := range pattern.FindAllStringSubmatch(sql, -1) {
+		names = append(names, m[1])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Each down file drops exactly the tables its up file creates, so reverting
+// one migration leaves the tables of the others alone
+func TestEmbeddedMigrationsDropOwnTables(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range migrations {
+		created := tableNames(createTablePattern, migration.up)
+		dropped := tableNames(dropTablePattern, migration.down)
+		if !reflect.DeepEqual(created, dropped) {
+			t.Errorf("migration %d_%s creates %v but drops %v", migration.Version, migration.Name, created, dropped)
+		}
+	}
+}
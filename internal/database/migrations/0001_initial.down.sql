@@ -0,0 +1,18 @@
+-- Drops every table and all data.
+DROP TABLE IF EXISTS "post_tombstones";
+DROP TABLE IF EXISTS "fetch_logs";
+DROP TABLE IF EXISTS "extraction_rules";
+DROP TABLE IF EXISTS "web_sub_subscriptions";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "post_states";
+DROP TABLE IF EXISTS "digest_items";
+DROP TABLE IF EXISTS "digests";
+DROP TABLE IF EXISTS "digest_settings";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhooks";
+DROP TABLE IF EXISTS "folders";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "subscriptions";
+DROP TABLE IF EXISTS "posts";
+DROP TABLE IF EXISTS "feeds";
+DROP TABLE IF EXISTS "users";
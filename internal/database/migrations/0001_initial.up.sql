@@ -0,0 +1,275 @@
+-- Schema as created by AutoMigrate before versioned migrations. Every
+-- statement is conditional, so on existing installs this only records the
+-- version. The tables of the first release are created without the columns
+-- added since, so those are added to them before any index uses them.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "username" text NOT NULL,
+    "email" text NOT NULL,
+    "password" text,
+    "totp_secret" text,
+    "totp_enabled" boolean,
+    "totp_last_step" bigint,
+    "o_id_c_issuer" text,
+    "o_id_c_subject" text,
+    "fever_api_key" text,
+    "newsletter_token" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled" boolean;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "o_id_c_issuer" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "o_id_c_subject" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "fever_api_key" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "newsletter_token" text;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_newsletter_token" ON "users" ("newsletter_token");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_fever_api_key" ON "users" ("fever_api_key");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_oidc_identity" ON "users" ("o_id_c_issuer","o_id_c_subject");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "feeds" (
+    "id" bigserial,
+    "title" text,
+    "url" text NOT NULL,
+    "source_type" text NOT NULL DEFAULT 'rss',
+    "source_config" text,
+    "source_headers" text,
+    "error_count" bigint NOT NULL DEFAULT 0,
+    "last_error" text,
+    "next_fetch_at" timestamptz,
+    "hub_url" text,
+    "topic_url" text,
+    "disabled" boolean NOT NULL DEFAULT false,
+    "fetch_full_content" boolean NOT NULL DEFAULT false,
+    "retention_days" bigint,
+    "retention_max_posts" bigint,
+    "created_at" timestamptz,
+    "last_fetched" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "source_type" text NOT NULL DEFAULT 'rss';
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "source_config" text;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "source_headers" text;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "error_count" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "last_error" text;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "next_fetch_at" timestamptz;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "hub_url" text;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "topic_url" text;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "disabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "fetch_full_content" boolean NOT NULL DEFAULT false;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "retention_days" bigint;
+ALTER TABLE "feeds" ADD COLUMN IF NOT EXISTS "retention_max_posts" bigint;
+CREATE INDEX IF NOT EXISTS "idx_feeds_next_fetch_at" ON "feeds" ("next_fetch_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_feeds_url" ON "feeds" ("url");
+
+CREATE TABLE IF NOT EXISTS "posts" (
+    "id" bigserial,
+    "title" text,
+    "link" text NOT NULL,
+    "guid" text,
+    "author" text,
+    "metadata" text,
+    "content" text,
+    "content_text" text,
+    "full_content" text,
+    "full_content_fetched_at" timestamptz,
+    "published" timestamptz,
+    "first_seen_at" timestamptz,
+    "feed_id" bigint,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "guid" text;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "author" text;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "metadata" text;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "content_text" text;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "full_content" text;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "full_content_fetched_at" timestamptz;
+ALTER TABLE "posts" ADD COLUMN IF NOT EXISTS "first_seen_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_posts_guid" ON "posts" ("guid");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_posts_link" ON "posts" ("link");
+
+CREATE TABLE IF NOT EXISTS "subscriptions" (
+    "id" bigserial,
+    "user_id" bigint,
+    "feed_id" bigint,
+    "folder_id" bigint,
+    "fetch_full_content" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "subscriptions" ADD COLUMN IF NOT EXISTS "folder_id" bigint;
+ALTER TABLE "subscriptions" ADD COLUMN IF NOT EXISTS "fetch_full_content" boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS "idx_subscriptions_folder_id" ON "subscriptions" ("folder_id");
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "folders" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_folders_user_name" ON "folders" ("user_id","name");
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "feed_ids" text,
+    "folder_ids" text,
+    "keywords" text,
+    "active" boolean NOT NULL DEFAULT true,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" bigserial,
+    "webhook_id" bigint NOT NULL,
+    "event" text NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "last_attempt_at" timestamptz,
+    "response_code" bigint,
+    "error" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries" ("webhook_id");
+
+CREATE TABLE IF NOT EXISTS "digest_settings" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "enabled" boolean,
+    "frequency" text NOT NULL DEFAULT 'daily',
+    "hour" bigint,
+    "weekday" bigint,
+    "timezone" text NOT NULL DEFAULT 'UTC',
+    "feed_ids" text,
+    "folder_ids" text,
+    "last_sent_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_digest_settings_user_id" ON "digest_settings" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "digests" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "sent_at" timestamptz,
+    "post_count" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_digests_user_id" ON "digests" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "digest_items" (
+    "id" bigserial,
+    "digest_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "post_id" bigint NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_digest_items_user_post" ON "digest_items" ("user_id","post_id");
+CREATE INDEX IF NOT EXISTS "idx_digest_items_digest_id" ON "digest_items" ("digest_id");
+
+CREATE TABLE IF NOT EXISTS "post_states" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "post_id" bigint NOT NULL,
+    "read" boolean NOT NULL DEFAULT false,
+    "starred" boolean NOT NULL DEFAULT false,
+    "read_at" timestamptz,
+    "starred_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_post_states_post_id" ON "post_states" ("post_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_post_states_user_post" ON "post_states" ("user_id","post_id");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "description" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "last_used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_token_hash" ON "api_keys" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "web_sub_subscriptions" (
+    "id" bigserial,
+    "feed_id" bigint NOT NULL,
+    "hub_url" text NOT NULL,
+    "topic" text NOT NULL,
+    "callback_token" text NOT NULL,
+    "secret" text NOT NULL,
+    "state" text NOT NULL,
+    "lease_seconds" bigint,
+    "expires_at" timestamptz,
+    "last_push_at" timestamptz,
+    "last_error" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_web_sub_subscriptions_state" ON "web_sub_subscriptions" ("state");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_web_sub_subscriptions_callback_token" ON "web_sub_subscriptions" ("callback_token");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_web_sub_subscriptions_feed_id" ON "web_sub_subscriptions" ("feed_id");
+
+CREATE TABLE IF NOT EXISTS "extraction_rules" (
+    "id" bigserial,
+    "host" text NOT NULL,
+    "content_selector" text NOT NULL,
+    "remove_selectors" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_extraction_rules_host" ON "extraction_rules" ("host");
+
+CREATE TABLE IF NOT EXISTS "fetch_logs" (
+    "id" bigserial,
+    "feed_id" bigint NOT NULL,
+    "started_at" timestamptz,
+    "duration_ms" bigint,
+    "status_code" bigint,
+    "bytes" bigint,
+    "item_count" bigint,
+    "new_count" bigint,
+    "updated_count" bigint,
+    "error" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_fetch_logs_feed_started" ON "fetch_logs" ("feed_id","started_at");
+
+CREATE TABLE IF NOT EXISTS "post_tombstones" (
+    "id" bigserial,
+    "feed_id" bigint NOT NULL,
+    "guid" text,
+    "link" text,
+    "pruned_at" timestamptz,
+    "last_seen_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_post_tombstones_last_seen_at" ON "post_tombstones" ("last_seen_at");
+CREATE INDEX IF NOT EXISTS "idx_post_tombstones_feed_link" ON "post_tombstones" ("feed_id","link");
+CREATE INDEX IF NOT EXISTS "idx_post_tombstones_feed_guid" ON "post_tombstones" ("feed_id","guid");
@@ -0,0 +1,14 @@
+-- Limits wrong second factor codes per pending login and per user.
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_failures" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_failed_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "login_challenges" (
+    "id" text,
+    "user_id" bigint NOT NULL,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_login_challenges_user_id" ON "login_challenges" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_login_challenges_expires_at" ON "login_challenges" ("expires_at");
@@ -0,0 +1,10 @@
+-- Single-use tickets that authenticate the post stream for EventSource.
+
+CREATE TABLE IF NOT EXISTS "stream_tickets" (
+    "token_hash" text,
+    "user_id" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("token_hash")
+);
+CREATE INDEX IF NOT EXISTS "idx_stream_tickets_user_id" ON "stream_tickets" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_stream_tickets_expires_at" ON "stream_tickets" ("expires_at");
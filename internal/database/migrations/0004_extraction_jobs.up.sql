@@ -0,0 +1,14 @@
+-- Full-article extraction runs in the background from this queue instead
+-- of while a feed is stored.
+
+CREATE TABLE IF NOT EXISTS "extraction_jobs" (
+    "id" bigserial,
+    "post_id" bigint NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "error" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_extraction_jobs_post_id" ON "extraction_jobs" ("post_id");
+CREATE INDEX IF NOT EXISTS "idx_extraction_jobs_next_attempt_at" ON "extraction_jobs" ("next_attempt_at");
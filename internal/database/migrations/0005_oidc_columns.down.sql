@@ -0,0 +1,2 @@
+ALTER TABLE "users" RENAME COLUMN "oidc_issuer" TO "o_id_c_issuer";
+ALTER TABLE "users" RENAME COLUMN "oidc_subject" TO "o_id_c_subject";
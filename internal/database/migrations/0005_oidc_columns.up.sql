@@ -0,0 +1,5 @@
+-- The first release let GORM name the single sign-on columns
+-- o_id_c_issuer and o_id_c_subject; name them as the queries do.
+
+ALTER TABLE "users" RENAME COLUMN "o_id_c_issuer" TO "oidc_issuer";
+ALTER TABLE "users" RENAME COLUMN "o_id_c_subject" TO "oidc_subject";
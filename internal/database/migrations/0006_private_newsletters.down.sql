@@ -0,0 +1,2 @@
+-- The removed subscriptions are not restored.
+SELECT 1;
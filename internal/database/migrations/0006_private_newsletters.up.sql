@@ -0,0 +1,8 @@
+-- Newsletter feeds hold one user's mail. Drop subscriptions that other
+-- users made to them before subscribing checked the recipient.
+
+DELETE FROM "subscriptions" AS s
+    USING "feeds" AS f
+    WHERE s."feed_id" = f."id"
+        AND f."source_type" = 'newsletter'
+        AND f."url" NOT LIKE '%?recipient=' || s."user_id";
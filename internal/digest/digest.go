@@ -0,0 +1,238 @@
+// Package digest assembles and sends the daily and weekly email digests.
+package digest
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/mailer"
+	"blogAggregator/internal/models"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+
+	// maxPosts caps a single digest; anything beyond goes in the next one
+	maxPosts = 100
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/digest.html.tmpl"))
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/digest.txt.tmpl"))
+)
+
+// FeedGroup is one feed's posts within a digest
+type FeedGroup struct {
+	Feed  models.Feed
+	Posts []models.Post
+}
+
+type templateData struct {
+	User      models.User
+	Frequency string
+	Posts     []models.Post
+	Groups    []FeedGroup
+}
+
+// LastOccurrence returns the most recent scheduled send time at or before now
+func LastOccurrence(setting models.DigestSetting, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(setting.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := now.In(loc)
+	occurrence := time.Date(local.Year(), local.Month(), local.Day(), setting.Hour, 0, 0, 0, loc)
+	if occurrence.After(local) {
+		occurrence = occurrence.AddDate(0, 0, -1)
+	}
+	if setting.Frequency == Weekly {
+		back := (int(occurrence.Weekday()) - setting.Weekday + 7) % 7
+		occurrence = occurrence.AddDate(0, 0, -back)
+	}
+	return occurrence, nil
+}
+
+// SendDue sends every enabled digest whose scheduled time has passed
+func SendDue(m mailer.Mailer, now time.Time) {
+	var settings []models.DigestSetting
+	if err := database.DB.Where("enabled = ?", true).Find(&settings).Error; err != nil {
+		log.Println("digest: could not load settings:", err)
+		return
+	}
+	for _, setting := range settings {
+		occurrence, err := LastOccurrence(setting, now)
+		if err != nil {
+			log.Printf("digest: user %d: %v", setting.UserID, err)
+			continue
+		}
+		// Settings saved after the last slot wait for the next one
+		if occurrence.Before(setting.CreatedAt) {
+			continue
+		}
+		if setting.LastSentAt != nil && !setting.LastSentAt.Before(occurrence) {
+			continue
+		}
+		if err := send(m, setting, occurrence, now); err != nil {
+			log.Printf("digest: user %d: %v", setting.UserID, err)
+		}
+	}
+}
+
+// send claims the slot by bumping last_sent_at, so only one replica sends
+// it, then assembles and mails the digest.
+func send(m mailer.Mailer, setting models.DigestSetting, occurrence, now time.Time) error {
+	claim := database.DB.Model(&models.DigestSetting{}).
+		Where("id = ? AND (last_sent_at IS NULL OR last_sent_at < ?)", setting.ID, occurrence).
+		Update("last_sent_at", now)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, setting.UserID).Error; err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	posts, err := CollectPosts(setting, now)
+	if err != nil || len(posts) == 0 {
+		return err
+	}
+
+	msg, err := Render(user, setting, posts)
+	if err != nil {
+		return err
+	}
+	if err := m.Send(*msg); err != nil {
+		// Release the slot so the next run retries
+		database.DB.Model(&models.DigestSetting{}).Where("id = ?", setting.ID).Update("last_sent_at", setting.LastSentAt)
+		return fmt.Errorf("sending failed: %w", err)
+	}
+	return Record(user.ID, posts, now)
+}
+
+// CollectPosts returns posts from the selected feeds that have not been in
+// an earlier digest. The lookback spans twice the digest period so posts
+// fetched late, with an older published date, are still picked up.
+func CollectPosts(setting models.DigestSetting, now time.Time) ([]models.Post, error) {
+	feedIDs, err := digestFeedIDs(setting)
+	if err != nil || len(feedIDs) == 0 {
+		return nil, err
+	}
+
+	period := 24 * time.Hour
+	if setting.Frequency == Weekly {
+		period = 7 * 24 * time.Hour
+	}
+	since := now.Add(-2 * period)
+	if setting.LastSentAt != nil && setting.LastSentAt.Add(-period).Before(since) {
+		since = setting.LastSentAt.Add(-period)
+	}
+
+	var posts []models.Post
+	err = database.DB.
+		Where("feed_id IN ? AND published >= ?", feedIDs, since).
+		Where("id NOT IN (?)", database.DB.Model(&models.DigestItem{}).Select("post_id").Where("user_id = ?", setting.UserID)).
+		Order("published desc").
+		Limit(maxPosts).
+		Find(&posts).Error
+	return posts, err
+}
+
+// digestFeedIDs resolves the feeds a digest covers: the explicit feeds and
+// folders, or every subscription when neither is set.
+func digestFeedIDs(setting models.DigestSetting) ([]uint, error) {
+	query := database.DB.Model(&models.Subscription{}).Where("user_id = ?", setting.UserID)
+	if len(setting.FeedIDs) > 0 || len(setting.FolderIDs) > 0 {
+		cond := database.DB.Where("1 = 0")
+		if len(setting.FeedIDs) > 0 {
+			cond = cond.Or("feed_id IN ?", setting.FeedIDs)
+		}
+		if len(setting.FolderIDs) > 0 {
+			cond = cond.Or("folder_id IN ?", setting.FolderIDs)
+		}
+		query = query.Where(cond)
+	}
+	var ids []uint
+	err := query.Pluck("feed_id", &ids).Error
+	return ids, err
+}
+
+// Render builds the multipart email for a digest
+func Render(user models.User, setting models.DigestSetting, posts []models.Post) (*mailer.Message, error) {
+	groups, err := groupByFeed(posts)
+	if err != nil {
+		return nil, err
+	}
+	data := templateData{User: user, Frequency: setting.Frequency, Posts: posts, Groups: groups}
+
+	var html, text bytes.Buffer
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return nil, err
+	}
+
+	return &mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Your %s digest: %d new posts", setting.Frequency, len(posts)),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func groupByFeed(posts []models.Post) ([]FeedGroup, error) {
+	byFeed := map[uint]*FeedGroup{}
+	var feedIDs []uint
+	for _, p := range posts {
+		if _, ok := byFeed[p.FeedId]; !ok {
+			byFeed[p.FeedId] = &FeedGroup{}
+			feedIDs = append(feedIDs, p.FeedId)
+		}
+		byFeed[p.FeedId].Posts = append(byFeed[p.FeedId].Posts, p)
+	}
+
+	var feeds []models.Feed
+	if err := database.DB.Where("id IN ?", feedIDs).Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	groups := make([]FeedGroup, 0, len(feeds))
+	for _, f := range feeds {
+		g := byFeed[f.ID]
+		g.Feed = f
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Feed.Title < groups[j].Feed.Title })
+	return groups, nil
+}
+
+// Record stores which posts a digest included
+func Record(userID uint, posts []models.Post, sentAt time.Time) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		d := models.Digest{UserID: userID, SentAt: sentAt, PostCount: len(posts)}
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+		items := make([]models.DigestItem, len(posts))
+		for i, p := range posts {
+			items[i] = models.DigestItem{DigestID: d.ID, UserID: userID, PostID: p.ID}
+		}
+		return tx.Create(&items).Error
+	})
+}
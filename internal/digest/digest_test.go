@@ -0,0 +1,196 @@
+package digest
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/mailer"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastOccurrence(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday
+	now := time.Date(2024, 3, 13, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		setting models.DigestSetting
+		want    time.Time
+	}{
+		{"daily, earlier today", models.DigestSetting{Frequency: Daily, Hour: 8, Timezone: "UTC"},
+			time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC)},
+		{"daily, right now", models.DigestSetting{Frequency: Daily, Hour: 10, Timezone: "UTC"},
+			time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)},
+		{"daily, later today", models.DigestSetting{Frequency: Daily, Hour: 11, Timezone: "UTC"},
+			time.Date(2024, 3, 12, 11, 0, 0, 0, time.UTC)},
+		{"daily, in the user's zone", models.DigestSetting{Frequency: Daily, Hour: 11, Timezone: "Europe/Berlin"},
+			time.Date(2024, 3, 13, 11, 0, 0, 0, berlin)},
+		{"weekly, today", models.DigestSetting{Frequency: Weekly, Hour: 9, Weekday: 3, Timezone: "UTC"},
+			time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC)},
+		{"weekly, later today", models.DigestSetting{Frequency: Weekly, Hour: 12, Weekday: 3, Timezone: "UTC"},
+			time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)},
+		{"weekly, Sunday", models.DigestSetting{Frequency: Weekly, Hour: 18, Weekday: 0, Timezone: "UTC"},
+			time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := LastOccurrence(tt.setting, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s: LastOccurrence = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := LastOccurrence(models.DigestSetting{Frequency: Daily, Timezone: "Nowhere/City"}, now); err == nil {
+		t.Error("unknown timezone accepted")
+	}
+}
+
+func TestLastOccurrenceAcrossDST(t *testing.T) {
+	// Berlin moves to summer time on 31 March 2024; 8:00 local is 7:00 UTC
+	// before and 6:00 UTC after
+	setting := models.DigestSetting{Frequency: Daily, Hour: 8, Timezone: "Europe/Berlin"}
+	got, err := LastOccurrence(setting, time.Date(2024, 3, 31, 6, 30, 0, 0, time.UTC))
+	if err != nil || !got.Equal(time.Date(2024, 3, 31, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("LastOccurrence = %v, %v", got.UTC(), err)
+	}
+}
+
+// outbox collects the messages sent, failing while err is set
+type outbox struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (o *outbox) Send(msg mailer.Message) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// seedDigest creates a subscribed user with a daily 8:00 UTC digest saved
+// before the slots the tests use
+func seedDigest(t *testing.T) (models.DigestSetting, models.Feed) {
+	t.Helper()
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	feed := models.Feed{Title: "Feed", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+	setting := models.DigestSetting{
+		UserID:    user.ID,
+		Enabled:   true,
+		Frequency: Daily,
+		Hour:      8,
+		Timezone:  "UTC",
+		CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := database.DB.Create(&setting).Error; err != nil {
+		t.Fatal(err)
+	}
+	return setting, feed
+}
+
+func addPost(feed models.Feed, title string, published time.Time) {
+	database.DB.Create(&models.Post{Title: title, Link: feed.URL + "/" + title, Published: published, FeedId: feed.ID})
+}
+
+func TestSendDueSendsEachSlotOnce(t *testing.T) {
+	testdb.Open(t)
+	_, feed := seedDigest(t)
+	addPost(feed, "first", time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC))
+	box := &outbox{}
+
+	now := time.Date(2024, 3, 13, 8, 5, 0, 0, time.UTC)
+	SendDue(box, now)
+	if len(box.sent) != 1 || !strings.Contains(box.sent[0].Text, "first") || box.sent[0].To != "alice@example.com" {
+		t.Fatalf("sent %+v", box.sent)
+	}
+	// Later runs in the same slot send nothing
+	SendDue(box, now.Add(time.Hour))
+	if len(box.sent) != 1 {
+		t.Fatalf("slot sent %d times", len(box.sent))
+	}
+
+	// The next day's digest has only the new post
+	addPost(feed, "second", time.Date(2024, 3, 13, 20, 0, 0, 0, time.UTC))
+	SendDue(box, now.Add(24*time.Hour))
+	if len(box.sent) != 2 || strings.Contains(box.sent[1].Text, "first") || !strings.Contains(box.sent[1].Text, "second") {
+		t.Fatalf("second digest: %+v", box.sent)
+	}
+	var items int64
+	database.DB.Model(&models.DigestItem{}).Count(&items)
+	if items != 2 {
+		t.Fatalf("%d digest items", items)
+	}
+}
+
+func TestSendClaimsSlot(t *testing.T) {
+	testdb.Open(t)
+	setting, feed := seedDigest(t)
+	addPost(feed, "first", time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC))
+	now := time.Date(2024, 3, 13, 8, 5, 0, 0, time.UTC)
+	occurrence, err := LastOccurrence(setting, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two replicas that both loaded the setting before either sent
+	box := &outbox{}
+	for i := 0; i < 2; i++ {
+		if err := send(box, setting, occurrence, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(box.sent) != 1 {
+		t.Fatalf("sent %d digests for one slot", len(box.sent))
+	}
+	database.DB.First(&setting, setting.ID)
+	if setting.LastSentAt == nil || !setting.LastSentAt.Equal(now) {
+		t.Fatalf("last_sent_at = %v", setting.LastSentAt)
+	}
+}
+
+func TestSendReleasesSlotOnFailure(t *testing.T) {
+	testdb.Open(t)
+	setting, feed := seedDigest(t)
+	addPost(feed, "first", time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC))
+	now := time.Date(2024, 3, 13, 8, 5, 0, 0, time.UTC)
+
+	box := &outbox{err: errors.New("smtp down")}
+	SendDue(box, now)
+	database.DB.First(&setting, setting.ID)
+	if setting.LastSentAt != nil {
+		t.Fatalf("failed send kept the slot: %v", setting.LastSentAt)
+	}
+
+	box.err = nil
+	SendDue(box, now.Add(10*time.Minute))
+	if len(box.sent) != 1 {
+		t.Fatalf("retry sent %d digests", len(box.sent))
+	}
+}
+
+func TestSendDueWaitsForFirstSlot(t *testing.T) {
+	testdb.Open(t)
+	setting, feed := seedDigest(t)
+	addPost(feed, "first", time.Date(2024, 3, 13, 7, 0, 0, 0, time.UTC))
+	// Saved after today's 8:00 slot
+	database.DB.Model(&setting).Update("created_at", time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC))
+
+	box := &outbox{}
+	SendDue(box, time.Date(2024, 3, 13, 9, 30, 0, 0, time.UTC))
+	if len(box.sent) != 0 {
+		t.Fatal("digest sent for a slot before the setting existed")
+	}
+	SendDue(box, time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC))
+	if len(box.sent) != 1 {
+		t.Fatalf("sent %d digests in the first slot", len(box.sent))
+	}
+}
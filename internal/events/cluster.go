@@ -0,0 +1,69 @@
+package events
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// notifyChannel is the PostgreSQL channel new post ids are announced on
+const notifyChannel = "new_posts"
+
+// ClusterPosts carries posts stored by any replica, for consumers such as the
+// SSE stream that must see posts no matter which instance ingested them.
+var ClusterPosts = NewBus("cluster-posts")
+
+// StartClusterFanout announces this instance's new posts with NOTIFY and
+// republishes every announced post (including our own) on ClusterPosts.
+func StartClusterFanout(dsn string) {
+	sub := NewPosts.Subscribe(1024)
+	go func() {
+		for post := range sub.C {
+			if err := database.DB.Exec("SELECT pg_notify(?, ?)", notifyChannel, strconv.FormatUint(uint64(post.ID), 10)).Error; err != nil {
+				log.Printf("events: notify for post %d failed: %v", post.ID, err)
+			}
+		}
+	}()
+
+	for {
+		if err := listen(dsn); err != nil {
+			log.Printf("events: listener stopped: %v, reconnecting", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// listen holds a dedicated connection for LISTEN until it fails
+func listen(dsn string) error {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(n.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+		var post models.Post
+		if err := database.DB.First(&post, id).Error; err != nil {
+			log.Printf("events: could not load announced post %d: %v", id, err)
+			continue
+		}
+		ClusterPosts.Publish(post)
+	}
+}
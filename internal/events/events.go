@@ -0,0 +1,65 @@
+// Package events fans out in-process notifications about newly stored posts
+// to live consumers such as the SSE stream, without blocking the feed
+// fetcher. Consumers that must not miss a post, like webhooks, are fed from
+// the database instead.
+package events
+
+import (
+	"blogAggregator/internal/models"
+	"log"
+	"sync"
+)
+
+// Bus delivers published posts to every current subscriber
+type Bus struct {
+	name string
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives posts on C until Close is called
+type Subscription struct {
+	C   <-chan models.Post
+	c   chan models.Post
+	bus *Bus
+}
+
+// NewPosts carries posts inserted by this instance's fetchers
+var NewPosts = NewBus("new-posts")
+
+func NewBus(name string) *Bus {
+	return &Bus{name: name, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscriber with the given channel buffer
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	c := make(chan models.Post, buffer)
+	sub := &Subscription{C: c, c: c, bus: b}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Close unregisters the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
+
+// Publish never blocks: a subscriber whose buffer is full misses the post
+func (b *Bus) Publish(post models.Post) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		select {
+		case sub.c <- post:
+		default:
+			log.Printf("%s: subscriber buffer full, dropping post %d", b.name, post.ID)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package fever implements the Fever API (version 3) for lightweight
+// clients. Every call is a POST to /fever/?api with the api_key form value;
+// the query string selects what to return and which mark action to apply.
+package fever
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	apiVersion = 3
+	// maxItems is the page size Fever clients expect for items
+	maxItems = 50
+	// faviconID is the placeholder icon every feed points at, since feed
+	// icons are not stored
+	faviconID   = 1
+	blankGIF    = "image/gif;base64,R0lGODlhAQABAIAAAObm5gAAACH5BAEAAAAALAAAAAABAAEAAAICRAEAOw=="
+	allGroupsID = 0
+)
+
+// APIKey computes the Fever key for a username and Fever password
+func APIKey(username, password string) string {
+	sum := md5.Sum([]byte(username + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+type group struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+type feedsGroup struct {
+	GroupID uint   `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feed struct {
+	ID                uint   `json:"id"`
+	FaviconID         uint   `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type item struct {
+	ID            uint   `json:"id"`
+	FeedID        uint   `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// Handler answers every Fever call. Authentication failures are reported
+// with auth=0 and status 200, as the protocol specifies.
+func Handler(c *gin.Context) {
+	resp := gin.H{"api_version": apiVersion, "auth": 0}
+
+	key := strings.ToLower(c.Request.FormValue("api_key"))
+	var user models.User
+	if key == "" || database.DB.Where("fever_api_key = ?", key).First(&user).Error != nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	resp["auth"] = 1
+
+	var lastFetched sql.NullTime
+	database.DB.Model(&models.Feed{}).Select("MAX(last_fetched)").
+		Where("id IN (?)", poststate.SubscribedFeedIDs(user.ID)).Row().Scan(&lastFetched)
+	resp["last_refreshed_on_time"] = int64(0)
+	if lastFetched.Valid {
+		resp["last_refreshed_on_time"] = lastFetched.Time.Unix()
+	}
+
+	// Mark actions run first so the same request returns updated state
+	if err := mark(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := c.Request.URL.Query()
+	var err error
+	if query.Has("groups") || query.Has("feeds") {
+		var subs []models.Subscription
+		if err = database.DB.Where("user_id = ?", user.ID).Find(&subs).Error; err == nil {
+			resp["feeds_groups"] = feedsGroups(subs)
+		}
+	}
+	if err == nil && query.Has("groups") {
+		resp["groups"], err = groups(user.ID)
+	}
+	if err == nil && query.Has("feeds") {
+		resp["feeds"], err = feeds(user.ID)
+	}
+	if query.Has("favicons") {
+		resp["favicons"] = []gin.H{{"id": faviconID, "data": blankGIF}}
+	}
+	if err == nil && query.Has("items") {
+		err = items(c, user.ID, resp)
+	}
+	if query.Has("links") {
+		resp["links"] = []gin.H{}
+	}
+	if err == nil && query.Has("unread_item_ids") {
+		var ids []uint
+		err = database.DB.Model(&models.Post{}).
+			Where("feed_id IN (?)", poststate.SubscribedFeedIDs(user.ID)).
+			Where("id NOT IN (?)", poststate.ReadPostIDs(user.ID)).
+			Order("id").Pluck("id", &ids).Error
+		resp["unread_item_ids"] = joinIDs(ids)
+	}
+	if err == nil && query.Has("saved_item_ids") {
+		var ids []uint
+		err = poststate.StarredPostIDs(user.ID).Order("post_id").Pluck("post_id", &ids).Error
+		resp["saved_item_ids"] = joinIDs(ids)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func groups(userID uint) ([]group, error) {
+	var folders []models.Folder
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	list := make([]group, len(folders))
+	for i, f := range folders {
+		list[i] = group{ID: f.ID, Title: f.Name}
+	}
+	return list, nil
+}
+
+func feedsGroups(subs []models.Subscription) []feedsGroup {
+	byFolder := map[uint][]uint{}
+	var order []uint
+	for _, s := range subs {
+		if s.FolderID == nil {
+			continue
+		}
+		if _, ok := byFolder[*s.FolderID]; !ok {
+			order = append(order, *s.FolderID)
+		}
+		byFolder[*s.FolderID] = append(byFolder[*s.FolderID], s.FeedID)
+	}
+	list := make([]feedsGroup, 0, len(order))
+	for _, id := range order {
+		list = append(list, feedsGroup{GroupID: id, FeedIDs: joinIDs(byFolder[id])})
+	}
+	return list
+}
+
+func feeds(userID uint) ([]feed, error) {
+	var rows []models.Feed
+	if err := database.DB.Where("id IN (?)", poststate.SubscribedFeedIDs(userID)).Order("title").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	list := make([]feed, len(rows))
+	for i, f := range rows {
+		list[i] = feed{ID: f.ID, FaviconID: faviconID, Title: f.Title, URL: f.URL, SiteURL: f.URL}
+		if f.LastFetched != nil {
+			list[i].LastUpdatedOnTime = f.LastFetched.Unix()
+		}
+	}
+	return list, nil
+}
+
+// items pages through posts of subscribed feeds: since_id returns the next
+// newer posts, max_id the next older ones and with_ids specific posts.
+func items(c *gin.Context, userID uint, resp gin.H) error {
+	base := database.DB.Model(&models.Post{}).Where("feed_id IN (?)", poststate.SubscribedFeedIDs(userID))
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return err
+	}
+	resp["total_items"] = total
+
+	query := base.Session(&gorm.Session{})
+	switch {
+	case c.Query("with_ids") != "":
+		query = query.Where("id IN ?", splitIDs(c.Query("with_ids"), maxItems)).Order("id")
+	case c.Query("max_id") != "":
+		maxID, _ := strconv.ParseUint(c.Query("max_id"), 10, 64)
+		query = query.Where("id < ?", maxID).Order("id desc")
+	default:
+		sinceID, _ := strconv.ParseUint(c.Query("since_id"), 10, 64)
+		query = query.Where("id > ?", sinceID).Order("id")
+	}
+
+	var posts []models.Post
+	if err := query.Limit(maxItems).Find(&posts).Error; err != nil {
+		return err
+	}
+	ids := make([]uint, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	states, err := poststate.Load(userID, ids)
+	if err != nil {
+		return err
+	}
+
+	list := make([]item, len(posts))
+	for i, p := range posts {
+		p = imageproxy.RewritePost(p)
+		list[i] = item{
+			ID:            p.ID,
+			FeedID:        p.FeedId,
+			Title:         p.Title,
+			HTML:          p.Content,
+			URL:           p.Link,
+			IsSaved:       boolInt(states[p.ID].Starred),
+			IsRead:        boolInt(states[p.ID].Read),
+			CreatedOnTime: p.Published.Unix(),
+		}
+	}
+	resp["items"] = list
+	return nil
+}
+
+// mark applies mark=item|feed|group with as=read|unread|saved|unsaved.
+// Feeds and groups are marked read up to the before timestamp; group 0
+// stands for all feeds.
+func mark(c *gin.Context, userID uint) error {
+	kind := c.Request.FormValue("mark")
+	if kind == "" {
+		return nil
+	}
+	as := c.Request.FormValue("as")
+	id, err := strconv.ParseInt(c.Request.FormValue("id"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	before := time.Now()
+	if ts, err := strconv.ParseInt(c.Request.FormValue("before"), 10, 64); err == nil && ts > 0 {
+		before = time.Unix(ts, 0)
+	}
+
+	switch kind {
+	case "item":
+		ids := []uint{uint(id)}
+		switch as {
+		case "read":
+			return poststate.SetRead(userID, ids, true)
+		case "unread":
+			return poststate.SetRead(userID, ids, false)
+		case "saved":
+			return poststate.SetStarred(userID, ids, true)
+		case "unsaved":
+			return poststate.SetStarred(userID, ids, false)
+		}
+	case "feed":
+		if as == "read" {
+			var feedIDs []uint
+			if err := poststate.SubscribedFeedIDs(userID).Where("feed_id = ?", id).Pluck("feed_id", &feedIDs).Error; err != nil {
+				return err
+			}
+			return poststate.MarkFeedsRead(userID, feedIDs, before)
+		}
+	case "group":
+		if as == "read" {
+			query := poststate.SubscribedFeedIDs(userID)
+			if id != allGroupsID {
+				query = query.Where("folder_id = ?", id)
+			}
+			var feedIDs []uint
+			if err := query.Pluck("feed_id", &feedIDs).Error; err != nil {
+				return err
+			}
+			return poststate.MarkFeedsRead(userID, feedIDs, before)
+		}
+	}
+	return nil
+}
+
+func joinIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitIDs(s string, limit int) []uint {
+	var ids []uint
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+		if len(ids) == limit {
+			break
+		}
+	}
+	return ids
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
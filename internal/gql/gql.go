@@ -0,0 +1,137 @@
+// Package gql serves the GraphQL API used by the web frontend. Requests go
+// through middleware.AuthMiddleware; resolvers batch their lookups with
+// per-request loaders, and queries are bounded by depth and complexity.
+package gql
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+const (
+	maxDepth       = 8
+	maxComplexity  = 5000
+	maxQueryLength = 16 << 10
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+var schema = graphql.MustParseSchema(schemaSDL, &Resolver{},
+	graphql.UseFieldResolvers(),
+	graphql.MaxDepth(maxDepth),
+	graphql.MaxQueryLength(maxQueryLength),
+)
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler executes a GraphQL request for the authenticated user
+func Handler(c *gin.Context) {
+	var req request
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "a JSON body with a query is required"}}})
+		return
+	}
+	if len(req.Query) > maxQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "query is too long"}}})
+		return
+	}
+	cost, err := complexity(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+	if cost > maxComplexity {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{
+			"message": "query is too complex: cost " + strconv.Itoa(cost) + " exceeds " + strconv.Itoa(maxComplexity),
+		}}})
+		return
+	}
+
+	ctx := withLoaders(c.Request.Context(), c.GetUint("User_id"))
+	c.JSON(http.StatusOK, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// complexity scores an operation: every field costs one, and the selection
+// under a paginated field counts once per requested item. Syntax errors are
+// left for the executor to report.
+func complexity(query, operationName string, variables map[string]interface{}) (int, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return 0, nil
+	}
+	var op *ast.OperationDefinition
+	if operationName != "" {
+		op = doc.Operations.ForName(operationName)
+	} else if len(doc.Operations) == 1 {
+		op = doc.Operations[0]
+	}
+	if op == nil {
+		return 0, nil
+	}
+	return selectionCost(doc, op.SelectionSet, variables, map[string]bool{}), nil
+}
+
+func selectionCost(doc *ast.QueryDocument, set ast.SelectionSet, variables map[string]interface{}, visiting map[string]bool) int {
+	cost := 0
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			children := selectionCost(doc, s.SelectionSet, variables, visiting)
+			cost += 1 + children*multiplier(s, variables)
+		case *ast.InlineFragment:
+			cost += selectionCost(doc, s.SelectionSet, variables, visiting)
+		case *ast.FragmentSpread:
+			frag := doc.Fragments.ForName(s.Name)
+			// Cycles are rejected by validation; just stop counting here
+			if frag == nil || visiting[s.Name] {
+				continue
+			}
+			visiting[s.Name] = true
+			cost += selectionCost(doc, frag.SelectionSet, variables, visiting)
+			delete(visiting, s.Name)
+		}
+	}
+	return cost
+}
+
+// multiplier is the page size a paginated field will return
+func multiplier(field *ast.Field, variables map[string]interface{}) int {
+	arg := field.Arguments.ForName("first")
+	if arg == nil {
+		switch field.Name {
+		case "feeds", "posts":
+			return defaultPageSize
+		}
+		return 1
+	}
+	n := defaultPageSize
+	switch arg.Value.Kind {
+	case ast.IntValue:
+		n, _ = strconv.Atoi(arg.Value.Raw)
+	case ast.Variable:
+		switch v := variables[arg.Value.Raw].(type) {
+		case float64:
+			n = int(v)
+		case int:
+			n = v
+		}
+	}
+	if n < 1 {
+		n = defaultPageSize
+	}
+	if n > maxPageSize {
+		n = maxPageSize
+	}
+	return n
+}
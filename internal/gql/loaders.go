@@ -0,0 +1,231 @@
+package gql
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"context"
+	"sync"
+)
+
+// loader batches lookups by key within one request. Resolvers that produce
+// a list register the keys their children will need; the first Load then
+// fetches every registered key in a single query.
+type loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	fetch   func(keys []K) (map[K]V, error)
+	cache   map[K]V
+	pending map[K]bool
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, cache: map[K]V{}, pending: map[K]bool{}}
+}
+
+// Register queues keys for the next batch
+func (l *loader[K, V]) Register(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		if _, ok := l.cache[k]; !ok {
+			l.pending[k] = true
+		}
+	}
+}
+
+// Prime caches a value the caller already has
+func (l *loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache[key] = value
+	delete(l.pending, key)
+}
+
+// Clear drops cached values, for keys changed by a mutation
+func (l *loader[K, V]) Clear(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		delete(l.cache, k)
+	}
+}
+
+// Keys returns the keys with cached values
+func (l *loader[K, V]) Keys() []K {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make([]K, 0, len(l.cache))
+	for k := range l.cache {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Load returns the value for key, fetching it together with all pending
+// keys. Missing keys yield the zero value and false.
+func (l *loader[K, V]) Load(key K) (V, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.cache[key]; ok {
+		return v, true, nil
+	}
+	l.pending[key] = true
+	keys := make([]K, 0, len(l.pending))
+	for k := range l.pending {
+		keys = append(keys, k)
+	}
+	values, err := l.fetch(keys)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	for k, v := range values {
+		l.cache[k] = v
+	}
+	l.pending = map[K]bool{}
+	v, ok := l.cache[key]
+	return v, ok, nil
+}
+
+// feedPage names the first page of a feed's posts
+type feedPage struct {
+	feedID uint
+	size   int
+}
+
+// loaders are the per-request batch loaders. Per-feed lookups are batched
+// over every feed loaded in the request, however the feeds were reached.
+type loaders struct {
+	userID        uint
+	feeds         *loader[uint, models.Feed]
+	folders       *loader[uint, models.Folder]
+	states        *loader[uint, models.PostState]
+	unreadCounts  *loader[uint, int32]
+	subscriptions *loader[uint, models.Subscription]
+	// feedPosts holds size+1 posts per page, the extra one telling whether
+	// there is a next page
+	feedPosts *loader[feedPage, []models.Post]
+}
+
+func newLoaders(userID uint) *loaders {
+	return &loaders{
+		userID: userID,
+		feeds: newLoader(func(ids []uint) (map[uint]models.Feed, error) {
+			var feeds []models.Feed
+			if err := database.DB.Where("id IN ?", ids).Find(&feeds).Error; err != nil {
+				return nil, err
+			}
+			byID := make(map[uint]models.Feed, len(feeds))
+			for _, f := range feeds {
+				byID[f.ID] = f
+			}
+			return byID, nil
+		}),
+		folders: newLoader(func(ids []uint) (map[uint]models.Folder, error) {
+			var folders []models.Folder
+			if err := database.DB.Where("id IN ? AND user_id = ?", ids, userID).Find(&folders).Error; err != nil {
+				return nil, err
+			}
+			byID := make(map[uint]models.Folder, len(folders))
+			for _, f := range folders {
+				byID[f.ID] = f
+			}
+			return byID, nil
+		}),
+		states: newLoader(func(ids []uint) (map[uint]models.PostState, error) {
+			states, err := poststate.Load(userID, ids)
+			if err != nil {
+				return nil, err
+			}
+			// Cache misses too, so posts without state are not fetched again
+			for _, id := range ids {
+				if _, ok := states[id]; !ok {
+					states[id] = models.PostState{}
+				}
+			}
+			return states, nil
+		}),
+		unreadCounts: newLoader(func(feedIDs []uint) (map[uint]int32, error) {
+			var rows []struct {
+				FeedID uint
+				Count  int32
+			}
+			err := database.DB.Model(&models.Post{}).Select("feed_id, COUNT(*) AS count").
+				Where("feed_id IN ?", feedIDs).
+				Where("id NOT IN (?)", poststate.ReadPostIDs(userID)).
+				Group("feed_id").Scan(&rows).Error
+			if err != nil {
+				return nil, err
+			}
+			counts := make(map[uint]int32, len(feedIDs))
+			for _, id := range feedIDs {
+				counts[id] = 0
+			}
+			for _, r := range rows {
+				counts[r.FeedID] = r.Count
+			}
+			return counts, nil
+		}),
+		// subscriptions are keyed by feed id
+		subscriptions: newLoader(func(feedIDs []uint) (map[uint]models.Subscription, error) {
+			var subs []models.Subscription
+			if err := database.DB.Where("user_id = ? AND feed_id IN ?", userID, feedIDs).Find(&subs).Error; err != nil {
+				return nil, err
+			}
+			byFeed := make(map[uint]models.Subscription, len(feedIDs))
+			for _, id := range feedIDs {
+				byFeed[id] = models.Subscription{}
+			}
+			for _, s := range subs {
+				byFeed[s.FeedID] = s
+			}
+			return byFeed, nil
+		}),
+		feedPosts: newLoader(fetchFeedPages),
+	}
+}
+
+// fetchFeedPages loads the newest posts of each feed, one query per page
+// size
+func fetchFeedPages(pages []feedPage) (map[feedPage][]models.Post, error) {
+	feedsBySize := map[int][]uint{}
+	for _, p := range pages {
+		feedsBySize[p.size] = append(feedsBySize[p.size], p.feedID)
+	}
+	result := make(map[feedPage][]models.Post, len(pages))
+	for _, p := range pages {
+		result[p] = nil
+	}
+	for size, feedIDs := range feedsBySize {
+		ranked := database.DB.Model(&models.Post{}).
+			Select("*, row_number() OVER (PARTITION BY feed_id ORDER BY published DESC, id DESC) AS page_rank").
+			Where("feed_id IN ?", feedIDs)
+		var posts []models.Post
+		err := database.DB.Table("(?) AS ranked", ranked).
+			Where("page_rank <= ?", size+1).
+			Order("feed_id, page_rank").Find(&posts).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			key := feedPage{post.FeedId, size}
+			result[key] = append(result[key], post)
+		}
+	}
+	return result, nil
+}
+
+// loadedFeeds returns every feed loaded so far in the request
+func (l *loaders) loadedFeeds() []uint {
+	return l.feeds.Keys()
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, loadersKey{}, newLoaders(userID))
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
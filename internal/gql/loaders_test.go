@@ -0,0 +1,129 @@
+package gql
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestLoaderBatchesRegisteredKeys(t *testing.T) {
+	var calls int32
+	l := newLoader(func(keys []int) (map[int]int, error) {
+		atomic.AddInt32(&calls, 1)
+		values := map[int]int{}
+		for _, k := range keys {
+			values[k] = k * 10
+		}
+		return values, nil
+	})
+	l.Register(1, 2, 3)
+
+	var wg sync.WaitGroup
+	for k := 1; k <= 3; k++ {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			if v, ok, err := l.Load(k); err != nil || !ok || v != k*10 {
+				t.Errorf("Load(%d) = %d, %v, %v", k, v, ok, err)
+			}
+		}(k)
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("fetched %d times, want once", calls)
+	}
+	if keys := l.Keys(); len(keys) != 3 {
+		t.Fatalf("Keys = %v", keys)
+	}
+}
+
+// countQueries counts the statements run until the test ends
+func countQueries(t *testing.T) *int32 {
+	t.Helper()
+	var n int32
+	count := func(*gorm.DB) { atomic.AddInt32(&n, 1) }
+	database.DB.Callback().Query().After("gorm:query").Register("test:count_queries", count)
+	database.DB.Callback().Row().After("gorm:row").Register("test:count_rows", count)
+	t.Cleanup(func() {
+		database.DB.Callback().Query().Remove("test:count_queries")
+		database.DB.Callback().Row().Remove("test:count_rows")
+	})
+	return &n
+}
+
+func TestFeedFieldsAreBatched(t *testing.T) {
+	testdb.Open(t)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	const feedCount = 8
+	for i := 0; i < feedCount; i++ {
+		feed := models.Feed{Title: "Feed " + strconv.Itoa(i), URL: "https://example.com/" + strconv.Itoa(i)}
+		database.DB.Create(&feed)
+		database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+		for j := 0; j < 3; j++ {
+			database.DB.Create(&models.Post{
+				Title:     "Post",
+				Link:      "https://example.com/" + strconv.Itoa(i) + "/" + strconv.Itoa(j),
+				FeedId:    feed.ID,
+				Published: time.Now().Add(-time.Duration(j) * time.Hour),
+			})
+		}
+	}
+
+	feedFields := `subscribed unreadCount posts(first: 2) { edges { node { title } } pageInfo { hasNextPage } }`
+	for _, query := range []string{
+		`{ feeds(first: 50) { edges { node { ` + feedFields + ` } } } }`,
+		`{ subscriptions { feed { ` + feedFields + ` } } }`,
+	} {
+		queries := countQueries(t)
+		result := schema.Exec(withLoaders(context.Background(), user.ID), query, "", nil)
+		if len(result.Errors) > 0 {
+			t.Fatalf("%s: %v", query, result.Errors)
+		}
+		// The list, then at most one batch per loader, not one per feed
+		if *queries > 5 {
+			t.Errorf("%s ran %d queries for %d feeds", query, *queries, feedCount)
+		}
+
+		var data struct {
+			Feeds struct {
+				Edges []struct{ Node feedData }
+			}
+			Subscriptions []struct{ Feed feedData }
+		}
+		json.Unmarshal(result.Data, &data)
+		feeds := make([]feedData, 0, feedCount)
+		for _, e := range data.Feeds.Edges {
+			feeds = append(feeds, e.Node)
+		}
+		for _, s := range data.Subscriptions {
+			feeds = append(feeds, s.Feed)
+		}
+		if len(feeds) != feedCount {
+			t.Fatalf("%s returned %d feeds", query, len(feeds))
+		}
+		for _, f := range feeds {
+			if !f.Subscribed || f.UnreadCount != 3 || len(f.Posts.Edges) != 2 || !f.Posts.PageInfo.HasNextPage {
+				t.Fatalf("%s returned %+v", query, f)
+			}
+		}
+	}
+}
+
+type feedData struct {
+	Subscribed  bool
+	UnreadCount int
+	Posts       struct {
+		Edges    []struct{ Node struct{ Title string } }
+		PageInfo struct{ HasNextPage bool }
+	}
+}
@@ -0,0 +1,545 @@
+package gql
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/poststate"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errNotFound = errors.New("not found")
+
+// Resolver is the root of the schema
+type Resolver struct{}
+
+func parseID(id graphql.ID) (uint, error) {
+	n, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid id %q", id)
+	}
+	return uint(n), nil
+}
+
+func toID(id uint) graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(id), 10))
+}
+
+func pageSize(first *int32) int {
+	if first == nil || *first < 1 {
+		return defaultPageSize
+	}
+	if *first > maxPageSize {
+		return maxPageSize
+	}
+	return int(*first)
+}
+
+// Cursors are opaque to clients: base64 of the keyset position
+
+func encodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+func decodeCursor(cursor string, n int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	parts := strings.Split(string(raw), ":")
+	if err != nil || len(parts) != n {
+		return nil, errors.New("invalid cursor")
+	}
+	return parts, nil
+}
+
+type pageInfo struct {
+	hasNext bool
+	end     *string
+}
+
+func (p pageInfo) HasNextPage() bool  { return p.hasNext }
+func (p pageInfo) EndCursor() *string { return p.end }
+
+// Queries
+
+func (r *Resolver) Me(ctx context.Context) (*userResolver, error) {
+	var user models.User
+	if err := database.DB.First(&user, loadersFrom(ctx).userID).Error; err != nil {
+		return nil, err
+	}
+	return &userResolver{user}, nil
+}
+
+func (r *Resolver) Feeds(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*feedConnection, error) {
+	l := loadersFrom(ctx)
+	size := pageSize(args.First)
+	query := database.DB.Where("id NOT IN (?)", newsletter.HiddenFeedIDs(l.userID)).Order("id")
+	if args.After != nil {
+		parts, err := decodeCursor(*args.After, 1)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id > ?", parts[0])
+	}
+	var feeds []models.Feed
+	if err := query.Limit(size + 1).Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	conn := &feedConnection{}
+	if len(feeds) > size {
+		feeds, conn.page.hasNext = feeds[:size], true
+	}
+	for _, f := range feeds {
+		l.feeds.Prime(f.ID, f)
+		l.subscriptions.Register(f.ID)
+		l.unreadCounts.Register(f.ID)
+		conn.edges = append(conn.edges, feedEdge{
+			cursor: encodeCursor(strconv.FormatUint(uint64(f.ID), 10)),
+			node:   &feedResolver{f},
+		})
+	}
+	if n := len(conn.edges); n > 0 {
+		conn.page.end = &conn.edges[n-1].cursor
+	}
+	return conn, nil
+}
+
+func (r *Resolver) Feed(ctx context.Context, args struct{ ID graphql.ID }) (*feedResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	feed, ok, err := l.feeds.Load(id)
+	if err != nil || !ok || !feed.VisibleTo(l.userID) {
+		return nil, err
+	}
+	return &feedResolver{feed}, nil
+}
+
+func (r *Resolver) Subscriptions(ctx context.Context) ([]*subscriptionResolver, error) {
+	return userSubscriptions(ctx, database.DB.Where("user_id = ?", loadersFrom(ctx).userID))
+}
+
+func (r *Resolver) Folders(ctx context.Context) ([]*folderResolver, error) {
+	l := loadersFrom(ctx)
+	var folders []models.Folder
+	if err := database.DB.Where("user_id = ?", l.userID).Order("name").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	list := make([]*folderResolver, len(folders))
+	for i, f := range folders {
+		list[i] = &folderResolver{f}
+	}
+	return list, nil
+}
+
+type postsArgs struct {
+	First       *int32
+	After       *string
+	FeedId      *graphql.ID
+	UnreadOnly  *bool
+	StarredOnly *bool
+}
+
+func (r *Resolver) Posts(ctx context.Context, args postsArgs) (*postConnection, error) {
+	userID := loadersFrom(ctx).userID
+	query := database.DB.Model(&models.Post{}).Where("feed_id NOT IN (?)", newsletter.HiddenFeedIDs(userID))
+	if args.FeedId != nil {
+		feedID, err := parseID(*args.FeedId)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("feed_id = ?", feedID)
+	} else {
+		query = query.Where("feed_id IN (?)", poststate.SubscribedFeedIDs(userID))
+	}
+	if args.UnreadOnly != nil && *args.UnreadOnly {
+		query = query.Where("id NOT IN (?)", poststate.ReadPostIDs(userID))
+	}
+	if args.StarredOnly != nil && *args.StarredOnly {
+		query = query.Where("id IN (?)", poststate.StarredPostIDs(userID))
+	}
+	return postPage(ctx, query, args.First, args.After)
+}
+
+func (r *Resolver) Post(ctx context.Context, args struct{ ID graphql.ID }) (*postResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	var post models.Post
+	hidden := newsletter.HiddenFeedIDs(loadersFrom(ctx).userID)
+	if err := database.DB.Where("feed_id NOT IN (?)", hidden).First(&post, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newPostResolver(post), nil
+}
+
+// postPage pages posts newest first, keyed on (published, id)
+func postPage(ctx context.Context, query *gorm.DB, first *int32, after *string) (*postConnection, error) {
+	size := pageSize(first)
+	if after != nil {
+		parts, err := decodeCursor(*after, 2)
+		if err != nil {
+			return nil, err
+		}
+		nanos, err1 := strconv.ParseInt(parts[0], 10, 64)
+		id, err2 := strconv.ParseUint(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		published := time.Unix(0, nanos)
+		query = query.Where("published < ? OR (published = ? AND id < ?)", published, published, id)
+	}
+	var posts []models.Post
+	if err := query.Order("published desc, id desc").Limit(size + 1).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return newPostConnection(ctx, posts, size), nil
+}
+
+// newPostConnection pages posts fetched with one extra row beyond size
+func newPostConnection(ctx context.Context, posts []models.Post, size int) *postConnection {
+	conn := &postConnection{}
+	if len(posts) > size {
+		posts, conn.page.hasNext = posts[:size], true
+	}
+	l := loadersFrom(ctx)
+	for _, p := range posts {
+		l.feeds.Register(p.FeedId)
+		l.states.Register(p.ID)
+		conn.edges = append(conn.edges, postEdge{
+			cursor: encodeCursor(strconv.FormatInt(p.Published.UnixNano(), 10), strconv.FormatUint(uint64(p.ID), 10)),
+			node:   newPostResolver(p),
+		})
+	}
+	if n := len(conn.edges); n > 0 {
+		conn.page.end = &conn.edges[n-1].cursor
+	}
+	return conn
+}
+
+func userSubscriptions(ctx context.Context, query *gorm.DB) ([]*subscriptionResolver, error) {
+	var subs []models.Subscription
+	if err := query.Order("id").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	list := make([]*subscriptionResolver, len(subs))
+	for i, s := range subs {
+		l.feeds.Register(s.FeedID)
+		if s.FolderID != nil {
+			l.folders.Register(*s.FolderID)
+		}
+		list[i] = &subscriptionResolver{s}
+	}
+	return list, nil
+}
+
+// Mutations
+
+func (r *Resolver) Subscribe(ctx context.Context, args struct {
+	FeedId   graphql.ID
+	FolderId *graphql.ID
+}) (*subscriptionResolver, error) {
+	l := loadersFrom(ctx)
+	feedID, err := parseID(args.FeedId)
+	if err != nil {
+		return nil, err
+	}
+	if feed, ok, err := l.feeds.Load(feedID); err != nil {
+		return nil, err
+	} else if !ok || !feed.VisibleTo(l.userID) {
+		return nil, errors.New("feed not found")
+	}
+	sub := models.Subscription{UserID: l.userID, FeedID: feedID}
+	if args.FolderId != nil {
+		folderID, err := parseID(*args.FolderId)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok, err := l.folders.Load(folderID); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errors.New("folder not found")
+		}
+		sub.FolderID = &folderID
+	}
+	err = database.DB.Where("user_id = ? AND feed_id = ?", l.userID, feedID).
+		Assign(models.Subscription{FolderID: sub.FolderID}).
+		FirstOrCreate(&sub).Error
+	if err != nil {
+		return nil, err
+	}
+	l.subscriptions.Clear(feedID)
+	return &subscriptionResolver{sub}, nil
+}
+
+func (r *Resolver) Unsubscribe(ctx context.Context, args struct{ FeedId graphql.ID }) (bool, error) {
+	l := loadersFrom(ctx)
+	feedID, err := parseID(args.FeedId)
+	if err != nil {
+		return false, err
+	}
+	result := database.DB.Where("user_id = ? AND feed_id = ?", l.userID, feedID).Delete(&models.Subscription{})
+	l.subscriptions.Clear(feedID)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *Resolver) MarkRead(ctx context.Context, args struct {
+	PostIds []graphql.ID
+	Read    bool
+}) ([]*postResolver, error) {
+	l := loadersFrom(ctx)
+	ids := make([]uint, 0, len(args.PostIds))
+	for _, gid := range args.PostIds {
+		id, err := parseID(gid)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	var posts []models.Post
+	err := database.DB.Where("id IN ? AND feed_id NOT IN (?)", ids, newsletter.HiddenFeedIDs(l.userID)).
+		Order("published desc, id desc").Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	found := make([]uint, len(posts))
+	for i, p := range posts {
+		found[i] = p.ID
+	}
+	if err := poststate.SetRead(l.userID, found, args.Read); err != nil {
+		return nil, err
+	}
+	l.states.Clear(found...)
+	list := make([]*postResolver, len(posts))
+	for i, p := range posts {
+		l.states.Register(p.ID)
+		l.feeds.Register(p.FeedId)
+		list[i] = newPostResolver(p)
+	}
+	return list, nil
+}
+
+func (r *Resolver) MarkFeedRead(ctx context.Context, args struct{ FeedId graphql.ID }) (bool, error) {
+	l := loadersFrom(ctx)
+	feedID, err := parseID(args.FeedId)
+	if err != nil {
+		return false, err
+	}
+	if err := poststate.MarkFeedsRead(l.userID, []uint{feedID}, time.Now()); err != nil {
+		return false, err
+	}
+	l.unreadCounts.Clear(feedID)
+	return true, nil
+}
+
+func (r *Resolver) Star(ctx context.Context, args struct {
+	PostId  graphql.ID
+	Starred bool
+}) (*postResolver, error) {
+	l := loadersFrom(ctx)
+	id, err := parseID(args.PostId)
+	if err != nil {
+		return nil, err
+	}
+	var post models.Post
+	if err := database.DB.Where("feed_id NOT IN (?)", newsletter.HiddenFeedIDs(l.userID)).First(&post, id).Error; err != nil {
+		return nil, errNotFound
+	}
+	if err := poststate.SetStarred(l.userID, []uint{id}, args.Starred); err != nil {
+		return nil, err
+	}
+	l.states.Clear(id)
+	return newPostResolver(post), nil
+}
+
+// Types
+
+type userResolver struct{ u models.User }
+
+func (r *userResolver) ID() graphql.ID          { return toID(r.u.ID) }
+func (r *userResolver) Username() string        { return r.u.Username }
+func (r *userResolver) Email() string           { return r.u.Email }
+func (r *userResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.u.CreatedAt} }
+func (r *userResolver) Subscriptions(ctx context.Context) ([]*subscriptionResolver, error) {
+	return userSubscriptions(ctx, database.DB.Where("user_id = ?", r.u.ID))
+}
+
+type feedResolver struct{ f models.Feed }
+
+func (r *feedResolver) ID() graphql.ID          { return toID(r.f.ID) }
+func (r *feedResolver) Title() string           { return r.f.Title }
+func (r *feedResolver) URL() string             { return r.f.URL }
+func (r *feedResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.f.CreatedAt} }
+
+func (r *feedResolver) LastFetched() *graphql.Time {
+	if r.f.LastFetched == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.f.LastFetched}
+}
+
+func (r *feedResolver) Subscribed(ctx context.Context) (bool, error) {
+	l := loadersFrom(ctx)
+	l.subscriptions.Register(l.loadedFeeds()...)
+	sub, _, err := l.subscriptions.Load(r.f.ID)
+	return sub.ID != 0, err
+}
+
+func (r *feedResolver) UnreadCount(ctx context.Context) (int32, error) {
+	l := loadersFrom(ctx)
+	l.unreadCounts.Register(l.loadedFeeds()...)
+	count, _, err := l.unreadCounts.Load(r.f.ID)
+	return count, err
+}
+
+// Posts batches the first page over all loaded feeds; later pages are
+// fetched one feed at a time
+func (r *feedResolver) Posts(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*postConnection, error) {
+	if args.After != nil {
+		return postPage(ctx, database.DB.Model(&models.Post{}).Where("feed_id = ?", r.f.ID), args.First, args.After)
+	}
+	l := loadersFrom(ctx)
+	size := pageSize(args.First)
+	feedIDs := l.loadedFeeds()
+	pages := make([]feedPage, len(feedIDs))
+	for i, id := range feedIDs {
+		pages[i] = feedPage{id, size}
+	}
+	l.feedPosts.Register(pages...)
+	posts, _, err := l.feedPosts.Load(feedPage{r.f.ID, size})
+	if err != nil {
+		return nil, err
+	}
+	return newPostConnection(ctx, posts, size), nil
+}
+
+type subscriptionResolver struct{ s models.Subscription }
+
+func (r *subscriptionResolver) ID() graphql.ID { return toID(r.s.ID) }
+
+func (r *subscriptionResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, ok, err := loadersFrom(ctx).feeds.Load(r.s.FeedID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errNotFound
+	}
+	return &feedResolver{feed}, nil
+}
+
+func (r *subscriptionResolver) Folder(ctx context.Context) (*folderResolver, error) {
+	if r.s.FolderID == nil {
+		return nil, nil
+	}
+	folder, ok, err := loadersFrom(ctx).folders.Load(*r.s.FolderID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &folderResolver{folder}, nil
+}
+
+type folderResolver struct{ f models.Folder }
+
+func (r *folderResolver) ID() graphql.ID { return toID(r.f.ID) }
+func (r *folderResolver) Name() string   { return r.f.Name }
+
+func (r *folderResolver) Subscriptions(ctx context.Context) ([]*subscriptionResolver, error) {
+	l := loadersFrom(ctx)
+	l.folders.Prime(r.f.ID, r.f)
+	return userSubscriptions(ctx, database.DB.Where("user_id = ? AND folder_id = ?", l.userID, r.f.ID))
+}
+
+type postResolver struct{ p models.Post }
+
+// newPostResolver serves the post's images through the image proxy
+func newPostResolver(p models.Post) *postResolver {
+	return &postResolver{imageproxy.RewritePost(p)}
+}
+
+func (r *postResolver) ID() graphql.ID          { return toID(r.p.ID) }
+func (r *postResolver) Title() string           { return r.p.Title }
+func (r *postResolver) Link() string            { return r.p.Link }
+func (r *postResolver) Content() string         { return r.p.Content }
+func (r *postResolver) Published() graphql.Time { return graphql.Time{Time: r.p.Published} }
+
+func (r *postResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, ok, err := loadersFrom(ctx).feeds.Load(r.p.FeedId)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errNotFound
+	}
+	return &feedResolver{feed}, nil
+}
+
+func (r *postResolver) Read(ctx context.Context) (bool, error) {
+	state, _, err := loadersFrom(ctx).states.Load(r.p.ID)
+	return state.Read, err
+}
+
+func (r *postResolver) Starred(ctx context.Context) (bool, error) {
+	state, _, err := loadersFrom(ctx).states.Load(r.p.ID)
+	return state.Starred, err
+}
+
+// Connections
+
+type feedConnection struct {
+	edges []feedEdge
+	page  pageInfo
+}
+
+func (c *feedConnection) Edges() []feedEdge  { return c.edges }
+func (c *feedConnection) PageInfo() pageInfo { return c.page }
+
+type feedEdge struct {
+	cursor string
+	node   *feedResolver
+}
+
+func (e feedEdge) Cursor() string      { return e.cursor }
+func (e feedEdge) Node() *feedResolver { return e.node }
+
+type postConnection struct {
+	edges []postEdge
+	page  pageInfo
+}
+
+func (c *postConnection) Edges() []postEdge  { return c.edges }
+func (c *postConnection) PageInfo() pageInfo { return c.page }
+
+type postEdge struct {
+	cursor string
+	node   *postResolver
+}
+
+func (e postEdge) Cursor() string      { return e.cursor }
+func (e postEdge) Node() *postResolver { return e.node }
@@ -0,0 +1,51 @@
+package greader
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func clientLogin(t *testing.T, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/accounts/ClientLogin", ClientLogin)
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestClientLoginIgnoresQueryCredentials(t *testing.T) {
+	w := clientLogin(t, "/accounts/ClientLogin?Email=alice&Passwd=secret", "")
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "Error=BadAuthentication\n" {
+		t.Fatalf("ClientLogin returned %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestClientLoginWithForm(t *testing.T) {
+	testdb.Open(t)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := auth.HashPassword("secret")
+	database.DB.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: hash})
+
+	if w := clientLogin(t, "/accounts/ClientLogin", "Email=alice&Passwd=wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password returned %d", w.Code)
+	}
+	w := clientLogin(t, "/accounts/ClientLogin", "Email=alice&Passwd=secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "\nAuth=") {
+		t.Fatalf("ClientLogin returned %d: %s", w.Code, w.Body)
+	}
+}
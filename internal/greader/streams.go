@@ -0,0 +1,371 @@
+package greader
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/poststate"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCount   = 20
+	maxItemsCount  = 1000
+	maxItemIDCount = 10000
+)
+
+var errUnknownStream = errors.New("unknown stream")
+
+type link struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type summary struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type origin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HTMLURL  string `json:"htmlUrl"`
+}
+
+type item struct {
+	ID            string   `json:"id"`
+	CrawlTimeMsec string   `json:"crawlTimeMsec"`
+	TimestampUsec string   `json:"timestampUsec"`
+	Published     int64    `json:"published"`
+	Updated       int64    `json:"updated"`
+	Title         string   `json:"title"`
+	Canonical     []link   `json:"canonical"`
+	Alternate     []link   `json:"alternate"`
+	Summary       summary  `json:"summary"`
+	Categories    []string `json:"categories"`
+	Origin        origin   `json:"origin"`
+	Author        string   `json:"author"`
+}
+
+// streamQuery selects the posts of a stream: the reading list, the read or
+// starred state, a label (folder) or a single feed.
+func streamQuery(userID uint, streamID string) (*gorm.DB, error) {
+	query := database.DB.Model(&models.Post{}).Where("feed_id NOT IN (?)", newsletter.HiddenFeedIDs(userID))
+	streamID = normalizeStreamID(streamID)
+	switch {
+	case streamID == "" || streamID == stateReadingList:
+		return query.Where("feed_id IN (?)", poststate.SubscribedFeedIDs(userID)), nil
+	case streamID == stateStarred:
+		return query.Where("id IN (?)", poststate.StarredPostIDs(userID)), nil
+	case streamID == stateRead:
+		return query.Where("id IN (?)", poststate.ReadPostIDs(userID)), nil
+	case strings.HasPrefix(streamID, labelPrefix):
+		return query.Where("feed_id IN (?)", labelFeedIDs(userID, strings.TrimPrefix(streamID, labelPrefix))), nil
+	case strings.HasPrefix(streamID, feedPrefix):
+		feed, err := resolveFeed(streamID)
+		if err != nil {
+			return nil, errUnknownStream
+		}
+		return query.Where("feed_id = ?", feed.ID), nil
+	}
+	return nil, errUnknownStream
+}
+
+func labelFeedIDs(userID uint, name string) *gorm.DB {
+	return database.DB.Table("subscriptions").Select("subscriptions.feed_id").
+		Joins("JOIN folders ON folders.id = subscriptions.folder_id").
+		Where("subscriptions.user_id = ? AND folders.name = ?", userID, name)
+}
+
+// filteredStream applies the common stream parameters: xt (exclude state),
+// it (include state), ot/nt (time window), r=o (oldest first), n and c.
+func filteredStream(c *gin.Context, maxCount int) (*gorm.DB, int, int, error) {
+	userID := c.GetUint("User_id")
+	query, err := streamQuery(userID, c.Request.FormValue("s"))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	for _, xt := range formValues(c, "xt") {
+		switch normalizeStreamID(xt) {
+		case stateRead:
+			query = query.Where("id NOT IN (?)", poststate.ReadPostIDs(userID))
+		case stateStarred:
+			query = query.Where("id NOT IN (?)", poststate.StarredPostIDs(userID))
+		}
+	}
+	for _, it := range formValues(c, "it") {
+		switch normalizeStreamID(it) {
+		case stateRead:
+			query = query.Where("id IN (?)", poststate.ReadPostIDs(userID))
+		case stateStarred:
+			query = query.Where("id IN (?)", poststate.StarredPostIDs(userID))
+		}
+	}
+	if ot, err := strconv.ParseInt(c.Request.FormValue("ot"), 10, 64); err == nil && ot > 0 {
+		query = query.Where("published >= ?", time.Unix(ot, 0))
+	}
+	if nt, err := strconv.ParseInt(c.Request.FormValue("nt"), 10, 64); err == nil && nt > 0 {
+		query = query.Where("published <= ?", time.Unix(nt, 0))
+	}
+	if c.Request.FormValue("r") == "o" {
+		query = query.Order("published asc, id asc")
+	} else {
+		query = query.Order("published desc, id desc")
+	}
+
+	count, err := strconv.Atoi(c.Request.FormValue("n"))
+	if err != nil || count < 1 {
+		count = defaultCount
+	}
+	if count > maxCount {
+		count = maxCount
+	}
+	// The continuation is an offset into the ordered stream
+	offset, _ := strconv.Atoi(c.Request.FormValue("c"))
+	if offset < 0 {
+		offset = 0
+	}
+	return query.Offset(offset).Limit(count), count, offset, nil
+}
+
+func continuation(returned, count, offset int) string {
+	if returned < count {
+		return ""
+	}
+	return strconv.Itoa(offset + returned)
+}
+
+// StreamContents returns full items of a stream
+func StreamContents(c *gin.Context) {
+	// Most clients put the stream in the path instead of s=
+	if streamID := strings.TrimPrefix(c.Param("stream"), "/"); streamID != "" {
+		c.Request.ParseForm()
+		c.Request.Form.Set("s", streamID)
+	}
+	query, count, offset, err := filteredStream(c, maxItemsCount)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	var posts []models.Post
+	if err := query.Find(&posts).Error; err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	items, err := renderItems(c.GetUint("User_id"), posts)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := gin.H{
+		"id":      c.Request.FormValue("s"),
+		"updated": time.Now().Unix(),
+		"items":   items,
+	}
+	if next := continuation(len(posts), count, offset); next != "" {
+		resp["continuation"] = next
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// StreamItemIDs returns only the ids of a stream, for clients that sync
+// states first and fetch contents afterwards
+func StreamItemIDs(c *gin.Context) {
+	query, count, offset, err := filteredStream(c, maxItemIDCount)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	var posts []models.Post
+	if err := query.Select("id", "published").Find(&posts).Error; err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	refs := make([]gin.H, len(posts))
+	for i, p := range posts {
+		refs[i] = gin.H{
+			"id":              strconv.FormatUint(uint64(p.ID), 10),
+			"directStreamIds": []string{},
+			"timestampUsec":   strconv.FormatInt(p.Published.UnixMicro(), 10),
+		}
+	}
+	resp := gin.H{"itemRefs": refs}
+	if next := continuation(len(posts), count, offset); next != "" {
+		resp["continuation"] = next
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ItemsContents returns the items listed with i=
+func ItemsContents(c *gin.Context) {
+	ids := parseItemIDs(formValues(c, "i"))
+	var posts []models.Post
+	if len(ids) > 0 {
+		err := database.DB.Where("id IN ? AND feed_id NOT IN (?)", ids, newsletter.HiddenFeedIDs(c.GetUint("User_id"))).
+			Order("published desc, id desc").Find(&posts).Error
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	items, err := renderItems(c.GetUint("User_id"), posts)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      stateReadingList,
+		"updated": time.Now().Unix(),
+		"items":   items,
+	})
+}
+
+func parseItemIDs(values []string) []uint {
+	ids := make([]uint, 0, len(values))
+	for _, v := range values {
+		if id, ok := parseItemID(v); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func renderItems(userID uint, posts []models.Post) ([]item, error) {
+	postIDs := make([]uint, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
+	states, err := poststate.Load(userID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := loadSubscriptions(userID)
+	if err != nil {
+		return nil, err
+	}
+	subs := map[uint]subscriptionRow{}
+	for _, r := range rows {
+		subs[r.FeedID] = r
+	}
+	// Starred items may come from feeds the user no longer follows
+	var missing []uint
+	for _, p := range posts {
+		if _, ok := subs[p.FeedId]; !ok {
+			missing = append(missing, p.FeedId)
+		}
+	}
+	if len(missing) > 0 {
+		var feeds []models.Feed
+		if err := database.DB.Where("id IN ?", missing).Find(&feeds).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range feeds {
+			subs[f.ID] = subscriptionRow{FeedID: f.ID, Title: f.Title, URL: f.URL}
+		}
+	}
+
+	items := make([]item, len(posts))
+	for i, p := range posts {
+		sub := subs[p.FeedId]
+		categories := []string{stateReadingList}
+		if sub.FolderName != nil {
+			categories = append(categories, labelStreamID(*sub.FolderName))
+		}
+		state := states[p.ID]
+		if state.Read {
+			categories = append(categories, stateRead)
+		}
+		if state.Starred {
+			categories = append(categories, stateStarred)
+		}
+		p = imageproxy.RewritePost(p)
+		items[i] = item{
+			ID:            itemID(p.ID),
+			CrawlTimeMsec: strconv.FormatInt(p.Published.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(p.Published.UnixMicro(), 10),
+			Published:     p.Published.Unix(),
+			Updated:       p.Published.Unix(),
+			Title:         p.Title,
+			Canonical:     []link{{Href: p.Link}},
+			Alternate:     []link{{Href: p.Link, Type: "text/html"}},
+			Summary:       summary{Direction: "ltr", Content: p.Content},
+			Categories:    categories,
+			Origin:        origin{StreamID: feedStreamID(p.FeedId), Title: sub.Title, HTMLURL: siteURL(sub.URL)},
+		}
+	}
+	return items, nil
+}
+
+// EditTag adds (a=) or removes (r=) the read and starred states on the
+// items listed with i=
+func EditTag(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	ids := parseItemIDs(formValues(c, "i"))
+	apply := func(tag string, set bool) error {
+		switch normalizeStreamID(tag) {
+		case stateRead:
+			return poststate.SetRead(userID, ids, set)
+		case stateKeptUnread:
+			return poststate.SetRead(userID, ids, !set)
+		case stateStarred:
+			return poststate.SetStarred(userID, ids, set)
+		}
+		return nil
+	}
+	for _, tag := range formValues(c, "a") {
+		if err := apply(tag, true); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	for _, tag := range formValues(c, "r") {
+		if err := apply(tag, false); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// MarkAllAsRead marks a feed, label or the whole reading list read, up to
+// the ts timestamp (microseconds) when given
+func MarkAllAsRead(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	before := time.Now()
+	if ts, err := strconv.ParseInt(c.Request.FormValue("ts"), 10, 64); err == nil && ts > 0 {
+		before = time.UnixMicro(ts)
+	}
+
+	streamID := normalizeStreamID(c.Request.FormValue("s"))
+	var feedIDs []uint
+	var err error
+	switch {
+	case streamID == stateReadingList:
+		err = poststate.SubscribedFeedIDs(userID).Pluck("feed_id", &feedIDs).Error
+	case strings.HasPrefix(streamID, labelPrefix):
+		err = labelFeedIDs(userID, strings.TrimPrefix(streamID, labelPrefix)).Pluck("subscriptions.feed_id", &feedIDs).Error
+	case strings.HasPrefix(streamID, feedPrefix):
+		var feed models.Feed
+		if feed, err = resolveFeed(streamID); err == nil {
+			feedIDs = []uint{feed.ID}
+		}
+	default:
+		err = errUnknownStream
+	}
+	if err == nil {
+		err = poststate.MarkFeedsRead(userID, feedIDs, before)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "OK")
+}
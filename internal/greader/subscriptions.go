@@ -0,0 +1,336 @@
+package greader
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"blogAggregator/internal/rss"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type category struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type subscription struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Categories []category `json:"categories"`
+	URL        string     `json:"url"`
+	HTMLURL    string     `json:"htmlUrl"`
+	IconURL    string     `json:"iconUrl"`
+}
+
+// subscriptionRow is a subscription joined with its feed and folder
+type subscriptionRow struct {
+	FeedID     uint
+	Title      string
+	URL        string
+	FolderName *string
+}
+
+func loadSubscriptions(userID uint) ([]subscriptionRow, error) {
+	var rows []subscriptionRow
+	err := database.DB.Table("subscriptions").
+		Select("subscriptions.feed_id, feeds.title, feeds.url, folders.name AS folder_name").
+		Joins("JOIN feeds ON feeds.id = subscriptions.feed_id").
+		Joins("LEFT JOIN folders ON folders.id = subscriptions.folder_id").
+		Where("subscriptions.user_id = ?", userID).
+		Order("feeds.title").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SubscriptionList lists the user's feeds with their folder as category
+func SubscriptionList(c *gin.Context) {
+	rows, err := loadSubscriptions(c.GetUint("User_id"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	subs := make([]subscription, 0, len(rows))
+	for _, r := range rows {
+		s := subscription{
+			ID:         feedStreamID(r.FeedID),
+			Title:      r.Title,
+			Categories: []category{},
+			URL:        r.URL,
+			HTMLURL:    siteURL(r.URL),
+		}
+		if r.FolderName != nil {
+			s.Categories = append(s.Categories, category{ID: labelStreamID(*r.FolderName), Label: *r.FolderName})
+		}
+		subs = append(subs, s)
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subs})
+}
+
+// siteURL guesses the site address from the feed URL, as we do not store it
+func siteURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// TagList lists the starred state and the user's folders as labels
+func TagList(c *gin.Context) {
+	var folders []models.Folder
+	if err := database.DB.Where("user_id = ?", c.GetUint("User_id")).Order("name").Find(&folders).Error; err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	tags := []gin.H{{"id": stateStarred}}
+	for _, f := range folders {
+		tags = append(tags, gin.H{"id": labelStreamID(f.Name), "type": "folder"})
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// SubscriptionEdit handles ac=subscribe, unsubscribe and edit. Labels are
+// added with a= and removed with r=; a feed sits in at most one folder, so
+// adding a label moves it. Feeds are shared between users, so t= only names
+// feeds that are new to the server.
+func SubscriptionEdit(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	action := c.Request.FormValue("ac")
+	title := c.Request.FormValue("t")
+	add := c.Request.FormValue("a")
+	remove := c.Request.FormValue("r")
+
+	for _, s := range formValues(c, "s") {
+		var err error
+		switch action {
+		case "subscribe":
+			var feed models.Feed
+			if feed, err = subscribe(userID, s, title); err == nil {
+				err = applyLabels(userID, feed.ID, add, remove)
+			}
+		case "unsubscribe":
+			var feed models.Feed
+			if feed, err = resolveFeed(s); err == nil {
+				err = database.DB.Where("user_id = ? AND feed_id = ?", userID, feed.ID).Delete(&models.Subscription{}).Error
+			}
+		case "edit":
+			var feed models.Feed
+			if feed, err = resolveFeed(s); err == nil {
+				err = applyLabels(userID, feed.ID, add, remove)
+			}
+		default:
+			err = errors.New("unknown action")
+		}
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// QuickAdd subscribes to a feed URL
+func QuickAdd(c *gin.Context) {
+	feedURL := strings.TrimPrefix(c.Request.FormValue("quickadd"), feedPrefix)
+	feed, err := subscribe(c.GetUint("User_id"), feedPrefix+feedURL, "")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"numResults": 0, "query": feedURL, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"numResults": 1,
+		"query":      feedURL,
+		"streamId":   feedStreamID(feed.ID),
+		"streamName": feed.Title,
+	})
+}
+
+// subscribe finds or creates the feed for a "feed/<url>" stream id and
+// subscribes the user. New feeds are fetched right away so clients have
+// something to show.
+func subscribe(userID uint, streamID, title string) (models.Feed, error) {
+	feed, err := resolveFeed(streamID)
+	if err == nil && !feed.VisibleTo(userID) {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		feedURL := strings.TrimPrefix(streamID, feedPrefix)
+		u, perr := url.Parse(feedURL)
+		if perr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return feed, errors.New("invalid feed URL")
+		}
+		if title == "" {
+			title = feedURL
+		}
+		feed = models.Feed{Title: title, URL: feedURL}
+		if err = rss.ValidateSource(feed); err != nil {
+			return feed, err
+		}
+		if err = database.DB.Create(&feed).Error; err != nil {
+			return feed, err
+		}
+		go func(f models.Feed) {
+			if err := rss.FetchAndStoreFeed(f); err != nil {
+				log.Printf("greader: first fetch of %s failed: %v", f.URL, err)
+			}
+		}(feed)
+	} else if err != nil {
+		return feed, err
+	}
+
+	sub := models.Subscription{UserID: userID, FeedID: feed.ID}
+	err = database.DB.Where("user_id = ? AND feed_id = ?", userID, feed.ID).FirstOrCreate(&sub).Error
+	return feed, err
+}
+
+// resolveFeed finds the feed for "feed/<id>" or "feed/<url>"
+func resolveFeed(streamID string) (models.Feed, error) {
+	var feed models.Feed
+	if !strings.HasPrefix(streamID, feedPrefix) {
+		return feed, errors.New("not a feed stream")
+	}
+	ref := strings.TrimPrefix(streamID, feedPrefix)
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		err = database.DB.First(&feed, id).Error
+		return feed, err
+	}
+	err := database.DB.Where("url = ?", ref).First(&feed).Error
+	return feed, err
+}
+
+func applyLabels(userID, feedID uint, add, remove string) error {
+	query := database.DB.Model(&models.Subscription{}).Where("user_id = ? AND feed_id = ?", userID, feedID)
+	if name, ok := strings.CutPrefix(normalizeStreamID(add), labelPrefix); ok && name != "" {
+		folder := models.Folder{UserID: userID, Name: name}
+		if err := database.DB.Where("user_id = ? AND name = ?", userID, name).FirstOrCreate(&folder).Error; err != nil {
+			return err
+		}
+		return query.Update("folder_id", folder.ID).Error
+	}
+	if name, ok := strings.CutPrefix(normalizeStreamID(remove), labelPrefix); ok && name != "" {
+		var folder models.Folder
+		if err := database.DB.Where("user_id = ? AND name = ?", userID, name).First(&folder).Error; err != nil {
+			return nil
+		}
+		return query.Where("folder_id = ?", folder.ID).Update("folder_id", nil).Error
+	}
+	return nil
+}
+
+// RenameTag renames a folder (s= old label, dest= new label)
+func RenameTag(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	from, ok1 := strings.CutPrefix(normalizeStreamID(c.Request.FormValue("s")), labelPrefix)
+	to, ok2 := strings.CutPrefix(normalizeStreamID(c.Request.FormValue("dest")), labelPrefix)
+	if !ok1 || !ok2 || to == "" {
+		c.String(http.StatusBadRequest, "invalid label")
+		return
+	}
+	result := database.DB.Model(&models.Folder{}).Where("user_id = ? AND name = ?", userID, from).Update("name", to)
+	if result.Error != nil {
+		c.String(http.StatusBadRequest, "label already exists")
+		return
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// DisableTag deletes a folder; its feeds stay subscribed, unfiled
+func DisableTag(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	name, ok := strings.CutPrefix(normalizeStreamID(c.Request.FormValue("s")), labelPrefix)
+	if !ok {
+		c.String(http.StatusBadRequest, "invalid label")
+		return
+	}
+	var folder models.Folder
+	if err := database.DB.Where("user_id = ? AND name = ?", userID, name).First(&folder).Error; err != nil {
+		c.String(http.StatusOK, "OK")
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Subscription{}).Where("folder_id = ?", folder.ID).Update("folder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// UnreadCount reports unread posts per feed, per folder and overall
+func UnreadCount(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	var counts []struct {
+		FeedID uint
+		Count  int64
+		Newest *int64
+	}
+	err := database.DB.Model(&models.Post{}).
+		Select("feed_id, COUNT(*) AS count, CAST(EXTRACT(EPOCH FROM MAX(published)) * 1000000 AS BIGINT) AS newest").
+		Where("feed_id IN (?)", poststate.SubscribedFeedIDs(userID)).
+		Where("id NOT IN (?)", poststate.ReadPostIDs(userID)).
+		Group("feed_id").
+		Scan(&counts).Error
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, err := loadSubscriptions(userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	folderOf := map[uint]string{}
+	for _, r := range rows {
+		if r.FolderName != nil {
+			folderOf[r.FeedID] = *r.FolderName
+		}
+	}
+
+	type unread struct {
+		ID      string `json:"id"`
+		Count   int64  `json:"count"`
+		Newest  int64  `json:"-"`
+		NewestS string `json:"newestItemTimestampUsec"`
+	}
+	var list []*unread
+	labels := map[string]*unread{}
+	total := &unread{ID: stateReadingList}
+	for _, cnt := range counts {
+		var newest int64
+		if cnt.Newest != nil {
+			newest = *cnt.Newest
+		}
+		list = append(list, &unread{ID: feedStreamID(cnt.FeedID), Count: cnt.Count, Newest: newest})
+		targets := []*unread{total}
+		if name, ok := folderOf[cnt.FeedID]; ok {
+			if labels[name] == nil {
+				labels[name] = &unread{ID: labelStreamID(name)}
+				list = append(list, labels[name])
+			}
+			targets = append(targets, labels[name])
+		}
+		for _, t := range targets {
+			t.Count += cnt.Count
+			if newest > t.Newest {
+				t.Newest = newest
+			}
+		}
+	}
+	list = append(list, total)
+	for _, u := range list {
+		u.NewestS = strconv.FormatInt(u.Newest, 10)
+	}
+	c.JSON(http.StatusOK, gin.H{"max": total.Count, "unreadcounts": list})
+}
@@ -0,0 +1,1071 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: aggregator/v1/aggregator.proto
+
+package aggregatorv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Feed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastFetched   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_fetched,json=lastFetched,proto3" json:"last_fetched,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Feed) Reset() {
+	*x = Feed{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Feed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Feed) ProtoMessage() {}
+
+func (x *Feed) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Feed.ProtoReflect.Descriptor instead.
+func (*Feed) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Feed) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Feed) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Feed) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Feed) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Feed) GetLastFetched() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastFetched
+	}
+	return nil
+}
+
+type Post struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Link          string                 `protobuf:"bytes,3,opt,name=link,proto3" json:"link,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Published     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=published,proto3" json:"published,omitempty"`
+	FeedId        uint64                 `protobuf:"varint,6,opt,name=feed_id,json=feedId,proto3" json:"feed_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Post) Reset() {
+	*x = Post{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Post) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Post) ProtoMessage() {}
+
+func (x *Post) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Post.ProtoReflect.Descriptor instead.
+func (*Post) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Post) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Post) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Post) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *Post) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Post) GetPublished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Published
+	}
+	return nil
+}
+
+func (x *Post) GetFeedId() uint64 {
+	if x != nil {
+		return x.FeedId
+	}
+	return 0
+}
+
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	FeedId        uint64                 `protobuf:"varint,2,opt,name=feed_id,json=feedId,proto3" json:"feed_id,omitempty"`
+	FolderId      *uint64                `protobuf:"varint,3,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Subscription) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Subscription) GetFeedId() uint64 {
+	if x != nil {
+		return x.FeedId
+	}
+	return 0
+}
+
+func (x *Subscription) GetFolderId() uint64 {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return 0
+}
+
+type ListFeedsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// At most 100; defaults to 50
+	PageSize      int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFeedsRequest) Reset() {
+	*x = ListFeedsRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFeedsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFeedsRequest) ProtoMessage() {}
+
+func (x *ListFeedsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFeedsRequest.ProtoReflect.Descriptor instead.
+func (*ListFeedsRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListFeedsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListFeedsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListFeedsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Feeds []*Feed                `protobuf:"bytes,1,rep,name=feeds,proto3" json:"feeds,omitempty"`
+	// Empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFeedsResponse) Reset() {
+	*x = ListFeedsResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFeedsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFeedsResponse) ProtoMessage() {}
+
+func (x *ListFeedsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFeedsResponse.ProtoReflect.Descriptor instead.
+func (*ListFeedsResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListFeedsResponse) GetFeeds() []*Feed {
+	if x != nil {
+		return x.Feeds
+	}
+	return nil
+}
+
+func (x *ListFeedsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetFeedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFeedRequest) Reset() {
+	*x = GetFeedRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFeedRequest) ProtoMessage() {}
+
+func (x *GetFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFeedRequest.ProtoReflect.Descriptor instead.
+func (*GetFeedRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetFeedRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type CreateFeedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateFeedRequest) Reset() {
+	*x = CreateFeedRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateFeedRequest) ProtoMessage() {}
+
+func (x *CreateFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateFeedRequest.ProtoReflect.Descriptor instead.
+func (*CreateFeedRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateFeedRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateFeedRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type RefreshFeedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshFeedRequest) Reset() {
+	*x = RefreshFeedRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshFeedRequest) ProtoMessage() {}
+
+func (x *RefreshFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshFeedRequest.ProtoReflect.Descriptor instead.
+func (*RefreshFeedRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RefreshFeedRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type RefreshFeedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshFeedResponse) Reset() {
+	*x = RefreshFeedResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshFeedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshFeedResponse) ProtoMessage() {}
+
+func (x *RefreshFeedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshFeedResponse.ProtoReflect.Descriptor instead.
+func (*RefreshFeedResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{8}
+}
+
+type ListSubscriptionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsRequest) Reset() {
+	*x = ListSubscriptionsRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{9}
+}
+
+type ListSubscriptionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscriptions []*Subscription        `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsResponse) Reset() {
+	*x = ListSubscriptionsResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsResponse) ProtoMessage() {}
+
+func (x *ListSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListSubscriptionsResponse) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FeedId        uint64                 `protobuf:"varint,1,opt,name=feed_id,json=feedId,proto3" json:"feed_id,omitempty"`
+	FolderId      *uint64                `protobuf:"varint,2,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SubscribeRequest) GetFeedId() uint64 {
+	if x != nil {
+		return x.FeedId
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetFolderId() uint64 {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return 0
+}
+
+type UnsubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FeedId        uint64                 `protobuf:"varint,1,opt,name=feed_id,json=feedId,proto3" json:"feed_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnsubscribeRequest) Reset() {
+	*x = UnsubscribeRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnsubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnsubscribeRequest) ProtoMessage() {}
+
+func (x *UnsubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnsubscribeRequest.ProtoReflect.Descriptor instead.
+func (*UnsubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UnsubscribeRequest) GetFeedId() uint64 {
+	if x != nil {
+		return x.FeedId
+	}
+	return 0
+}
+
+type UnsubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnsubscribeResponse) Reset() {
+	*x = UnsubscribeResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnsubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnsubscribeResponse) ProtoMessage() {}
+
+func (x *UnsubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnsubscribeResponse.ProtoReflect.Descriptor instead.
+func (*UnsubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{13}
+}
+
+type ListPostsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Feeds to list; empty means the caller's subscriptions
+	FeedIds []uint64 `protobuf:"varint,1,rep,packed,name=feed_ids,json=feedIds,proto3" json:"feed_ids,omitempty"`
+	// At most 100; defaults to 50
+	PageSize      int32  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPostsRequest) Reset() {
+	*x = ListPostsRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPostsRequest) ProtoMessage() {}
+
+func (x *ListPostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPostsRequest.ProtoReflect.Descriptor instead.
+func (*ListPostsRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListPostsRequest) GetFeedIds() []uint64 {
+	if x != nil {
+		return x.FeedIds
+	}
+	return nil
+}
+
+func (x *ListPostsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListPostsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListPostsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Posts         []*Post                `protobuf:"bytes,1,rep,name=posts,proto3" json:"posts,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPostsResponse) Reset() {
+	*x = ListPostsResponse{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPostsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPostsResponse) ProtoMessage() {}
+
+func (x *ListPostsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPostsResponse.ProtoReflect.Descriptor instead.
+func (*ListPostsResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListPostsResponse) GetPosts() []*Post {
+	if x != nil {
+		return x.Posts
+	}
+	return nil
+}
+
+func (x *ListPostsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type StreamPostsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Feeds to follow; empty means the caller's subscriptions, which are
+	// re-read while the stream is open
+	FeedIds []uint64 `protobuf:"varint,1,rep,packed,name=feed_ids,json=feedIds,proto3" json:"feed_ids,omitempty"`
+	// Replay stored posts with a larger id before streaming
+	AfterId       uint64 `protobuf:"varint,2,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamPostsRequest) Reset() {
+	*x = StreamPostsRequest{}
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamPostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamPostsRequest) ProtoMessage() {}
+
+func (x *StreamPostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_v1_aggregator_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamPostsRequest.ProtoReflect.Descriptor instead.
+func (*StreamPostsRequest) Descriptor() ([]byte, []int) {
+	return file_aggregator_v1_aggregator_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StreamPostsRequest) GetFeedIds() []uint64 {
+	if x != nil {
+		return x.FeedIds
+	}
+	return nil
+}
+
+func (x *StreamPostsRequest) GetAfterId() uint64 {
+	if x != nil {
+		return x.AfterId
+	}
+	return 0
+}
+
+var File_aggregator_v1_aggregator_proto protoreflect.FileDescriptor
+
+const file_aggregator_v1_aggregator_proto_rawDesc = "" +
+	"\n" +
+	"\x1eaggregator/v1/aggregator.proto\x12\raggregator.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb8\x01\n" +
+	"\x04Feed\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12=\n" +
+	"\flast_fetched\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vlastFetched\"\xad\x01\n" +
+	"\x04Post\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04link\x18\x03 \x01(\tR\x04link\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x128\n" +
+	"\tpublished\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tpublished\x12\x17\n" +
+	"\afeed_id\x18\x06 \x01(\x04R\x06feedId\"g\n" +
+	"\fSubscription\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x17\n" +
+	"\afeed_id\x18\x02 \x01(\x04R\x06feedId\x12 \n" +
+	"\tfolder_id\x18\x03 \x01(\x04H\x00R\bfolderId\x88\x01\x01B\f\n" +
+	"\n" +
+	"_folder_id\"N\n" +
+	"\x10ListFeedsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\"f\n" +
+	"\x11ListFeedsResponse\x12)\n" +
+	"\x05feeds\x18\x01 \x03(\v2\x13.aggregator.v1.FeedR\x05feeds\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\" \n" +
+	"\x0eGetFeedRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\";\n" +
+	"\x11CreateFeedRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"$\n" +
+	"\x12RefreshFeedRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"\x15\n" +
+	"\x13RefreshFeedResponse\"\x1a\n" +
+	"\x18ListSubscriptionsRequest\"^\n" +
+	"\x19ListSubscriptionsResponse\x12A\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x1b.aggregator.v1.SubscriptionR\rsubscriptions\"[\n" +
+	"\x10SubscribeRequest\x12\x17\n" +
+	"\afeed_id\x18\x01 \x01(\x04R\x06feedId\x12 \n" +
+	"\tfolder_id\x18\x02 \x01(\x04H\x00R\bfolderId\x88\x01\x01B\f\n" +
+	"\n" +
+	"_folder_id\"-\n" +
+	"\x12UnsubscribeRequest\x12\x17\n" +
+	"\afeed_id\x18\x01 \x01(\x04R\x06feedId\"\x15\n" +
+	"\x13UnsubscribeResponse\"i\n" +
+	"\x10ListPostsRequest\x12\x19\n" +
+	"\bfeed_ids\x18\x01 \x03(\x04R\afeedIds\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"f\n" +
+	"\x11ListPostsResponse\x12)\n" +
+	"\x05posts\x18\x01 \x03(\v2\x13.aggregator.v1.PostR\x05posts\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"J\n" +
+	"\x12StreamPostsRequest\x12\x19\n" +
+	"\bfeed_ids\x18\x01 \x03(\x04R\afeedIds\x12\x19\n" +
+	"\bafter_id\x18\x02 \x01(\x04R\aafterId2\xdf\x05\n" +
+	"\x11AggregatorService\x12N\n" +
+	"\tListFeeds\x12\x1f.aggregator.v1.ListFeedsRequest\x1a .aggregator.v1.ListFeedsResponse\x12=\n" +
+	"\aGetFeed\x12\x1d.aggregator.v1.GetFeedRequest\x1a\x13.aggregator.v1.Feed\x12C\n" +
+	"\n" +
+	"CreateFeed\x12 .aggregator.v1.CreateFeedRequest\x1a\x13.aggregator.v1.Feed\x12T\n" +
+	"\vRefreshFeed\x12!.aggregator.v1.RefreshFeedRequest\x1a\".aggregator.v1.RefreshFeedResponse\x12f\n" +
+	"\x11ListSubscriptions\x12'.aggregator.v1.ListSubscriptionsRequest\x1a(.aggregator.v1.ListSubscriptionsResponse\x12I\n" +
+	"\tSubscribe\x12\x1f.aggregator.v1.SubscribeRequest\x1a\x1b.aggregator.v1.Subscription\x12T\n" +
+	"\vUnsubscribe\x12!.aggregator.v1.UnsubscribeRequest\x1a\".aggregator.v1.UnsubscribeResponse\x12N\n" +
+	"\tListPosts\x12\x1f.aggregator.v1.ListPostsRequest\x1a .aggregator.v1.ListPostsResponse\x12G\n" +
+	"\vStreamPosts\x12!.aggregator.v1.StreamPostsRequest\x1a\x13.aggregator.v1.Post0\x01B;Z9blogAggregator/internal/grpcapi/aggregatorv1;aggregatorv1b\x06proto3"
+
+var (
+	file_aggregator_v1_aggregator_proto_rawDescOnce sync.Once
+	file_aggregator_v1_aggregator_proto_rawDescData []byte
+)
+
+func file_aggregator_v1_aggregator_proto_rawDescGZIP() []byte {
+	file_aggregator_v1_aggregator_proto_rawDescOnce.Do(func() {
+		file_aggregator_v1_aggregator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_aggregator_v1_aggregator_proto_rawDesc), len(file_aggregator_v1_aggregator_proto_rawDesc)))
+	})
+	return file_aggregator_v1_aggregator_proto_rawDescData
+}
+
+var file_aggregator_v1_aggregator_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_aggregator_v1_aggregator_proto_goTypes = []any{
+	(*Feed)(nil),                      // 0: aggregator.v1.Feed
+	(*Post)(nil),                      // 1: aggregator.v1.Post
+	(*Subscription)(nil),              // 2: aggregator.v1.Subscription
+	(*ListFeedsRequest)(nil),          // 3: aggregator.v1.ListFeedsRequest
+	(*ListFeedsResponse)(nil),         // 4: aggregator.v1.ListFeedsResponse
+	(*GetFeedRequest)(nil),            // 5: aggregator.v1.GetFeedRequest
+	(*CreateFeedRequest)(nil),         // 6: aggregator.v1.CreateFeedRequest
+	(*RefreshFeedRequest)(nil),        // 7: aggregator.v1.RefreshFeedRequest
+	(*RefreshFeedResponse)(nil),       // 8: aggregator.v1.RefreshFeedResponse
+	(*ListSubscriptionsRequest)(nil),  // 9: aggregator.v1.ListSubscriptionsRequest
+	(*ListSubscriptionsResponse)(nil), // 10: aggregator.v1.ListSubscriptionsResponse
+	(*SubscribeRequest)(nil),          // 11: aggregator.v1.SubscribeRequest
+	(*UnsubscribeRequest)(nil),        // 12: aggregator.v1.UnsubscribeRequest
+	(*UnsubscribeResponse)(nil),       // 13: aggregator.v1.UnsubscribeResponse
+	(*ListPostsRequest)(nil),          // 14: aggregator.v1.ListPostsRequest
+	(*ListPostsResponse)(nil),         // 15: aggregator.v1.ListPostsResponse
+	(*StreamPostsRequest)(nil),        // 16: aggregator.v1.StreamPostsRequest
+	(*timestamppb.Timestamp)(nil),     // 17: google.protobuf.Timestamp
+}
+var file_aggregator_v1_aggregator_proto_depIdxs = []int32{
+	17, // 0: aggregator.v1.Feed.created_at:type_name -> google.protobuf.Timestamp
+	17, // 1: aggregator.v1.Feed.last_fetched:type_name -> google.protobuf.Timestamp
+	17, // 2: aggregator.v1.Post.published:type_name -> google.protobuf.Timestamp
+	0,  // 3: aggregator.v1.ListFeedsResponse.feeds:type_name -> aggregator.v1.Feed
+	2,  // 4: aggregator.v1.ListSubscriptionsResponse.subscriptions:type_name -> aggregator.v1.Subscription
+	1,  // 5: aggregator.v1.ListPostsResponse.posts:type_name -> aggregator.v1.Post
+	3,  // 6: aggregator.v1.AggregatorService.ListFeeds:input_type -> aggregator.v1.ListFeedsRequest
+	5,  // 7: aggregator.v1.AggregatorService.GetFeed:input_type -> aggregator.v1.GetFeedRequest
+	6,  // 8: aggregator.v1.AggregatorService.CreateFeed:input_type -> aggregator.v1.CreateFeedRequest
+	7,  // 9: aggregator.v1.AggregatorService.RefreshFeed:input_type -> aggregator.v1.RefreshFeedRequest
+	9,  // 10: aggregator.v1.AggregatorService.ListSubscriptions:input_type -> aggregator.v1.ListSubscriptionsRequest
+	11, // 11: aggregator.v1.AggregatorService.Subscribe:input_type -> aggregator.v1.SubscribeRequest
+	12, // 12: aggregator.v1.AggregatorService.Unsubscribe:input_type -> aggregator.v1.UnsubscribeRequest
+	14, // 13: aggregator.v1.AggregatorService.ListPosts:input_type -> aggregator.v1.ListPostsRequest
+	16, // 14: aggregator.v1.AggregatorService.StreamPosts:input_type -> aggregator.v1.StreamPostsRequest
+	4,  // 15: aggregator.v1.AggregatorService.ListFeeds:output_type -> aggregator.v1.ListFeedsResponse
+	0,  // 16: aggregator.v1.AggregatorService.GetFeed:output_type -> aggregator.v1.Feed
+	0,  // 17: aggregator.v1.AggregatorService.CreateFeed:output_type -> aggregator.v1.Feed
+	8,  // 18: aggregator.v1.AggregatorService.RefreshFeed:output_type -> aggregator.v1.RefreshFeedResponse
+	10, // 19: aggregator.v1.AggregatorService.ListSubscriptions:output_type -> aggregator.v1.ListSubscriptionsResponse
+	2,  // 20: aggregator.v1.AggregatorService.Subscribe:output_type -> aggregator.v1.Subscription
+	13, // 21: aggregator.v1.AggregatorService.Unsubscribe:output_type -> aggregator.v1.UnsubscribeResponse
+	15, // 22: aggregator.v1.AggregatorService.ListPosts:output_type -> aggregator.v1.ListPostsResponse
+	1,  // 23: aggregator.v1.AggregatorService.StreamPosts:output_type -> aggregator.v1.Post
+	15, // [15:24] is the sub-list for method output_type
+	6,  // [6:15] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_aggregator_v1_aggregator_proto_init() }
+func file_aggregator_v1_aggregator_proto_init() {
+	if File_aggregator_v1_aggregator_proto != nil {
+		return
+	}
+	file_aggregator_v1_aggregator_proto_msgTypes[2].OneofWrappers = []any{}
+	file_aggregator_v1_aggregator_proto_msgTypes[11].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_aggregator_v1_aggregator_proto_rawDesc), len(file_aggregator_v1_aggregator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_aggregator_v1_aggregator_proto_goTypes,
+		DependencyIndexes: file_aggregator_v1_aggregator_proto_depIdxs,
+		MessageInfos:      file_aggregator_v1_aggregator_proto_msgTypes,
+	}.Build()
+	File_aggregator_v1_aggregator_proto = out.File
+	file_aggregator_v1_aggregator_proto_goTypes = nil
+	file_aggregator_v1_aggregator_proto_depIdxs = nil
+}
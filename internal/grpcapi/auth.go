@@ -0,0 +1,72 @@
+package grpcapi
+
+import (
+	"blogAggregator/internal/apikeys"
+	"blogAggregator/internal/auth"
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type userIDKey struct{}
+
+// authenticate resolves the caller from "authorization: Bearer <token>",
+// where the token is a JWT or an API key, or from "x-api-key"
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+		}
+		if userID, err := auth.ParseToken(token); err == nil {
+			return context.WithValue(ctx, userIDKey{}, userID), nil
+		}
+		if userID, ok := apikeys.Authenticate(token); ok {
+			return context.WithValue(ctx, userIDKey{}, userID), nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		if userID, ok := apikeys.Authenticate(values[0]); ok {
+			return context.WithValue(ctx, userIDKey{}, userID), nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing token")
+}
+
+func userID(ctx context.Context) uint {
+	id, _ := ctx.Value(userIDKey{}).(uint)
+	return id
+}
+
+func unaryAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream carries the authenticated context into stream handlers
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
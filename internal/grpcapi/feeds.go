@@ -0,0 +1,165 @@
+package grpcapi
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/grpcapi/aggregatorv1"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/rss"
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+func toFeed(f models.Feed) *aggregatorv1.Feed {
+	feed := &aggregatorv1.Feed{
+		Id:        uint64(f.ID),
+		Title:     f.Title,
+		Url:       f.URL,
+		CreatedAt: timestamppb.New(f.CreatedAt),
+	}
+	if f.LastFetched != nil {
+		feed.LastFetched = timestamppb.New(*f.LastFetched)
+	}
+	return feed
+}
+
+// findFeed loads a feed the caller may see; another user's newsletter feed
+// is reported as missing
+func findFeed(ctx context.Context, id uint64) (models.Feed, error) {
+	var feed models.Feed
+	err := database.DB.First(&feed, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && !feed.VisibleTo(userID(ctx)) {
+		return feed, status.Error(codes.NotFound, "feed not found")
+	}
+	if err != nil {
+		return feed, internalError(err)
+	}
+	return feed, nil
+}
+
+func (s *service) ListFeeds(ctx context.Context, req *aggregatorv1.ListFeedsRequest) (*aggregatorv1.ListFeedsResponse, error) {
+	size := pageSize(req.GetPageSize())
+	query := database.DB.Where("id NOT IN (?)", newsletter.HiddenFeedIDs(0)).Order("id")
+	if req.GetPageToken() != "" {
+		after, err := decodeIDToken(req.GetPageToken())
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id > ?", after)
+	}
+	var feeds []models.Feed
+	// One extra row tells whether another page follows
+	if err := query.Limit(size + 1).Find(&feeds).Error; err != nil {
+		return nil, internalError(err)
+	}
+	resp := &aggregatorv1.ListFeedsResponse{}
+	if len(feeds) > size {
+		feeds = feeds[:size]
+		resp.NextPageToken = encodeIDToken(feeds[size-1].ID)
+	}
+	for _, f := range feeds {
+		resp.Feeds = append(resp.Feeds, toFeed(f))
+	}
+	return resp, nil
+}
+
+func (s *service) GetFeed(ctx context.Context, req *aggregatorv1.GetFeedRequest) (*aggregatorv1.Feed, error) {
+	feed, err := findFeed(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toFeed(feed), nil
+}
+
+func (s *service) CreateFeed(ctx context.Context, req *aggregatorv1.CreateFeedRequest) (*aggregatorv1.Feed, error) {
+	if req.GetTitle() == "" || req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "title and url are required")
+	}
+	feed := models.Feed{Title: req.GetTitle(), URL: req.GetUrl()}
+	if err := rss.ValidateSource(feed); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := database.DB.Create(&feed).Error; err != nil {
+		if uniqueViolation(err) {
+			return nil, status.Error(codes.AlreadyExists, "feed already exists")
+		}
+		return nil, internalError(err)
+	}
+	return toFeed(feed), nil
+}
+
+func (s *service) RefreshFeed(ctx context.Context, req *aggregatorv1.RefreshFeedRequest) (*aggregatorv1.RefreshFeedResponse, error) {
+	feed, err := findFeed(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := rss.FetchAndStoreFeed(feed); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &aggregatorv1.RefreshFeedResponse{}, nil
+}
+
+func toSubscription(sub models.Subscription) *aggregatorv1.Subscription {
+	s := &aggregatorv1.Subscription{Id: uint64(sub.ID), FeedId: uint64(sub.FeedID)}
+	if sub.FolderID != nil {
+		folderID := uint64(*sub.FolderID)
+		s.FolderId = &folderID
+	}
+	return s
+}
+
+func (s *service) ListSubscriptions(ctx context.Context, req *aggregatorv1.ListSubscriptionsRequest) (*aggregatorv1.ListSubscriptionsResponse, error) {
+	var subs []models.Subscription
+	if err := database.DB.Where("user_id = ?", userID(ctx)).Order("id").Find(&subs).Error; err != nil {
+		return nil, internalError(err)
+	}
+	resp := &aggregatorv1.ListSubscriptionsResponse{}
+	for _, sub := range subs {
+		resp.Subscriptions = append(resp.Subscriptions, toSubscription(sub))
+	}
+	return resp, nil
+}
+
+// Subscribe is idempotent: subscribing again only moves the subscription
+// to the given folder
+func (s *service) Subscribe(ctx context.Context, req *aggregatorv1.SubscribeRequest) (*aggregatorv1.Subscription, error) {
+	uid := userID(ctx)
+	if _, err := findFeed(ctx, req.GetFeedId()); err != nil {
+		return nil, err
+	}
+	sub := models.Subscription{UserID: uid, FeedID: uint(req.GetFeedId())}
+	if req.FolderId != nil {
+		var count int64
+		if err := database.DB.Model(&models.Folder{}).Where("id = ? AND user_id = ?", req.GetFolderId(), uid).Count(&count).Error; err != nil {
+			return nil, internalError(err)
+		}
+		if count == 0 {
+			return nil, status.Error(codes.NotFound, "folder not found")
+		}
+		folderID := uint(req.GetFolderId())
+		sub.FolderID = &folderID
+	}
+	err := database.DB.Where("user_id = ? AND feed_id = ?", uid, sub.FeedID).
+		Assign(models.Subscription{FolderID: sub.FolderID}).
+		FirstOrCreate(&sub).Error
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return toSubscription(sub), nil
+}
+
+func (s *service) Unsubscribe(ctx context.Context, req *aggregatorv1.UnsubscribeRequest) (*aggregatorv1.UnsubscribeResponse, error) {
+	result := database.DB.Where("user_id = ? AND feed_id = ?", userID(ctx), req.GetFeedId()).Delete(&models.Subscription{})
+	if result.Error != nil {
+		return nil, internalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, status.Error(codes.NotFound, "subscription not found")
+	}
+	return &aggregatorv1.UnsubscribeResponse{}, nil
+}
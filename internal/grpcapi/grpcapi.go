@@ -0,0 +1,113 @@
+// Package grpcapi serves the AggregatorService gRPC API, defined in
+// proto/aggregator/v1, for internal services. It runs on its own port next
+// to the HTTP router and accepts the same JWTs and API keys.
+package grpcapi
+
+import (
+	"blogAggregator/internal/grpcapi/aggregatorv1"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc -I ../../proto --go_out=aggregatorv1 --go_opt=paths=source_relative --go-grpc_out=aggregatorv1 --go-grpc_opt=paths=source_relative aggregator/v1/aggregator.proto
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+var errBadPageToken = status.Error(codes.InvalidArgument, "invalid page token")
+
+// NewServer returns a gRPC server with the auth interceptors installed and
+// the AggregatorService registered
+func NewServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryAuth),
+		grpc.StreamInterceptor(streamAuth),
+	)
+	aggregatorv1.RegisterAggregatorServiceServer(s, &service{})
+	return s
+}
+
+// Serve listens on addr and serves gRPC until the listener fails
+func Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return NewServer().Serve(lis)
+}
+
+type service struct {
+	aggregatorv1.UnimplementedAggregatorServiceServer
+}
+
+func pageSize(requested int32) int {
+	switch {
+	case requested <= 0:
+		return defaultPageSize
+	case requested > maxPageSize:
+		return maxPageSize
+	}
+	return int(requested)
+}
+
+func internalError(err error) error {
+	return status.Error(codes.Internal, err.Error())
+}
+
+// uniqueViolation reports whether err is a Postgres unique_violation (23505)
+func uniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// Feed pages are ordered by id, so their token is the last id returned
+func encodeIDToken(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeIDToken(token string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errBadPageToken
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, errBadPageToken
+	}
+	return uint(id), nil
+}
+
+// Post pages are ordered newest first, so their token is the published
+// time and id of the last post returned
+func encodePostToken(published time.Time, id uint) string {
+	raw := strconv.FormatInt(published.UnixNano(), 10) + ":" + strconv.FormatUint(uint64(id), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePostToken(token string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, 0, errBadPageToken
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, errBadPageToken
+	}
+	n, err1 := strconv.ParseInt(nanos, 10, 64)
+	i, err2 := strconv.ParseUint(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, 0, errBadPageToken
+	}
+	return time.Unix(0, n).UTC(), uint(i), nil
+}
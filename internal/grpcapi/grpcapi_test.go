@@ -0,0 +1,53 @@
+package grpcapi
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/grpcapi/aggregatorv1"
+	"blogAggregator/internal/testdb"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505"}, true},
+		{"wrapped", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), true},
+		{"not null violation", &pgconn.PgError{Code: "23502"}, false},
+		{"connection error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := uniqueViolation(tt.err); got != tt.want {
+			t.Errorf("%s: uniqueViolation = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateFeedErrorCodes(t *testing.T) {
+	testdb.Open(t)
+	s := &service{}
+	req := &aggregatorv1.CreateFeedRequest{Title: "Blog", Url: "https://example.com/feed.xml"}
+	if _, err := s.CreateFeed(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateFeed(context.Background(), req); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("duplicate feed returned %v", err)
+	}
+
+	// Any other database failure is not reported as a duplicate
+	database.DB.Exec(`ALTER TABLE "feeds" ADD CONSTRAINT "test_short_title" CHECK (length(title) < 10)`)
+	t.Cleanup(func() { database.DB.Exec(`ALTER TABLE "feeds" DROP CONSTRAINT IF EXISTS "test_short_title"`) })
+	req = &aggregatorv1.CreateFeedRequest{Title: "A much longer title", Url: "https://example.com/other.xml"}
+	if _, err := s.CreateFeed(context.Background(), req); status.Code(err) != codes.Internal {
+		t.Fatalf("failed insert returned %v", err)
+	}
+}
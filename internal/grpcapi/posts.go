@@ -0,0 +1,159 @@
+package grpcapi
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/events"
+	"blogAggregator/internal/grpcapi/aggregatorv1"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/poststate"
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	// streamRefresh is how often StreamPosts re-reads the caller's
+	// subscriptions
+	streamRefresh = 15 * time.Second
+	// streamReplayLimit caps how many stored posts are replayed on connect
+	streamReplayLimit = 500
+)
+
+func toPost(p models.Post) *aggregatorv1.Post {
+	p = imageproxy.RewritePost(p)
+	return &aggregatorv1.Post{
+		Id:        uint64(p.ID),
+		Title:     p.Title,
+		Link:      p.Link,
+		Content:   p.Content,
+		Published: timestamppb.New(p.Published),
+		FeedId:    uint64(p.FeedId),
+	}
+}
+
+// postsQuery limits posts to the requested feeds, or to the caller's
+// subscriptions when none are given. Other users' newsletters never match.
+func postsQuery(ctx context.Context, feedIDs []uint64) *gorm.DB {
+	query := database.DB.Model(&models.Post{})
+	if len(feedIDs) > 0 {
+		return query.Where("feed_id IN ? AND feed_id NOT IN (?)", feedIDs, newsletter.HiddenFeedIDs(userID(ctx)))
+	}
+	return query.Where("feed_id IN (?)", poststate.SubscribedFeedIDs(userID(ctx)))
+}
+
+func (s *service) ListPosts(ctx context.Context, req *aggregatorv1.ListPostsRequest) (*aggregatorv1.ListPostsResponse, error) {
+	size := pageSize(req.GetPageSize())
+	query := postsQuery(ctx, req.GetFeedIds())
+	if req.GetPageToken() != "" {
+		published, id, err := decodePostToken(req.GetPageToken())
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("published < ? OR (published = ? AND id < ?)", published, published, id)
+	}
+	var posts []models.Post
+	if err := query.Order("published desc").Order("id desc").Limit(size + 1).Find(&posts).Error; err != nil {
+		return nil, internalError(err)
+	}
+	resp := &aggregatorv1.ListPostsResponse{}
+	if len(posts) > size {
+		posts = posts[:size]
+		last := posts[size-1]
+		resp.NextPageToken = encodePostToken(last.Published, last.ID)
+	}
+	for _, p := range posts {
+		resp.Posts = append(resp.Posts, toPost(p))
+	}
+	return resp, nil
+}
+
+func (s *service) StreamPosts(req *aggregatorv1.StreamPostsRequest, stream grpc.ServerStreamingServer[aggregatorv1.Post]) error {
+	ctx := stream.Context()
+
+	// Subscribe before replaying so nothing stored in between is missed
+	sub := events.ClusterPosts.Subscribe(256)
+	defer sub.Close()
+
+	feedIDs, err := streamFeedIDs(ctx, req.GetFeedIds())
+	if err != nil {
+		return err
+	}
+
+	// Live posts are not filtered on id: ids are assigned before commit, so
+	// a post can arrive after one with a higher id. Only posts already sent
+	// by the replay are skipped.
+	replayed := map[uint]bool{}
+	if afterID := req.GetAfterId(); afterID > 0 && len(feedIDs) > 0 {
+		var missed []models.Post
+		err := database.DB.Where("id > ? AND feed_id IN ?", afterID, setKeys(feedIDs)).
+			Order("id").Limit(streamReplayLimit).Find(&missed).Error
+		if err != nil {
+			return internalError(err)
+		}
+		for _, post := range missed {
+			if err := stream.Send(toPost(post)); err != nil {
+				return err
+			}
+			replayed[post.ID] = true
+		}
+	}
+
+	refresh := time.NewTicker(streamRefresh)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case post, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if replayed[post.ID] || !feedIDs[post.FeedId] {
+				continue
+			}
+			if err := stream.Send(toPost(post)); err != nil {
+				return err
+			}
+		case <-refresh.C:
+			// Fixed feed lists never change; subscriptions may have
+			if len(req.GetFeedIds()) == 0 {
+				if ids, err := streamFeedIDs(ctx, nil); err == nil {
+					feedIDs = ids
+				}
+			}
+		}
+	}
+}
+
+func streamFeedIDs(ctx context.Context, requested []uint64) (map[uint]bool, error) {
+	set := map[uint]bool{}
+	var ids []uint
+	if len(requested) > 0 {
+		// Requested ids of other users' newsletters are dropped
+		err := database.DB.Model(&models.Feed{}).
+			Where("id IN ? AND id NOT IN (?)", requested, newsletter.HiddenFeedIDs(userID(ctx))).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return nil, internalError(err)
+		}
+	} else if err := database.DB.Model(&models.Subscription{}).Where("user_id = ?", userID(ctx)).Pluck("feed_id", &ids).Error; err != nil {
+		return nil, internalError(err)
+	}
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+func setKeys(set map[uint]bool) []uint {
+	keys := make([]uint, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"blogAggregator/internal/apikeys"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyInput struct {
+	Description string `json:"description"`
+}
+
+// CreateAPIKey
+// @Summary      Create API key
+// @Description  Issues a long-lived token for scripts and API clients, sent as X-Auth-Token. The token is only returned here.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body  APIKeyInput  true  "API key"
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Router       /api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Description string `json:"description" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, token, err := apikeys.Create(c.GetUint("User_id"), input.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "token": token})
+}
+
+// ListAPIKeys
+// @Summary      List API keys
+// @Tags         auth
+// @Produce      json
+// @Success      200  {array}  models.APIKey
+// @Router       /api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := database.DB.Where("user_id = ?", c.GetUint("User_id")).Order("id").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// DeleteAPIKey
+// @Summary      Revoke API key
+// @Tags         auth
+// @Produce      json
+// @Param        id   path  int  true  "API key ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /api-keys/{id} [delete]
+func DeleteAPIKey(c *gin.Context) {
+	result := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("User_id")).Delete(&models.APIKey{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
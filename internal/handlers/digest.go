@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/digest"
+	"blogAggregator/internal/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DigestSettingInput struct {
+	Enabled   bool   `json:"enabled"`
+	Frequency string `json:"frequency"`
+	Hour      int    `json:"hour"`
+	Weekday   int    `json:"weekday"`
+	Timezone  string `json:"timezone"`
+	FeedIDs   []uint `json:"feed_ids"`
+	FolderIDs []uint `json:"folder_ids"`
+}
+
+// GetDigestSetting
+// @Summary      Get email digest settings
+// @Tags         digests
+// @Produce      json
+// @Success      200  {object}  models.DigestSetting
+// @Router       /users/digest [get]
+func GetDigestSetting(c *gin.Context) {
+	userId := c.GetUint("User_id")
+	var setting models.DigestSetting
+	err := database.DB.Where("user_id = ?", userId).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, models.DigestSetting{UserID: userId, Frequency: digest.Daily, Timezone: "UTC"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, setting)
+}
+
+// UpdateDigestSetting
+// @Summary      Update email digest settings
+// @Description  Frequency is daily or weekly; hour (0-23) and weekday (0 = Sunday) are in the given IANA timezone. Empty feed_ids and folder_ids include every subscription.
+// @Tags         digests
+// @Accept       json
+// @Produce      json
+// @Param        input  body  DigestSettingInput  true  "Settings"
+// @Success      200  {object}  models.DigestSetting
+// @Failure      400  {object}  map[string]string
+// @Router       /users/digest [put]
+func UpdateDigestSetting(c *gin.Context) {
+	var input struct {
+		Enabled   bool   `json:"enabled"`
+		Frequency string `json:"frequency" binding:"required,oneof=daily weekly"`
+		Hour      int    `json:"hour" binding:"min=0,max=23"`
+		Weekday   int    `json:"weekday" binding:"min=0,max=6"`
+		Timezone  string `json:"timezone"`
+		FeedIDs   []uint `json:"feed_ids"`
+		FolderIDs []uint `json:"folder_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(input.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown timezone"})
+		return
+	}
+	userId := c.GetUint("User_id")
+
+	var setting models.DigestSetting
+	database.DB.Where("user_id = ?", userId).First(&setting)
+	setting.UserID = userId
+	setting.Enabled = input.Enabled
+	setting.Frequency = input.Frequency
+	setting.Hour = input.Hour
+	setting.Weekday = input.Weekday
+	setting.Timezone = input.Timezone
+	setting.FeedIDs = input.FeedIDs
+	setting.FolderIDs = input.FolderIDs
+	if err := database.DB.Save(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, setting)
+}
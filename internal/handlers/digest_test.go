@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/digest"
+	"blogAggregator/internal/mailer"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type recordingMailer struct{ sent []mailer.Message }
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestUpdateDigestSettingValidates(t *testing.T) {
+	testdb.Open(t)
+	r := newTestRouter(t)
+	r.PUT("/users/digest", asUser, UpdateDigestSetting)
+
+	for _, body := range []gin.H{
+		{"frequency": "hourly"},
+		{"frequency": "daily", "hour": 24},
+		{"frequency": "weekly", "weekday": 7},
+		{"frequency": "daily", "timezone": "Mars/Olympus"},
+	} {
+		if w := doJSON(r, http.MethodPut, "/users/digest", body, 1); w.Code != http.StatusBadRequest {
+			t.Errorf("%v returned %d", body, w.Code)
+		}
+	}
+}
+
+func TestEditingDigestKeepsSentSlot(t *testing.T) {
+	testdb.Open(t)
+	r := newTestRouter(t)
+	r.GET("/users/digest", asUser, GetDigestSetting)
+	r.PUT("/users/digest", asUser, UpdateDigestSetting)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	feed := models.Feed{Title: "Feed", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+	database.DB.Create(&models.Post{Title: "Post", Link: "https://example.com/1", Published: time.Now().UTC(), FeedId: feed.ID})
+
+	// Due an hour from now, so the slot after saving is the first one
+	hour := time.Now().UTC().Add(time.Hour).Hour()
+	w := doJSON(r, http.MethodPut, "/users/digest", gin.H{"enabled": true, "frequency": "daily", "hour": hour}, user.ID)
+	requireStatus(t, w, http.StatusOK)
+
+	box := &recordingMailer{}
+	slot := time.Now().UTC().Add(time.Hour + time.Minute)
+	digest.SendDue(box, slot)
+	if len(box.sent) != 1 {
+		t.Fatalf("sent %d digests", len(box.sent))
+	}
+
+	// Saving the settings again must not make the slot due once more
+	w = doJSON(r, http.MethodPut, "/users/digest", gin.H{"enabled": true, "frequency": "daily", "hour": hour, "timezone": "UTC"}, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var setting models.DigestSetting
+	decode(t, w, &setting)
+	if setting.LastSentAt == nil {
+		t.Fatal("last_sent_at was reset")
+	}
+	database.DB.Create(&models.Post{Title: "Later", Link: "https://example.com/2", Published: time.Now().UTC(), FeedId: feed.ID})
+	digest.SendDue(box, slot.Add(time.Minute))
+	if len(box.sent) != 1 {
+		t.Fatalf("slot sent %d times", len(box.sent))
+	}
+
+	var count int64
+	database.DB.Model(&models.DigestSetting{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("%d settings rows", count)
+	}
+}
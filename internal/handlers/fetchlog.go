@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/rss"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFeedFetches
+// @Summary      Fetch log of a feed
+// @Description  Recent fetch attempts, newest first. The latest 100 attempts of each feed are kept.
+// @Tags         feeds
+// @Produce      json
+// @Param        id     path   int  true   "Feed ID"
+// @Param        limit  query  int  false  "Limit"
+// @Success      200  {array}  models.FetchLog
+// @Failure      404  {object}  map[string]string
+// @Router       /feeds/{id}/fetches [get]
+func ListFeedFetches(c *gin.Context) {
+	var feed models.Feed
+	if err := database.DB.First(&feed, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	var fetches []models.FetchLog
+	if err := database.DB.Where("feed_id = ?", feed.ID).Order("id desc").Limit(limit).Find(&fetches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fetches)
+}
+
+// DiagnoseFeed
+// @Summary      Diagnose a feed
+// @Description  Fetches the feed now and reports the response headers, redirects, detected format, parse warnings and which items would be new. Nothing is stored.
+// @Tags         feeds
+// @Produce      json
+// @Param        id   path  int  true  "Feed ID"
+// @Success      200  {object}  rss.Diagnosis
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /feeds/{id}/diagnose [post]
+func DiagnoseFeed(c *gin.Context) {
+	var feed models.Feed
+	if err := database.DB.First(&feed, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		return
+	}
+	diagnosis, err := rss.Diagnose(feed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diagnosis)
+}
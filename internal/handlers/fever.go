@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/fever"
+	"blogAggregator/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeverPasswordInput struct {
+	Password string `json:"password"`
+}
+
+// SetFeverPassword
+// @Summary      Set Fever API password
+// @Description  Enables the Fever API for the caller. Fever clients sign in with the username and this password, which is kept apart from the account password because Fever sends an unsalted MD5 of it.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body  FeverPasswordInput  true  "Fever password"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Router       /users/fever [put]
+func SetFeverPassword(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("User_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	key := fever.APIKey(user.Username, input.Password)
+	if err := database.DB.Model(&user).Update("fever_api_key", key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "fever password set", "username": user.Username})
+}
+
+// DeleteFeverPassword
+// @Summary      Disable Fever API
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /users/fever [delete]
+func DeleteFeverPassword(c *gin.Context) {
+	if err := database.DB.Model(&models.User{}).Where("id = ?", c.GetUint("User_id")).Update("fever_api_key", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "fever api disabled"})
+}
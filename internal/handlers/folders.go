@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FolderInput struct {
+	Name string `json:"name"`
+}
+
+type MoveSubscriptionInput struct {
+	FeedID   uint  `json:"feed_id"`
+	FolderID *uint `json:"folder_id"`
+}
+
+// CreateFolder
+// @Summary      Create folder
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        input  body  FolderInput  true  "Folder"
+// @Success      201  {object}  models.Folder
+// @Failure      400  {object}  map[string]string
+// @Router       /folders [post]
+func CreateFolder(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	folder := models.Folder{UserID: c.GetUint("User_id"), Name: input.Name}
+	if err := database.DB.Create(&folder).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "folder already exists"})
+		return
+	}
+	c.JSON(http.StatusCreated, folder)
+}
+
+// ListFolders
+// @Summary      List folders
+// @Tags         folders
+// @Produce      json
+// @Success      200  {array}  models.Folder
+// @Router       /folders [get]
+func ListFolders(c *gin.Context) {
+	var folders []models.Folder
+	if err := database.DB.Where("user_id = ?", c.GetUint("User_id")).Order("name").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+// DeleteFolder
+// @Summary      Delete folder
+// @Description  Subscriptions in the folder are kept and become unfiled
+// @Tags         folders
+// @Produce      json
+// @Param        id   path  int  true  "Folder ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /folders/{id} [delete]
+func DeleteFolder(c *gin.Context) {
+	var folder models.Folder
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("User_id")).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Subscription{}).Where("folder_id = ?", folder.ID).Update("folder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "folder deleted"})
+}
+
+// MoveSubscription
+// @Summary      Move subscription to a folder
+// @Description  A null folder_id removes the subscription from its folder
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input body MoveSubscriptionInput true "Subscription"
+// @Success      200 {object} models.Subscription
+// @Failure      404 {object} map[string]string
+// @Router       /subscriptions [put]
+func MoveSubscription(c *gin.Context) {
+	var input struct {
+		FeedID   uint  `json:"feed_id" binding:"required"`
+		FolderID *uint `json:"folder_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userId := c.GetUint("User_id")
+
+	if input.FolderID != nil && !ownsFolder(userId, *input.FolderID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+		return
+	}
+
+	var sub models.Subscription
+	if err := database.DB.Where("user_id = ? AND feed_id = ?", userId, input.FeedID).First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err := database.DB.Model(&sub).Update("folder_id", input.FolderID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sub.FolderID = input.FolderID
+	c.JSON(http.StatusOK, sub)
+}
+
+func ownsFolder(userId, folderId uint) bool {
+	var count int64
+	database.DB.Model(&models.Folder{}).Where("id = ? AND user_id = ?", folderId, userId).Count(&count)
+	return count > 0
+}
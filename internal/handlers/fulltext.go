@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/rss"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+type FullContentSettingInput struct {
+	FeedID           uint `json:"feed_id"`
+	FetchFullContent bool `json:"fetch_full_content"`
+}
+
+type FullContentResponse struct {
+	PostID      uint       `json:"post_id"`
+	FullContent string     `json:"full_content"`
+	FetchedAt   *time.Time `json:"fetched_at"`
+}
+
+type ExtractionRuleInput struct {
+	Host            string   `json:"host"`
+	ContentSelector string   `json:"content_selector"`
+	RemoveSelectors []string `json:"remove_selectors"`
+}
+
+// UpdateFullContentSetting
+// @Summary      Toggle full-article extraction for a subscription
+// @Description  New posts of the feed get their linked page fetched and the main article stored in full_content
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input body FullContentSettingInput true "Setting"
+// @Success      200 {object} models.Subscription
+// @Failure      404 {object} map[string]string
+// @Router       /subscriptions/full-content [put]
+func UpdateFullContentSetting(c *gin.Context) {
+	var input struct {
+		FeedID           uint  `json:"feed_id" binding:"required"`
+		FetchFullContent *bool `json:"fetch_full_content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var sub models.Subscription
+	if err := database.DB.Where("user_id = ? AND feed_id = ?", c.GetUint("User_id"), input.FeedID).First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err := database.DB.Model(&sub).Update("fetch_full_content", *input.FetchFullContent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sub.FetchFullContent = *input.FetchFullContent
+	c.JSON(http.StatusOK, sub)
+}
+
+// FetchPostFullContent
+// @Summary      Fetch the full article of a post
+// @Description  Returns the stored article, extracting it from the post's link first when there is none yet or refresh is set
+// @Tags         posts
+// @Produce      json
+// @Param        id       path   int   true   "Post ID"
+// @Param        refresh  query  bool  false  "Extract again even if an article is stored"
+// @Success      200  {object}  FullContentResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      502  {object}  map[string]string
+// @Router       /posts/{id}/full-content [post]
+func FetchPostFullContent(c *gin.Context) {
+	var post models.Post
+	if err := database.DB.First(&post, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+	if post.FullContent == "" || c.Query("refresh") == "true" {
+		if err := rss.FetchFullContent(&post, c.Query("refresh") == "true"); err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, rss.ErrNoArticle) {
+				status = http.StatusUnprocessableEntity
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, FullContentResponse{
+		PostID:      post.ID,
+		FullContent: imageproxy.RewritePost(post).FullContent,
+		FetchedAt:   post.FullContentFetchedAt,
+	})
+}
+
+// SaveExtractionRule
+// @Summary      Create or replace a site extraction rule
+// @Description  Pages on the host are extracted with content_selector instead of the readability heuristic. Rules are shared by all users, so only administrators may change them; a www. host also matches the bare domain rule.
+// @Tags         posts
+// @Accept       json
+// @Produce      json
+// @Param        input body ExtractionRuleInput true "Rule"
+// @Success      200 {object} models.ExtractionRule
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Router       /extraction-rules [post]
+func SaveExtractionRule(c *gin.Context) {
+	var input struct {
+		Host            string   `json:"host" binding:"required"`
+		ContentSelector string   `json:"content_selector" binding:"required"`
+		RemoveSelectors []string `json:"remove_selectors"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := models.ExtractionRule{
+		Host:            strings.ToLower(strings.TrimSpace(input.Host)),
+		ContentSelector: input.ContentSelector,
+		RemoveSelectors: input.RemoveSelectors,
+	}
+	if err := rss.ValidateExtractionRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "host"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content_selector", "remove_selectors", "updated_at"}),
+	}).Create(&rule).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	database.DB.Where("host = ?", rule.Host).First(&rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+// ListExtractionRules
+// @Summary      List site extraction rules
+// @Tags         posts
+// @Produce      json
+// @Success      200  {array}  models.ExtractionRule
+// @Router       /extraction-rules [get]
+func ListExtractionRules(c *gin.Context) {
+	var rules []models.ExtractionRule
+	if err := database.DB.Order("host").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// DeleteExtractionRule
+// @Summary      Delete a site extraction rule
+// @Description  Only administrators may delete rules.
+// @Tags         posts
+// @Produce      json
+// @Param        id   path  int  true  "Rule ID"
+// @Success      200  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /extraction-rules/{id} [delete]
+func DeleteExtractionRule(c *gin.Context) {
+	result := database.DB.Where("id = ?", c.Param("id")).Delete(&models.ExtractionRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "extraction rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "extraction rule deleted"})
+}
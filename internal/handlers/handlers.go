@@ -3,7 +3,9 @@ package handlers
 import (
 	"blogAggregator/internal/auth"
 	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
 	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
 	"blogAggregator/internal/rss"
 	"net/http"
 	"strconv"
@@ -21,6 +23,17 @@ type RegisterInput struct {
 type FeedCreateInput struct {
     Title string `json:"title"`
     URL   string `json:"url"`
+    // "rss" (default), "scrape", "json", "github", "reddit" or "hackernews"
+    SourceType   string               `json:"source_type"`
+    SourceConfig *models.SourceConfig `json:"source_config"`
+    // Sent with every fetch of a json feed, e.g. Authorization
+    SourceHeaders map[string]string `json:"source_headers"`
+    // Extract the full article behind each new item's link
+    FetchFullContent bool `json:"fetch_full_content"`
+}
+
+type FeedPreviewResponse struct {
+    Items []models.Post `json:"items"`
 }
 
 type RefreshFeedInput struct {
@@ -34,6 +47,8 @@ type CreateUserInput struct {
 type SubscribeInput struct {
     UserID uint `json:"user_id"`
     FeedID uint `json:"feed_id"`
+    FolderID *uint `json:"folder_id"`
+    FetchFullContent bool `json:"fetch_full_content"`
 }
 
 type LoginInput struct {
@@ -105,8 +120,12 @@ func RegisterUser(c *gin.Context) {
 // @Router       /feeds [post]
 func CreateFeed(c *gin.Context) {
 	var input struct {
-		Title string `json:"title" binding:"required"`
-		URL   string `json:"url" binding:"required"`
+		Title        string               `json:"title" binding:"required"`
+		URL          string               `json:"url" binding:"required"`
+		SourceType    string               `json:"source_type"`
+		SourceConfig  *models.SourceConfig `json:"source_config"`
+		SourceHeaders map[string]string    `json:"source_headers"`
+		FetchFullContent bool              `json:"fetch_full_content"`
 	}
 	err := c.ShouldBindJSON(&input)
 	if err != nil {
@@ -115,7 +134,16 @@ func CreateFeed(c *gin.Context) {
 		})
 		return
 	}
-	feed := models.Feed{Title: input.Title, URL: input.URL}
+	feed := models.Feed{Title: input.Title, URL: input.URL, SourceType: input.SourceType, SourceConfig: input.SourceConfig, SourceHeaders: input.SourceHeaders, FetchFullContent: input.FetchFullContent}
+	if feed.SourceType == "" {
+		feed.SourceType = models.FeedSourceRSS
+	}
+	if err := rss.ValidateSource(feed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	err = database.DB.Create(&feed).Error
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -126,6 +154,44 @@ func CreateFeed(c *gin.Context) {
 	c.JSON(http.StatusCreated, feed)
 }
 
+// PreviewFeed
+// @Summary      Preview a feed
+// @Description  Fetches a feed definition and returns the items it would produce, without saving anything. Useful to try out scrape selectors and JSONPath mappings.
+// @Tags         feeds
+// @Accept       json
+// @Produce      json
+// @Param        input  body  FeedCreateInput  true  "Feed definition; title is optional"
+// @Success      200    {object}  FeedPreviewResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      502    {object}  map[string]string
+// @Router       /feeds/preview [post]
+func PreviewFeed(c *gin.Context) {
+	var input struct {
+		URL          string               `json:"url" binding:"required"`
+		SourceType    string               `json:"source_type"`
+		SourceConfig  *models.SourceConfig `json:"source_config"`
+		SourceHeaders map[string]string    `json:"source_headers"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	feed := models.Feed{URL: input.URL, SourceType: input.SourceType, SourceConfig: input.SourceConfig, SourceHeaders: input.SourceHeaders}
+	if err := rss.ValidateSource(feed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	posts, err := rss.FetchPosts(feed)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if posts == nil {
+		posts = []models.Post{}
+	}
+	c.JSON(http.StatusOK, FeedPreviewResponse{Items: posts})
+}
+
 //list feed
 
 // ListFeeds
@@ -136,7 +202,8 @@ func CreateFeed(c *gin.Context) {
 // @Router       /feeds [get]
 func ListFeeds(c *gin.Context) {
 	var feeds []models.Feed
-	err := database.DB.Find(&feeds).Error
+	// Newsletter feeds hold one user's mail and are never listed here
+	err := database.DB.Where("id NOT IN (?)", newsletter.HiddenFeedIDs(0)).Find(&feeds).Error
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -191,10 +258,20 @@ func RefreshFeed(c *gin.Context) {
 // @Router       /posts [get]
 func ListPosts(c *gin.Context) {
 	var posts []models.Post
-	database.DB.Order("published desc").Limit(20).Find(&posts)
+	database.DB.Where("feed_id NOT IN (?)", newsletter.HiddenFeedIDs(0)).
+		Order("published desc").Limit(20).Find(&posts)
+	proxyImages(posts)
 	c.JSON(http.StatusOK, posts)
 }
 
+// proxyImages points images in post content at the image proxy, when it
+// is enabled, so readers don't load them from third-party hosts
+func proxyImages(posts []models.Post) {
+	for i := range posts {
+		posts[i] = imageproxy.RewritePost(posts[i])
+	}
+}
+
 // CreateUser
 // @Summary      Create user (internal/demo)
 // @Tags         users
@@ -231,11 +308,14 @@ func CreateUser(c *gin.Context) {
 // @Param        input body SubscribeInput true "Subscription"
 // @Success      201 {object} models.Subscription
 // @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
 // @Router       /subscriptions [post]
 func SubscribeFeed(c *gin.Context) {
 	var input struct {
 		UserID uint `json:"user_id" binding:"required"`
 		FeedID uint `json:"feed_id" binding:"required"`
+		FolderID *uint `json:"folder_id"`
+		FetchFullContent bool `json:"fetch_full_content"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -243,9 +323,26 @@ func SubscribeFeed(c *gin.Context) {
 		})
 		return
 	}
+	// Other users' newsletter feeds look like they do not exist
+	var feed models.Feed
+	err := database.DB.First(&feed, input.FeedID).Error
+	if err != nil || !feed.VisibleTo(c.GetUint("User_id")) || !feed.VisibleTo(input.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "feed not found",
+		})
+		return
+	}
+	if input.FolderID != nil && !ownsFolder(input.UserID, *input.FolderID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "folder not found",
+		})
+		return
+	}
 	sub := models.Subscription{
 		UserID: input.UserID,
 		FeedID: input.FeedID,
+		FolderID: input.FolderID,
+		FetchFullContent: input.FetchFullContent,
 	}
 
 	if err := database.DB.Create(&sub).Error; err != nil {
@@ -316,6 +413,7 @@ func GetUserFeed(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	 }
+	proxyImages(posts)
 
 	c.JSON(http.StatusOK, gin.H{
 		"page":  page,
@@ -326,6 +424,7 @@ func GetUserFeed(c *gin.Context) {
 
 // Login
 // @Summary      User login
+// @Description  Returns a session token, or a challenge token for /login/2fa when two-factor authentication is enabled
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -355,6 +454,32 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	completeLogin(c, user)
+}
+
+// completeLogin answers a successful first-factor login: with 2FA enabled
+// it only hands out a challenge for /login/2fa, otherwise a session token.
+func completeLogin(c *gin.Context, user models.User) {
+	if user.TOTPEnabled {
+		challenge, id, err := auth.GenerateChallengeToken(user.ID)
+		if err == nil {
+			err = createLoginChallenge(user.ID, id)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+	sessionResponse(c, user)
+}
+
+// sessionResponse issues a session token for an authenticated user
+func sessionResponse(c *gin.Context, user models.User) {
 	token, err := auth.GenerateToken(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
@@ -370,3 +495,15 @@ func Login(c *gin.Context) {
 		},
 	})
 }
+
+// GetJWKS
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying tokens issued by this server
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  auth.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.JWKS())
+}
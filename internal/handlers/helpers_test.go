@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns an empty router with auth initialised. Routes
+// registered behind asUser take the signed in user from X-User-ID.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	return gin.New()
+}
+
+// asUser stands in for AuthMiddleware
+func asUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.GetHeader("X-User-ID"))
+	c.Set("User_id", uint(id))
+	c.Next()
+}
+
+// doJSON sends body as JSON, as userID when it is not 0
+func doJSON(r *gin.Engine, method, target string, body interface{}, userID uint) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.Header.Set("X-User-ID", strconv.Itoa(int(userID)))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a response body or fails the test
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("response %d %s: %v", w.Code, w.Body, err)
+	}
+}
+
+func requireStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d, want %d: %s", w.Code, status, w.Body)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NewsletterAddressResponse struct {
+	Address string `json:"address"`
+}
+
+// GetNewsletterAddress
+// @Summary      Get newsletter address
+// @Description  Returns the caller's address for email newsletters. Mail sent to it becomes posts on a feed of the caller's per sender, which the caller is subscribed to automatically.
+// @Tags         subscriptions
+// @Produce      json
+// @Success      200  {object}  NewsletterAddressResponse
+// @Failure      404  {object}  map[string]string
+// @Router       /users/newsletter [get]
+func GetNewsletterAddress(c *gin.Context) {
+	if !newsletter.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "newsletter ingestion is disabled"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("User_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.NewsletterToken == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no newsletter address yet"})
+		return
+	}
+	c.JSON(http.StatusOK, NewsletterAddressResponse{Address: newsletter.Address(*user.NewsletterToken)})
+}
+
+// CreateNewsletterAddress
+// @Summary      Create newsletter address
+// @Description  Generates a new newsletter address for the caller. Any previous address stops accepting mail.
+// @Tags         subscriptions
+// @Produce      json
+// @Success      201  {object}  NewsletterAddressResponse
+// @Failure      404  {object}  map[string]string
+// @Router       /users/newsletter [post]
+func CreateNewsletterAddress(c *gin.Context) {
+	if !newsletter.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "newsletter ingestion is disabled"})
+		return
+	}
+	token, err := newsletter.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", c.GetUint("User_id")).Update("newsletter_token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, NewsletterAddressResponse{Address: newsletter.Address(token)})
+}
+
+// DeleteNewsletterAddress
+// @Summary      Delete newsletter address
+// @Description  Mail to the old address is rejected; feeds and posts already received are kept.
+// @Tags         subscriptions
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /users/newsletter [delete]
+func DeleteNewsletterAddress(c *gin.Context) {
+	if err := database.DB.Model(&models.User{}).Where("id = ?", c.GetUint("User_id")).Update("newsletter_token", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "newsletter address deleted"})
+}
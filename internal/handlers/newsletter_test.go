@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newsletterFixture stores a shared feed and a newsletter feed of the
+// recipient, each with one post
+func newsletterFixture(t *testing.T, recipient uint) (shared, newsletter models.Feed) {
+	t.Helper()
+	shared = models.Feed{Title: "Blog", URL: "https://example.com/feed"}
+	newsletter = models.Feed{
+		Title:      "Digest",
+		URL:        "mailto:digest@example.com?recipient=" + strconv.Itoa(int(recipient)),
+		SourceType: models.FeedSourceNewsletter,
+	}
+	for _, feed := range []*models.Feed{&shared, &newsletter} {
+		if err := database.DB.Create(feed).Error; err != nil {
+			t.Fatal(err)
+		}
+		post := models.Post{Title: feed.Title, Link: feed.URL + "#1", FeedId: feed.ID, Published: time.Now()}
+		if err := database.DB.Create(&post).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return shared, newsletter
+}
+
+func TestNewsletterFeedsAreNotListed(t *testing.T) {
+	testdb.Open(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&alice)
+	shared, _ := newsletterFixture(t, alice.ID)
+	r := newTestRouter(t)
+	r.GET("/feeds", ListFeeds)
+	r.GET("/posts", ListPosts)
+
+	w := doJSON(r, http.MethodGet, "/feeds", nil, 0)
+	requireStatus(t, w, http.StatusOK)
+	var feeds []models.Feed
+	decode(t, w, &feeds)
+	if len(feeds) != 1 || feeds[0].ID != shared.ID {
+		t.Fatalf("feeds = %+v", feeds)
+	}
+
+	w = doJSON(r, http.MethodGet, "/posts", nil, 0)
+	requireStatus(t, w, http.StatusOK)
+	var posts []models.Post
+	decode(t, w, &posts)
+	if len(posts) != 1 || posts[0].FeedId != shared.ID {
+		t.Fatalf("posts = %+v", posts)
+	}
+}
+
+func TestSubscribeToNewsletterFeed(t *testing.T) {
+	testdb.Open(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	_, newsletter := newsletterFixture(t, alice.ID)
+	r := newTestRouter(t)
+	r.POST("/subscriptions", asUser, SubscribeFeed)
+
+	tests := []struct {
+		name   string
+		caller uint
+		userID uint
+		status int
+	}{
+		{"other user", bob.ID, bob.ID, http.StatusNotFound},
+		{"recipient subscribing another user", alice.ID, bob.ID, http.StatusNotFound},
+		{"recipient", alice.ID, alice.ID, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := doJSON(r, http.MethodPost, "/subscriptions", gin.H{"user_id": tt.userID, "feed_id": newsletter.ID}, tt.caller)
+		if w.Code != tt.status {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+	var count int64
+	database.DB.Model(&models.Subscription{}).Where("feed_id = ? AND user_id = ?", newsletter.ID, bob.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("bob has %d subscriptions to alice's newsletter", count)
+	}
+}
+
+func TestWebhookRejectsOtherUsersNewsletter(t *testing.T) {
+	testdb.Open(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	shared, newsletter := newsletterFixture(t, alice.ID)
+	rec := newReceiver(t)
+	r := newWebhookRouter(t)
+
+	w := doJSON(r, http.MethodPost, "/webhooks", gin.H{"url": rec.URL, "feed_ids": []uint{newsletter.ID}}, bob.ID)
+	requireStatus(t, w, http.StatusBadRequest)
+
+	hook := createWebhook(t, r, bob.ID, gin.H{"url": rec.URL, "feed_ids": []uint{shared.ID}})
+	target := "/webhooks/" + strconv.Itoa(int(hook.ID))
+	w = doJSON(r, http.MethodPut, target, gin.H{"url": rec.URL, "feed_ids": []uint{shared.ID, newsletter.ID}}, bob.ID)
+	requireStatus(t, w, http.StatusBadRequest)
+	var stored models.Webhook
+	database.DB.First(&stored, hook.ID)
+	if len(stored.FeedIDs) != 1 || stored.FeedIDs[0] != shared.ID {
+		t.Fatalf("feed_ids after a refused update = %v", stored.FeedIDs)
+	}
+
+	// The recipient may watch their own newsletter
+	createWebhook(t, r, alice.ID, gin.H{"url": rec.URL, "feed_ids": []uint{newsletter.ID}})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/oidc"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcFlowCookie carries state, nonce and PKCE verifier between the login
+// redirect and the callback, so no server-side session store is needed.
+// When linking, a signed link token naming the signed in user follows.
+const oidcFlowCookie = "oidc_flow"
+
+var (
+	oidcProvider *oidc.Provider
+	// oidcLinkByEmail lets a first SSO login take over the local account
+	// with the same verified email
+	oidcLinkByEmail bool
+)
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+var (
+	errOIDCAccountConflict  = errors.New("account is already linked to a different identity")
+	errOIDCEmailNotVerified = errors.New("identity provider did not return a verified email")
+	errOIDCAccountExists    = errors.New("an account with this email already exists; sign in with its password and link single sign-on from it")
+)
+
+// ConfigureOIDC enables single sign-on through the given provider. With
+// linkByEmail, a new identity is linked to the existing account with the
+// same verified email; otherwise users have to link from their account.
+func ConfigureOIDC(p *oidc.Provider, linkByEmail bool) {
+	oidcProvider = p
+	oidcLinkByEmail = linkByEmail
+}
+
+// OIDCLogin
+// @Summary      Start single sign-on
+// @Description  Redirects to the configured OpenID Connect provider (authorization-code flow with PKCE)
+// @Tags         auth
+// @Success      302
+// @Failure      404  {object}  map[string]string
+// @Router       /auth/oidc/login [get]
+func OIDCLogin(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+		return
+	}
+	if authURL, ok := startOIDCFlow(c, ""); ok {
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// LinkOIDC
+// @Summary      Link single sign-on to the account
+// @Description  Returns the identity provider URL to open in the browser. Its callback links the identity to the signed in user instead of logging in.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /users/oidc/link [post]
+func LinkOIDC(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+		return
+	}
+	link, err := auth.GenerateOIDCLinkToken(c.GetUint("User_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	if authURL, ok := startOIDCFlow(c, link); ok {
+		c.JSON(http.StatusOK, gin.H{"url": authURL})
+	}
+}
+
+// startOIDCFlow sets the flow cookie and returns the authorization URL. It
+// answers the request itself when that fails.
+func startOIDCFlow(c *gin.Context, link string) (string, bool) {
+	state, err := oidc.RandomString(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	authURL, err := oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	flow := state + "." + nonce + "." + verifier
+	if link != "" {
+		flow += "." + link
+	}
+	secure := strings.HasPrefix(oidcProvider.RedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, flow, 600, "/", "", secure, true)
+	return authURL, true
+}
+
+// OIDCCallback
+// @Summary      Complete single sign-on
+// @Description  Exchanges the authorization code, verifies the ID token and returns a session token like /login, or links the identity when the flow was started by /users/oidc/link
+// @Tags         auth
+// @Produce      json
+// @Param        code   query  string  true  "Authorization code"
+// @Param        state  query  string  true  "State"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /auth/oidc/callback [get]
+func OIDCCallback(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+		return
+	}
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "identity provider returned: " + errParam})
+		return
+	}
+
+	flow, err := c.Cookie(oidcFlowCookie)
+	c.SetCookie(oidcFlowCookie, "", -1, "/", "", false, true)
+	// The link token is a JWT and contains dots itself
+	parts := strings.SplitN(flow, ".", 4)
+	if err != nil || len(parts) < 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing or expired login flow"})
+		return
+	}
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+	var linkUserID uint
+	if len(parts) == 4 {
+		if linkUserID, err = auth.ParseOIDCLinkToken(parts[3]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing or expired login flow"})
+			return
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state mismatch"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokens, err := oidcProvider.Exchange(ctx, c.Query("code"), verifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	claims, err := oidcProvider.VerifyIDToken(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if linkUserID != 0 {
+		if err := linkOIDCIdentity(linkUserID, claims); err != nil {
+			c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "single sign-on linked"})
+		return
+	}
+
+	user, err := findOrProvisionOIDCUser(claims)
+	if err != nil {
+		c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	completeLogin(c, *user)
+}
+
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errOIDCAccountConflict), errors.Is(err, errOIDCEmailNotVerified):
+		return http.StatusForbidden
+	case errors.Is(err, errOIDCAccountExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// linkOIDCIdentity links an identity to a signed in user, unless either is
+// already linked elsewhere
+func linkOIDCIdentity(userID uint, claims *oidc.IDTokenClaims) error {
+	var linked models.User
+	err := database.DB.Where("oidc_issuer = ? AND oidc_subject = ?", claims.Issuer, claims.Subject).First(&linked).Error
+	if err == nil {
+		if linked.ID == userID {
+			return nil
+		}
+		return errOIDCAccountConflict
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.OIDCSubject != nil {
+		return errOIDCAccountConflict
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"oidc_issuer":  claims.Issuer,
+		"oidc_subject": claims.Subject,
+	}).Error; err != nil {
+		return err
+	}
+	log.Printf("linked user %d to %s identity %s", user.ID, claims.Issuer, claims.Subject)
+	return nil
+}
+
+// findOrProvisionOIDCUser resolves the local account for an identity: an
+// already linked user, a newly provisioned user or, with oidcLinkByEmail,
+// an existing user with the same verified email, which gets linked.
+func findOrProvisionOIDCUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	var user models.User
+	err := database.DB.Where("oidc_issuer = ? AND oidc_subject = ?", claims.Issuer, claims.Subject).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errOIDCEmailNotVerified
+	}
+
+	err = database.DB.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+	if err == nil {
+		if !oidcLinkByEmail {
+			return nil, errOIDCAccountExists
+		}
+		if user.OIDCSubject != nil {
+			return nil, errOIDCAccountConflict
+		}
+		if err := database.DB.Model(&user).Updates(map[string]interface{}{
+			"oidc_issuer":  claims.Issuer,
+			"oidc_subject": claims.Subject,
+		}).Error; err != nil {
+			return nil, err
+		}
+		log.Printf("linked user %d to %s identity %s", user.ID, claims.Issuer, claims.Subject)
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	username, err := availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	user = models.User{
+		Username:    username,
+		Email:       claims.Email,
+		OIDCIssuer:  &claims.Issuer,
+		OIDCSubject: &claims.Subject,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("provisioned user %d for %s identity %s", user.ID, claims.Issuer, claims.Subject)
+	return &user, nil
+}
+
+// availableUsername derives a username from the identity claims, adding a
+// numeric suffix when it is already taken.
+func availableUsername(claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := database.DB.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("could not find a free username")
+}
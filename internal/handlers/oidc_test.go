@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/oidc"
+	"blogAggregator/internal/oidc/oidctest"
+	"blogAggregator/internal/testdb"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testRedirectURL = "http://app.test/auth/oidc/callback"
+
+// newOIDCTest configures single sign-on against a test provider and
+// returns a router with the SSO routes. The link route takes the signed in
+// user from the X-User-ID header.
+func newOIDCTest(t *testing.T, linkByEmail bool) (*oidctest.Server, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	idp := oidctest.NewServer(t)
+	p := oidc.NewProvider(idp.URL, oidctest.ClientID, oidctest.ClientSecret, testRedirectURL, nil)
+	p.Client = idp.Client()
+	ConfigureOIDC(p, linkByEmail)
+	t.Cleanup(func() { ConfigureOIDC(nil, false) })
+
+	r := gin.New()
+	r.GET("/auth/oidc/login", OIDCLogin)
+	r.GET("/auth/oidc/callback", OIDCCallback)
+	r.POST("/users/oidc/link", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.GetHeader("X-User-ID"))
+		c.Set("User_id", uint(id))
+		LinkOIDC(c)
+	})
+	return idp, r
+}
+
+// oidcFlow is a login in progress: the flow cookie and the callback URL
+// the provider redirected the browser to
+type oidcFlow struct {
+	cookie   *http.Cookie
+	callback *url.URL
+}
+
+// startOIDC begins a login, or a link when userID is set, and goes through
+// the provider
+func startOIDC(t *testing.T, idp *oidctest.Server, r *gin.Engine, userID uint) oidcFlow {
+	t.Helper()
+	w := httptest.NewRecorder()
+	var authURL string
+	if userID == 0 {
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("login returned %d: %s", w.Code, w.Body)
+		}
+		authURL = w.Header().Get("Location")
+	} else {
+		req := httptest.NewRequest(http.MethodPost, "/users/oidc/link", nil)
+		req.Header.Set("X-User-ID", strconv.Itoa(int(userID)))
+		r.ServeHTTP(w, req)
+		var body struct{ URL string }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+			t.Fatalf("link returned %d: %s", w.Code, w.Body)
+		}
+		authURL = body.URL
+	}
+
+	var flow oidcFlow
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcFlowCookie {
+			flow.cookie = cookie
+		}
+	}
+	if flow.cookie == nil {
+		t.Fatal("no flow cookie set")
+	}
+	flow.callback = idp.Authorize(t, authURL)
+	return flow
+}
+
+// finish calls the callback with the flow cookie
+func (f oidcFlow) finish(r *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, f.callback.RequestURI(), nil)
+	if f.cookie != nil {
+		req.AddCookie(f.cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// setFlowPart replaces one of state, nonce and verifier in the flow cookie
+func (f *oidcFlow) setFlowPart(t *testing.T, i int, value string) {
+	t.Helper()
+	raw, err := url.QueryUnescape(f.cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(raw, ".", 4)
+	parts[i] = value
+	f.cookie.Value = url.QueryEscape(strings.Join(parts, "."))
+}
+
+func TestOIDCCallbackRejectsTamperedFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, f *oidcFlow)
+		status int
+	}{
+		{
+			name:   "missing cookie",
+			tamper: func(t *testing.T, f *oidcFlow) { f.cookie = nil },
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "state mismatch",
+			tamper: func(t *testing.T, f *oidcFlow) {
+				q := f.callback.Query()
+				q.Set("state", "forged")
+				f.callback.RawQuery = q.Encode()
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "nonce mismatch",
+			tamper: func(t *testing.T, f *oidcFlow) { f.setFlowPart(t, 1, "forged") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong PKCE verifier",
+			tamper: func(t *testing.T, f *oidcFlow) { f.setFlowPart(t, 2, "forged") },
+			status: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp, r := newOIDCTest(t, false)
+			flow := startOIDC(t, idp, r, 0)
+			tt.tamper(t, &flow)
+			if w := flow.finish(r); w.Code != tt.status {
+				t.Fatalf("callback returned %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidSignature(t *testing.T) {
+	idp, r := newOIDCTest(t, false)
+	idp.SigningKey = oidctest.NewKey(t)
+	if w := startOIDC(t, idp, r, 0).finish(r); w.Code != http.StatusUnauthorized {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestOIDCProvisionsAndFindsUser(t *testing.T) {
+	testdb.Open(t)
+	idp, r := newOIDCTest(t, false)
+	idp.Claims["preferred_username"] = "alice"
+
+	for i := 0; i < 2; i++ {
+		if w := startOIDC(t, idp, r, 0).finish(r); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"token"`) {
+			t.Fatalf("login %d returned %d: %s", i, w.Code, w.Body)
+		}
+	}
+	var users []models.User
+	database.DB.Find(&users)
+	if len(users) != 1 || users[0].Username != "alice" || *users[0].OIDCSubject != "user-1" {
+		t.Fatalf("users = %+v", users)
+	}
+}
+
+func TestOIDCDoesNotTakeOverAccountByEmail(t *testing.T) {
+	testdb.Open(t)
+	idp, r := newOIDCTest(t, false)
+	local := models.User{Username: "bob", Email: "User@Example.com", Password: "hash"}
+	database.DB.Create(&local)
+
+	if w := startOIDC(t, idp, r, 0).finish(r); w.Code != http.StatusConflict {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body)
+	}
+	database.DB.First(&local, local.ID)
+	if local.OIDCSubject != nil {
+		t.Fatal("identity was linked to the local account")
+	}
+}
+
+func TestOIDCLinksByEmailWhenEnabled(t *testing.T) {
+	testdb.Open(t)
+	idp, r := newOIDCTest(t, true)
+	local := models.User{Username: "bob", Email: "user@example.com", Password: "hash"}
+	database.DB.Create(&local)
+
+	if w := startOIDC(t, idp, r, 0).finish(r); w.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body)
+	}
+	database.DB.First(&local, local.ID)
+	if local.OIDCSubject == nil || *local.OIDCSubject != "user-1" {
+		t.Fatal("identity was not linked")
+	}
+}
+
+func TestOIDCLinkFromAccount(t *testing.T) {
+	testdb.Open(t)
+	idp, r := newOIDCTest(t, false)
+	idp.Claims["email"] = "other@example.com"
+	local := models.User{Username: "bob", Email: "bob@example.com", Password: "hash"}
+	database.DB.Create(&local)
+
+	if w := startOIDC(t, idp, r, local.ID).finish(r); w.Code != http.StatusOK {
+		t.Fatalf("link callback returned %d: %s", w.Code, w.Body)
+	}
+	database.DB.First(&local, local.ID)
+	if local.OIDCSubject == nil || *local.OIDCSubject != "user-1" {
+		t.Fatal("identity was not linked")
+	}
+
+	// The linked identity now logs in to the account
+	w := startOIDC(t, idp, r, 0).finish(r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"bob"`) {
+		t.Fatalf("login returned %d: %s", w.Code, w.Body)
+	}
+
+	// and cannot be linked to a second account
+	other := models.User{Username: "carol", Email: "carol@example.com", Password: "hash"}
+	database.DB.Create(&other)
+	if w := startOIDC(t, idp, r, other.ID).finish(r); w.Code != http.StatusForbidden {
+		t.Fatalf("second link returned %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestOIDCLinkRejectsForgedLinkToken(t *testing.T) {
+	idp, r := newOIDCTest(t, false)
+	flow := startOIDC(t, idp, r, 0)
+	raw, _ := url.QueryUnescape(flow.cookie.Value)
+	// A session token is not a link token
+	session, _ := auth.GenerateToken(1)
+	flow.cookie.Value = url.QueryEscape(raw + "." + session)
+	if w := flow.finish(r); w.Code != http.StatusBadRequest {
+		t.Fatalf("callback returned %d: %s", w.Code, w.Body)
+	}
+}
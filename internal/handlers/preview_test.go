@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// servePage serves body with contentType at every path; the safe client
+// is allowed to reach it
+func servePage(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		safehttp.Configure(nil, nil)
+	})
+	return srv
+}
+
+func preview(t *testing.T, body gin.H) []models.Post {
+	t.Helper()
+	r := newTestRouter(t)
+	r.POST("/feeds/preview", asUser, PreviewFeed)
+	w := doJSON(r, http.MethodPost, "/feeds/preview", body, 1)
+	requireStatus(t, w, http.StatusOK)
+	var resp FeedPreviewResponse
+	decode(t, w, &resp)
+	return resp.Items
+}
+
+const scrapePage = `<!doctype html>
+<html><body>
+<article class="post">
+  <h2 class="title">  First
+    post </h2>
+  <a class="more" href="/posts/1">Read more</a>
+  <time datetime="2024-03-01T10:00:00Z">March 1st</time>
+  <div class="body"><p>Hello <img src="/img/1.png"></p><script>alert(1)</script></div>
+</article>
+<article class="post">
+  <h2 class="title">Second post</h2>
+  <a class="more" href="https://elsewhere.example.com/2">Read more</a>
+  <span class="date">02/01/2024</span>
+</article>
+<article class="post">
+  <h2 class="title">No link</h2>
+</article>
+<div class="post-like"><a href="/ignored">Not an item</a></div>
+</body></html>`
+
+func TestPreviewScrapeFeed(t *testing.T) {
+	srv := servePage(t, "text/html; charset=utf-8", scrapePage)
+	posts := preview(t, gin.H{
+		"url":         srv.URL + "/blog/",
+		"source_type": models.FeedSourceScrape,
+		"source_config": gin.H{"scrape": gin.H{
+			"item_selector":    "article.post",
+			"title_selector":   ".title",
+			"link_selector":    "a.more",
+			"date_selector":    "time, .date",
+			"content_selector": ".body",
+			"date_formats":     []string{"01/02/2006"},
+		}},
+	})
+	if len(posts) != 3 {
+		t.Fatalf("got %d items: %+v", len(posts), posts)
+	}
+
+	first := posts[0]
+	if first.Title != "First post" || first.Link != srv.URL+"/posts/1" ||
+		!first.Published.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("first item = %+v", first)
+	}
+	if !strings.Contains(first.Content, `src="`+srv.URL+`/img/1.png"`) || strings.Contains(first.Content, "<script") {
+		t.Fatalf("first content = %s", first.Content)
+	}
+
+	second := posts[1]
+	if second.Title != "Second post" || second.Link != "https://elsewhere.example.com/2" ||
+		!second.Published.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) || second.Content != "" {
+		t.Fatalf("second item = %+v", second)
+	}
+
+	// Items without a link get a stable one on the page and the preview time
+	third := posts[2]
+	if third.Title != "No link" || !strings.HasPrefix(third.Link, srv.URL+"/blog/#") || third.Published.IsZero() {
+		t.Fatalf("third item = %+v", third)
+	}
+}
+
+func TestPreviewScrapeFeedRejectsBadSelectors(t *testing.T) {
+	srv := servePage(t, "text/html", scrapePage)
+	r := newTestRouter(t)
+	r.POST("/feeds/preview", asUser, PreviewFeed)
+	tests := []struct {
+		config gin.H
+		err    string
+	}{
+		{gin.H{"scrape": gin.H{"title_selector": "h2"}}, "item_selector is required"},
+		{gin.H{"scrape": gin.H{"item_selector": "article["}}, "invalid item_selector"},
+		{gin.H{"scrape": gin.H{"item_selector": "article", "date_selector": ">>"}}, "invalid date_selector"},
+		{gin.H{}, "source_config.scrape is required"},
+	}
+	for _, tt := range tests {
+		w := doJSON(r, http.MethodPost, "/feeds/preview", gin.H{
+			"url":           srv.URL,
+			"source_type":   models.FeedSourceScrape,
+			"source_config": tt.config,
+		}, 1)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.err) {
+			t.Errorf("%v: got %d %s, want %q", tt.config, w.Code, w.Body, tt.err)
+		}
+	}
+}
+
+const jsonDocument = `{"data": {"releases": [
+  {"id": 101, "name": "  Version\n 1.0 ", "url": "/releases/1.0", "date": "2024-03-01T10:00:00Z",
+   "notes": "<p>Fixes <a href=\"/issues/7\">#7</a></p><script>alert(1)</script>"},
+  {"id": "r2", "name": "Version 1.1", "url": "https://elsewhere.example.com/1.1", "date": 1709287200,
+   "notes": "Plain text & more\n\nSecond paragraph"},
+  {"id": 103, "name": "Version 1.2", "date": 1709287200000},
+  {"name": "Version 1.3", "date": "01.03.2024"},
+  {"other": true}
+]}}`
+
+func TestPreviewJSONFeed(t *testing.T) {
+	srv := servePage(t, "application/json", jsonDocument)
+	posts := preview(t, gin.H{
+		"url":         srv.URL + "/api/releases",
+		"source_type": models.FeedSourceJSON,
+		"source_config": gin.H{"json": gin.H{
+			"items_path":   "$.data.releases[*]",
+			"title_path":   "$.name",
+			"link_path":    "$.url",
+			"id_path":      "$.id",
+			"date_path":    "$.date",
+			"body_path":    "$.notes",
+			"date_formats": []string{"02.01.2006"},
+		}},
+	})
+	// The item without title, id or link is skipped
+	if len(posts) != 4 {
+		t.Fatalf("got %d items: %+v", len(posts), posts)
+	}
+	march1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		title, link, guid string
+		published         time.Time
+	}{
+		{"Version 1.0", srv.URL + "/releases/1.0", "101", march1},
+		{"Version 1.1", "https://elsewhere.example.com/1.1", "r2", march1},
+		// Numbers too large for seconds are milliseconds; items without a
+		// link get one from their id
+		{"Version 1.2", srv.URL + "/api/releases#103", "103", march1},
+		{"Version 1.3", "", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for i, tt := range tests {
+		post := posts[i]
+		if post.Title != tt.title || post.GUID != tt.guid || !post.Published.Equal(tt.published) {
+			t.Errorf("item %d = %+v", i, post)
+		}
+		if tt.link != "" && post.Link != tt.link {
+			t.Errorf("item %d link = %s, want %s", i, post.Link, tt.link)
+		}
+	}
+	// Without an id the link is derived from the title
+	if !strings.HasPrefix(posts[3].Link, srv.URL+"/api/releases#") || posts[3].Link == posts[2].Link {
+		t.Errorf("item 3 link = %s", posts[3].Link)
+	}
+
+	// HTML bodies are sanitized with links made absolute; text is escaped
+	// into paragraphs
+	if html := posts[0].Content; !strings.Contains(html, `href="`+srv.URL+`/issues/7"`) || strings.Contains(html, "<script") {
+		t.Errorf("html body = %s", html)
+	}
+	if text := posts[1].Content; !strings.Contains(text, "<p>Plain text &amp; more</p>") || !strings.Contains(text, "<p>Second paragraph</p>") {
+		t.Errorf("text body = %s", text)
+	}
+	if posts[2].Content != "" {
+		t.Errorf("item without body has content %s", posts[2].Content)
+	}
+}
+
+func TestPreviewJSONFeedRejectsBadPaths(t *testing.T) {
+	srv := servePage(t, "application/json", jsonDocument)
+	r := newTestRouter(t)
+	r.POST("/feeds/preview", asUser, PreviewFeed)
+	tests := []struct {
+		config gin.H
+		err    string
+	}{
+		{gin.H{"json": gin.H{"items_path": "$.data[*]"}}, "items_path and title_path are required"},
+		{gin.H{"json": gin.H{"items_path": "$.data[", "title_path": "$.name"}}, "invalid items_path"},
+		{gin.H{"json": gin.H{"items_path": "$.data[*]", "title_path": "$.name", "date_path": "$[?("}}, "invalid date_path"},
+		{gin.H{}, "source_config.json is required"},
+	}
+	for _, tt := range tests {
+		w := doJSON(r, http.MethodPost, "/feeds/preview", gin.H{
+			"url":           srv.URL,
+			"source_type":   models.FeedSourceJSON,
+			"source_config": tt.config,
+		}, 1)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.err) {
+			t.Errorf("%v: got %d %s, want %q", tt.config, w.Code, w.Body, tt.err)
+		}
+	}
+}
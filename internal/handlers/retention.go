@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedRetentionInput struct {
+	RetentionDays     *int `json:"retention_days"`
+	RetentionMaxPosts *int `json:"retention_max_posts"`
+}
+
+// UpdateFeedRetention
+// @Summary      Set a feed's retention policy
+// @Description  Posts older than retention_days, or beyond the newest retention_max_posts of the feed, are deleted by the pruner unless starred or still unread within the grace period. 0 keeps posts; null or omitted values follow the server-wide policy. Feeds are shared by all their subscribers, so only administrators may change this.
+// @Tags         feeds
+// @Accept       json
+// @Produce      json
+// @Param        id     path  int                 true  "Feed ID"
+// @Param        input  body  FeedRetentionInput  true  "Retention overrides"
+// @Success      200  {object}  models.Feed
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /feeds/{id}/retention [put]
+func UpdateFeedRetention(c *gin.Context) {
+	var input struct {
+		RetentionDays     *int `json:"retention_days" binding:"omitempty,min=0"`
+		RetentionMaxPosts *int `json:"retention_max_posts" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var feed models.Feed
+	if err := database.DB.First(&feed, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		return
+	}
+	err := database.DB.Model(&feed).Updates(map[string]interface{}{
+		"retention_days": input.RetentionDays, "retention_max_posts": input.RetentionMaxPosts,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	feed.RetentionDays, feed.RetentionMaxPosts = input.RetentionDays, input.RetentionMaxPosts
+	c.JSON(http.StatusOK, feed)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/events"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/middleware"
+	"blogAggregator/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	// streamReplayLimit caps how many missed posts are replayed on reconnect
+	streamReplayLimit = 500
+)
+
+// CreateStreamTicket
+// @Summary      Create stream ticket
+// @Description  Issues a single-use ticket that opens /posts/stream within 30 seconds, for EventSource clients that cannot send the Authorization header.
+// @Tags         posts
+// @Produce      json
+// @Success      201  {object}  map[string]interface{}
+// @Router       /posts/stream/ticket [post]
+func CreateStreamTicket(c *gin.Context) {
+	ticket, err := middleware.IssueStreamTicket(c.GetUint("User_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(middleware.StreamTicketTTL.Seconds()),
+	})
+}
+
+// StreamPosts
+// @Summary      Stream new posts
+// @Description  Server-Sent Events stream of new posts from the caller's subscriptions. Each event id is the post id; reconnecting with Last-Event-ID or last_event_id replays posts stored after it. EventSource clients authenticate with a ticket from /posts/stream/ticket.
+// @Tags         posts
+// @Produce      text/event-stream
+// @Param        Last-Event-ID  header  string  false  "Last post id received"
+// @Param        last_event_id  query   string  false  "Last post id received, for clients that cannot set headers"
+// @Param        ticket         query   string  false  "Single-use stream ticket"
+// @Success      200  {string}  string  "event stream"
+// @Router       /posts/stream [get]
+func StreamPosts(c *gin.Context) {
+	userId := c.GetUint("User_id")
+
+	// Subscribe before replaying so nothing stored in between is missed
+	sub := events.ClusterPosts.Subscribe(256)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 5000\n\n")
+	c.Writer.Flush()
+
+	feedIDs := subscribedFeedIDs(userId)
+
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	if lastID == 0 {
+		lastID, _ = strconv.ParseUint(c.Query("last_event_id"), 10, 64)
+	}
+	// Live posts are not filtered on id: ids are assigned before commit, so
+	// a post can arrive after one with a higher id. Only posts already sent
+	// by the replay are skipped.
+	replayed := map[uint]bool{}
+	if lastID > 0 && len(feedIDs) > 0 {
+		var missed []models.Post
+		database.DB.Where("id > ? AND feed_id IN ?", lastID, mapKeys(feedIDs)).
+			Order("id").Limit(streamReplayLimit).Find(&missed)
+		for _, post := range missed {
+			if !writePostEvent(c, post) {
+				return
+			}
+			replayed[post.ID] = true
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case post, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if replayed[post.ID] || !feedIDs[post.FeedId] {
+				continue
+			}
+			if !writePostEvent(c, post) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			// Pick up subscription changes made while connected
+			feedIDs = subscribedFeedIDs(userId)
+		}
+	}
+}
+
+func writePostEvent(c *gin.Context, post models.Post) bool {
+	post = imageproxy.RewritePost(post)
+	data, err := json.Marshal(post)
+	if err != nil {
+		return true
+	}
+	if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: post\ndata: %s\n\n", post.ID, data); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+func subscribedFeedIDs(userId uint) map[uint]bool {
+	var ids []uint
+	database.DB.Model(&models.Subscription{}).Where("user_id = ?", userId).Pluck("feed_id", &ids)
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func mapKeys(set map[uint]bool) []uint {
+	keys := make([]uint, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
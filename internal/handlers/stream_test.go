@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/middleware"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStreamRouter(t *testing.T) *gin.Engine {
+	r := newTestRouter(t)
+	r.POST("/posts/stream/ticket", asUser, CreateStreamTicket)
+	r.GET("/posts/stream", middleware.StreamAuthMiddleware(), StreamPosts)
+	return r
+}
+
+func streamTicket(t *testing.T, r *gin.Engine, userID uint) string {
+	t.Helper()
+	w := doJSON(r, http.MethodPost, "/posts/stream/ticket", nil, userID)
+	requireStatus(t, w, http.StatusCreated)
+	var body struct{ Ticket string }
+	decode(t, w, &body)
+	return body.Ticket
+}
+
+// openStream reads the stream for a moment and returns what was sent
+func openStream(r *gin.Engine, query string) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/posts/stream?"+query, nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStreamReconnectWithNewTicket(t *testing.T) {
+	testdb.Open(t)
+	r := newStreamRouter(t)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	feed := models.Feed{Title: "Feed", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+	posts := []models.Post{
+		{Title: "One", Link: "https://example.com/1", Published: time.Now(), FeedId: feed.ID},
+		{Title: "Two", Link: "https://example.com/2", Published: time.Now(), FeedId: feed.ID},
+		{Title: "Three", Link: "https://example.com/3", Published: time.Now(), FeedId: feed.ID},
+	}
+	database.DB.Create(&posts)
+
+	ticket := streamTicket(t, r, user.ID)
+	w := openStream(r, "ticket="+ticket)
+	requireStatus(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), "event: post") {
+		t.Fatalf("fresh stream replayed posts: %s", w.Body)
+	}
+
+	// EventSource retrying the same URL is refused
+	if w := openStream(r, "ticket="+ticket); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused ticket returned %d", w.Code)
+	}
+
+	// A new ticket resumes after the last post received
+	w = openStream(r, fmt.Sprintf("ticket=%s&last_event_id=%d", streamTicket(t, r, user.ID), posts[0].ID))
+	requireStatus(t, w, http.StatusOK)
+	body := w.Body.String()
+	for i, post := range posts {
+		sent := strings.Contains(body, fmt.Sprintf("id: %d\n", post.ID))
+		if sent != (i > 0) {
+			t.Errorf("post %d sent: %v\n%s", i, sent, body)
+		}
+	}
+}
+
+func TestStreamRejectsExpiredTicket(t *testing.T) {
+	testdb.Open(t)
+	r := newStreamRouter(t)
+	ticket := streamTicket(t, r, 1)
+	database.DB.Model(&models.StreamTicket{}).Where("1 = 1").
+		Update("expires_at", time.Now().Add(-time.Second))
+	if w := openStream(r, "ticket="+ticket); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expired ticket returned %d", w.Code)
+	}
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "BlogAggregator"
+	recoveryCodeCount = 10
+	// maxChallengeFailures wrong codes invalidate a pending login
+	maxChallengeFailures = 5
+	// maxTwoFactorFailures wrong codes in a row, over any number of pending
+	// logins, block the second step until none was tried for
+	// twoFactorLockout
+	maxTwoFactorFailures = 10
+	twoFactorLockout     = 15 * time.Minute
+)
+
+type TOTPCodeInput struct {
+	Code string `json:"code"`
+}
+
+type PasswordConfirmInput struct {
+	Password string `json:"password"`
+}
+
+type LoginTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// EnrollTOTP
+// @Summary      Start TOTP enrollment
+// @Description  Generates a new TOTP secret and provisioning URI to render as a QR code. 2FA stays disabled until confirmed.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Router       /users/2fa/enroll [post]
+func EnrollTOTP(c *gin.Context) {
+	userId := c.GetUint("User_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication already enabled"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := database.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": auth.TOTPProvisioningURI(totpIssuer, user.Username, secret),
+	})
+}
+
+// ConfirmTOTP
+// @Summary      Confirm TOTP enrollment
+// @Description  Verifies a code from the authenticator app, enables 2FA and returns single-use recovery codes
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body  TOTPCodeInput  true  "Code"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Router       /users/2fa/confirm [post]
+func ConfirmTOTP(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userId := c.GetUint("User_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no pending two-factor enrollment"})
+		return
+	}
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, input.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range codes {
+			hash, err := auth.HashPassword(code)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: hash}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP
+// @Summary      Disable TOTP
+// @Description  Turns off 2FA after confirming the account password and removes recovery codes
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body  PasswordConfirmInput  true  "Password"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Router       /users/2fa/disable [post]
+func DisableTOTP(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userId := c.GetUint("User_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !auth.CheckPasswordHash(input.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// LoginTwoFactor
+// @Summary      Complete two-factor login
+// @Description  Exchanges the challenge token from /login plus a TOTP or recovery code for a session token. A challenge allows 5 attempts; 10 wrong codes in a row block the user for 15 minutes.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input body LoginTwoFactorInput true "Challenge"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Router       /login/2fa [post]
+func LoginTwoFactor(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, challengeID, err := auth.ParseChallengeToken(input.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userId).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	// Attempts are counted before the code is checked so that parallel
+	// guesses cannot get past the limits
+	now := time.Now().UTC()
+	if !countChallengeAttempt(user.ID, challengeID, now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+	if !countTwoFactorAttempt(user.ID, now) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many invalid codes, try again later"})
+		return
+	}
+
+	if !verifySecondFactor(&user, input.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	database.DB.Delete(&models.LoginChallenge{}, "id = ?", challengeID)
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"totp_failures":  0,
+		"totp_failed_at": nil,
+	})
+	sessionResponse(c, user)
+}
+
+// createLoginChallenge records a pending two-factor login and drops the
+// expired ones
+func createLoginChallenge(userID uint, id string) error {
+	now := time.Now().UTC()
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.LoginChallenge{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Create(&models.LoginChallenge{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: now.Add(auth.ChallengeTTL),
+	}).Error
+}
+
+// countChallengeAttempt uses up one of the attempts of a pending login. It
+// fails once the challenge is used, expired or out of attempts.
+func countChallengeAttempt(userID uint, id string, now time.Time) bool {
+	result := database.DB.Model(&models.LoginChallenge{}).
+		Where("id = ? AND user_id = ? AND failures < ? AND expires_at > ?", id, userID, maxChallengeFailures, now).
+		Update("failures", gorm.Expr("failures + 1"))
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// countTwoFactorAttempt counts an attempt against the user's limit, which
+// starts over when the previous attempt is older than twoFactorLockout. A
+// successful login resets it.
+func countTwoFactorAttempt(userID uint, now time.Time) bool {
+	since := now.Add(-twoFactorLockout)
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND (totp_failures < ? OR totp_failed_at IS NULL OR totp_failed_at < ?)", userID, maxTwoFactorFailures, since).
+		Updates(map[string]interface{}{
+			"totp_failures":  gorm.Expr("CASE WHEN totp_failed_at IS NULL OR totp_failed_at < ? THEN 1 ELSE totp_failures + 1 END", since),
+			"totp_failed_at": now,
+		})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// verifySecondFactor accepts a TOTP code newer than the last one used, or
+// consumes an unused recovery code.
+func verifySecondFactor(user *models.User, code string) bool {
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		if step <= user.TOTPLastStep {
+			return false
+		}
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	var codes []models.RecoveryCode
+	database.DB.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&codes)
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, rc := range codes {
+		if auth.CheckPasswordHash(code, rc.CodeHash) {
+			now := time.Now().UTC()
+			result := database.DB.Model(&models.RecoveryCode{}).
+				Where("id = ? AND used_at IS NULL", rc.ID).
+				Update("used_at", &now)
+			return result.Error == nil && result.RowsAffected == 1
+		}
+	}
+	return false
+}
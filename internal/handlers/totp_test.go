@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// totpCode computes the code an authenticator app shows at t
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func newTOTPRouter(t *testing.T) *gin.Engine {
+	r := newTestRouter(t)
+	r.POST("/login", Login)
+	r.POST("/login/2fa", LoginTwoFactor)
+	users := r.Group("/users/2fa", asUser)
+	users.POST("/enroll", EnrollTOTP)
+	users.POST("/confirm", ConfirmTOTP)
+	users.POST("/disable", DisableTOTP)
+	return r
+}
+
+// enrolledUser creates alice with the password "password" and turns on
+// 2FA through the enrollment endpoints. It returns the user, the secret
+// and the recovery codes.
+func enrolledUser(t *testing.T, r *gin.Engine) (models.User, string, []string) {
+	t.Helper()
+	hash, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: hash}
+	database.DB.Create(&user)
+
+	w := doJSON(r, http.MethodPost, "/users/2fa/enroll", nil, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var enrolled struct{ Secret string }
+	decode(t, w, &enrolled)
+
+	// Not enabled before a code is confirmed
+	w = doJSON(r, http.MethodPost, "/users/2fa/confirm", gin.H{"code": totpCode(t, enrolled.Secret, time.Now().Add(-time.Hour))}, user.ID)
+	requireStatus(t, w, http.StatusBadRequest)
+	w = doJSON(r, http.MethodPost, "/users/2fa/confirm", gin.H{"code": totpCode(t, enrolled.Secret, time.Now())}, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	decode(t, w, &confirmed)
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes", len(confirmed.RecoveryCodes))
+	}
+	database.DB.First(&user, user.ID)
+	return user, enrolled.Secret, confirmed.RecoveryCodes
+}
+
+// challenge logs in with the password and returns the challenge token
+func challenge(t *testing.T, r *gin.Engine) string {
+	t.Helper()
+	w := doJSON(r, http.MethodPost, "/login", gin.H{"username": "alice", "password": "password"}, 0)
+	requireStatus(t, w, http.StatusOK)
+	var body struct {
+		Required bool   `json:"two_factor_required"`
+		Token    string `json:"challenge_token"`
+	}
+	decode(t, w, &body)
+	if !body.Required || body.Token == "" {
+		t.Fatalf("login did not ask for a second factor: %s", w.Body)
+	}
+	return body.Token
+}
+
+func secondFactor(r *gin.Engine, token, code string) int {
+	return doJSON(r, http.MethodPost, "/login/2fa", gin.H{"challenge_token": token, "code": code}, 0).Code
+}
+
+func TestTOTPLoginRejectsReplay(t *testing.T) {
+	testdb.Open(t)
+	r := newTOTPRouter(t)
+	_, secret, _ := enrolledUser(t, r)
+
+	// Confirming used the current step, so only the next one is new
+	code := totpCode(t, secret, time.Now().Add(30*time.Second))
+	if status := secondFactor(r, challenge(t, r), code); status != http.StatusOK {
+		t.Fatalf("login returned %d", status)
+	}
+	if status := secondFactor(r, challenge(t, r), code); status != http.StatusUnauthorized {
+		t.Fatalf("replayed code returned %d", status)
+	}
+	if status := secondFactor(r, challenge(t, r), totpCode(t, secret, time.Now())); status != http.StatusUnauthorized {
+		t.Fatalf("older code returned %d", status)
+	}
+}
+
+func TestRecoveryCodeWorksOnce(t *testing.T) {
+	testdb.Open(t)
+	r := newTOTPRouter(t)
+	user, _, codes := enrolledUser(t, r)
+
+	if status := secondFactor(r, challenge(t, r), codes[3]); status != http.StatusOK {
+		t.Fatalf("recovery code returned %d", status)
+	}
+	if status := secondFactor(r, challenge(t, r), codes[3]); status != http.StatusUnauthorized {
+		t.Fatalf("used recovery code returned %d", status)
+	}
+	var unused int64
+	database.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&unused)
+	if unused != recoveryCodeCount-1 {
+		t.Fatalf("%d unused recovery codes", unused)
+	}
+}
+
+func TestChallengeLockout(t *testing.T) {
+	testdb.Open(t)
+	r := newTOTPRouter(t)
+	_, secret, _ := enrolledUser(t, r)
+
+	token := challenge(t, r)
+	for i := 0; i < maxChallengeFailures; i++ {
+		if status := secondFactor(r, token, "000000"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d returned %d", i, status)
+		}
+	}
+	// The challenge is used up, even for the right code
+	if status := secondFactor(r, token, totpCode(t, secret, time.Now().Add(30*time.Second))); status != http.StatusUnauthorized {
+		t.Fatalf("exhausted challenge returned %d", status)
+	}
+	// A new login still works
+	if status := secondFactor(r, challenge(t, r), totpCode(t, secret, time.Now().Add(30*time.Second))); status != http.StatusOK {
+		t.Fatalf("new challenge returned %d", status)
+	}
+}
+
+func TestUserLockout(t *testing.T) {
+	testdb.Open(t)
+	r := newTOTPRouter(t)
+	user, secret, _ := enrolledUser(t, r)
+	next := totpCode(t, secret, time.Now().Add(30*time.Second))
+
+	// Fresh challenges do not reset the per-user count
+	for i := 0; i < maxTwoFactorFailures; i++ {
+		if status := secondFactor(r, challenge(t, r), "000000"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d returned %d", i, status)
+		}
+	}
+	if status := secondFactor(r, challenge(t, r), next); status != http.StatusTooManyRequests {
+		t.Fatalf("locked out user returned %d", status)
+	}
+
+	// The lockout ends once no code was tried for its duration
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("totp_failed_at", time.Now().Add(-twoFactorLockout-time.Minute))
+	if status := secondFactor(r, challenge(t, r), next); status != http.StatusOK {
+		t.Fatalf("after the lockout returned %d", status)
+	}
+	database.DB.First(&user, user.ID)
+	if user.TOTPFailures != 0 || user.TOTPFailedAt != nil {
+		t.Fatalf("failures not reset: %d, %v", user.TOTPFailures, user.TOTPFailedAt)
+	}
+}
+
+func TestDisableTOTPRequiresPassword(t *testing.T) {
+	testdb.Open(t)
+	r := newTOTPRouter(t)
+	user, _, _ := enrolledUser(t, r)
+
+	w := doJSON(r, http.MethodPost, "/users/2fa/disable", gin.H{"password": "wrong"}, user.ID)
+	requireStatus(t, w, http.StatusUnauthorized)
+	database.DB.First(&user, user.ID)
+	if !user.TOTPEnabled {
+		t.Fatal("wrong password disabled 2FA")
+	}
+
+	w = doJSON(r, http.MethodPost, "/users/2fa/disable", gin.H{"password": "password"}, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	database.DB.First(&user, user.ID)
+	var codes int64
+	database.DB.Model(&models.RecoveryCode{}).Where("user_id = ?", user.ID).Count(&codes)
+	if user.TOTPEnabled || user.TOTPSecret != "" || codes != 0 {
+		t.Fatalf("2FA left behind: enabled %v, %d recovery codes", user.TOTPEnabled, codes)
+	}
+	// The password alone signs in again
+	w = doJSON(r, http.MethodPost, "/login", gin.H{"username": "alice", "password": "password"}, 0)
+	requireStatus(t, w, http.StatusOK)
+	var body struct{ Token string }
+	decode(t, w, &body)
+	if body.Token == "" {
+		t.Fatalf("no session token: %s", w.Body)
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/newsletter"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/webhooks"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookInput struct {
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret"`
+	FeedIDs   []uint   `json:"feed_ids"`
+	FolderIDs []uint   `json:"folder_ids"`
+	Keywords  []string `json:"keywords"`
+	Active    *bool    `json:"active"`
+}
+
+// CreateWebhook
+// @Summary      Create webhook
+// @Description  Registers an endpoint for post.created events. The signing secret is only returned here.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        input  body  WebhookInput  true  "Webhook"
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Router       /webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var input struct {
+		URL       string   `json:"url" binding:"required,url"`
+		Secret    string   `json:"secret"`
+		FeedIDs   []uint   `json:"feed_ids"`
+		FolderIDs []uint   `json:"folder_ids"`
+		Keywords  []string `json:"keywords"`
+		Active    *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := safehttp.CheckURL(input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !visibleFeeds(c.GetUint("User_id"), input.FeedIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "feed not found"})
+		return
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+	}
+
+	hook := models.Webhook{
+		UserID:    c.GetUint("User_id"),
+		URL:       input.URL,
+		Secret:    secret,
+		FeedIDs:   input.FeedIDs,
+		FolderIDs: input.FolderIDs,
+		Keywords:  input.Keywords,
+		Active:    input.Active == nil || *input.Active,
+	}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"webhook": hook, "secret": secret})
+}
+
+// ListWebhooks
+// @Summary      List webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}  models.Webhook
+// @Router       /webhooks [get]
+func ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	if err := database.DB.Where("user_id = ?", c.GetUint("User_id")).Order("id").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// UpdateWebhook
+// @Summary      Update webhook
+// @Description  Replaces URL, filters and active flag; the secret is only changed when given
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id     path  int           true  "Webhook ID"
+// @Param        input  body  WebhookInput  true  "Webhook"
+// @Success      200  {object}  models.Webhook
+// @Failure      404  {object}  map[string]string
+// @Router       /webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	var input struct {
+		URL       string   `json:"url" binding:"required,url"`
+		Secret    string   `json:"secret"`
+		FeedIDs   []uint   `json:"feed_ids"`
+		FolderIDs []uint   `json:"folder_ids"`
+		Keywords  []string `json:"keywords"`
+		Active    *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := safehttp.CheckURL(input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !visibleFeeds(c.GetUint("User_id"), input.FeedIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "feed not found"})
+		return
+	}
+
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	hook.URL = input.URL
+	hook.FeedIDs = input.FeedIDs
+	hook.FolderIDs = input.FolderIDs
+	hook.Keywords = input.Keywords
+	if input.Secret != "" {
+		hook.Secret = input.Secret
+	}
+	if input.Active != nil {
+		hook.Active = *input.Active
+	}
+	if err := database.DB.Save(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook
+// @Summary      Delete webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// ListWebhookDeliveries
+// @Summary      Webhook delivery log
+// @Tags         webhooks
+// @Produce      json
+// @Param        id     path   int  true   "Webhook ID"
+// @Param        limit  query  int  false  "Limit"
+// @Success      200  {array}  models.WebhookDelivery
+// @Failure      404  {object}  map[string]string
+// @Router       /webhooks/{id}/deliveries [get]
+func ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	var deliveries []models.WebhookDelivery
+	if err := database.DB.Where("webhook_id = ?", hook.ID).Order("id desc").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhook
+// @Summary      Send test event
+// @Description  Sends a ping event right away and returns the logged delivery. Failed pings are retried like any delivery.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      200  {object}  models.WebhookDelivery
+// @Failure      404  {object}  map[string]string
+// @Router       /webhooks/{id}/test [post]
+func TestWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := webhooks.SendTest(hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// visibleFeeds reports whether every feed exists and may be watched by the
+// user, which rules out other users' newsletter feeds
+func visibleFeeds(userID uint, feedIDs []uint) bool {
+	if len(feedIDs) == 0 {
+		return true
+	}
+	var ids []uint
+	err := database.DB.Model(&models.Feed{}).
+		Where("id IN ? AND id NOT IN (?)", feedIDs, newsletter.HiddenFeedIDs(userID)).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return false
+	}
+	found := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, id := range feedIDs {
+		if !found[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// findWebhook loads the caller's webhook from the :id path parameter,
+// writing a 404 when it does not exist or belongs to someone else.
+func findWebhook(c *gin.Context) (models.Webhook, bool) {
+	var hook models.Webhook
+	err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("User_id")).First(&hook).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return hook, false
+	}
+	return hook, true
+}
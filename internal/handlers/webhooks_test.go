@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/testdb"
+	"blogAggregator/internal/webhooks"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// receiver is a webhook endpoint answering with status and keeping the
+// requests it got
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T) *receiver {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { safehttp.Configure(nil, nil) })
+	rec := &receiver{status: http.StatusOK}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		w.WriteHeader(rec.status)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *receiver) respond(status int) {
+	rec.mu.Lock()
+	rec.status = status
+	rec.mu.Unlock()
+}
+
+func newWebhookRouter(t *testing.T) *gin.Engine {
+	r := newTestRouter(t)
+	hooks := r.Group("/webhooks", asUser)
+	hooks.POST("", CreateWebhook)
+	hooks.PUT("/:id", UpdateWebhook)
+	hooks.GET("/:id/deliveries", ListWebhookDeliveries)
+	hooks.POST("/:id/test", TestWebhook)
+	return r
+}
+
+func createWebhook(t *testing.T, r *gin.Engine, userID uint, body gin.H) models.Webhook {
+	t.Helper()
+	w := doJSON(r, http.MethodPost, "/webhooks", body, userID)
+	requireStatus(t, w, http.StatusCreated)
+	var created struct{ Webhook models.Webhook }
+	decode(t, w, &created)
+	return created.Webhook
+}
+
+func TestWebhookPingIsSigned(t *testing.T) {
+	testdb.Open(t)
+	r := newWebhookRouter(t)
+	rec := newReceiver(t)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+
+	// A generated secret is returned once
+	w := doJSON(r, http.MethodPost, "/webhooks", gin.H{"url": rec.URL}, user.ID)
+	requireStatus(t, w, http.StatusCreated)
+	var generated struct{ Secret string }
+	decode(t, w, &generated)
+	if generated.Secret == "" {
+		t.Fatal("no secret returned")
+	}
+
+	hook := createWebhook(t, r, user.ID, gin.H{"url": rec.URL, "secret": "whsec_test"})
+	w = doJSON(r, http.MethodPost, fmt.Sprintf("/webhooks/%d/test", hook.ID), nil, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var delivery models.WebhookDelivery
+	decode(t, w, &delivery)
+	if delivery.Status != webhooks.StatusSucceeded || delivery.Attempts != 1 || delivery.ResponseCode != http.StatusOK {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+
+	if len(rec.requests) != 1 {
+		t.Fatalf("receiver got %d requests", len(rec.requests))
+	}
+	req, body := rec.requests[0], rec.bodies[0]
+	want := webhooks.Sign("whsec_test", req.Header.Get("X-Webhook-Timestamp"), body)
+	if got := req.Header.Get("X-Webhook-Signature"); got != want {
+		t.Fatalf("signature %q, want %q", got, want)
+	}
+	if req.Header.Get("X-Webhook-Event") != webhooks.EventPing || req.Header.Get("X-Webhook-Delivery") != fmt.Sprint(delivery.ID) {
+		t.Fatalf("headers = %v", req.Header)
+	}
+
+	// Another user cannot trigger or read it
+	other := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&other)
+	w = doJSON(r, http.MethodPost, fmt.Sprintf("/webhooks/%d/test", hook.ID), nil, other.ID)
+	requireStatus(t, w, http.StatusNotFound)
+	w = doJSON(r, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", hook.ID), nil, other.ID)
+	requireStatus(t, w, http.StatusNotFound)
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	testdb.Open(t)
+	r := newWebhookRouter(t)
+	rec := newReceiver(t)
+	rec.respond(http.StatusInternalServerError)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	hook := createWebhook(t, r, user.ID, gin.H{"url": rec.URL})
+
+	w := doJSON(r, http.MethodPost, fmt.Sprintf("/webhooks/%d/test", hook.ID), nil, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var delivery models.WebhookDelivery
+	decode(t, w, &delivery)
+
+	// Each failure doubles the wait: 30s, then 1m
+	for attempt, wait := range []time.Duration{30 * time.Second, time.Minute} {
+		database.DB.First(&delivery, delivery.ID)
+		if delivery.Status != webhooks.StatusPending || delivery.Attempts != attempt+1 ||
+			delivery.ResponseCode != http.StatusInternalServerError || delivery.Error == "" {
+			t.Fatalf("after attempt %d: %+v", attempt+1, delivery)
+		}
+		scheduled := delivery.NextAttemptAt.Sub(*delivery.LastAttemptAt)
+		if scheduled < wait-time.Second || scheduled > wait+time.Second {
+			t.Fatalf("attempt %d retries after %s, want %s", attempt+1, scheduled, wait)
+		}
+		// Not picked up before it is due
+		if n, err := webhooks.ProcessDue(10); err != nil || n != 0 {
+			t.Fatalf("processed %d early: %v", n, err)
+		}
+		database.DB.Model(&delivery).Update("next_attempt_at", time.Now().UTC().Add(-time.Second))
+		if attempt == 1 {
+			rec.respond(http.StatusNoContent)
+		}
+		if n, err := webhooks.ProcessDue(10); err != nil || n != 1 {
+			t.Fatalf("processed %d: %v", n, err)
+		}
+	}
+
+	w = doJSON(r, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", hook.ID), nil, user.ID)
+	requireStatus(t, w, http.StatusOK)
+	var log []models.WebhookDelivery
+	decode(t, w, &log)
+	if len(log) != 1 || log[0].Status != webhooks.StatusSucceeded || log[0].Attempts != 3 || log[0].Error != "" {
+		t.Fatalf("deliveries = %+v", log)
+	}
+	if len(rec.requests) != 3 {
+		t.Fatalf("receiver got %d requests", len(rec.requests))
+	}
+}
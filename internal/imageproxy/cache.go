@@ -0,0 +1,170 @@
+package imageproxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// meta is stored next to each cached image
+type meta struct {
+	URL          string `json:"url"`
+	ContentType  string `json:"content_type"`
+	CacheControl string `json:"cache_control,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+}
+
+// diskCache keeps images in a directory, evicting the least recently used
+// ones once the total size passes max. Each entry is a <key> file with the
+// body and a <key>.json file with its meta.
+type diskCache struct {
+	dir string
+	max int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+func openCache(dir string, max int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &diskCache{dir: dir, max: max, lru: list.New(), entries: map[string]*list.Element{}}
+
+	// Rebuild the LRU order from modification times, which get touched on use
+	type found struct {
+		key   string
+		size  int64
+		mtime time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".tmp") {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, found{key: d.Name(), size: info.Size(), mtime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.After(files[j].mtime) })
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushBack(&cacheEntry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func cacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// get opens a cached image, marking it recently used
+func (c *diskCache) get(rawURL string) (*os.File, meta, bool) {
+	key := cacheKey(rawURL)
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, meta{}, false
+	}
+
+	var m meta
+	data, err := os.ReadFile(c.path(key) + ".json")
+	if err != nil || json.Unmarshal(data, &m) != nil || m.URL != rawURL {
+		return nil, meta{}, false
+	}
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		return nil, meta{}, false
+	}
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	return f, m, true
+}
+
+// put stores an image, replacing any previous copy
+func (c *diskCache) put(rawURL string, m meta, body []byte) error {
+	key := cacheKey(rawURL)
+	if int64(len(body)) > c.max {
+		return errors.New("image larger than the cache")
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	// Write to temporary files first so readers never see a partial image
+	if err := writeFile(c.path(key)+".json", data); err != nil {
+		return err
+	}
+	if err := writeFile(c.path(key), body); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.size -= el.Value.(*cacheEntry).size
+		c.lru.Remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: int64(len(body))})
+	c.size += int64(len(body))
+	c.evict()
+	return nil
+}
+
+// evict drops least recently used entries until the cache fits; c.mu must
+// be held
+func (c *diskCache) evict() {
+	for c.size > c.max && c.lru.Len() > 0 {
+		el := c.lru.Back()
+		e := el.Value.(*cacheEntry)
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+		c.size -= e.size
+		os.Remove(c.path(e.key))
+		os.Remove(c.path(e.key) + ".json")
+	}
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
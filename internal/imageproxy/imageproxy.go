@@ -0,0 +1,143 @@
+// Package imageproxy serves images embedded in post content from our own
+// origin, so readers do not load them from third-party hosts. Image URLs in
+// content are rewritten to /img/<signature>/<encoded url>; the signature is
+// an HMAC over the original URL, so the proxy cannot be used to fetch
+// arbitrary addresses.
+package imageproxy
+
+import (
+	"blogAggregator/internal/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Options configures the proxy
+type Options struct {
+	// Key signs proxied URLs
+	Key string
+	// BaseURL is prefixed to proxied URLs, e.g. https://reader.example.com;
+	// empty gives paths relative to the API host
+	BaseURL string
+	// CacheDir holds fetched images, bounded to CacheBytes
+	CacheDir   string
+	CacheBytes int64
+}
+
+var (
+	key     []byte
+	baseURL string
+	cache   *diskCache
+)
+
+// Enable turns on URL rewriting and the /img/ endpoint
+func Enable(opts Options) error {
+	if opts.Key == "" {
+		return errors.New("image proxy key is empty")
+	}
+	c, err := openCache(opts.CacheDir, opts.CacheBytes)
+	if err != nil {
+		return err
+	}
+	key = []byte(opts.Key)
+	baseURL = strings.TrimRight(opts.BaseURL, "/")
+	cache = c
+	return nil
+}
+
+// Enabled reports whether Enable was called
+func Enabled() bool {
+	return cache != nil
+}
+
+func sign(rawURL string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(rawURL))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the proxied address of an image, or src unchanged when it is
+// not an http(s) URL or the proxy is disabled
+func URL(src string) string {
+	if !Enabled() || !(strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")) {
+		return src
+	}
+	return baseURL + "/img/" + sign(src) + "/" + base64.RawURLEncoding.EncodeToString([]byte(src))
+}
+
+// decode checks a signature and returns the original URL
+func decode(signature, encoded string) (string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(string(raw)))) {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// Rewrite points the img src and srcset attributes (and picture sources)
+// of sanitized content at the proxy
+func Rewrite(content string) string {
+	if !Enabled() || !strings.Contains(content, "<img") && !strings.Contains(content, "<source") {
+		return content
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type: html.ElementNode, Data: "div", DataAtom: atom.Div,
+	})
+	if err != nil {
+		return content
+	}
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		rewriteNode(n)
+		html.Render(&buf, n)
+	}
+	return buf.String()
+}
+
+// RewritePost returns the post with images in its content and full content
+// pointed at the proxy. Every API serializing posts for readers goes
+// through it, so none of them leaks the original image URLs.
+func RewritePost(post models.Post) models.Post {
+	post.Content = Rewrite(post.Content)
+	post.FullContent = Rewrite(post.FullContent)
+	return post
+}
+
+func rewriteNode(n *html.Node) {
+	if n.Type == html.ElementNode && (n.DataAtom == atom.Img || n.DataAtom == atom.Source) {
+		for i, attr := range n.Attr {
+			switch attr.Key {
+			case "src":
+				n.Attr[i].Val = URL(attr.Val)
+			case "srcset":
+				n.Attr[i].Val = rewriteSrcset(attr.Val)
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		rewriteNode(c)
+	}
+}
+
+// rewriteSrcset proxies each candidate of "url 2x, url 640w" lists
+func rewriteSrcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = URL(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
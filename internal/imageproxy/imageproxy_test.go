@@ -0,0 +1,59 @@
+package imageproxy
+
+import (
+	"blogAggregator/internal/models"
+	"strings"
+	"testing"
+)
+
+func enableForTest(t *testing.T) {
+	t.Helper()
+	if err := Enable(Options{Key: "test-key", BaseURL: "https://reader.example.com/", CacheDir: t.TempDir(), CacheBytes: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { key, baseURL, cache = nil, "", nil })
+}
+
+func TestURL(t *testing.T) {
+	enableForTest(t)
+	proxied := URL("https://cdn.example.com/a.png")
+	if !strings.HasPrefix(proxied, "https://reader.example.com/img/") {
+		t.Fatalf("URL = %s", proxied)
+	}
+	parts := strings.Split(strings.TrimPrefix(proxied, "https://reader.example.com/img/"), "/")
+	if original, ok := decode(parts[0], parts[1]); !ok || original != "https://cdn.example.com/a.png" {
+		t.Fatalf("decode = %q, %v", original, ok)
+	}
+	if _, ok := decode(sign("https://other.example.com/b.png"), parts[1]); ok {
+		t.Fatal("signature of another URL accepted")
+	}
+	if got := URL("data:image/png;base64,AAAA"); got != "data:image/png;base64,AAAA" {
+		t.Fatalf("data URL rewritten to %s", got)
+	}
+}
+
+func TestRewritePost(t *testing.T) {
+	post := models.Post{
+		Title:       "Post",
+		Content:     `<p><img src="https://cdn.example.com/a.png"></p>`,
+		FullContent: `<picture><source srcset="https://cdn.example.com/b.webp 1x, https://cdn.example.com/c.webp 2x"><img src="https://cdn.example.com/b.png"></picture>`,
+	}
+	// Disabled, content is left alone
+	if got := RewritePost(post); got.Content != post.Content || got.FullContent != post.FullContent {
+		t.Fatalf("rewrote content while disabled: %+v", got)
+	}
+
+	enableForTest(t)
+	got := RewritePost(post)
+	for _, content := range []string{got.Content, got.FullContent} {
+		if strings.Contains(content, "cdn.example.com") || !strings.Contains(content, "https://reader.example.com/img/") {
+			t.Fatalf("content = %s", content)
+		}
+	}
+	if !strings.Contains(got.FullContent, " 2x") {
+		t.Fatalf("srcset descriptors lost: %s", got.FullContent)
+	}
+	if post.Content != `<p><img src="https://cdn.example.com/a.png"></p>` || got.Title != post.Title {
+		t.Fatal("RewritePost changed the original post")
+	}
+}
@@ -0,0 +1,125 @@
+package imageproxy
+
+import (
+	"blogAggregator/internal/safehttp"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImageBytes is the largest image the proxy passes through
+	maxImageBytes = 10 << 20
+	// defaultCacheControl is sent when the origin gives no caching headers
+	defaultCacheControl = "public, max-age=86400"
+	userAgent           = "blogAggregator-imageproxy/1.0"
+)
+
+// client refuses private addresses, see safehttp
+var client = safehttp.New(20*time.Second, maxImageBytes)
+
+// Serve answers GET /img/:sig/:url with the image, from the disk cache
+// when possible
+func Serve(c *gin.Context) {
+	if !Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image proxy is disabled"})
+		return
+	}
+	rawURL, ok := decode(c.Param("sig"), c.Param("url"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	if f, m, ok := cache.get(rawURL); ok {
+		defer f.Close()
+		serve(c, m, f)
+		return
+	}
+
+	m, body, status, err := fetch(rawURL)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cache.put(rawURL, m, body); err != nil {
+		log.Printf("imageproxy: caching %s failed: %v", rawURL, err)
+	}
+	serve(c, m, bytes.NewReader(body))
+}
+
+// fetch downloads an image, returning the status to answer with on error
+func fetch(rawURL string) (meta, []byte, int, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return meta{}, nil, http.StatusBadRequest, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "image/*")
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, safehttp.ErrBlocked) {
+			return meta{}, nil, http.StatusForbidden, err
+		}
+		if errors.Is(err, safehttp.ErrTooLarge) {
+			return meta{}, nil, http.StatusRequestEntityTooLarge, errors.New("image is too large")
+		}
+		return meta{}, nil, http.StatusBadGateway, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return meta{}, nil, http.StatusBadGateway, fmt.Errorf("image host answered %s", resp.Status)
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	// SVG can carry scripts, so it is not served from our origin
+	if err != nil || !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		return meta{}, nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if errors.Is(err, safehttp.ErrTooLarge) {
+		return meta{}, nil, http.StatusRequestEntityTooLarge, errors.New("image is too large")
+	}
+	if err != nil {
+		return meta{}, nil, http.StatusBadGateway, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return meta{
+		URL:          rawURL,
+		ContentType:  contentType,
+		CacheControl: resp.Header.Get("Cache-Control"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Expires:      resp.Header.Get("Expires"),
+	}, body, http.StatusOK, nil
+}
+
+// serve writes an image with the origin's caching headers. ServeContent
+// answers conditional and range requests.
+func serve(c *gin.Context, m meta, content io.ReadSeeker) {
+	h := c.Writer.Header()
+	h.Set("Content-Type", m.ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	if m.CacheControl != "" {
+		h.Set("Cache-Control", m.CacheControl)
+	} else {
+		h.Set("Cache-Control", defaultCacheControl)
+	}
+	if m.ETag != "" {
+		h.Set("ETag", m.ETag)
+	}
+	if m.Expires != "" {
+		h.Set("Expires", m.Expires)
+	}
+	modified, _ := http.ParseTime(m.LastModified)
+	http.ServeContent(c.Writer, c.Request, "", modified, content)
+}
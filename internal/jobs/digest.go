@@ -0,0 +1,18 @@
+package jobs
+
+import (
+	"blogAggregator/internal/digest"
+	"blogAggregator/internal/mailer"
+	"time"
+)
+
+// StartDigestScheduler checks every interval for digests whose delivery
+// hour has passed and mails them.
+func StartDigestScheduler(interval time.Duration, m mailer.Mailer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		digest.SendDue(m, time.Now().UTC())
+	}
+}
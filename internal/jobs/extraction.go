@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"blogAggregator/internal/rss"
+	"fmt"
+	"time"
+)
+
+// StartFullContentWorker extracts the full articles of new posts every
+// interval. Jobs are queued when posts of feeds that want them are stored.
+func StartFullContentWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		for {
+			n, err := rss.ProcessFullContentJobs(20)
+			if err != nil {
+				fmt.Println("could not extract full articles:", err)
+				break
+			}
+			if n < 20 {
+				break
+			}
+		}
+	}
+}
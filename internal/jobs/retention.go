@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"blogAggregator/internal/retention"
+	"fmt"
+	"time"
+)
+
+// StartRetentionPruner deletes posts past their feed's retention policy
+// every interval
+func StartRetentionPruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		n, err := retention.Prune()
+		if n > 0 {
+			fmt.Printf("pruned %d posts\n", n)
+		}
+		if err != nil {
+			fmt.Println("could not prune posts:", err)
+		}
+	}
+}
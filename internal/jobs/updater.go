@@ -4,6 +4,7 @@ import (
 	"blogAggregator/internal/database"
 	"blogAggregator/internal/models"
 	"blogAggregator/internal/rss"
+	"blogAggregator/internal/websub"
 	"fmt"
 	"time"
 )
@@ -15,7 +16,15 @@ func StartFeedUpdater(interval time.Duration) {
 		<-ticker.C
 		fmt.Println("running feed updater.....")
 		var feeds []models.Feed
-		if err:=database.DB.Find(&feeds).Error;err!=nil{
+		now:=time.Now().UTC()
+		query:=database.DB.Where("source_type <> ? AND disabled = ?",models.FeedSourceNewsletter,false).
+			Where("next_fetch_at IS NULL OR next_fetch_at <= ?",now)
+		if websub.Enabled(){
+			// Pushed feeds are only polled as a safety net
+			query=query.Where("id NOT IN (?) OR last_fetched IS NULL OR last_fetched <= ?",
+				websub.ActiveFeedIDs(),now.Add(-websub.SafetyPollInterval))
+		}
+		if err:=query.Find(&feeds).Error;err!=nil{
 			fmt.Println("could not fetch feeds:" ,err)
 			continue
 		}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"blogAggregator/internal/webhooks"
+	"fmt"
+	"time"
+)
+
+// StartWebhookDispatcher sends due deliveries every interval, retrying
+// failures with backoff. Deliveries are queued when posts are stored.
+func StartWebhookDispatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		for {
+			n, err := webhooks.ProcessDue(50)
+			if err != nil {
+				fmt.Println("could not process webhook deliveries:", err)
+				break
+			}
+			if n < 50 {
+				break
+			}
+		}
+	}
+}
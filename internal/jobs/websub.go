@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"blogAggregator/internal/websub"
+	"fmt"
+	"time"
+)
+
+// StartWebSubManager subscribes feeds that advertise a WebSub hub and
+// renews leases every interval
+func StartWebSubManager(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		if err := websub.Reconcile(); err != nil {
+			fmt.Println("could not reconcile websub subscriptions:", err)
+		}
+	}
+}
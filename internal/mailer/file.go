@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes each message as an .eml file, for local testing
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	data, err := Render(msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	recipient := strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s-%s.eml", time.Now().UTC().Format("20060102T150405"), recipient, randomID()[:6])
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
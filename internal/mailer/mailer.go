@@ -0,0 +1,110 @@
+// Package mailer sends multipart emails through a pluggable transport.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"time"
+)
+
+// Message is an email with plain-text and HTML alternatives
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config selects and configures the transport
+type Config struct {
+	Transport    string // "smtp", "file" or "" to disable mail
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SinkDir      string
+}
+
+// New returns the mailer for the configured transport, or nil when mail is disabled
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Transport {
+	case "":
+		return nil, nil
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.From == "" {
+			return nil, fmt.Errorf("smtp mailer needs SMTP_HOST and MAIL_FROM")
+		}
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}, nil
+	case "file":
+		return &FileMailer{Dir: cfg.SinkDir, From: cfg.From}, nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+}
+
+// Render encodes the message as a multipart/alternative MIME document
+func Render(msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@blog-aggregator>\r\n", randomID())
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer relays through an SMTP server, upgrading to STARTTLS when offered
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	data, err := Render(msg)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	port := m.Port
+	if port == "" {
+		port = "587"
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, port), auth, m.From, []string{msg.To}, data)
+}
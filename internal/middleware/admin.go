@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin lets only administrators through. It runs after
+// AuthMiddleware, which identifies the user.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := database.DB.First(&user, c.GetUint("User_id")).Error; err != nil || !auth.IsAdmin(user.Username) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	testdb.Open(t)
+	gin.SetMode(gin.TestMode)
+	cfg := config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test", AdminUsers: []string{"admin"}}
+	if err := auth.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}) })
+	admin := models.User{Username: "admin", Email: "admin@example.com"}
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&admin)
+	database.DB.Create(&user)
+
+	r := gin.New()
+	r.GET("/admin", AuthMiddleware(), RequireAdmin(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	for _, tt := range []struct {
+		user models.User
+		code int
+	}{
+		{admin, http.StatusOK},
+		{user, http.StatusForbidden},
+		{models.User{ID: 999}, http.StatusForbidden},
+	} {
+		token, err := auth.GenerateToken(tt.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := get(r, "/admin", "Bearer "+token); w.Code != tt.code {
+			t.Errorf("user %d: got %d, want %d", tt.user.ID, w.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamTicketTTL is how long a stream ticket can be redeemed
+const StreamTicketTTL = 30 * time.Second
+
+// IssueStreamTicket returns a new single-use ticket for the user's post
+// stream and drops expired ones
+func IssueStreamTicket(userID uint) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now().UTC()
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.StreamTicket{}).Error; err != nil {
+		return "", err
+	}
+	err := database.DB.Create(&models.StreamTicket{
+		TokenHash: hashTicket(ticket),
+		UserID:    userID,
+		ExpiresAt: now.Add(StreamTicketTTL),
+	}).Error
+	return ticket, err
+}
+
+// StreamAuthMiddleware authenticates the post stream with the Authorization
+// header like AuthMiddleware, or, for EventSource clients that cannot set
+// headers, with a ticket from IssueStreamTicket in the query
+func StreamAuthMiddleware() gin.HandlerFunc {
+	authenticate := AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if c.GetHeader("Authorization") != "" || ticket == "" {
+			authenticate(c)
+			return
+		}
+		userID, ok := redeemStreamTicket(ticket)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid ticket"})
+			c.Abort()
+			return
+		}
+		c.Set("User_id", userID)
+		c.Next()
+	}
+}
+
+// redeemStreamTicket returns the owner of an unexpired ticket and deletes
+// it, so only one request can use it
+func redeemStreamTicket(ticket string) (uint, bool) {
+	var t models.StreamTicket
+	err := database.DB.Where("token_hash = ? AND expires_at > ?", hashTicket(ticket), time.Now().UTC()).First(&t).Error
+	if err != nil {
+		return 0, false
+	}
+	result := database.DB.Where("token_hash = ?", t.TokenHash).Delete(&models.StreamTicket{})
+	return t.UserID, result.Error == nil && result.RowsAffected == 1
+}
+
+func hashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
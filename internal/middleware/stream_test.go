@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStreamRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/posts/stream", StreamAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.Itoa(int(c.GetUint("User_id"))))
+	})
+	return r
+}
+
+func get(r *gin.Engine, target, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStreamAuthAcceptsHeader(t *testing.T) {
+	r := newStreamRouter(t)
+	token, err := auth.GenerateToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/posts/stream", "Bearer "+token); w.Code != http.StatusOK || w.Body.String() != "7" {
+		t.Fatalf("header auth returned %d: %s", w.Code, w.Body)
+	}
+	if w := get(r, "/posts/stream", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("no credentials returned %d", w.Code)
+	}
+}
+
+func TestAuthMiddlewareIgnoresQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/posts", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	token, _ := auth.GenerateToken(7)
+	if w := get(r, "/posts?access_token="+token, ""); w.Code == http.StatusOK {
+		t.Fatal("a token in the query authenticated the request")
+	}
+}
+
+func TestStreamTicketIsSingleUse(t *testing.T) {
+	testdb.Open(t)
+	r := newStreamRouter(t)
+	ticket, err := IssueStreamTicket(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/posts/stream?ticket="+ticket, ""); w.Code != http.StatusOK || w.Body.String() != "7" {
+		t.Fatalf("ticket returned %d: %s", w.Code, w.Body)
+	}
+	if w := get(r, "/posts/stream?ticket="+ticket, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused ticket returned %d", w.Code)
+	}
+	if w := get(r, "/posts/stream?ticket=forged", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown ticket returned %d", w.Code)
+	}
+}
+
+func TestStreamTicketExpires(t *testing.T) {
+	testdb.Open(t)
+	r := newStreamRouter(t)
+	ticket, err := IssueStreamTicket(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Model(&models.StreamTicket{}).Where("user_id = ?", 7).
+		Update("expires_at", time.Now().Add(-time.Second))
+	if w := get(r, "/posts/stream?ticket="+ticket, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expired ticket returned %d", w.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package miniflux
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// categoryRef resolves a category id from a request body to a folder id;
+// 0 means no folder. It writes a 400 for folders the user does not own.
+func categoryRef(c *gin.Context, userID, categoryID uint) (*uint, bool) {
+	if categoryID == 0 {
+		return nil, true
+	}
+	var count int64
+	database.DB.Model(&models.Folder{}).Where("id = ? AND user_id = ?", categoryID, userID).Count(&count)
+	if count == 0 {
+		writeError(c, http.StatusBadRequest, "This category does not exist or does not belong to this user.")
+		return nil, false
+	}
+	return &categoryID, true
+}
+
+// findCategory loads the user's folder from the :categoryID parameter
+func findCategory(c *gin.Context) (models.Folder, bool) {
+	var folder models.Folder
+	id, ok := paramID(c, "categoryID")
+	if !ok {
+		return folder, false
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", id, c.GetUint("User_id")).First(&folder).Error; err != nil {
+		notFound(c)
+		return folder, false
+	}
+	return folder, true
+}
+
+func toCategory(f models.Folder) category {
+	return category{ID: f.ID, Title: f.Name, UserID: f.UserID}
+}
+
+func folderFeedIDs(userID, folderID uint) *gorm.DB {
+	return database.DB.Model(&models.Subscription{}).Select("feed_id").Where("user_id = ? AND folder_id = ?", userID, folderID)
+}
+
+// GetCategories lists the user's folders; counts=true adds feed and
+// unread counts
+func GetCategories(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	var folders []models.Folder
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&folders).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	categories := make([]category, len(folders))
+	for i, f := range folders {
+		categories[i] = toCategory(f)
+		if c.Query("counts") == "true" {
+			var feeds, unread int64
+			database.DB.Model(&models.Subscription{}).Where("user_id = ? AND folder_id = ?", userID, f.ID).Count(&feeds)
+			database.DB.Model(&models.Post{}).
+				Where("feed_id IN (?)", folderFeedIDs(userID, f.ID)).
+				Where("id NOT IN (?)", poststate.ReadPostIDs(userID)).
+				Count(&unread)
+			categories[i].FeedCount, categories[i].TotalUnread = &feeds, &unread
+		}
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateCategory creates a folder
+func CreateCategory(c *gin.Context) {
+	var input struct {
+		Title string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Title == "" {
+		writeError(c, http.StatusBadRequest, "The title is mandatory.")
+		return
+	}
+	folder := models.Folder{UserID: c.GetUint("User_id"), Name: input.Title}
+	if err := database.DB.Create(&folder).Error; err != nil {
+		writeError(c, http.StatusBadRequest, "This category already exists.")
+		return
+	}
+	c.JSON(http.StatusCreated, toCategory(folder))
+}
+
+// UpdateCategory renames a folder
+func UpdateCategory(c *gin.Context) {
+	folder, ok := findCategory(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Title string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Title == "" {
+		writeError(c, http.StatusBadRequest, "The title is mandatory.")
+		return
+	}
+	if err := database.DB.Model(&folder).Update("name", input.Title).Error; err != nil {
+		writeError(c, http.StatusBadRequest, "This category already exists.")
+		return
+	}
+	c.JSON(http.StatusCreated, toCategory(folder))
+}
+
+// DeleteCategory deletes a folder; its feeds stay subscribed, uncategorized
+func DeleteCategory(c *gin.Context) {
+	folder, ok := findCategory(c)
+	if !ok {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Subscription{}).Where("folder_id = ?", folder.ID).Update("folder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetCategoryFeeds lists the feeds in a folder
+func GetCategoryFeeds(c *gin.Context) {
+	folder, ok := findCategory(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("User_id")
+	feeds, err := loadFeeds(userFeedsQuery(userID).Where("subscriptions.folder_id = ?", folder.ID), userID)
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, feeds)
+}
+
+// MarkCategoryAsRead marks every entry in a folder read
+func MarkCategoryAsRead(c *gin.Context) {
+	folder, ok := findCategory(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("User_id")
+	var feedIDs []uint
+	if err := folderFeedIDs(userID, folder.ID).Pluck("feed_id", &feedIDs).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	if err := poststate.MarkFeedsRead(userID, feedIDs, time.Now()); err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
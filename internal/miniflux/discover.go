@@ -0,0 +1,126 @@
+package miniflux
+
+import (
+	"blogAggregator/internal/safehttp"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+const maxDiscoveryBody = 5 << 20
+
+var discoveryClient = safehttp.New(20*time.Second, maxDiscoveryBody)
+
+// feedTypes maps <link rel="alternate"> types to Miniflux subscription types
+var feedTypes = map[string]string{
+	"application/rss+xml":   "rss",
+	"application/atom+xml":  "atom",
+	"application/feed+json": "json",
+	"application/json":      "json",
+}
+
+type subscription struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+// Discover finds the feeds behind a URL: the URL itself when it is a feed,
+// otherwise the feeds a web page advertises with <link rel="alternate">
+func Discover(c *gin.Context) {
+	var input struct {
+		URL       string `json:"url"`
+		UserAgent string `json:"user_agent"`
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.URL == "" {
+		writeError(c, http.StatusBadRequest, "The URL is required.")
+		return
+	}
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(c, http.StatusBadRequest, "Invalid URL.")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.UserAgent != "" {
+		req.Header.Set("User-Agent", input.UserAgent)
+	}
+	if input.Username != "" {
+		req.SetBasicAuth(input.Username, input.Password)
+	}
+	resp, err := discoveryClient.Do(req)
+	if err != nil {
+		writeError(c, http.StatusBadGateway, "This website is unreachable: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBody))
+	if err != nil || resp.StatusCode >= 400 {
+		writeError(c, http.StatusBadGateway, "This website is unreachable.")
+		return
+	}
+	base := resp.Request.URL
+
+	if parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body)); err == nil {
+		c.JSON(http.StatusOK, []subscription{{URL: base.String(), Title: parsed.Title, Type: parsed.FeedType}})
+		return
+	}
+
+	subs := alternateLinks(body, base)
+	if len(subs) == 0 {
+		notFound(c)
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// alternateLinks collects feed links from an HTML page's head
+func alternateLinks(body []byte, base *url.URL) []subscription {
+	var subs []subscription
+	seen := map[string]bool{}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return subs
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		if tok.Data == "body" {
+			return subs
+		}
+		if tok.Data != "link" {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, a := range tok.Attr {
+			attrs[strings.ToLower(a.Key)] = a.Val
+		}
+		feedType, ok := feedTypes[strings.ToLower(strings.TrimSpace(attrs["type"]))]
+		if !ok || !strings.Contains(strings.ToLower(attrs["rel"]), "alternate") || attrs["href"] == "" {
+			continue
+		}
+		ref, err := base.Parse(attrs["href"])
+		if err != nil || seen[ref.String()] {
+			continue
+		}
+		seen[ref.String()] = true
+		subs = append(subs, subscription{URL: ref.String(), Title: attrs["title"], Type: feedType})
+	}
+}
@@ -0,0 +1,321 @@
+package miniflux
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/imageproxy"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	statusRead   = "read"
+	statusUnread = "unread"
+
+	defaultEntriesLimit = 100
+	// wordsPerMinute is the reading speed Miniflux assumes for reading_time
+	wordsPerMinute = 265
+)
+
+var (
+	entryOrders = map[string]string{
+		"id":             "posts.id",
+		"status":         "posts.id",
+		"published_at":   "posts.published",
+		"category_title": "posts.published",
+		"category_id":    "posts.published",
+	}
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+)
+
+type entry struct {
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	FeedID      uint       `json:"feed_id"`
+	Status      string     `json:"status"`
+	Hash        string     `json:"hash"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	CommentsURL string     `json:"comments_url"`
+	PublishedAt time.Time  `json:"published_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ChangedAt   time.Time  `json:"changed_at"`
+	Content     string     `json:"content"`
+	Author      string     `json:"author"`
+	ShareCode   string     `json:"share_code"`
+	Starred     bool       `json:"starred"`
+	ReadingTime int        `json:"reading_time"`
+	Enclosures  []struct{} `json:"enclosures"`
+	Tags        []string   `json:"tags"`
+	Feed        *feed      `json:"feed"`
+}
+
+// entriesQuery applies Miniflux's entry filters to posts from the user's
+// feeds: status, starred, feed_id, category_id, before/after,
+// published_before/after, before_entry_id/after_entry_id and search.
+func entriesQuery(c *gin.Context, userID uint) *gorm.DB {
+	query := database.DB.Model(&models.Post{}).Where("posts.feed_id IN (?)", poststate.SubscribedFeedIDs(userID))
+
+	statuses := c.QueryArray("status")
+	hasRead, hasUnread := false, false
+	for _, s := range statuses {
+		switch s {
+		case statusRead:
+			hasRead = true
+		case statusUnread:
+			hasUnread = true
+		}
+	}
+	switch {
+	case len(statuses) > 0 && !hasRead && !hasUnread:
+		// Only "removed" was asked for, which we never have
+		query = query.Where("1 = 0")
+	case hasRead && !hasUnread:
+		query = query.Where("posts.id IN (?)", poststate.ReadPostIDs(userID))
+	case hasUnread && !hasRead:
+		query = query.Where("posts.id NOT IN (?)", poststate.ReadPostIDs(userID))
+	}
+
+	switch c.Query("starred") {
+	case "true", "1":
+		query = query.Where("posts.id IN (?)", poststate.StarredPostIDs(userID))
+	case "false", "0":
+		query = query.Where("posts.id NOT IN (?)", poststate.StarredPostIDs(userID))
+	}
+
+	if id, err := strconv.ParseUint(c.Param("feedID"), 10, 64); err == nil {
+		query = query.Where("posts.feed_id = ?", id)
+	} else if id, err := strconv.ParseUint(c.Query("feed_id"), 10, 64); err == nil {
+		query = query.Where("posts.feed_id = ?", id)
+	}
+	if id, err := strconv.ParseUint(c.Param("categoryID"), 10, 64); err == nil {
+		query = query.Where("posts.feed_id IN (?)", folderFeedIDs(userID, uint(id)))
+	} else if id, err := strconv.ParseUint(c.Query("category_id"), 10, 64); err == nil {
+		query = query.Where("posts.feed_id IN (?)", folderFeedIDs(userID, uint(id)))
+	}
+
+	for param, op := range map[string]string{
+		"before": "<", "after": ">", "published_before": "<", "published_after": ">",
+	} {
+		if ts, err := strconv.ParseInt(c.Query(param), 10, 64); err == nil && ts > 0 {
+			query = query.Where("posts.published "+op+" ?", time.Unix(ts, 0))
+		}
+	}
+	if id, err := strconv.ParseUint(c.Query("before_entry_id"), 10, 64); err == nil && id > 0 {
+		query = query.Where("posts.id < ?", id)
+	}
+	if id, err := strconv.ParseUint(c.Query("after_entry_id"), 10, 64); err == nil && id > 0 {
+		query = query.Where("posts.id > ?", id)
+	}
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("posts.title ILIKE ? OR posts.content ILIKE ?", like, like)
+	}
+	return query
+}
+
+// GetEntries lists entries; the same handler serves the per-feed and
+// per-category variants
+func GetEntries(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	if c.Param("feedID") != "" {
+		if _, ok := findFeed(c); !ok {
+			return
+		}
+	}
+	if c.Param("categoryID") != "" {
+		if _, ok := findCategory(c); !ok {
+			return
+		}
+	}
+
+	order, ok := entryOrders[c.DefaultQuery("order", "published_at")]
+	if !ok {
+		writeError(c, http.StatusBadRequest, "Invalid order.")
+		return
+	}
+	direction := c.DefaultQuery("direction", "asc")
+	if direction != "asc" && direction != "desc" {
+		writeError(c, http.StatusBadRequest, "Invalid direction.")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultEntriesLimit)))
+	if err != nil || limit < 0 {
+		writeError(c, http.StatusBadRequest, "Invalid limit.")
+		return
+	}
+	if limit == 0 {
+		// Miniflux treats limit=0 as no limit
+		limit = -1
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		writeError(c, http.StatusBadRequest, "Invalid offset.")
+		return
+	}
+
+	query := entriesQuery(c, userID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	var posts []models.Post
+	err = query.Session(&gorm.Session{}).
+		Order(order + " " + direction).Order("posts.id " + direction).
+		Offset(offset).Limit(limit).Find(&posts).Error
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	entries, err := toEntries(userID, posts)
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "entries": entries})
+}
+
+// GetEntry returns one entry, optionally scoped to a feed or category
+func GetEntry(c *gin.Context) {
+	id, ok := paramID(c, "entryID")
+	if !ok {
+		return
+	}
+	userID := c.GetUint("User_id")
+	var posts []models.Post
+	if err := entriesQuery(c, userID).Where("posts.id = ?", id).Find(&posts).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	if len(posts) == 0 {
+		notFound(c)
+		return
+	}
+	entries, err := toEntries(userID, posts)
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries[0])
+}
+
+// UpdateEntries sets the read status of several entries
+func UpdateEntries(c *gin.Context) {
+	var input struct {
+		EntryIDs []uint `json:"entry_ids"`
+		Status   string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || len(input.EntryIDs) == 0 {
+		writeError(c, http.StatusBadRequest, "The list of entries is required.")
+		return
+	}
+	if input.Status != statusRead && input.Status != statusUnread {
+		writeError(c, http.StatusBadRequest, "Invalid status.")
+		return
+	}
+	userID := c.GetUint("User_id")
+	var ids []uint
+	if err := entriesQuery(c, userID).Where("posts.id IN ?", input.EntryIDs).Pluck("posts.id", &ids).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	if err := poststate.SetRead(userID, ids, input.Status == statusRead); err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ToggleBookmark flips the starred flag of an entry
+func ToggleBookmark(c *gin.Context) {
+	id, ok := paramID(c, "entryID")
+	if !ok {
+		return
+	}
+	userID := c.GetUint("User_id")
+	var count int64
+	entriesQuery(c, userID).Where("posts.id = ?", id).Count(&count)
+	if count == 0 {
+		notFound(c)
+		return
+	}
+	states, err := poststate.Load(userID, []uint{id})
+	if err == nil {
+		err = poststate.SetStarred(userID, []uint{id}, !states[id].Starred)
+	}
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func toEntries(userID uint, posts []models.Post) ([]entry, error) {
+	ids := make([]uint, len(posts))
+	feedIDs := make([]uint, 0, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+		feedIDs = append(feedIDs, p.FeedId)
+	}
+	states, err := poststate.Load(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	feeds, err := loadFeeds(userFeedsQuery(userID).Where("feeds.id IN ?", feedIDs), userID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*feed, len(feeds))
+	for i := range feeds {
+		byID[feeds[i].ID] = &feeds[i]
+	}
+
+	entries := make([]entry, len(posts))
+	for i, p := range posts {
+		p = imageproxy.RewritePost(p)
+		state := states[p.ID]
+		status, changed := statusUnread, p.Published
+		if state.Read {
+			status = statusRead
+		}
+		if !state.UpdatedAt.IsZero() {
+			changed = state.UpdatedAt
+		}
+		sum := sha256.Sum256([]byte(p.Link))
+		entries[i] = entry{
+			ID:          p.ID,
+			UserID:      userID,
+			FeedID:      p.FeedId,
+			Status:      status,
+			Hash:        hex.EncodeToString(sum[:]),
+			Title:       p.Title,
+			URL:         p.Link,
+			PublishedAt: p.Published,
+			CreatedAt:   p.Published,
+			ChangedAt:   changed,
+			Content:     p.Content,
+			Author:      p.Author,
+			Starred:     state.Starred,
+			ReadingTime: readingTime(p.Content),
+			Enclosures:  []struct{}{},
+			Tags:        []string{},
+			Feed:        byID[p.FeedId],
+		}
+	}
+	return entries, nil
+}
+
+func readingTime(content string) int {
+	words := len(strings.Fields(tagPattern.ReplaceAllString(content, " ")))
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
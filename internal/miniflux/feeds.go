@@ -0,0 +1,350 @@
+package miniflux
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/poststate"
+	"blogAggregator/internal/rss"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mmcdole/gofeed"
+	"gorm.io/gorm"
+)
+
+// uncategorizedTitle names the category 0 that subscriptions outside any
+// folder report, since every Miniflux feed has a category
+const uncategorizedTitle = "All"
+
+type category struct {
+	ID           uint   `json:"id"`
+	Title        string `json:"title"`
+	UserID       uint   `json:"user_id"`
+	HideGlobally bool   `json:"hide_globally"`
+	FeedCount    *int64 `json:"feed_count,omitempty"`
+	TotalUnread  *int64 `json:"total_unread,omitempty"`
+}
+
+type feed struct {
+	ID                  uint      `json:"id"`
+	UserID              uint      `json:"user_id"`
+	FeedURL             string    `json:"feed_url"`
+	SiteURL             string    `json:"site_url"`
+	Title               string    `json:"title"`
+	CheckedAt           time.Time `json:"checked_at"`
+	EtagHeader          string    `json:"etag_header"`
+	LastModifiedHeader  string    `json:"last_modified_header"`
+	ParsingErrorMessage string    `json:"parsing_error_message"`
+	ParsingErrorCount   int       `json:"parsing_error_count"`
+	ScraperRules        string    `json:"scraper_rules"`
+	RewriteRules        string    `json:"rewrite_rules"`
+	Crawler             bool      `json:"crawler"`
+	BlocklistRules      string    `json:"blocklist_rules"`
+	KeeplistRules       string    `json:"keeplist_rules"`
+	UserAgent           string    `json:"user_agent"`
+	Username            string    `json:"username"`
+	Password            string    `json:"password"`
+	Disabled            bool      `json:"disabled"`
+	IgnoreHTTPCache     bool      `json:"ignore_http_cache"`
+	FetchViaProxy       bool      `json:"fetch_via_proxy"`
+	Category            category  `json:"category"`
+	Icon                *struct{} `json:"icon"`
+}
+
+// feedRow is a subscribed feed with its folder
+type feedRow struct {
+	models.Feed `gorm:"embedded"`
+	FolderID    *uint
+	FolderName  *string
+}
+
+func userFeedsQuery(userID uint) *gorm.DB {
+	return database.DB.Table("subscriptions").
+		Select("feeds.*, subscriptions.folder_id, folders.name AS folder_name").
+		Joins("JOIN feeds ON feeds.id = subscriptions.feed_id").
+		Joins("LEFT JOIN folders ON folders.id = subscriptions.folder_id").
+		Where("subscriptions.user_id = ?", userID).
+		Order("feeds.title")
+}
+
+func loadFeeds(query *gorm.DB, userID uint) ([]feed, error) {
+	var rows []feedRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	feeds := make([]feed, len(rows))
+	for i, r := range rows {
+		feeds[i] = toFeed(r, userID)
+	}
+	return feeds, nil
+}
+
+func toFeed(r feedRow, userID uint) feed {
+	f := feed{
+		ID:       r.ID,
+		UserID:   userID,
+		FeedURL:  r.URL,
+		SiteURL:  siteURL(r.URL),
+		Title:    r.Title,
+		Disabled: r.Disabled,
+		Category: category{Title: uncategorizedTitle, UserID: userID},
+	}
+	if r.LastFetched != nil {
+		f.CheckedAt = *r.LastFetched
+	}
+	if r.FolderID != nil && r.FolderName != nil {
+		f.Category = category{ID: *r.FolderID, Title: *r.FolderName, UserID: userID}
+	}
+	return f
+}
+
+func siteURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// findFeed loads one of the user's feeds from the :feedID parameter,
+// writing a 404 when the user does not subscribe to it
+func findFeed(c *gin.Context) (feed, bool) {
+	id, ok := paramID(c, "feedID")
+	if !ok {
+		return feed{}, false
+	}
+	userID := c.GetUint("User_id")
+	feeds, err := loadFeeds(userFeedsQuery(userID).Where("feeds.id = ?", id), userID)
+	if err != nil {
+		serverError(c, err)
+		return feed{}, false
+	}
+	if len(feeds) == 0 {
+		notFound(c)
+		return feed{}, false
+	}
+	return feeds[0], true
+}
+
+// GetFeeds lists the user's feeds
+func GetFeeds(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	feeds, err := loadFeeds(userFeedsQuery(userID), userID)
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, feeds)
+}
+
+// GetFeed returns one feed
+func GetFeed(c *gin.Context) {
+	if f, ok := findFeed(c); ok {
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+// CreateFeed subscribes to a feed URL, answering 201 with the feed id
+func CreateFeed(c *gin.Context) {
+	var input struct {
+		FeedURL    string `json:"feed_url"`
+		CategoryID uint   `json:"category_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.FeedURL == "" {
+		writeError(c, http.StatusBadRequest, "The feed URL is required")
+		return
+	}
+	userID := c.GetUint("User_id")
+	folderID, ok := categoryRef(c, userID, input.CategoryID)
+	if !ok {
+		return
+	}
+
+	var existing models.Feed
+	err := database.DB.Where("url = ?", input.FeedURL).First(&existing).Error
+	// Other users' newsletter feeds are treated as unknown URLs, which a
+	// mailto: URL fails validation as
+	if err == nil && !existing.VisibleTo(userID) {
+		err = gorm.ErrRecordNotFound
+	}
+	switch {
+	case err == nil:
+		var count int64
+		database.DB.Model(&models.Subscription{}).Where("user_id = ? AND feed_id = ?", userID, existing.ID).Count(&count)
+		if count > 0 {
+			writeError(c, http.StatusBadRequest, "This feed already exists.")
+			return
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := rss.ValidateSource(models.Feed{URL: input.FeedURL}); err != nil {
+			writeError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		parser := gofeed.NewParser()
+		parser.Client = discoveryClient
+		parsed, perr := parser.ParseURL(input.FeedURL)
+		if perr != nil {
+			writeError(c, http.StatusBadRequest, "Unable to parse this feed: "+perr.Error())
+			return
+		}
+		title := parsed.Title
+		if title == "" {
+			title = input.FeedURL
+		}
+		existing = models.Feed{Title: title, URL: input.FeedURL}
+		if err := database.DB.Create(&existing).Error; err != nil {
+			serverError(c, err)
+			return
+		}
+		go func(f models.Feed) {
+			if err := rss.FetchAndStoreFeed(f); err != nil {
+				log.Printf("miniflux: first fetch of %s failed: %v", f.URL, err)
+			}
+		}(existing)
+	default:
+		serverError(c, err)
+		return
+	}
+
+	sub := models.Subscription{UserID: userID, FeedID: existing.ID, FolderID: folderID}
+	if err := database.DB.Create(&sub).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"feed_id": existing.ID})
+}
+
+// UpdateFeed moves a feed to another category. Feeds are shared between
+// users, so the remaining Miniflux settings are accepted and ignored.
+func UpdateFeed(c *gin.Context) {
+	f, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		CategoryID *uint `json:"category_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID := c.GetUint("User_id")
+	if input.CategoryID != nil {
+		folderID, ok := categoryRef(c, userID, *input.CategoryID)
+		if !ok {
+			return
+		}
+		err := database.DB.Model(&models.Subscription{}).
+			Where("user_id = ? AND feed_id = ?", userID, f.ID).
+			Update("folder_id", folderID).Error
+		if err != nil {
+			serverError(c, err)
+			return
+		}
+	}
+	if f, ok = findFeed(c); ok {
+		c.JSON(http.StatusCreated, f)
+	}
+}
+
+// DeleteFeed unsubscribes from a feed
+func DeleteFeed(c *gin.Context) {
+	f, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Where("user_id = ? AND feed_id = ?", c.GetUint("User_id"), f.ID).Delete(&models.Subscription{}).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RefreshFeed fetches one feed right away
+func RefreshFeed(c *gin.Context) {
+	f, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	var model models.Feed
+	if err := database.DB.First(&model, f.ID).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	if err := rss.FetchAndStoreFeed(model); err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RefreshAllFeeds queues a fetch of every feed the user subscribes to
+func RefreshAllFeeds(c *gin.Context) {
+	var feeds []models.Feed
+	if err := database.DB.Where("id IN (?)", poststate.SubscribedFeedIDs(c.GetUint("User_id"))).Find(&feeds).Error; err != nil {
+		serverError(c, err)
+		return
+	}
+	go func() {
+		for _, f := range feeds {
+			if err := rss.FetchAndStoreFeed(f); err != nil {
+				log.Printf("miniflux: refresh of %s failed: %v", f.URL, err)
+			}
+		}
+	}()
+	c.Status(http.StatusNoContent)
+}
+
+// FeedIcon reports that no icon is stored, as Miniflux does for feeds
+// without one
+func FeedIcon(c *gin.Context) {
+	if _, ok := findFeed(c); ok {
+		notFound(c)
+	}
+}
+
+// FeedCounters returns read and unread entry counts per feed
+func FeedCounters(c *gin.Context) {
+	userID := c.GetUint("User_id")
+	var rows []struct {
+		FeedID uint
+		Read   bool
+		Count  int64
+	}
+	err := database.DB.Table("posts").
+		Select("posts.feed_id, COALESCE(post_states.read, false) AS read, COUNT(*) AS count").
+		Joins("LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = ?", userID).
+		Where("posts.feed_id IN (?)", poststate.SubscribedFeedIDs(userID)).
+		Group("posts.feed_id, COALESCE(post_states.read, false)").
+		Scan(&rows).Error
+	if err != nil {
+		serverError(c, err)
+		return
+	}
+	reads, unreads := map[uint]int64{}, map[uint]int64{}
+	for _, r := range rows {
+		if r.Read {
+			reads[r.FeedID] = r.Count
+		} else {
+			unreads[r.FeedID] = r.Count
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"reads": reads, "unreads": unreads})
+}
+
+// MarkFeedAsRead marks every entry of a feed read
+func MarkFeedAsRead(c *gin.Context) {
+	f, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	if err := poststate.MarkFeedsRead(c.GetUint("User_id"), []uint{f.ID}, time.Now()); err != nil {
+		serverError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
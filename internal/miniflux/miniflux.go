@@ -0,0 +1,128 @@
+// Package miniflux mirrors the Miniflux v1 REST API (feeds, categories,
+// entries and discovery) on top of our models, so tools written for
+// Miniflux work unmodified. Folders play the part of categories.
+package miniflux
+
+import (
+	"blogAggregator/internal/apikeys"
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// version is reported by /v1/version; clients use it for feature checks
+const version = "2.0.0"
+
+var enabled bool
+
+// Enable turns on the /v1/ route group
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether the /v1/ routes should be registered
+func Enabled() bool {
+	return enabled
+}
+
+// writeError answers with Miniflux's error shape
+func writeError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"error_message": message})
+}
+
+func notFound(c *gin.Context) {
+	writeError(c, http.StatusNotFound, "Resource not found")
+}
+
+func serverError(c *gin.Context, err error) {
+	writeError(c, http.StatusInternalServerError, err.Error())
+}
+
+// AuthMiddleware accepts an API key in X-Auth-Token or HTTP Basic
+// credentials, like Miniflux. Basic auth is refused for accounts with
+// two-factor authentication; those need an API key.
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.GetHeader("X-Auth-Token"); token != "" {
+			userID, ok := apikeys.Authenticate(token)
+			if !ok {
+				writeError(c, http.StatusUnauthorized, "Access Unauthorized")
+				return
+			}
+			c.Set("User_id", userID)
+			c.Next()
+			return
+		}
+
+		username, password, ok := c.Request.BasicAuth()
+		var user models.User
+		if !ok || database.DB.Where("username = ?", username).First(&user).Error != nil ||
+			user.TOTPEnabled || !auth.CheckPasswordHash(password, user.Password) {
+			writeError(c, http.StatusUnauthorized, "Access Unauthorized")
+			return
+		}
+		c.Set("User_id", user.ID)
+		c.Next()
+	}
+}
+
+// NotImplemented answers Miniflux operations we do not support
+func NotImplemented(c *gin.Context) {
+	writeError(c, http.StatusNotImplemented, "This Miniflux API operation is not supported by this server")
+}
+
+// NoRoute returns 501 for unknown paths under /v1/; other paths keep gin's
+// default 404
+func NoRoute(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/") {
+		NotImplemented(c)
+	}
+}
+
+// Version reports a Miniflux version for client feature detection
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"version":    version,
+		"commit":     "",
+		"build_date": "",
+		"go_version": "",
+		"compiler":   "gc",
+		"arch":       "",
+		"os":         "",
+	})
+}
+
+// Me returns the authenticated user
+func Me(c *gin.Context) {
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("User_id")).Error; err != nil {
+		notFound(c)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":                      user.ID,
+		"username":                user.Username,
+		"is_admin":                auth.IsAdmin(user.Username),
+		"theme":                   "light_serif",
+		"language":                "en_US",
+		"timezone":                "UTC",
+		"entry_sorting_direction": "desc",
+		"entry_sorting_order":     "published_at",
+		"entries_per_page":        100,
+		"last_login_at":           nil,
+	})
+}
+
+func paramID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		writeError(c, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,165 @@
+package miniflux_test
+
+import (
+	"blogAggregator/internal/apikeys"
+	"blogAggregator/internal/auth"
+	"blogAggregator/internal/config"
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/miniflux"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/server"
+	"blogAggregator/internal/testdb"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := auth.Init(config.Config{JWTSecret: "test-secret", JWTIssuer: "test", JWTAudience: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	miniflux.Enable()
+	return server.NewRouter()
+}
+
+func get(r *gin.Engine, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func basic(username, password string) http.Header {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth(username, password)
+	return req.Header
+}
+
+func TestNoRoute(t *testing.T) {
+	r := newRouter(t)
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/v1/users", http.StatusNotImplemented},
+		{http.MethodGet, "/v1/feeds/1/entries/2/fetch-content", http.StatusNotImplemented},
+		{http.MethodDelete, "/v1/me", http.StatusNotImplemented},
+		{http.MethodGet, "/nope", http.StatusNotFound},
+		{http.MethodGet, "/v1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := get(r, tt.method, tt.path, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusNotImplemented {
+			var body struct {
+				ErrorMessage string `json:"error_message"`
+			}
+			if json.Unmarshal(w.Body.Bytes(), &body) != nil || body.ErrorMessage == "" {
+				t.Errorf("%s %s body = %s", tt.method, tt.path, w.Body)
+			}
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	testdb.Open(t)
+	r := newRouter(t)
+	hash, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: hash}
+	database.DB.Create(&alice)
+	// Accounts with 2FA need an API key
+	bob := models.User{Username: "bob", Email: "bob@example.com", Password: hash, TOTPEnabled: true}
+	database.DB.Create(&bob)
+	_, aliceKey, err := apikeys.Create(alice.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bobKey, err := apikeys.Create(bob.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := auth.GenerateToken(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		user   string
+	}{
+		{"API key", http.Header{"X-Auth-Token": {aliceKey}}, "alice"},
+		{"API key of a 2FA account", http.Header{"X-Auth-Token": {bobKey}}, "bob"},
+		{"basic auth", basic("alice", "password"), "alice"},
+		{"nothing", nil, ""},
+		{"unknown API key", http.Header{"X-Auth-Token": {"nope"}}, ""},
+		{"wrong password", basic("alice", "wrong"), ""},
+		{"unknown user", basic("carol", "password"), ""},
+		{"basic auth with 2FA", basic("bob", "password"), ""},
+		{"session token", http.Header{"Authorization": {"Bearer " + session}}, ""},
+		// A bad key is not rescued by good credentials
+		{"unknown API key with basic auth", http.Header{"X-Auth-Token": {"nope"}, "Authorization": basic("alice", "password")["Authorization"]}, ""},
+	}
+	for _, tt := range tests {
+		w := get(r, http.MethodGet, "/v1/me", tt.header)
+		if tt.user == "" {
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: got %d", tt.name, w.Code)
+			}
+			continue
+		}
+		var me struct{ Username string }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &me) != nil || me.Username != tt.user {
+			t.Errorf("%s: got %d %s", tt.name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCreateFeedIgnoresOtherUsersNewsletters(t *testing.T) {
+	testdb.Open(t)
+	r := newRouter(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	feed := models.Feed{
+		Title:      "Digest",
+		URL:        "mailto:digest@example.com?recipient=" + strconv.Itoa(int(alice.ID)),
+		SourceType: models.FeedSourceNewsletter,
+	}
+	database.DB.Create(&feed)
+	_, bobKey, err := apikeys.Create(bob.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/feeds", strings.NewReader(`{"feed_url":"`+feed.URL+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auth-Token", bobKey)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var count int64
+	database.DB.Model(&models.Subscription{}).Where("user_id = ?", bob.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("bob has %d subscriptions", count)
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// newsletterRecipientParam ends the URL of a newsletter feed and names the
+// user it was created for, as in mailto:news@example.com?recipient=42
+const newsletterRecipientParam = "?recipient="
+
+// NewsletterRecipient returns the user a newsletter feed holds mail for.
+// ok is false for feeds of other source types.
+func (f Feed) NewsletterRecipient() (userID uint, ok bool) {
+	if f.SourceType != FeedSourceNewsletter {
+		return 0, false
+	}
+	i := strings.LastIndex(f.URL, newsletterRecipientParam)
+	if i < 0 {
+		return 0, true
+	}
+	id, _ := strconv.ParseUint(f.URL[i+len(newsletterRecipientParam):], 10, 64)
+	return uint(id), true
+}
+
+// VisibleTo reports whether a user may read, subscribe to or watch the
+// feed. Newsletter feeds are private to their recipient, everything else
+// is shared.
+func (f Feed) VisibleTo(userID uint) bool {
+	recipient, ok := f.NewsletterRecipient()
+	return !ok || recipient != 0 && recipient == userID
+}
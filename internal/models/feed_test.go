@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestFeedVisibleTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		feed    Feed
+		userID  uint
+		visible bool
+	}{
+		{"rss feed", Feed{URL: "https://example.com/feed"}, 0, true},
+		{"rss feed for a user", Feed{URL: "https://example.com/feed", SourceType: FeedSourceRSS}, 7, true},
+		{"own newsletter", Feed{URL: "mailto:news@example.com?recipient=7", SourceType: FeedSourceNewsletter}, 7, true},
+		{"other user's newsletter", Feed{URL: "mailto:news@example.com?recipient=7", SourceType: FeedSourceNewsletter}, 8, false},
+		{"longer id with the same prefix", Feed{URL: "mailto:news@example.com?recipient=7", SourceType: FeedSourceNewsletter}, 70, false},
+		{"newsletter for anonymous callers", Feed{URL: "mailto:news@example.com?recipient=7", SourceType: FeedSourceNewsletter}, 0, false},
+		{"newsletter without recipient", Feed{URL: "mailto:news@example.com", SourceType: FeedSourceNewsletter}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.feed.VisibleTo(tt.userID); got != tt.visible {
+			t.Errorf("%s: VisibleTo(%d) = %v", tt.name, tt.userID, got)
+		}
+	}
+}
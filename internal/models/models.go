@@ -7,14 +7,120 @@ type User struct {
 	Username string `gorm:"unique;not null" json:"username"`
 	Email     string `gorm:"uniqueIndex;not null" json:"email"`
 	Password  string `json:"-"`
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"totp_enabled"`
+	TOTPLastStep int64  `json:"-"`
+	// Second factor attempts in a row over all pending logins, the latest
+	// at TOTPFailedAt
+	TOTPFailures int        `gorm:"not null;default:0" json:"-"`
+	TOTPFailedAt *time.Time `json:"-"`
+	// Identity at an OpenID Connect provider for users linked through SSO
+	OIDCIssuer  *string `gorm:"column:oidc_issuer;uniqueIndex:idx_users_oidc_identity" json:"-"`
+	OIDCSubject *string `gorm:"column:oidc_subject;uniqueIndex:idx_users_oidc_identity" json:"-"`
+	// md5("username:password") of the separate password for Fever clients
+	FeverAPIKey *string `gorm:"uniqueIndex" json:"-"`
+	// Local part of the user's newsletter address, nil when not enabled
+	NewsletterToken *string `gorm:"uniqueIndex" json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// RecoveryCode is a single-use bcrypt hashed fallback for TOTP codes
+type RecoveryCode struct {
+	ID       uint       `gorm:"primaryKey" json:"id"`
+	UserID   uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash string     `gorm:"not null" json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// LoginChallenge is a pending two-factor login, named by the jti of its
+// challenge token. It is deleted when used or after too many wrong codes.
+type LoginChallenge struct {
+	ID        string    `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	Failures  int       `gorm:"not null;default:0" json:"-"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"-"`
+}
+
+// StreamTicket lets an EventSource, which cannot send headers, open the
+// post stream. It is single use, short-lived and stored as a SHA-256 hash.
+type StreamTicket struct {
+	TokenHash string    `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"-"`
+}
+
+
+// Feed source types. Newsletter feeds are filled by the SMTP receiver, one
+// per sender, and have a mailto: URL instead of something to fetch. Scrape
+// feeds extract items from an HTML page with the selectors in SourceConfig,
+// and JSON feeds map items of a JSON document with JSONPath expressions.
+// GitHub, Reddit and Hacker News feeds read those sites' APIs for the
+// repository, subreddit or listing in URL.
+const (
+	FeedSourceRSS        = "rss"
+	FeedSourceNewsletter = "newsletter"
+	FeedSourceScrape     = "scrape"
+	FeedSourceJSON       = "json"
+	FeedSourceGitHub     = "github"
+	FeedSourceReddit     = "reddit"
+	FeedSourceHackerNews = "hackernews"
+)
+
+// SourceConfig holds the settings of source types other than plain RSS
+type SourceConfig struct {
+	Scrape *ScrapeConfig `json:"scrape,omitempty"`
+	JSON   *JSONConfig   `json:"json,omitempty"`
+}
+
+// ScrapeConfig turns a web page into feed items. Each item is an element
+// matched by ItemSelector; the other selectors run inside it. Without a
+// LinkSelector the first link in the item is used, and DateFormats are Go
+// time layouts tried before the common formats.
+type ScrapeConfig struct {
+	ItemSelector    string   `json:"item_selector"`
+	TitleSelector   string   `json:"title_selector"`
+	LinkSelector    string   `json:"link_selector"`
+	DateSelector    string   `json:"date_selector"`
+	ContentSelector string   `json:"content_selector"`
+	DateFormats     []string `json:"date_formats"`
+}
+
+// JSONConfig maps a JSON document to feed items. ItemsPath selects the
+// items, e.g. "$.releases[*]"; the other paths are evaluated against each
+// item, e.g. "$.name". Dates may be strings, parsed like scraped dates, or
+// Unix timestamps in seconds or milliseconds.
+type JSONConfig struct {
+	ItemsPath   string   `json:"items_path"`
+	TitlePath   string   `json:"title_path"`
+	LinkPath    string   `json:"link_path"`
+	IDPath      string   `json:"id_path"`
+	DatePath    string   `json:"date_path"`
+	BodyPath    string   `json:"body_path"`
+	DateFormats []string `json:"date_formats"`
+}
 
 type Feed struct{
 	ID uint `gorm:"primaryKey" json:"id"`
 	Title string `json:"title"`
 	URL string `gorm:"uniqueIndex;not null" json:"url"`
+	SourceType string `gorm:"not null;default:rss" json:"source_type"`
+	SourceConfig *SourceConfig `gorm:"type:text;serializer:json" json:"source_config,omitempty"`
+	// Static request headers, e.g. for API auth; never returned by the API
+	SourceHeaders map[string]string `gorm:"type:text;serializer:json" json:"-"`
+	// Failed fetches push NextFetchAt back exponentially until one succeeds
+	ErrorCount  int        `gorm:"not null;default:0" json:"error_count"`
+	LastError   string     `json:"last_error,omitempty"`
+	NextFetchAt *time.Time `gorm:"index" json:"next_fetch_at,omitempty"`
+	// WebSub hub and topic advertised by the feed, empty when it has none
+	HubURL   string `json:"hub_url,omitempty"`
+	TopicURL string `json:"-"`
+	// Disabled feeds are not fetched on schedule, e.g. after 410 Gone
+	Disabled bool `gorm:"not null;default:false" json:"disabled"`
+	// Fetch each new item's linked page and extract the full article
+	FetchFullContent bool `gorm:"not null;default:false" json:"fetch_full_content"`
+	// Retention overrides; nil follows the global policy, 0 keeps posts
+	RetentionDays     *int `json:"retention_days,omitempty"`
+	RetentionMaxPosts *int `json:"retention_max_posts,omitempty"`
   CreatedAt time.Time `json:"created_at"`
 	LastFetched *time.Time `json:"last_fetched"`
 }
@@ -23,8 +129,22 @@ type Post struct{
 	ID uint `gorm:"primaryKey" json:"id"`
 	Title string `json:"title"`
 	Link string `gorm:"uniqueIndex;not null" json:"link"`
+	// Source specific item id, used instead of the link to deduplicate
+	GUID string `gorm:"index" json:"guid,omitempty"`
+	Author string `json:"author,omitempty"`
+	// Source specific extras such as score, comment count or release tag
+	Metadata map[string]interface{} `gorm:"type:text;serializer:json" json:"metadata,omitempty"`
+	// Content is sanitized HTML; ContentText is its plain text rendering
+	// for previews and search
 	Content string `json:"content"`
+	ContentText string `gorm:"type:text" json:"content_text,omitempty"`
+	// Article extracted from the linked page, kept apart from the feed content
+	FullContent string `gorm:"type:text" json:"full_content,omitempty"`
+	FullContentFetchedAt *time.Time `json:"full_content_fetched_at,omitempty"`
+	// Published falls back to FirstSeenAt for items without a usable date
 	Published time.Time `json:"published"`
+	// When the fetcher first stored the item
+	FirstSeenAt *time.Time `json:"first_seen_at,omitempty"`
 	FeedId uint `json:"feed_id"`
 }
 
@@ -33,4 +153,179 @@ type Subscription struct{
 	ID uint `gorm:"primaryKey" json:"id"`
 	UserID uint `json:"user_id"`
 	FeedID uint `json:"feed_id"`
-}
\ No newline at end of file
+	FolderID *uint `gorm:"index" json:"folder_id"`
+	// Ask for full articles of this feed even when the feed does not
+	FetchFullContent bool `gorm:"not null;default:false" json:"fetch_full_content"`
+}
+
+// ExtractionRule tells the full-article extractor where the content is on a
+// site, replacing the readability heuristic for pages on Host
+type ExtractionRule struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Host            string    `gorm:"uniqueIndex;not null" json:"host"`
+	ContentSelector string    `gorm:"not null" json:"content_selector"`
+	// Removed from the extracted content, e.g. share buttons
+	RemoveSelectors []string  `gorm:"type:text;serializer:json" json:"remove_selectors"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ExtractionJob queues the full-article extraction of a new post. Jobs are
+// retried with backoff and deleted once they succeed or give up.
+type ExtractionJob struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PostID        uint      `gorm:"uniqueIndex;not null" json:"post_id"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time `gorm:"index;not null" json:"next_attempt_at"`
+	Error         string    `json:"error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PostTombstone remembers a post removed by retention so fetching its feed
+// again does not bring it back. Items are matched by GUID when they have
+// one and by link otherwise, as when they were stored.
+type PostTombstone struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	FeedID   uint      `gorm:"index:idx_post_tombstones_feed_guid;index:idx_post_tombstones_feed_link;not null" json:"feed_id"`
+	GUID     string    `gorm:"index:idx_post_tombstones_feed_guid" json:"guid,omitempty"`
+	Link     string    `gorm:"index:idx_post_tombstones_feed_link" json:"link"`
+	PrunedAt time.Time `json:"pruned_at"`
+	// Tombstones the feed has not listed for a long time are dropped
+	LastSeenAt time.Time `gorm:"index" json:"last_seen_at"`
+}
+
+// FetchLog records one fetch attempt of a feed. Only the latest attempts
+// of each feed are kept.
+type FetchLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FeedID     uint      `gorm:"index:idx_fetch_logs_feed_started;not null" json:"feed_id"`
+	StartedAt  time.Time `gorm:"index:idx_fetch_logs_feed_started" json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	// StatusCode is the HTTP status of the feed document, 0 when no
+	// response arrived
+	StatusCode   int    `json:"status_code"`
+	Bytes        int64  `json:"bytes"`
+	ItemCount    int    `json:"item_count"`
+	NewCount     int    `json:"new_count"`
+	UpdatedCount int    `json:"updated_count"`
+	Error        string `gorm:"type:text" json:"error,omitempty"`
+}
+
+// Folder groups a user's subscriptions
+type Folder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_folders_user_name;not null" json:"user_id"`
+	Name      string    `gorm:"uniqueIndex:idx_folders_user_name;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Webhook is a user-managed endpoint notified about new posts. Empty filter
+// lists match everything; without FeedIDs only subscribed feeds are considered.
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	URL       string    `gorm:"not null" json:"url"`
+	Secret    string    `gorm:"not null" json:"-"`
+	FeedIDs   []uint    `gorm:"type:text;serializer:json" json:"feed_ids"`
+	FolderIDs []uint    `gorm:"type:text;serializer:json" json:"folder_ids"`
+	Keywords  []string  `gorm:"type:text;serializer:json" json:"keywords"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one queued event for a webhook and its delivery log
+type WebhookDelivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	WebhookID     uint       `gorm:"index;not null" json:"webhook_id"`
+	Event         string     `gorm:"not null" json:"event"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"index;not null" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	ResponseCode  int        `json:"response_code"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// DigestSetting controls a user's periodic email digest. Hour and Weekday
+// (0 = Sunday, weekly digests only) are interpreted in Timezone.
+type DigestSetting struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Enabled    bool       `json:"enabled"`
+	Frequency  string     `gorm:"not null;default:daily" json:"frequency"`
+	Hour       int        `json:"hour"`
+	Weekday    int        `json:"weekday"`
+	Timezone   string     `gorm:"not null;default:UTC" json:"timezone"`
+	FeedIDs    []uint     `gorm:"type:text;serializer:json" json:"feed_ids"`
+	FolderIDs  []uint     `gorm:"type:text;serializer:json" json:"folder_ids"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Digest records one digest run for a user
+type Digest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	SentAt    time.Time `json:"sent_at"`
+	PostCount int       `json:"post_count"`
+}
+
+// DigestItem records that a post went out in a digest, so it is never repeated
+type DigestItem struct {
+	ID       uint `gorm:"primaryKey" json:"id"`
+	DigestID uint `gorm:"index;not null" json:"digest_id"`
+	UserID   uint `gorm:"uniqueIndex:idx_digest_items_user_post;not null" json:"user_id"`
+	PostID   uint `gorm:"uniqueIndex:idx_digest_items_user_post;not null" json:"post_id"`
+}
+
+// PostState is a user's read and starred flags for a post. Posts without a
+// row are unread and not starred.
+type PostState struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"uniqueIndex:idx_post_states_user_post;not null" json:"user_id"`
+	PostID    uint       `gorm:"uniqueIndex:idx_post_states_user_post;index;not null" json:"post_id"`
+	Read      bool       `gorm:"not null;default:false" json:"read"`
+	Starred   bool       `gorm:"not null;default:false" json:"starred"`
+	ReadAt    *time.Time `json:"read_at"`
+	StarredAt *time.Time `json:"starred_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// APIKey is a long-lived token for scripts and third-party API clients.
+// Only the SHA-256 of the token is stored.
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Description string     `gorm:"not null" json:"description"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// WebSub subscription states
+const (
+	WebSubPending = "pending"
+	WebSubActive  = "active"
+	WebSubDenied  = "denied"
+	WebSubFailed  = "failed"
+)
+
+// WebSubSubscription is our push subscription to a feed's hub. The hub
+// calls back on /websub/{CallbackToken} and signs content with Secret.
+type WebSubSubscription struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	FeedID        uint       `gorm:"uniqueIndex;not null" json:"feed_id"`
+	HubURL        string     `gorm:"not null" json:"hub_url"`
+	Topic         string     `gorm:"not null" json:"topic"`
+	CallbackToken string     `gorm:"uniqueIndex;not null" json:"-"`
+	Secret        string     `gorm:"not null" json:"-"`
+	State         string     `gorm:"index;not null" json:"state"`
+	LeaseSeconds  int        `json:"lease_seconds"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	LastPushAt    *time.Time `json:"last_push_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,214 @@
+package newsletter
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/events"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/sanitize"
+	"blogAggregator/internal/webhooks"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-smtp"
+	"gorm.io/gorm"
+)
+
+// parsedMail is what a newsletter post is built from
+type parsedMail struct {
+	fromName    string
+	fromAddress string
+	subject     string
+	messageID   string
+	date        time.Time
+	html        string
+	text        string
+}
+
+var errNoSender = &smtp.SMTPError{
+	Code:         550,
+	EnhancedCode: smtp.EnhancedCode{5, 1, 7},
+	Message:      "a sender address is required",
+}
+
+// parse reads the headers and the first HTML and plain text bodies.
+// Attachments are dropped.
+func parse(r io.Reader, envelopeFrom string) (parsedMail, error) {
+	var m parsedMail
+	mr, err := mail.CreateReader(r)
+	// An unknown charset still yields a reader for the raw bytes
+	if err != nil && !message.IsUnknownCharset(err) {
+		return m, err
+	}
+	defer mr.Close()
+
+	if from, err := mr.Header.AddressList("From"); err == nil && len(from) > 0 {
+		m.fromName, m.fromAddress = from[0].Name, from[0].Address
+	}
+	if m.fromAddress == "" {
+		m.fromAddress = envelopeFrom
+	}
+	m.fromAddress = strings.ToLower(strings.TrimSpace(m.fromAddress))
+	if m.fromAddress == "" {
+		return m, errNoSender
+	}
+	m.subject, _ = mr.Header.Subject()
+	m.messageID, _ = mr.Header.MessageID()
+	m.date, _ = mr.Header.Date()
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if message.IsUnknownCharset(err) {
+				continue
+			}
+			return m, err
+		}
+		h, ok := part.Header.(*mail.InlineHeader)
+		if !ok {
+			continue
+		}
+		contentType, _, _ := h.ContentType()
+		switch {
+		case contentType == "text/html" && m.html == "":
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return m, err
+			}
+			m.html = string(body)
+		case (contentType == "text/plain" || contentType == "") && m.text == "":
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return m, err
+			}
+			m.text = string(body)
+		}
+	}
+	return m, nil
+}
+
+// store files a copy of the message for every recipient, on the
+// recipient's own feed for the sender. From can be forged, so feeds are
+// never shared between recipients: a sender can only reach the inboxes it
+// has the address of.
+func store(m parsedMail, userIDs []uint) error {
+	var created []models.Post
+	defer func() {
+		if err := webhooks.QueuePosts(created); err != nil {
+			log.Printf("newsletter: webhooks for message %s: %v", m.link(), err)
+		}
+		for _, post := range created {
+			events.NewPosts.Publish(post)
+		}
+	}()
+	for _, userID := range userIDs {
+		post, isNew, err := storeFor(m, userID)
+		if err != nil {
+			return err
+		}
+		if isNew {
+			created = append(created, post)
+		}
+	}
+	return nil
+}
+
+// storeFor files the message on the recipient's feed for the sender and
+// subscribes the recipient to it
+func storeFor(m parsedMail, userID uint) (models.Post, bool, error) {
+	feed := models.Feed{
+		Title:      m.feedTitle(),
+		URL:        feedURL(m.fromAddress, userID),
+		SourceType: models.FeedSourceNewsletter,
+	}
+	if err := database.DB.Where("url = ?", feed.URL).FirstOrCreate(&feed).Error; err != nil {
+		return models.Post{}, false, err
+	}
+	sub := models.Subscription{UserID: userID, FeedID: feed.ID}
+	if err := database.DB.Where("user_id = ? AND feed_id = ?", userID, feed.ID).FirstOrCreate(&sub).Error; err != nil {
+		return models.Post{}, false, err
+	}
+
+	now := time.Now().UTC()
+	post := models.Post{
+		Title:       m.subject,
+		Link:        m.link() + recipientSuffix(userID),
+		Content:     m.content(),
+		Published:   m.published(),
+		FirstSeenAt: &now,
+		FeedId:      feed.ID,
+	}
+	post.ContentText = sanitize.PlainText(post.Content)
+	if post.Title == "" {
+		post.Title = "(no subject)"
+	}
+	result := database.DB.Where("link = ?", post.Link).FirstOrCreate(&post)
+	if result.Error != nil {
+		return post, false, result.Error
+	}
+	return post, result.RowsAffected > 0, database.DB.Model(&feed).Update("last_fetched", &now).Error
+}
+
+// feedURL names a recipient's feed for a sender
+func feedURL(sender string, userID uint) string {
+	return "mailto:" + sender + recipientSuffix(userID)
+}
+
+// HiddenFeedIDs selects the ids of newsletter feeds holding other users'
+// mail, which the user must not see. With userID 0, for anonymous
+// callers, that is every newsletter feed.
+func HiddenFeedIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.Feed{}).Select("id").
+		Where("source_type = ? AND url NOT LIKE ?", models.FeedSourceNewsletter, "%"+recipientSuffix(userID))
+}
+
+// recipientSuffix scopes feed URLs and post links to one recipient, by
+// user id rather than the address token, which is secret and can change
+func recipientSuffix(userID uint) string {
+	return "?recipient=" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func (m parsedMail) feedTitle() string {
+	if m.fromName != "" {
+		return m.fromName
+	}
+	return m.fromAddress
+}
+
+// link is a mid: URL (RFC 2392) so a message delivered twice is stored
+// once per recipient
+func (m parsedMail) link() string {
+	id := m.messageID
+	if id == "" {
+		sum := sha256.Sum256([]byte(m.fromAddress + "\n" + m.subject + "\n" + m.date.String() + "\n" + m.html + m.text))
+		id = hex.EncodeToString(sum[:16]) + "@" + domain
+	}
+	return "mid:" + url.PathEscape(id)
+}
+
+func (m parsedMail) content() string {
+	if m.html != "" {
+		return sanitize.HTML(m.html)
+	}
+	return sanitize.Text(m.text)
+}
+
+// published trusts the Date header unless it is missing or in the future
+func (m parsedMail) published() time.Time {
+	now := time.Now().UTC()
+	if m.date.IsZero() || m.date.After(now) {
+		return now
+	}
+	return m.date.UTC()
+}
@@ -0,0 +1,135 @@
+package newsletter
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"strings"
+	"testing"
+)
+
+const testMessage = "From: Weekly Digest <Digest@Example.com>\r\n" +
+	"To: someone@news.test\r\n" +
+	"Subject: Issue 12\r\n" +
+	"Message-ID: <issue-12@example.com>\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hello readers\r\n"
+
+func TestParse(t *testing.T) {
+	m, err := parse(strings.NewReader(testMessage), "bounce@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.fromName != "Weekly Digest" || m.fromAddress != "digest@example.com" || m.subject != "Issue 12" ||
+		m.messageID != "issue-12@example.com" || !strings.Contains(m.text, "Hello readers") {
+		t.Fatalf("parsed %+v", m)
+	}
+	if m.link() != "mid:issue-12@example.com" {
+		t.Fatalf("link = %s", m.link())
+	}
+}
+
+func TestParseRequiresSender(t *testing.T) {
+	msg := strings.Replace(testMessage, "From: Weekly Digest <Digest@Example.com>\r\n", "", 1)
+	if _, err := parse(strings.NewReader(msg), ""); err != errNoSender {
+		t.Fatalf("parse error = %v", err)
+	}
+}
+
+func TestStoreKeepsRecipientsApart(t *testing.T) {
+	testdb.Open(t)
+	Configure("news.test")
+	t.Cleanup(func() { Configure("") })
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+
+	m, err := parse(strings.NewReader(testMessage), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store(m, []uint{alice.ID, bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+	// A second delivery of the same message is stored once per recipient
+	if err := store(m, []uint{alice.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []models.User{alice, bob} {
+		var feed models.Feed
+		if err := database.DB.Where("url = ?", feedURL("digest@example.com", user.ID)).First(&feed).Error; err != nil {
+			t.Fatalf("%s has no feed: %v", user.Username, err)
+		}
+		var subs []models.Subscription
+		database.DB.Where("feed_id = ?", feed.ID).Find(&subs)
+		if len(subs) != 1 || subs[0].UserID != user.ID {
+			t.Fatalf("subscriptions of %s's feed: %+v", user.Username, subs)
+		}
+		var posts int64
+		database.DB.Model(&models.Post{}).Where("feed_id = ?", feed.ID).Count(&posts)
+		if posts != 1 {
+			t.Fatalf("%s's feed has %d posts", user.Username, posts)
+		}
+	}
+
+	// Mail that forges the sender only lands on the recipient's own feed
+	forged := strings.Replace(testMessage, "issue-12", "forged", 1)
+	m, _ = parse(strings.NewReader(forged), "")
+	if err := store(m, []uint{bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+	var alicePosts int64
+	database.DB.Model(&models.Post{}).Joins("JOIN feeds ON feeds.id = posts.feed_id").
+		Where("feeds.url = ?", feedURL("digest@example.com", alice.ID)).Count(&alicePosts)
+	if alicePosts != 1 {
+		t.Fatalf("alice's feed has %d posts after mail to bob", alicePosts)
+	}
+}
+
+func TestHiddenFeedIDs(t *testing.T) {
+	testdb.Open(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	m, err := parse(strings.NewReader(testMessage), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store(m, []uint{alice.ID, bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+	shared := models.Feed{Title: "Blog", URL: "https://example.com/feed"}
+	database.DB.Create(&shared)
+	feedID := func(user models.User) uint {
+		var feed models.Feed
+		database.DB.Where("url = ?", feedURL("digest@example.com", user.ID)).First(&feed)
+		if recipient, ok := feed.NewsletterRecipient(); !ok || recipient != user.ID {
+			t.Fatalf("recipient of %s's feed = %d, %v", user.Username, recipient, ok)
+		}
+		return feed.ID
+	}
+	aliceFeed, bobFeed := feedID(alice), feedID(bob)
+
+	tests := []struct {
+		userID uint
+		hidden []uint
+	}{
+		{alice.ID, []uint{bobFeed}},
+		{bob.ID, []uint{aliceFeed}},
+		{0, []uint{aliceFeed, bobFeed}},
+	}
+	for _, tt := range tests {
+		var hidden []uint
+		if err := HiddenFeedIDs(tt.userID).Order("id").Pluck("id", &hidden).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(hidden) != len(tt.hidden) || hidden[0] != tt.hidden[0] || hidden[len(hidden)-1] != tt.hidden[len(tt.hidden)-1] {
+			t.Errorf("HiddenFeedIDs(%d) = %v, want %v", tt.userID, hidden, tt.hidden)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package newsletter is an optional SMTP receiver that turns email-only
+// newsletters into posts. Every user can have an address on the configured
+// domain; mail sent to it lands on a synthetic feed per recipient and
+// sender, which the recipient is subscribed to.
+package newsletter
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-smtp"
+)
+
+const (
+	maxMessageBytes = 10 << 20
+	maxRecipients   = 50
+	ioTimeout       = time.Minute
+)
+
+var (
+	domain string
+
+	tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	errUnknownRecipient = &smtp.SMTPError{
+		Code:         550,
+		EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+		Message:      "no such mailbox",
+	}
+	errTemporary = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+		Message:      "could not store message, try again later",
+	}
+)
+
+// Configure sets the domain addresses are handed out on
+func Configure(mailDomain string) {
+	domain = strings.ToLower(mailDomain)
+}
+
+// Enabled reports whether the receiver is configured
+func Enabled() bool {
+	return domain != ""
+}
+
+// Address is the inbox for a user's token
+func Address(token string) string {
+	return token + "@" + domain
+}
+
+// NewToken returns a random local part for a newsletter address
+func NewToken() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(tokenEncoding.EncodeToString(b)), nil
+}
+
+// ListenAndServe accepts mail on addr until the listener fails
+func ListenAndServe(addr string) error {
+	s := smtp.NewServer(smtp.BackendFunc(func(*smtp.Conn) (smtp.Session, error) {
+		return &session{}, nil
+	}))
+	s.Addr = addr
+	s.Domain = domain
+	s.MaxMessageBytes = maxMessageBytes
+	s.MaxRecipients = maxRecipients
+	s.ReadTimeout = ioTimeout
+	s.WriteTimeout = ioTimeout
+	log.Printf("newsletter: accepting mail for *@%s on %s", domain, addr)
+	return s.ListenAndServe()
+}
+
+// session collects the envelope of one message
+type session struct {
+	from    string
+	userIDs []uint
+}
+
+func (s *session) Reset() {
+	s.from = ""
+	s.userIDs = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) Mail(from string, _ *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+// Rcpt refuses addresses that do not belong to a user, so senders get a
+// bounce instead of mail disappearing
+func (s *session) Rcpt(to string, _ *smtp.RcptOptions) error {
+	userID, ok := lookupRecipient(to)
+	if !ok {
+		return errUnknownRecipient
+	}
+	for _, id := range s.userIDs {
+		if id == userID {
+			return nil
+		}
+	}
+	s.userIDs = append(s.userIDs, userID)
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	msg, err := parse(r, s.from)
+	if err != nil {
+		// Drain the rest so the error reaches the client cleanly
+		io.Copy(io.Discard, r)
+		var smtpErr *smtp.SMTPError
+		if errors.As(err, &smtpErr) {
+			return smtpErr
+		}
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "malformed message",
+		}
+	}
+	if err := store(msg, s.userIDs); err != nil {
+		log.Printf("newsletter: storing message from %s failed: %v", msg.fromAddress, err)
+		return errTemporary
+	}
+	return nil
+}
+
+func lookupRecipient(to string) (uint, bool) {
+	at := strings.LastIndex(to, "@")
+	if at < 0 || !strings.EqualFold(to[at+1:], domain) {
+		return 0, false
+	}
+	var user models.User
+	err := database.DB.Where("newsletter_token = ?", strings.ToLower(to[:at])).First(&user).Error
+	if err != nil {
+		return 0, false
+	}
+	return user.ID, true
+}
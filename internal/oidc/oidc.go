@@ -0,0 +1,299 @@
+// Package oidc implements the relying party side of OpenID Connect: discovery,
+// the authorization-code flow with PKCE and ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+const jwksRefreshInterval = time.Minute
+
+// Provider is a configured OpenID Connect identity provider
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Client       *http.Client
+
+	mu          sync.Mutex
+	discovery   *discoveryDocument
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint reply
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// IDTokenClaims holds the identity claims used for login
+type IDTokenClaims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// NewProvider creates a provider; discovery happens lazily on first use so
+// the server can start while the identity provider is unreachable.
+func NewProvider(issuer, clientID, clientSecret, redirectURL string, scopes []string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the authorization endpoint URL to redirect the user to
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange trades an authorization code and PKCE verifier for tokens
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*TokenResponse, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokens TokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return &tokens, nil
+}
+
+// VerifyIDToken checks the ID token signature against the provider's JWKS
+// and validates iss, aud, exp, iat and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid id token claims")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	// With several audiences the token must have been issued to us
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, errors.New("id token azp mismatch")
+		}
+	}
+
+	result := &IDTokenClaims{Issuer: doc.Issuer}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	result.Name, _ = claims["name"].(string)
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+	if result.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	return result, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("oidc discovery issuer %q does not match %q", doc.Issuer, p.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is missing endpoints")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// getKey returns the provider key for kid, refetching the JWKS when the kid
+// is unknown so provider-side key rotation is picked up.
+func (p *Provider) getKey(ctx context.Context, kid string) (interface{}, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set jwkSet
+	if err := p.getJSON(ctx, doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching jwks failed: %w", err)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetched = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a key by kid; tokens without a kid are accepted only when
+// the provider publishes exactly one key.
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// NewPKCE returns a code verifier and its S256 challenge (RFC 7636)
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
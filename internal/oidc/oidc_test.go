@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"blogAggregator/internal/oidc/oidctest"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const redirectURL = "http://app.test/auth/oidc/callback"
+
+func newTestProvider(idp *oidctest.Server) *Provider {
+	p := NewProvider(idp.URL, oidctest.ClientID, oidctest.ClientSecret, redirectURL, nil)
+	p.Client = idp.Client()
+	return p
+}
+
+// login runs the authorization request and returns the code and state the
+// provider redirected back with
+func login(t *testing.T, idp *oidctest.Server, p *Provider, state, nonce, challenge string) (string, string) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := idp.Authorize(t, authURL)
+	if !strings.HasPrefix(callback.String(), redirectURL+"?") {
+		t.Fatalf("redirected to %s", callback)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.NewServer(t)
+	idp.Claims["preferred_username"] = "alice"
+	p := newTestProvider(idp)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := login(t, idp, p, "state-1", "nonce-1", challenge)
+	if state != "state-1" {
+		t.Fatalf("state = %q", state)
+	}
+	tokens, err := p.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(context.Background(), tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != idp.URL || claims.Subject != "user-1" || claims.Email != "user@example.com" ||
+		!claims.EmailVerified || claims.PreferredUsername != "alice" {
+		t.Fatalf("claims = %+v", claims)
+	}
+}
+
+func TestAuthCodeURLSendsS256Challenge(t *testing.T) {
+	idp := oidctest.NewServer(t)
+	p := newTestProvider(idp)
+	authURL, err := p.AuthCodeURL(context.Background(), "s", "n", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("code_challenge") != "challenge" || q.Get("code_challenge_method") != "S256" ||
+		q.Get("state") != "s" || q.Get("nonce") != "n" || q.Get("redirect_uri") != redirectURL {
+		t.Fatalf("authorization URL %s", authURL)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := oidctest.NewServer(t)
+	p := newTestProvider(idp)
+	_, challenge, _ := NewPKCE()
+	otherVerifier, _, _ := NewPKCE()
+
+	code, _ := login(t, idp, p, "s", "n", challenge)
+	if _, err := p.Exchange(context.Background(), code, otherVerifier); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier succeeded")
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	idp := oidctest.NewServer(t)
+	p := newTestProvider(idp)
+	verifier, challenge, _ := NewPKCE()
+
+	code, _ := login(t, idp, p, "s", "n", challenge)
+	if _, err := p.Exchange(context.Background(), code, verifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(context.Background(), code, verifier); err == nil {
+		t.Fatal("second exchange of the same code succeeded")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(idp *oidctest.Server)
+		nonce  string
+		reject string
+	}{
+		{name: "valid", nonce: "nonce-1"},
+		{name: "nonce mismatch", nonce: "other-nonce", reject: "nonce"},
+		{
+			name:   "invalid signature",
+			setup:  func(idp *oidctest.Server) { idp.SigningKey = oidctest.NewKey(t) },
+			nonce:  "nonce-1",
+			reject: "signature",
+		},
+		{
+			name:   "wrong audience",
+			setup:  func(idp *oidctest.Server) { idp.Claims["aud"] = "another-client" },
+			nonce:  "nonce-1",
+			reject: "audience",
+		},
+		{
+			name:   "wrong issuer",
+			setup:  func(idp *oidctest.Server) { idp.Claims["iss"] = "https://evil.test" },
+			nonce:  "nonce-1",
+			reject: "issuer",
+		},
+		{
+			name:   "expired",
+			setup:  func(idp *oidctest.Server) { idp.Claims["exp"] = 1 },
+			nonce:  "nonce-1",
+			reject: "expired",
+		},
+		{
+			name: "azp mismatch",
+			setup: func(idp *oidctest.Server) {
+				idp.Claims["aud"] = []string{oidctest.ClientID, "another-client"}
+				idp.Claims["azp"] = "another-client"
+			},
+			nonce:  "nonce-1",
+			reject: "azp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.NewServer(t)
+			if tt.setup != nil {
+				tt.setup(idp)
+			}
+			p := newTestProvider(idp)
+			verifier, challenge, _ := NewPKCE()
+			code, _ := login(t, idp, p, "s", "nonce-1", challenge)
+			tokens, err := p.Exchange(context.Background(), code, verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.VerifyIDToken(context.Background(), tokens.IDToken, tt.nonce)
+			switch {
+			case tt.reject == "" && err != nil:
+				t.Fatalf("rejected a valid token: %v", err)
+			case tt.reject != "" && err == nil:
+				t.Fatal("accepted the token")
+			case tt.reject != "" && !strings.Contains(err.Error(), tt.reject):
+				t.Fatalf("error %q does not mention %s", err, tt.reject)
+			}
+		})
+	}
+}
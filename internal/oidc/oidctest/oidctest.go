@@ -0,0 +1,215 @@
+// Package oidctest runs a minimal OpenID Connect provider for tests. It
+// serves discovery and a JWKS, an authorization endpoint that immediately
+// redirects back with a code, and a token endpoint that checks the client
+// credentials and the PKCE verifier before issuing an RS256 ID token.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClientID     = "test-client"
+	ClientSecret = "test-secret"
+	keyID        = "test-key"
+)
+
+// Server is a running test provider. Claims are added to every ID token,
+// overriding the defaults; set SigningKey to sign with a key other than
+// the published one.
+type Server struct {
+	*httptest.Server
+	Key        *rsa.PrivateKey
+	SigningKey *rsa.PrivateKey
+	Claims     map[string]interface{}
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+// NewServer starts a provider that is closed when the test ends
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Key:        key,
+		SigningKey: key,
+		Claims:     map[string]interface{}{},
+		grants:     map[string]grant{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewKey returns a key the provider does not publish
+func NewKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// Authorize follows an authorization URL as the user's browser would and
+// returns the redirect back to the client
+func (s *Server) Authorize(t *testing.T, authURL string) *url.URL {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.Key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.grants[code] = grant{
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	s.mu.Unlock()
+
+	target, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != ClientID || secret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// Codes are single use
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            ClientID,
+		"sub":            "user-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"nonce":          g.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	for k, v := range s.Claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.SigningKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
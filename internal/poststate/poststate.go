@@ -0,0 +1,102 @@
+// Package poststate reads and updates per-user read and starred flags.
+package poststate
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SetRead marks posts read or unread for a user
+func SetRead(userID uint, postIDs []uint, read bool) error {
+	return upsert(userID, postIDs, "read", read, "read_at")
+}
+
+// SetStarred stars or unstars posts for a user
+func SetStarred(userID uint, postIDs []uint, starred bool) error {
+	return upsert(userID, postIDs, "starred", starred, "starred_at")
+}
+
+func upsert(userID uint, postIDs []uint, column string, value bool, timeColumn string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	var at *time.Time
+	if value {
+		at = &now
+	}
+	states := make([]models.PostState, len(postIDs))
+	for i, id := range postIDs {
+		states[i] = models.PostState{UserID: userID, PostID: id, UpdatedAt: now}
+		if column == "read" {
+			states[i].Read, states[i].ReadAt = value, at
+		} else {
+			states[i].Starred, states[i].StarredAt = value, at
+		}
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{column, timeColumn, "updated_at"}),
+	}).Create(&states).Error
+}
+
+// MarkFeedsRead marks every post of the given feeds published before the
+// cutoff as read
+func MarkFeedsRead(userID uint, feedIDs []uint, before time.Time) error {
+	if len(feedIDs) == 0 {
+		return nil
+	}
+	var ids []uint
+	err := database.DB.Model(&models.Post{}).
+		Where("feed_id IN ? AND published <= ?", feedIDs, before).
+		Where("id NOT IN (?)", ReadPostIDs(userID)).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(ids); start += 500 {
+		end := start + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := SetRead(userID, ids[start:end], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPostIDs is a subquery of the posts the user has read
+func ReadPostIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.PostState{}).Select("post_id").Where("user_id = ? AND read", userID)
+}
+
+// StarredPostIDs is a subquery of the posts the user has starred
+func StarredPostIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.PostState{}).Select("post_id").Where("user_id = ? AND starred", userID)
+}
+
+// SubscribedFeedIDs is a subquery of the feeds the user subscribes to
+func SubscribedFeedIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.Subscription{}).Select("feed_id").Where("user_id = ?", userID)
+}
+
+// Load returns the user's states for the given posts keyed by post id
+func Load(userID uint, postIDs []uint) (map[uint]models.PostState, error) {
+	states := map[uint]models.PostState{}
+	if len(postIDs) == 0 {
+		return states, nil
+	}
+	var rows []models.PostState
+	if err := database.DB.Where("user_id = ? AND post_id IN ?", userID, postIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		states[r.PostID] = r
+	}
+	return states, nil
+}
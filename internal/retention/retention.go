@@ -0,0 +1,166 @@
+// Package retention deletes old posts according to a global policy and
+// per-feed overrides. Deleted posts leave tombstones so the next fetch of
+// their feed does not store them again.
+package retention
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// batchSize bounds how many posts one transaction deletes, keeping locks
+	// short while readers and the fetcher use the table
+	batchSize = 500
+	// tombstoneTTL is how long a tombstone survives after its item was last
+	// seen in the feed
+	tombstoneTTL = 90 * 24 * time.Hour
+)
+
+// Policy says which posts to delete. Starred posts are always kept.
+type Policy struct {
+	// MaxAge deletes posts published longer ago; 0 keeps old posts
+	MaxAge time.Duration
+	// MaxPosts keeps only the newest posts of each feed; 0 keeps all
+	MaxPosts int
+	// UnreadGrace keeps posts a subscriber has not read yet until they
+	// have been stored this long; 0 disables the exemption
+	UnreadGrace time.Duration
+}
+
+var defaults Policy
+
+// Configure sets the global policy; feeds may override MaxAge and MaxPosts
+func Configure(p Policy) {
+	defaults = p
+}
+
+// policyFor applies a feed's overrides to the global policy
+func policyFor(feed models.Feed) Policy {
+	p := defaults
+	if feed.RetentionDays != nil {
+		p.MaxAge = time.Duration(*feed.RetentionDays) * 24 * time.Hour
+	}
+	if feed.RetentionMaxPosts != nil {
+		p.MaxPosts = *feed.RetentionMaxPosts
+	}
+	return p
+}
+
+// Prune applies the policies to every feed and returns how many posts were
+// deleted. Tombstones not matched for tombstoneTTL are dropped as well.
+func Prune() (int, error) {
+	now := time.Now().UTC()
+	var feeds []models.Feed
+	if err := database.DB.Select("id", "retention_days", "retention_max_posts").Find(&feeds).Error; err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, feed := range feeds {
+		n, err := pruneFeed(feed, now)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	err := database.DB.Where("last_seen_at < ?", now.Add(-tombstoneTTL)).Delete(&models.PostTombstone{}).Error
+	return total, err
+}
+
+func pruneFeed(feed models.Feed, now time.Time) (int, error) {
+	p := policyFor(feed)
+	deleted := 0
+	if p.MaxAge > 0 {
+		n, err := deleteBatches(feed.ID, now, func() *gorm.DB {
+			return candidates(feed.ID, p, now).Where("published < ?", now.Add(-p.MaxAge))
+		})
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	if p.MaxPosts > 0 {
+		n, err := deleteBatches(feed.ID, now, func() *gorm.DB {
+			newest := database.DB.Model(&models.Post{}).Select("id").Where("feed_id = ?", feed.ID).
+				Order("published DESC, id DESC").Limit(p.MaxPosts)
+			return candidates(feed.ID, p, now).Where("id NOT IN (?)", newest)
+		})
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// notStarred excludes posts any user starred
+const notStarred = "NOT EXISTS (SELECT 1 FROM post_states ps WHERE ps.post_id = posts.id AND ps.starred)"
+
+// candidates selects the posts of a feed the policy may delete
+func candidates(feedID uint, p Policy, now time.Time) *gorm.DB {
+	query := database.DB.Model(&models.Post{}).Select("id").Where("feed_id = ?", feedID).Where(notStarred)
+	if p.UnreadGrace > 0 {
+		// Unread means a subscriber of the feed has no read state for it
+		query = query.Where(`NOT (COALESCE(first_seen_at, published) > ? AND EXISTS (
+			SELECT 1 FROM subscriptions s WHERE s.feed_id = posts.feed_id AND NOT EXISTS (
+				SELECT 1 FROM post_states ps WHERE ps.post_id = posts.id AND ps.user_id = s.user_id AND ps.read)))`,
+			now.Add(-p.UnreadGrace))
+	}
+	return query
+}
+
+// deleteBatches deletes the posts query selects, batchSize at a time
+func deleteBatches(feedID uint, now time.Time, query func() *gorm.DB) (int, error) {
+	deleted := 0
+	for {
+		var ids []uint
+		if err := query().Order("id").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return deleted, err
+		}
+		if len(ids) == 0 {
+			return deleted, nil
+		}
+		n, err := deletePosts(feedID, ids, now)
+		deleted += n
+		if err != nil || len(ids) < batchSize {
+			return deleted, err
+		}
+	}
+}
+
+// deletePosts deletes posts with their read states and digest records and
+// leaves a tombstone for each. Posts starred since they were selected stay.
+func deletePosts(feedID uint, ids []uint, now time.Time) (int, error) {
+	var removed []models.Post
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "guid"}, {Name: "link"}}}).
+			Where("id IN ?", ids).Where(notStarred).Delete(&removed).Error
+		if err != nil || len(removed) == 0 {
+			return err
+		}
+		removedIDs := make([]uint, len(removed))
+		tombstones := make([]models.PostTombstone, len(removed))
+		for i, post := range removed {
+			removedIDs[i] = post.ID
+			tombstones[i] = models.PostTombstone{FeedID: feedID, GUID: post.GUID, Link: post.Link, PrunedAt: now, LastSeenAt: now}
+		}
+		if err := tx.Create(&tombstones).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN ?", removedIDs).Delete(&models.PostState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN ?", removedIDs).Delete(&models.ExtractionJob{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("post_id IN ?", removedIDs).Delete(&models.DigestItem{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
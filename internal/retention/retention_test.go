@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestPolicyFor(t *testing.T) {
+	Configure(Policy{MaxAge: 30 * 24 * time.Hour, MaxPosts: 100, UnreadGrace: time.Hour})
+	t.Cleanup(func() { Configure(Policy{}) })
+
+	tests := []struct {
+		name string
+		feed models.Feed
+		want Policy
+	}{
+		{"defaults", models.Feed{}, Policy{MaxAge: 30 * 24 * time.Hour, MaxPosts: 100, UnreadGrace: time.Hour}},
+		{"override", models.Feed{RetentionDays: intPtr(7), RetentionMaxPosts: intPtr(10)}, Policy{MaxAge: 7 * 24 * time.Hour, MaxPosts: 10, UnreadGrace: time.Hour}},
+		{"keep everything", models.Feed{RetentionDays: intPtr(0), RetentionMaxPosts: intPtr(0)}, Policy{UnreadGrace: time.Hour}},
+	}
+	for _, tt := range tests {
+		if got := policyFor(tt.feed); got != tt.want {
+			t.Errorf("%s: policy = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// seedFeed stores a feed with a subscriber and a post per age in days
+func seedFeed(t *testing.T, feed *models.Feed, ages ...int) (models.User, []models.Post) {
+	t.Helper()
+	if err := database.DB.Create(feed).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "reader-" + strconv.Itoa(int(feed.ID)), Email: strconv.Itoa(int(feed.ID)) + "@example.com"}
+	database.DB.Create(&user)
+	database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+	posts := make([]models.Post, len(ages))
+	for i, days := range ages {
+		published := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+		posts[i] = models.Post{
+			Title:       "Post " + strconv.Itoa(i),
+			Link:        feed.URL + "/" + strconv.Itoa(i),
+			GUID:        "guid-" + strconv.Itoa(i),
+			Published:   published,
+			FirstSeenAt: &published,
+			FeedId:      feed.ID,
+		}
+	}
+	if err := database.DB.Create(&posts).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, posts
+}
+
+func remaining(t *testing.T, feedID uint) map[string]bool {
+	t.Helper()
+	var titles []string
+	database.DB.Model(&models.Post{}).Where("feed_id = ?", feedID).Pluck("title", &titles)
+	left := map[string]bool{}
+	for _, title := range titles {
+		left[title] = true
+	}
+	return left
+}
+
+func TestPruneByAge(t *testing.T) {
+	testdb.Open(t)
+	Configure(Policy{MaxAge: 30 * 24 * time.Hour, UnreadGrace: 60 * 24 * time.Hour})
+	t.Cleanup(func() { Configure(Policy{}) })
+
+	feed := models.Feed{Title: "Old", URL: "https://example.com/old"}
+	user, posts := seedFeed(t, &feed, 1, 40, 40, 90, 90)
+	now := time.Now()
+	// Read, and so only kept while new
+	for _, i := range []int{0, 1, 3} {
+		database.DB.Create(&models.PostState{UserID: user.ID, PostID: posts[i].ID, Read: true, ReadAt: &now})
+	}
+	// Starred posts are never deleted
+	database.DB.Create(&models.PostState{UserID: user.ID, PostID: posts[4].ID, Read: true, Starred: true, StarredAt: &now})
+
+	deleted, err := Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Post 1 is read and too old, post 3 as well; post 2 is unread within
+	// the grace period
+	left := remaining(t, feed.ID)
+	if deleted != 2 || len(left) != 3 || !left["Post 0"] || !left["Post 2"] || !left["Post 4"] {
+		t.Fatalf("deleted %d, left %v", deleted, left)
+	}
+
+	var tombstones []models.PostTombstone
+	database.DB.Where("feed_id = ?", feed.ID).Order("guid").Find(&tombstones)
+	if len(tombstones) != 2 || tombstones[0].GUID != "guid-1" || tombstones[1].Link != feed.URL+"/3" {
+		t.Fatalf("tombstones = %+v", tombstones)
+	}
+	var states int64
+	database.DB.Model(&models.PostState{}).Where("post_id IN ?", []uint{posts[1].ID, posts[3].ID}).Count(&states)
+	if states != 0 {
+		t.Fatalf("%d read states of deleted posts left", states)
+	}
+}
+
+func TestPruneFeedOverrides(t *testing.T) {
+	testdb.Open(t)
+	Configure(Policy{MaxAge: 24 * time.Hour})
+	t.Cleanup(func() { Configure(Policy{}) })
+
+	// Keeps its posts despite the global age limit
+	kept := models.Feed{Title: "Kept", URL: "https://example.com/kept", RetentionDays: intPtr(0)}
+	seedFeed(t, &kept, 10, 20)
+	// Keeps its two newest posts, whatever their age
+	capped := models.Feed{Title: "Capped", URL: "https://example.com/capped", RetentionDays: intPtr(0), RetentionMaxPosts: intPtr(2)}
+	seedFeed(t, &capped, 5, 1, 3, 2)
+
+	deleted, err := Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left := remaining(t, kept.ID); len(left) != 2 {
+		t.Fatalf("kept feed lost posts: %v", left)
+	}
+	if left := remaining(t, capped.ID); deleted != 2 || len(left) != 2 || !left["Post 1"] || !left["Post 3"] {
+		t.Fatalf("deleted %d, capped feed left %v", deleted, left)
+	}
+}
+
+func TestPruneDropsStaleTombstones(t *testing.T) {
+	testdb.Open(t)
+	feed := models.Feed{Title: "Feed", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	now := time.Now().UTC()
+	database.DB.Create(&[]models.PostTombstone{
+		{FeedID: feed.ID, GUID: "stale", PrunedAt: now.Add(-200 * 24 * time.Hour), LastSeenAt: now.Add(-tombstoneTTL - time.Hour)},
+		{FeedID: feed.ID, GUID: "fresh", PrunedAt: now.Add(-200 * 24 * time.Hour), LastSeenAt: now.Add(-time.Hour)},
+	})
+
+	if _, err := Prune(); err != nil {
+		t.Fatal(err)
+	}
+	var guids []string
+	database.DB.Model(&models.PostTombstone{}).Pluck("guid", &guids)
+	if len(guids) != 1 || guids[0] != "fresh" {
+		t.Fatalf("tombstones left: %v", guids)
+	}
+}
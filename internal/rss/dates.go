@@ -0,0 +1,150 @@
+package rss
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// dateLayouts are tried after a feed's own date formats. cleanDate drops
+// leading weekdays, so layouts past the standard ones leave them out.
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 06 15:04:05 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2, 2006 15:04:05 -0700",
+	"Jan 2, 2006 3:04 PM -0700",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006 15:04",
+	"January 2, 2006 3:04 PM -0700",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"02 Jan 2006",
+	"January 2006",
+}
+
+var (
+	weekdayPrefix  = regexp.MustCompile(`(?i)^(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?,?\s+`)
+	ordinalSuffix  = regexp.MustCompile(`(?i)\b(\d{1,2})(st|nd|rd|th)\b`)
+	monthPeriod    = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|jun|jul|aug|sep|sept|oct|nov|dec)\.`)
+	meridiem       = regexp.MustCompile(`(?i)(\d)\s*([ap])\.?m\.?(\s|$)`)
+	trailingParens = regexp.MustCompile(`\s*\([^)]*\)$`)
+	zoneSuffix     = regexp.MustCompile(`\s([A-Z]{1,5})$`)
+)
+
+// zoneOffsets resolves the time zone abbreviations seen in feeds, which
+// time.Parse would otherwise take as UTC
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800", "HST": "-1000",
+	"WET": "+0000", "WEST": "+0100", "BST": "+0100", "CET": "+0100", "CEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "MSK": "+0300", "IST": "+0530",
+	"JST": "+0900", "KST": "+0900", "AEST": "+1000", "AEDT": "+1100",
+	"NZST": "+1200", "NZDT": "+1300",
+}
+
+// parseDate tries the hinted layouts first, then common formats on a
+// cleaned up value. Dates at or before the Unix epoch are placeholders
+// and not usable.
+func parseDate(value string, hints []string) (time.Time, bool) {
+	value = collapseSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range hints {
+		if t, err := time.Parse(layout, value); err == nil {
+			return usableDate(t)
+		}
+	}
+	// The cleaned value goes first so zone abbreviations get their offset
+	for _, candidate := range []string{cleanDate(value), value} {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, candidate); err == nil {
+				return usableDate(t)
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// cleanDate rewrites the usual deviations from the standard formats: a
+// weekday that may be misspelled or mismatched, ordinals, "Sept.", "p.m.",
+// a trailing "(UTC)" and zone abbreviations
+func cleanDate(value string) string {
+	value = trailingParens.ReplaceAllString(value, "")
+	value = weekdayPrefix.ReplaceAllString(value, "")
+	value = ordinalSuffix.ReplaceAllString(value, "$1")
+	value = monthPeriod.ReplaceAllString(value, "$1")
+	value = strings.NewReplacer("Sept ", "Sep ", "sept ", "sep ", " at ", " ").Replace(value)
+	value = meridiem.ReplaceAllStringFunc(value, func(m string) string {
+		parts := meridiem.FindStringSubmatch(m)
+		return parts[1] + " " + strings.ToUpper(parts[2]) + "M" + parts[3]
+	})
+	if m := zoneSuffix.FindStringSubmatch(value); m != nil {
+		if offset, ok := zoneOffsets[m[1]]; ok {
+			value = strings.TrimSuffix(value, m[1]) + offset
+		}
+	}
+	return value
+}
+
+func usableDate(t time.Time) (time.Time, bool) {
+	if t.Unix() <= 0 {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+// itemDate picks the publication date of a gofeed item, falling back to
+// our own parser for dates gofeed could not read and to the update date
+func itemDate(item *gofeed.Item) (time.Time, bool) {
+	if item.PublishedParsed != nil {
+		if t, ok := usableDate(*item.PublishedParsed); ok {
+			return t, true
+		}
+	}
+	if t, ok := parseDate(item.Published, nil); ok {
+		return t, true
+	}
+	if item.UpdatedParsed != nil {
+		if t, ok := usableDate(*item.UpdatedParsed); ok {
+			return t, true
+		}
+	}
+	return parseDate(item.Updated, nil)
+}
@@ -0,0 +1,117 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestParseDate(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		value string
+		hints []string
+		want  time.Time
+	}{
+		{"2006-01-02T15:04:05Z", nil, utc(2006, 1, 2, 15, 4, 5)},
+		{"2006-01-02T15:04:05+02:00", nil, utc(2006, 1, 2, 13, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 +0000", nil, utc(2006, 1, 2, 15, 4, 5)},
+		// RFC 822 without seconds
+		{"Mon, 02 Jan 2006 15:04 GMT", nil, utc(2006, 1, 2, 15, 4, 0)},
+		{"Mon, 02 Jan 2006 15:04 -0700", nil, utc(2006, 1, 2, 22, 4, 0)},
+		{"02 Jan 06 15:04 EST", nil, utc(2006, 1, 2, 20, 4, 0)},
+		{"2 Jan 2006 15:04 PDT", nil, utc(2006, 1, 2, 22, 4, 0)},
+		// Zone abbreviations get their offset; unknown ones are taken as UTC
+		{"Mon, 2 Jan 2006 15:04:05 EST", nil, utc(2006, 1, 2, 20, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 CEST", nil, utc(2006, 1, 2, 13, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 XYZ", nil, utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 +0000 (UTC)", nil, utc(2006, 1, 2, 15, 4, 5)},
+		// Wrong or misspelled weekdays are ignored
+		{"Fri, 02 Jan 2006 15:04:05 +0000", nil, utc(2006, 1, 2, 15, 4, 5)},
+		{"Tues, 02 Jan 2006 15:04:05 GMT", nil, utc(2006, 1, 2, 15, 4, 5)},
+		{"January 2nd, 2006", nil, utc(2006, 1, 2, 0, 0, 0)},
+		{"Sept. 5, 2024", nil, utc(2024, 9, 5, 0, 0, 0)},
+		{"Jan 2, 2006 3:04 p.m.", nil, utc(2006, 1, 2, 15, 4, 0)},
+		{"  2006-01-02   15:04:05 ", nil, utc(2006, 1, 2, 15, 4, 5)},
+		{"02.01.2006", []string{"02.01.2006"}, utc(2006, 1, 2, 0, 0, 0)},
+		// Unusable values
+		{"", nil, time.Time{}},
+		{"yesterday", nil, time.Time{}},
+		{"1970-01-01T00:00:00Z", nil, time.Time{}},
+		{"0001-01-01T00:00:00Z", nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, ok := parseDate(tt.value, tt.hints)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) || ok && got.Location() != time.UTC {
+			t.Errorf("parseDate(%q) = %v, %v; want %v", tt.value, got, ok, tt.want)
+		}
+	}
+}
+
+func TestItemDate(t *testing.T) {
+	parsed := time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("", 3600))
+	epoch := time.Unix(0, 0)
+	want := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		item gofeed.Item
+		want time.Time
+	}{
+		{"parsed by gofeed", gofeed.Item{PublishedParsed: &parsed}, want},
+		{"published string gofeed could not read", gofeed.Item{Published: "Fri, 01 Mar 2024 09:00 GMT"}, want},
+		{"placeholder date", gofeed.Item{PublishedParsed: &epoch, Published: "Fri, 01 Mar 2024 09:00 GMT"}, want},
+		{"update date", gofeed.Item{Published: "soon", UpdatedParsed: &parsed}, want},
+		{"update string", gofeed.Item{Updated: "2024-03-01 09:00:00"}, want},
+		{"no date", gofeed.Item{}, time.Time{}},
+		{"unreadable dates", gofeed.Item{Published: "soon", Updated: "later", UpdatedParsed: &epoch}, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, ok := itemDate(&tt.item)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s: itemDate = %v, %v; want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestFetchPostsDatesUndatedItems(t *testing.T) {
+	srv := newFeedServer(t)
+	before := time.Now().UTC()
+	posts, err := FetchPosts(models.Feed{URL: srv.URL + "/feed.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Published.Before(before) || posts[0].Published.After(time.Now().UTC()) {
+		t.Fatalf("posts = %+v", posts)
+	}
+}
+
+func TestUndatedItemKeepsFirstSeenTime(t *testing.T) {
+	testdb.Open(t)
+	srv := newFeedServer(t)
+	feed := models.Feed{Title: "Blog", URL: srv.URL + "/feed.xml"}
+	database.DB.Create(&feed)
+	if err := FetchAndStoreFeed(feed); err != nil {
+		t.Fatal(err)
+	}
+	var post models.Post
+	database.DB.Where("feed_id = ?", feed.ID).First(&post)
+	if post.FirstSeenAt == nil || post.Published.IsZero() || !post.Published.Equal(*post.FirstSeenAt) {
+		t.Fatalf("post = %+v", post)
+	}
+
+	// Fetching again does not move it
+	if err := FetchAndStoreFeed(feed); err != nil {
+		t.Fatal(err)
+	}
+	var again models.Post
+	database.DB.First(&again, post.ID)
+	if !again.Published.Equal(post.Published) {
+		t.Fatalf("published moved from %v to %v", post.Published, again.Published)
+	}
+}
@@ -0,0 +1,193 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"gorm.io/gorm"
+)
+
+// Diagnosis describes a live fetch of a feed. It is filled as far as the
+// fetch got, so a failed fetch still reports the response it received.
+type Diagnosis struct {
+	FeedID     uint                `json:"feed_id"`
+	URL        string              `json:"url"`
+	FinalURL   string              `json:"final_url,omitempty"`
+	StatusCode int                 `json:"status_code"`
+	DurationMS int64               `json:"duration_ms"`
+	Bytes      int64               `json:"bytes"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Redirects  []Redirect          `json:"redirects"`
+	// Format is the detected document format, e.g. "rss 2.0" or "atom 1.0"
+	Format   string          `json:"format"`
+	Error    string          `json:"error,omitempty"`
+	Warnings []string        `json:"warnings"`
+	Items    []DiagnosedItem `json:"items"`
+}
+
+// DiagnosedItem is an item of a diagnosed fetch. New reports whether
+// storing the fetch would insert it, Pruned that retention deleted it
+// before and it stays deleted.
+type DiagnosedItem struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+	GUID  string `json:"guid,omitempty"`
+	// Published is null for items without a usable date
+	Published *time.Time `json:"published"`
+	New       bool       `json:"new"`
+	Pruned    bool       `json:"pruned,omitempty"`
+}
+
+// Diagnose fetches a feed the way the updater does and reports what came
+// back, without storing posts or updating the feed
+func Diagnose(feed models.Feed) (Diagnosis, error) {
+	if _, err := sourceFor(feed); err != nil {
+		return Diagnosis{}, err
+	}
+	d := Diagnosis{FeedID: feed.ID, URL: feed.URL, Redirects: []Redirect{}, Warnings: []string{}, Items: []DiagnosedItem{}}
+
+	// Without an id the sources do not record moves or hubs, as for previews
+	unsaved := feed
+	unsaved.ID = 0
+	trace := &fetchTrace{keepBody: true}
+	started := time.Now()
+	posts, err := fetchPosts(withTrace(context.Background(), trace), unsaved)
+	d.DurationMS = time.Since(started).Milliseconds()
+	d.Bytes = trace.bytes()
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	first := trace.first()
+	if first == nil {
+		return d, nil
+	}
+	d.FinalURL = first.finalURL
+	d.StatusCode = first.status
+	d.Headers = first.header
+	d.Redirects = append(d.Redirects, first.redirects...)
+	mediaType, _, _ := mime.ParseMediaType(first.header.Get("Content-Type"))
+	if sourceType(feed) != models.FeedSourceRSS {
+		d.Format = strings.TrimSpace(sourceType(feed) + " " + mediaType)
+	} else {
+		// Look at the document the way parseFeed does
+		body, fixes := normalizeFeed(first.body, first.header.Get("Content-Type"))
+		d.Format = feedFormat(body, mediaType)
+		for _, fix := range fixes {
+			d.Warnings = append(d.Warnings, "repaired: "+fix)
+		}
+		d.Warnings = append(d.Warnings, feedWarnings(body)...)
+	}
+	if err != nil {
+		return d, nil
+	}
+
+	d.Warnings = append(d.Warnings, itemWarnings(posts, started)...)
+	for _, post := range posts {
+		post.FeedId = feed.ID
+		_, findErr := findPost(post)
+		item := DiagnosedItem{
+			Title: post.Title,
+			Link:  post.Link,
+			GUID:  post.GUID,
+		}
+		if errors.Is(findErr, gorm.ErrRecordNotFound) {
+			_, tombErr := findTombstone(post)
+			item.Pruned = tombErr == nil
+			item.New = errors.Is(tombErr, gorm.ErrRecordNotFound)
+		}
+		if !post.Published.IsZero() {
+			item.Published = &post.Published
+		}
+		d.Items = append(d.Items, item)
+	}
+	return d, nil
+}
+
+func sourceType(feed models.Feed) string {
+	if feed.SourceType == "" {
+		return models.FeedSourceRSS
+	}
+	return feed.SourceType
+}
+
+// feedFormat names the type and version of a feed document
+func feedFormat(body []byte, mediaType string) string {
+	if parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body)); err == nil {
+		return strings.TrimSpace(parsed.FeedType + " " + parsed.FeedVersion)
+	}
+	switch gofeed.DetectFeedType(bytes.NewReader(body)) {
+	case gofeed.FeedTypeRSS:
+		return "rss"
+	case gofeed.FeedTypeAtom:
+		return "atom"
+	case gofeed.FeedTypeJSON:
+		return "json"
+	}
+	return strings.TrimSpace("unknown " + mediaType)
+}
+
+// feedWarnings reports problems in an RSS, Atom or JSON Feed document that
+// the posts no longer show, like dates that could not be read
+func feedWarnings(body []byte) []string {
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return []string{fmt.Sprintf("parse error: %v", err)}
+	}
+	var warnings []string
+	if parsed.Title == "" {
+		warnings = append(warnings, "feed has no title")
+	}
+	for i, item := range parsed.Items {
+		if _, ok := itemDate(item); !ok && item.Published != "" {
+			warnings = append(warnings, fmt.Sprintf("item %d: unparseable date %q", i+1, item.Published))
+		}
+	}
+	return warnings
+}
+
+// itemWarnings reports items that will be stored poorly or not at all
+func itemWarnings(posts []models.Post, fetched time.Time) []string {
+	warnings := []string{}
+	if len(posts) == 0 {
+		warnings = append(warnings, "no items")
+	}
+	guids := map[string]int{}
+	links := map[string]int{}
+	for i, post := range posts {
+		n := i + 1
+		if strings.TrimSpace(post.Title) == "" {
+			warnings = append(warnings, fmt.Sprintf("item %d: no title", n))
+		}
+		if post.Link == "" {
+			warnings = append(warnings, fmt.Sprintf("item %d: no link", n))
+		} else if prev, ok := links[post.Link]; ok {
+			warnings = append(warnings, fmt.Sprintf("item %d: same link as item %d, only one is stored", n, prev))
+		} else {
+			links[post.Link] = n
+		}
+		if post.GUID != "" {
+			if prev, ok := guids[post.GUID]; ok {
+				warnings = append(warnings, fmt.Sprintf("item %d: same id as item %d, only one is stored", n, prev))
+			} else {
+				guids[post.GUID] = n
+			}
+		}
+		if post.Published.IsZero() {
+			warnings = append(warnings, fmt.Sprintf("item %d: no usable date, the time it is first seen is used", n))
+		} else if post.Published.After(fetched.Add(time.Hour)) {
+			warnings = append(warnings, fmt.Sprintf("item %d: published in the future (%s)", n, post.Published.Format(time.RFC3339)))
+		}
+		if strings.TrimSpace(post.ContentText) == "" {
+			warnings = append(warnings, fmt.Sprintf("item %d: no content", n))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,111 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// extractionWorkers bounds the pages fetched at the same time
+	extractionWorkers = 4
+	// Failed extractions are retried after extractionBackoff, doubled each
+	// attempt, and dropped after maxExtractionAttempts
+	maxExtractionAttempts = 3
+	extractionBackoff     = 10 * time.Minute
+	// extractionLease keeps a claimed job away from other workers while it runs
+	extractionLease = 5 * time.Minute
+)
+
+// QueueFullContent stores an extraction job for each post. Fetchers call it
+// for new posts of feeds that want full articles, so storing a feed never
+// waits on the linked pages; extraction happens in ProcessFullContentJobs.
+func QueueFullContent(posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	jobs := make([]models.ExtractionJob, len(posts))
+	for i, post := range posts {
+		jobs[i] = models.ExtractionJob{PostID: post.ID, NextAttemptAt: now}
+	}
+	return database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&jobs).Error
+}
+
+// ProcessFullContentJobs claims up to limit due jobs and extracts their
+// articles on a bounded number of workers. It returns how many jobs it
+// claimed.
+func ProcessFullContentJobs(limit int) (int, error) {
+	var due []models.ExtractionJob
+	now := time.Now().UTC()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_attempt_at <= ?", now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(due))
+		for i, job := range due {
+			ids[i] = job.ID
+		}
+		return tx.Model(&models.ExtractionJob{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(extractionLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan *models.ExtractionJob)
+	var wg sync.WaitGroup
+	for i := 0; i < extractionWorkers && i < len(due); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				runExtraction(job)
+			}
+		}()
+	}
+	for i := range due {
+		jobs <- &due[i]
+	}
+	close(jobs)
+	wg.Wait()
+	return len(due), nil
+}
+
+// runExtraction makes one attempt at a job. It is deleted when it succeeds,
+// when its post is gone or after the last attempt, and rescheduled otherwise.
+func runExtraction(job *models.ExtractionJob) {
+	var post models.Post
+	if err := database.DB.First(&post, job.PostID).Error; err != nil {
+		database.DB.Delete(job)
+		return
+	}
+	err := FetchFullContent(&post, false)
+	if err == nil {
+		database.DB.Delete(job)
+		return
+	}
+	job.Attempts++
+	if job.Attempts >= maxExtractionAttempts {
+		log.Printf("full article for %s: %v", post.Link, err)
+		database.DB.Delete(job)
+		return
+	}
+	database.DB.Model(job).Updates(map[string]interface{}{
+		"attempts":        job.Attempts,
+		"next_attempt_at": time.Now().UTC().Add(extractionBackoff << (job.Attempts - 1)),
+		"error":           err.Error(),
+	})
+}
@@ -0,0 +1,136 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/testdb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const articlePage = `<html><head><title>Post</title></head><body>
+<nav><a href="/">Home</a></nav>
+<article><h1>Post</h1>
+<p>The whole article lives on the page and not in the feed, which only carries a teaser for it.</p>
+<p>It is long enough for the extractor to pick it as the main content of the page it belongs to.</p>
+</article></body></html>`
+
+// articleServer serves articlePage at /article and 404 elsewhere
+func articleServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/article" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(articlePage))
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		safehttp.Configure(nil, nil)
+	})
+	return srv
+}
+
+func TestStorePostsQueuesFullContent(t *testing.T) {
+	testdb.Open(t)
+	srv := articleServer(t)
+	feed := models.Feed{Title: "Teasers", URL: "https://example.com/feed", FetchFullContent: true}
+	database.DB.Create(&feed)
+
+	posts := []models.Post{{Title: "Post", Link: srv.URL + "/article", Content: "<p>Teaser</p>", Published: time.Now()}}
+	if _, err := storePosts(feed, posts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Storing returns before the page is fetched
+	var post models.Post
+	database.DB.Where("link = ?", posts[0].Link).First(&post)
+	if post.FullContent != "" {
+		t.Fatal("article extracted while storing the feed")
+	}
+	var queued int64
+	database.DB.Model(&models.ExtractionJob{}).Where("post_id = ?", post.ID).Count(&queued)
+	if queued != 1 {
+		t.Fatalf("%d jobs queued", queued)
+	}
+
+	n, err := ProcessFullContentJobs(20)
+	if err != nil || n != 1 {
+		t.Fatalf("processed %d jobs: %v", n, err)
+	}
+	database.DB.First(&post, post.ID)
+	if !strings.Contains(post.FullContent, "whole article") || post.FullContentFetchedAt == nil {
+		t.Fatalf("full content = %q", post.FullContent)
+	}
+	database.DB.Model(&models.ExtractionJob{}).Count(&queued)
+	if queued != 0 {
+		t.Fatalf("%d jobs left", queued)
+	}
+}
+
+func TestStorePostsSkipsFeedsWithoutFullContent(t *testing.T) {
+	testdb.Open(t)
+	feed := models.Feed{Title: "Full", URL: "https://example.com/full"}
+	database.DB.Create(&feed)
+	posts := []models.Post{{Title: "Post", Link: "https://example.com/post", Published: time.Now()}}
+	if _, err := storePosts(feed, posts); err != nil {
+		t.Fatal(err)
+	}
+	var queued int64
+	database.DB.Model(&models.ExtractionJob{}).Count(&queued)
+	if queued != 0 {
+		t.Fatalf("%d jobs queued", queued)
+	}
+}
+
+func TestFullContentJobsRetryThenGiveUp(t *testing.T) {
+	testdb.Open(t)
+	srv := articleServer(t)
+	feed := models.Feed{Title: "Broken", URL: "https://example.com/broken"}
+	database.DB.Create(&feed)
+	post := models.Post{Title: "Gone", Link: srv.URL + "/missing", FeedId: feed.ID, Published: time.Now()}
+	database.DB.Create(&post)
+	if err := QueueFullContent([]models.Post{post}); err != nil {
+		t.Fatal(err)
+	}
+	// Queuing twice keeps one job
+	if err := QueueFullContent([]models.Post{post}); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt < maxExtractionAttempts; attempt++ {
+		if _, err := ProcessFullContentJobs(20); err != nil {
+			t.Fatal(err)
+		}
+		var job models.ExtractionJob
+		if err := database.DB.Where("post_id = ?", post.ID).First(&job).Error; err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+		if job.Attempts != attempt || job.Error == "" || !job.NextAttemptAt.After(time.Now()) {
+			t.Fatalf("attempt %d: job = %+v", attempt, job)
+		}
+		// Not due yet
+		if n, _ := ProcessFullContentJobs(20); n != 0 {
+			t.Fatalf("attempt %d: claimed a job before its retry", attempt)
+		}
+		database.DB.Model(&job).Update("next_attempt_at", time.Now().Add(-time.Second))
+	}
+
+	if _, err := ProcessFullContentJobs(20); err != nil {
+		t.Fatal(err)
+	}
+	var left int64
+	database.DB.Model(&models.ExtractionJob{}).Count(&left)
+	if left != 0 {
+		t.Fatalf("%d jobs left after the last attempt", left)
+	}
+}
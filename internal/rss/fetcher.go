@@ -0,0 +1,198 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// defaultUserAgent identifies the aggregator with a contact URL; Reddit
+// and some publishers reject generic client agents
+const defaultUserAgent = "blogAggregator/1.0 (+https://github.com/shivamg7753/BlogAggregator)"
+
+const feedAccept = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8"
+
+// ErrGone reports a feed the publisher removed for good (410 Gone). Such
+// feeds are disabled instead of retried.
+var ErrGone = errors.New("feed is gone")
+
+// FetcherOptions configures how feeds, pages and source APIs are
+// downloaded. Zero values keep the defaults.
+type FetcherOptions struct {
+	UserAgent      string
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	Timeout        time.Duration
+	// MaxBytes caps a response, after decompression
+	MaxBytes int64
+}
+
+var (
+	userAgent          = defaultUserAgent
+	maxBodyBytes int64 = maxFeedBytes
+	// httpClient fetches feeds and pages; it refuses internal addresses
+	httpClient = safehttp.New(30*time.Second, maxFeedBytes)
+)
+
+// ConfigureFetcher replaces the fetcher settings; call it before fetching
+func ConfigureFetcher(opts FetcherOptions) {
+	if opts.UserAgent != "" {
+		userAgent = opts.UserAgent
+	}
+	if opts.MaxBytes > 0 {
+		maxBodyBytes = opts.MaxBytes
+	}
+	httpClient = safehttp.NewClient(safehttp.Options{
+		ConnectTimeout: opts.ConnectTimeout,
+		ReadTimeout:    opts.ReadTimeout,
+		Timeout:        opts.Timeout,
+		MaxBytes:       maxBodyBytes,
+	})
+}
+
+// fetched is a successful response with its body decoded
+type fetched struct {
+	body   []byte
+	header http.Header
+	// url is where the body came from, after redirects
+	url *url.URL
+	// movedTo is where permanent redirects from the requested URL lead,
+	// empty when the first hop was not a permanent redirect
+	movedTo string
+}
+
+// fetchURL GETs a URL with our User-Agent, accepting compressed responses.
+// 410 Gone is reported as ErrGone and rate limit responses as a
+// RateLimitError.
+func fetchURL(ctx context.Context, rawURL, accept string, headers map[string]string) (*fetched, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", rawURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+	// Set explicitly, the transport only decompresses gzip on its own
+	req.Header.Set("Accept-Encoding", "gzip, br, deflate")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	traced := traceFrom(ctx).start(resp)
+	if resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("%w: %s answered %s", ErrGone, rawURL, resp.Status)
+	}
+	if retryAt, limited := rateLimited(resp); limited {
+		return nil, &RateLimitError{URL: rawURL, RetryAt: retryAt}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", rawURL, resp.Status)
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", rawURL, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	traced.finish(data)
+	if int64(len(data)) > maxBodyBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, maxBodyBytes)
+	}
+	return &fetched{
+		body:    data,
+		header:  resp.Header,
+		url:     resp.Request.URL,
+		movedTo: permanentTarget(resp),
+	}, nil
+}
+
+// decodeBody undoes the Content-Encoding of a response
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	case "deflate":
+		return zlib.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// Redirect is one hop of a redirect chain
+type Redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+// redirects lists the redirects that led to a response, oldest first
+func redirects(resp *http.Response) []Redirect {
+	var hops []Redirect
+	for r := resp.Request; r != nil && r.Response != nil; r = r.Response.Request {
+		hops = append([]Redirect{{
+			From:   r.Response.Request.URL.String(),
+			To:     r.URL.String(),
+			Status: r.Response.StatusCode,
+		}}, hops...)
+	}
+	return hops
+}
+
+// permanentTarget returns the URL the leading permanent redirects (301,
+// 308) of a response point at. A temporary redirect ends the permanent
+// part of the chain.
+func permanentTarget(resp *http.Response) string {
+	moved := ""
+	for _, hop := range redirects(resp) {
+		if hop.Status != http.StatusMovedPermanently && hop.Status != http.StatusPermanentRedirect {
+			break
+		}
+		moved = hop.To
+	}
+	return moved
+}
+
+// recordMove points a feed at the URL it permanently moved to. It is only
+// called once the new location answered with a usable document.
+func recordMove(feed models.Feed, movedTo string) {
+	if feed.ID == 0 || movedTo == "" || movedTo == feed.URL {
+		return
+	}
+	err := database.DB.Model(&models.Feed{}).Where("id = ?", feed.ID).Update("url", movedTo).Error
+	if err != nil {
+		// Most likely another feed already has the new URL
+		log.Printf("feed %d moved from %s to %s but could not be updated: %v", feed.ID, feed.URL, movedTo, err)
+		return
+	}
+	log.Printf("feed %d moved permanently from %s to %s", feed.ID, feed.URL, movedTo)
+}
+
+// disableFeed stops scheduled fetches of a feed that is gone
+func disableFeed(feed models.Feed, reason error) {
+	database.DB.Model(&models.Feed{}).Where("id = ?", feed.ID).Updates(map[string]interface{}{
+		"disabled": true, "last_error": reason.Error(), "next_fetch_at": nil,
+	})
+}
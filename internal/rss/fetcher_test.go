@@ -0,0 +1,223 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/testdb"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link>
+<item><title>Hello</title><link>https://example.com/hello</link><guid>hello</guid></item>
+</channel></rss>`
+
+// compress encodes testFeed with a Content-Encoding
+func compress(t *testing.T, encoding string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	io.WriteString(w, testFeed)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newFeedServer serves testFeed at /feed.xml, compressed at /gzip, /br
+// and /deflate, redirects /<status> to /feed.xml, answers /gone with 410
+// and serves an HTML page at /page.html
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, testFeed)
+	})
+	for _, encoding := range []string{"gzip", "br", "deflate"} {
+		body := compress(t, encoding)
+		mux.HandleFunc("/"+encoding, func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+				t.Errorf("Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(body)
+		})
+	}
+	mux.HandleFunc("/compress", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		io.WriteString(w, testFeed)
+	})
+	for _, redirect := range []struct {
+		path   string
+		status int
+		to     string
+	}{
+		{"/301", http.StatusMovedPermanently, "/feed.xml"},
+		{"/308", http.StatusPermanentRedirect, "/feed.xml"},
+		{"/302", http.StatusFound, "/feed.xml"},
+		{"/307", http.StatusTemporaryRedirect, "/feed.xml"},
+		// A temporary hop ends the permanent part of a chain
+		{"/301-then-302", http.StatusMovedPermanently, "/302"},
+		{"/302-then-301", http.StatusFound, "/301"},
+		{"/301-to-gone", http.StatusMovedPermanently, "/gone"},
+	} {
+		mux.HandleFunc(redirect.path, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, redirect.to, redirect.status)
+		})
+	}
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<!doctype html><html><body><p>Not a feed</p></body></html>")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		safehttp.Configure(nil, nil)
+	})
+	return srv
+}
+
+func TestFetchURLRedirects(t *testing.T) {
+	srv := newFeedServer(t)
+	tests := []struct {
+		path    string
+		movedTo string
+	}{
+		{"/feed.xml", ""},
+		{"/301", "/feed.xml"},
+		{"/308", "/feed.xml"},
+		{"/302", ""},
+		{"/307", ""},
+		{"/301-then-302", "/302"},
+		{"/302-then-301", ""},
+	}
+	for _, tt := range tests {
+		resp, err := fetchURL(context.Background(), srv.URL+tt.path, feedAccept, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		want := ""
+		if tt.movedTo != "" {
+			want = srv.URL + tt.movedTo
+		}
+		if resp.movedTo != want || resp.url.String() != srv.URL+"/feed.xml" || string(resp.body) != testFeed {
+			t.Errorf("%s: moved to %q, fetched %s", tt.path, resp.movedTo, resp.url)
+		}
+	}
+}
+
+func TestFetchURLGone(t *testing.T) {
+	srv := newFeedServer(t)
+	for _, path := range []string{"/gone", "/301-to-gone"} {
+		if _, err := fetchURL(context.Background(), srv.URL+path, feedAccept, nil); !errors.Is(err, ErrGone) {
+			t.Errorf("%s: error = %v", path, err)
+		}
+	}
+}
+
+func TestFetchURLDecodesBody(t *testing.T) {
+	srv := newFeedServer(t)
+	for _, encoding := range []string{"gzip", "br", "deflate"} {
+		resp, err := fetchURL(context.Background(), srv.URL+"/"+encoding, feedAccept, nil)
+		if err != nil {
+			t.Errorf("%s: %v", encoding, err)
+			continue
+		}
+		if string(resp.body) != testFeed {
+			t.Errorf("%s: body = %q", encoding, resp.body)
+		}
+	}
+	if _, err := fetchURL(context.Background(), srv.URL+"/compress", feedAccept, nil); err == nil ||
+		!strings.Contains(err.Error(), "unsupported content encoding") {
+		t.Errorf("compress: error = %v", err)
+	}
+}
+
+func TestFetchURLLimitsDecodedSize(t *testing.T) {
+	srv := newFeedServer(t)
+	saved := maxBodyBytes
+	maxBodyBytes = int64(len(testFeed) - 1)
+	t.Cleanup(func() { maxBodyBytes = saved })
+	// The compressed body is small; the limit applies after decoding
+	if _, err := fetchURL(context.Background(), srv.URL+"/gzip", feedAccept, nil); err == nil ||
+		!strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestFetchAndStoreFeedFollowsMoves(t *testing.T) {
+	srv := newFeedServer(t)
+	tests := []struct {
+		path string
+		url  string
+	}{
+		{"/301", "/feed.xml"},
+		{"/308", "/feed.xml"},
+		{"/302", "/302"},
+		{"/307", "/307"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			testdb.Open(t)
+			feed := models.Feed{Title: "Blog", URL: srv.URL + tt.path}
+			database.DB.Create(&feed)
+			if err := FetchAndStoreFeed(feed); err != nil {
+				t.Fatal(err)
+			}
+			database.DB.First(&feed, feed.ID)
+			if feed.URL != srv.URL+tt.url {
+				t.Fatalf("url = %s, want %s", feed.URL, srv.URL+tt.url)
+			}
+			var posts int64
+			database.DB.Model(&models.Post{}).Where("feed_id = ?", feed.ID).Count(&posts)
+			if posts != 1 {
+				t.Fatalf("%d posts stored", posts)
+			}
+		})
+	}
+}
+
+func TestFetchAndStoreFeedDisablesGoneFeed(t *testing.T) {
+	testdb.Open(t)
+	srv := newFeedServer(t)
+	feed := models.Feed{Title: "Blog", URL: srv.URL + "/gone"}
+	database.DB.Create(&feed)
+	if err := FetchAndStoreFeed(feed); !errors.Is(err, ErrGone) {
+		t.Fatalf("error = %v", err)
+	}
+	database.DB.First(&feed, feed.ID)
+	if !feed.Disabled || !strings.Contains(feed.LastError, "gone") || feed.NextFetchAt != nil || feed.ErrorCount != 0 {
+		t.Fatalf("feed = %+v", feed)
+	}
+}
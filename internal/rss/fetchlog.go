@@ -0,0 +1,133 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// fetchLogsKept is how many fetch attempts are kept per feed
+const fetchLogsKept = 100
+
+// fetchTrace collects the responses fetchURL saw while fetching a feed,
+// for the fetch log and for Diagnose. Sources may fetch concurrently.
+type fetchTrace struct {
+	// keepBody keeps the decoded bodies, which only Diagnose needs
+	keepBody bool
+
+	mu       sync.Mutex
+	requests []*tracedRequest
+}
+
+// tracedRequest is one response seen by fetchURL
+type tracedRequest struct {
+	url       string
+	finalURL  string
+	status    int
+	header    http.Header
+	redirects []Redirect
+	bytes     int64
+	body      []byte
+	keepBody  bool
+}
+
+type traceKey struct{}
+
+func withTrace(ctx context.Context, trace *fetchTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// traceFrom returns the trace of a fetch, nil when it is not traced
+func traceFrom(ctx context.Context) *fetchTrace {
+	trace, _ := ctx.Value(traceKey{}).(*fetchTrace)
+	return trace
+}
+
+// start records a response; it does nothing on a nil trace
+func (t *fetchTrace) start(resp *http.Response) *tracedRequest {
+	if t == nil {
+		return nil
+	}
+	r := &tracedRequest{
+		url:       resp.Request.URL.String(),
+		finalURL:  resp.Request.URL.String(),
+		status:    resp.StatusCode,
+		header:    resp.Header,
+		redirects: redirects(resp),
+		keepBody:  t.keepBody,
+	}
+	if len(r.redirects) > 0 {
+		r.url = r.redirects[0].From
+	}
+	t.mu.Lock()
+	t.requests = append(t.requests, r)
+	t.mu.Unlock()
+	return r
+}
+
+// finish records the decoded body of a response
+func (r *tracedRequest) finish(body []byte) {
+	if r == nil {
+		return
+	}
+	r.bytes = int64(len(body))
+	if r.keepBody {
+		r.body = body
+	}
+}
+
+// first returns the first response, which is the feed document or the
+// listing of API sources
+func (t *fetchTrace) first() *tracedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.requests) == 0 {
+		return nil
+	}
+	return t.requests[0]
+}
+
+func (t *fetchTrace) bytes() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var n int64
+	for _, r := range t.requests {
+		n += r.bytes
+	}
+	return n
+}
+
+// logFetch writes a fetch attempt to the feed's fetch log and drops the
+// oldest attempts beyond fetchLogsKept
+func logFetch(feed models.Feed, started time.Time, trace *fetchTrace, items int, stored storeResult, fetchErr error) {
+	entry := models.FetchLog{
+		FeedID:       feed.ID,
+		StartedAt:    started,
+		DurationMS:   time.Since(started).Milliseconds(),
+		Bytes:        trace.bytes(),
+		ItemCount:    items,
+		NewCount:     stored.created,
+		UpdatedCount: stored.updated,
+	}
+	if first := trace.first(); first != nil {
+		entry.StatusCode = first.status
+	}
+	if fetchErr != nil {
+		entry.Error = fetchErr.Error()
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("could not log fetch of feed %d: %v", feed.ID, err)
+		return
+	}
+
+	var cutoff []uint
+	database.DB.Model(&models.FetchLog{}).Where("feed_id = ?", feed.ID).
+		Order("id DESC").Offset(fetchLogsKept).Limit(1).Pluck("id", &cutoff)
+	if len(cutoff) > 0 {
+		database.DB.Where("feed_id = ? AND id <= ?", feed.ID, cutoff[0]).Delete(&models.FetchLog{})
+	}
+}
@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchWritesLog(t *testing.T) {
+	testdb.Open(t)
+	srv := newFeedServer(t)
+	good := models.Feed{Title: "Good", URL: srv.URL + "/feed.xml"}
+	missing := models.Feed{Title: "Missing", URL: srv.URL + "/missing.xml"}
+	page := models.Feed{Title: "Page", URL: srv.URL + "/page.html"}
+	for _, feed := range []*models.Feed{&good, &missing, &page} {
+		database.DB.Create(feed)
+	}
+
+	lastLog := func(feed models.Feed) models.FetchLog {
+		t.Helper()
+		var entry models.FetchLog
+		if err := database.DB.Where("feed_id = ?", feed.ID).Order("id desc").First(&entry).Error; err != nil {
+			t.Fatalf("no fetch log for %s: %v", feed.Title, err)
+		}
+		return entry
+	}
+
+	if err := FetchAndStoreFeed(good); err != nil {
+		t.Fatal(err)
+	}
+	entry := lastLog(good)
+	if entry.StatusCode != http.StatusOK || entry.Error != "" || entry.Bytes != int64(len(testFeed)) ||
+		entry.ItemCount != 1 || entry.NewCount != 1 || entry.StartedAt.IsZero() {
+		t.Fatalf("first fetch logged %+v", entry)
+	}
+	// Nothing is new the second time
+	FetchAndStoreFeed(good)
+	if entry := lastLog(good); entry.ItemCount != 1 || entry.NewCount != 0 || entry.UpdatedCount != 0 {
+		t.Fatalf("second fetch logged %+v", entry)
+	}
+
+	tests := []struct {
+		feed   models.Feed
+		status int
+		err    string
+	}{
+		{missing, http.StatusNotFound, "404"},
+		{page, http.StatusOK, "parse"},
+	}
+	for _, tt := range tests {
+		if err := FetchAndStoreFeed(tt.feed); err == nil {
+			t.Fatalf("%s: fetch succeeded", tt.feed.Title)
+		}
+		entry := lastLog(tt.feed)
+		if entry.StatusCode != tt.status || !strings.Contains(entry.Error, tt.err) || entry.ItemCount != 0 {
+			t.Errorf("%s: logged %+v", tt.feed.Title, entry)
+		}
+	}
+}
+
+func TestFetchLogKeepsLatestAttempts(t *testing.T) {
+	testdb.Open(t)
+	feed := models.Feed{Title: "Blog", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	for i := 0; i < fetchLogsKept+5; i++ {
+		database.DB.Create(&models.FetchLog{FeedID: feed.ID, Error: fmt.Sprint(i)})
+	}
+	logFetch(feed, time.Now().UTC(), &fetchTrace{}, 0, storeResult{}, fmt.Errorf("latest"))
+
+	var entries []models.FetchLog
+	database.DB.Where("feed_id = ?", feed.ID).Order("id").Find(&entries)
+	if len(entries) != fetchLogsKept || entries[0].Error != "6" || entries[len(entries)-1].Error != "latest" {
+		t.Fatalf("%d entries kept, from %q to %q", len(entries), entries[0].Error, entries[len(entries)-1].Error)
+	}
+}
+
+func TestDiagnoseGoodFeed(t *testing.T) {
+	testdb.Open(t)
+	srv := newFeedServer(t)
+	feed := models.Feed{Title: "Blog", URL: srv.URL + "/301"}
+	database.DB.Create(&feed)
+
+	d, err := Diagnose(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Error != "" || d.StatusCode != http.StatusOK || d.FinalURL != srv.URL+"/feed.xml" ||
+		d.Format != "rss 2.0" || d.Bytes != int64(len(testFeed)) {
+		t.Fatalf("diagnosis = %+v", d)
+	}
+	if len(d.Redirects) != 1 || d.Redirects[0].Status != http.StatusMovedPermanently || d.Redirects[0].To != srv.URL+"/feed.xml" {
+		t.Fatalf("redirects = %+v", d.Redirects)
+	}
+	if len(d.Items) != 1 || !d.Items[0].New || d.Items[0].GUID != "hello" || d.Items[0].Published != nil {
+		t.Fatalf("items = %+v", d.Items)
+	}
+	if !containsWarning(d.Warnings, "item 1: no usable date") {
+		t.Fatalf("warnings = %v", d.Warnings)
+	}
+
+	// Nothing was stored, and the move was not recorded
+	var posts int64
+	database.DB.Model(&models.Post{}).Where("feed_id = ?", feed.ID).Count(&posts)
+	database.DB.First(&feed, feed.ID)
+	if posts != 0 || feed.URL != srv.URL+"/301" || feed.LastFetched != nil {
+		t.Fatalf("diagnose changed the feed: %d posts, %+v", posts, feed)
+	}
+
+	// Once stored the item is no longer new
+	if err := FetchAndStoreFeed(feed); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := Diagnose(feed); len(d.Items) != 1 || d.Items[0].New {
+		t.Fatalf("items after storing = %+v", d.Items)
+	}
+}
+
+func TestDiagnoseBrokenFeed(t *testing.T) {
+	testdb.Open(t)
+	srv := newFeedServer(t)
+	tests := []struct {
+		path    string
+		status  int
+		err     string
+		format  string
+		warning string
+	}{
+		{"/missing.xml", http.StatusNotFound, "404", "", ""},
+		{"/gone", http.StatusGone, "gone", "", ""},
+		{"/page.html", http.StatusOK, "parse", "unknown text/html", "parse error"},
+	}
+	for _, tt := range tests {
+		d, err := Diagnose(models.Feed{ID: 1, URL: srv.URL + tt.path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.StatusCode != tt.status || !strings.Contains(d.Error, tt.err) || d.Format != tt.format || len(d.Items) != 0 {
+			t.Errorf("%s: diagnosis = %+v", tt.path, d)
+		}
+		if tt.warning != "" && !containsWarning(d.Warnings, tt.warning) {
+			t.Errorf("%s: warnings = %v", tt.path, d.Warnings)
+		}
+	}
+}
+
+func TestDiagnoseRejectsNewsletters(t *testing.T) {
+	if _, err := Diagnose(models.Feed{URL: "mailto:news@example.com?recipient=1", SourceType: models.FeedSourceNewsletter}); err == nil {
+		t.Fatal("newsletter diagnosed")
+	}
+}
+
+func containsWarning(warnings []string, prefix string) bool {
+	for _, w := range warnings {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/sanitize"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	readability "github.com/go-shiori/go-readability"
+)
+
+const (
+	// Extracted articles are reused for articleTTL; failures are not
+	// retried for failureTTL so a broken page is not fetched on every post
+	articleTTL = 6 * time.Hour
+	failureTTL = 30 * time.Minute
+	// maxCachedArticles bounds the article cache
+	maxCachedArticles = 500
+)
+
+// ErrNoArticle reports a page where no main content could be found
+var ErrNoArticle = errors.New("no article content found")
+
+type cachedArticle struct {
+	content string
+	err     error
+	expires time.Time
+}
+
+var articleCache = struct {
+	sync.Mutex
+	entries map[string]cachedArticle
+}{entries: map[string]cachedArticle{}}
+
+// WantsFullContent reports whether full articles should be extracted for
+// a feed's new posts: the feed asks for it or one of its subscribers does
+func WantsFullContent(feed models.Feed) bool {
+	if feed.FetchFullContent {
+		return true
+	}
+	var count int64
+	database.DB.Model(&models.Subscription{}).
+		Where("feed_id = ? AND fetch_full_content = ?", feed.ID, true).
+		Count(&count)
+	return count > 0
+}
+
+// FetchFullContent extracts the article behind the post's link and stores
+// it in FullContent. refresh bypasses the article cache.
+func FetchFullContent(post *models.Post, refresh bool) error {
+	if !strings.HasPrefix(post.Link, "http://") && !strings.HasPrefix(post.Link, "https://") {
+		return fmt.Errorf("post link %q is not a web page", post.Link)
+	}
+	content, err := extractArticle(post.Link, refresh)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	err = database.DB.Model(&models.Post{}).Where("id = ?", post.ID).Updates(map[string]interface{}{
+		"full_content":            content,
+		"full_content_fetched_at": &now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	post.FullContent = content
+	post.FullContentFetchedAt = &now
+	return nil
+}
+
+// extractArticle returns the sanitized main content of a page, from the
+// cache when possible
+func extractArticle(pageURL string, refresh bool) (string, error) {
+	now := time.Now()
+	articleCache.Lock()
+	entry, ok := articleCache.entries[pageURL]
+	articleCache.Unlock()
+	if ok && !refresh && now.Before(entry.expires) {
+		return entry.content, entry.err
+	}
+
+	content, err := extractPage(pageURL)
+	entry = cachedArticle{content: content, err: err, expires: now.Add(articleTTL)}
+	if err != nil {
+		entry.expires = now.Add(failureTTL)
+	}
+
+	articleCache.Lock()
+	defer articleCache.Unlock()
+	if len(articleCache.entries) >= maxCachedArticles {
+		for key, e := range articleCache.entries {
+			if now.After(e.expires) {
+				delete(articleCache.entries, key)
+			}
+		}
+		// Still full: drop arbitrary entries, the cache only saves refetches
+		for key := range articleCache.entries {
+			if len(articleCache.entries) < maxCachedArticles {
+				break
+			}
+			delete(articleCache.entries, key)
+		}
+	}
+	articleCache.entries[pageURL] = entry
+	return content, err
+}
+
+func extractPage(pageURL string) (string, error) {
+	doc, base, err := fetchDocument(context.Background(), pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	var content string
+	if rule, ok := ruleFor(base.Hostname()); ok {
+		content, err = applyRule(doc, rule)
+		if err != nil {
+			return "", fmt.Errorf("extraction rule for %s: %w", rule.Host, err)
+		}
+	}
+	if content == "" && len(doc.Nodes) > 0 {
+		article, err := readability.FromDocument(doc.Nodes[0], base)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract %s: %w", pageURL, err)
+		}
+		content = article.Content
+	}
+
+	content = sanitize.Content(content, base.String())
+	if strings.TrimSpace(sanitize.PlainText(content)) == "" {
+		return "", ErrNoArticle
+	}
+	return content, nil
+}
+
+// ruleFor finds the extraction rule for a host, also trying it without a
+// leading "www."
+func ruleFor(host string) (models.ExtractionRule, bool) {
+	host = strings.ToLower(host)
+	hosts := []string{host}
+	if trimmed := strings.TrimPrefix(host, "www."); trimmed != host {
+		hosts = append(hosts, trimmed)
+	}
+	var rules []models.ExtractionRule
+	if err := database.DB.Where("host IN ?", hosts).Find(&rules).Error; err != nil || len(rules) == 0 {
+		return models.ExtractionRule{}, false
+	}
+	for _, rule := range rules {
+		if rule.Host == host {
+			return rule, true
+		}
+	}
+	return rules[0], true
+}
+
+// applyRule returns the HTML of the elements matching the rule, or "" when
+// nothing matches so the heuristic can take over
+func applyRule(doc *goquery.Document, rule models.ExtractionRule) (string, error) {
+	if err := ValidateExtractionRule(rule); err != nil {
+		return "", err
+	}
+	matches := doc.Find(rule.ContentSelector)
+	for _, selector := range rule.RemoveSelectors {
+		matches.Find(selector).Remove()
+	}
+	var b strings.Builder
+	matches.Each(func(_ int, sel *goquery.Selection) {
+		if h, err := goquery.OuterHtml(sel); err == nil {
+			b.WriteString(h)
+		}
+	})
+	return b.String(), nil
+}
+
+// ValidateExtractionRule checks a rule's host and selectors
+func ValidateExtractionRule(rule models.ExtractionRule) error {
+	if rule.Host == "" || strings.ContainsAny(rule.Host, "/:") {
+		return errors.New("host must be a bare host name such as example.com")
+	}
+	if _, err := cascadia.Compile(rule.ContentSelector); err != nil {
+		return fmt.Errorf("invalid content_selector: %w", err)
+	}
+	for _, selector := range rule.RemoveSelectors {
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("invalid remove selector %q: %w", selector, err)
+		}
+	}
+	return nil
+}
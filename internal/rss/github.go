@@ -0,0 +1,102 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHubSource reads a repository's releases from the GitHub REST API. The
+// feed URL is the repository page, e.g. https://github.com/golang/go; an
+// Authorization header in SourceHeaders raises the rate limit.
+type GitHubSource struct {
+	BaseURL string
+}
+
+type githubRelease struct {
+	ID          int64     `json:"id"`
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	HTMLURL     string    `json:"html_url"`
+	BodyHTML    string    `json:"body_html"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Author      struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Assets    []struct{} `json:"assets"`
+	Reactions struct {
+		TotalCount int `json:"total_count"`
+	} `json:"reactions"`
+}
+
+func (s *GitHubSource) Validate(feed models.Feed) error {
+	_, _, err := githubRepo(feed.URL)
+	return err
+}
+
+func (s *GitHubSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	owner, repo, err := githubRepo(feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		// Ask for rendered release notes instead of markdown
+		"Accept":               "application/vnd.github.html+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	for k, v := range feed.SourceHeaders {
+		headers[k] = v
+	}
+	var releases []githubRelease
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=30", strings.TrimSuffix(s.BaseURL, "/"), url.PathEscape(owner), url.PathEscape(repo))
+	if err := getJSON(ctx, apiURL, headers, &releases); err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0, len(releases))
+	for _, r := range releases {
+		if r.Draft {
+			continue
+		}
+		title := r.Name
+		if title == "" {
+			title = r.TagName
+		}
+		posts = append(posts, models.Post{
+			Title:     repo + " " + title,
+			Link:      r.HTMLURL,
+			GUID:      strconv.FormatInt(r.ID, 10),
+			Content:   r.BodyHTML,
+			Author:    r.Author.Login,
+			Published: r.PublishedAt.UTC(),
+			FeedId:    feed.ID,
+			Metadata: map[string]interface{}{
+				"tag":        r.TagName,
+				"prerelease": r.Prerelease,
+				"assets":     len(r.Assets),
+				"reactions":  r.Reactions.TotalCount,
+			},
+		})
+	}
+	return posts, nil
+}
+
+// githubRepo accepts https://github.com/owner/repo with any trailing path,
+// such as /releases
+func githubRepo(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(strings.TrimPrefix(u.Host, "www."), "github.com") {
+		return "", "", fmt.Errorf("github feeds need a https://github.com/owner/repo url, got %q", rawURL)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("github feeds need a https://github.com/owner/repo url, got %q", rawURL)
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), nil
+}
@@ -0,0 +1,100 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubFetchReleases(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"/repos/golang/go/releases?per_page=30": "github/releases.json",
+	})
+	source := &GitHubSource{BaseURL: srv.URL}
+	feed := models.Feed{
+		ID:            3,
+		URL:           "https://github.com/golang/go/releases",
+		SourceType:    models.FeedSourceGitHub,
+		SourceHeaders: map[string]string{"Authorization": "Bearer gh-token"},
+	}
+	posts, err := source.Fetch(context.Background(), feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := srv.request(t, "/repos/golang/go/releases")
+	if req.Header.Get("Accept") != "application/vnd.github.html+json" ||
+		req.Header.Get("X-GitHub-Api-Version") == "" || req.Header.Get("Authorization") != "Bearer gh-token" {
+		t.Fatalf("request headers = %v", req.Header)
+	}
+
+	// The draft is skipped
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	release := posts[0]
+	if release.Title != "go Go 1.23.1" || release.Link != "https://github.com/golang/go/releases/tag/go1.23.1" ||
+		release.GUID != "187654321" || release.Author != "gopherbot" || release.FeedId != 3 ||
+		!release.Published.Equal(time.Date(2024, 9, 5, 17, 22, 10, 0, time.UTC)) {
+		t.Fatalf("release = %+v", release)
+	}
+	if release.Metadata["tag"] != "go1.23.1" || release.Metadata["assets"] != 2 ||
+		release.Metadata["reactions"] != 42 || release.Metadata["prerelease"] != false {
+		t.Fatalf("metadata = %v", release.Metadata)
+	}
+	if !strings.Contains(release.Content, "Security fixes") {
+		t.Fatalf("content = %s", release.Content)
+	}
+	// Unnamed releases fall back to the tag
+	if posts[1].Title != "go go1.24rc1" || posts[1].Metadata["prerelease"] != true {
+		t.Fatalf("prerelease = %+v", posts[1])
+	}
+}
+
+func TestGitHubFetchSanitizesThroughFetchPosts(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"/repos/golang/go/releases?per_page=30": "github/releases.json",
+	})
+	Register(models.FeedSourceGitHub, &GitHubSource{BaseURL: srv.URL})
+	t.Cleanup(func() { Register(models.FeedSourceGitHub, &GitHubSource{BaseURL: "https://api.github.com"}) })
+
+	posts, err := FetchPosts(models.Feed{URL: "https://github.com/golang/go", SourceType: models.FeedSourceGitHub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := posts[0].Content
+	if strings.Contains(content, "<script") || !strings.Contains(content, `href="https://github.com/golang/go/issues/68000"`) {
+		t.Fatalf("content = %s", content)
+	}
+}
+
+func TestGitHubFetchNotFound(t *testing.T) {
+	srv := newFixtureServer(t, nil)
+	source := &GitHubSource{BaseURL: srv.URL}
+	_, err := source.Fetch(context.Background(), models.Feed{URL: "https://github.com/golang/missing"})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestGitHubRepo(t *testing.T) {
+	tests := []struct {
+		url, owner, repo string
+		ok               bool
+	}{
+		{"https://github.com/golang/go", "golang", "go", true},
+		{"https://www.github.com/golang/go.git", "golang", "go", true},
+		{"https://github.com/golang/go/releases/tag/go1.23.1", "golang", "go", true},
+		{"https://github.com/golang", "", "", false},
+		{"https://gitlab.com/golang/go", "", "", false},
+		{"not a url\x7f", "", "", false},
+	}
+	for _, tt := range tests {
+		owner, repo, err := githubRepo(tt.url)
+		if (err == nil) != tt.ok || owner != tt.owner || repo != tt.repo {
+			t.Errorf("githubRepo(%q) = %q, %q, %v", tt.url, owner, repo, err)
+		}
+	}
+}
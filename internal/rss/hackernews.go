@@ -0,0 +1,183 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hnItemURL = "https://news.ycombinator.com/item?id="
+	// hnLimit is how many stories or comments one fetch reads
+	hnLimit = 30
+	// hnWorkers bounds the concurrent item requests
+	hnWorkers = 8
+)
+
+// hnListings maps news.ycombinator.com pages to API story lists
+var hnListings = map[string]string{
+	"":       "topstories",
+	"news":   "topstories",
+	"newest": "newstories",
+	"best":   "beststories",
+	"ask":    "askstories",
+	"show":   "showstories",
+	"jobs":   "jobstories",
+}
+
+// HackerNewsSource reads Hacker News through its Firebase API. The feed URL
+// is a listing such as https://news.ycombinator.com/best, or a thread,
+// https://news.ycombinator.com/item?id=123, whose top-level comments
+// become the posts.
+type HackerNewsSource struct {
+	BaseURL string
+}
+
+type hnItem struct {
+	ID          int64   `json:"id"`
+	Type        string  `json:"type"`
+	By          string  `json:"by"`
+	Time        int64   `json:"time"`
+	Title       string  `json:"title"`
+	URL         string  `json:"url"`
+	Text        string  `json:"text"`
+	Score       int     `json:"score"`
+	Descendants int     `json:"descendants"`
+	Kids        []int64 `json:"kids"`
+	Dead        bool    `json:"dead"`
+	Deleted     bool    `json:"deleted"`
+}
+
+func (s *HackerNewsSource) Validate(feed models.Feed) error {
+	_, _, err := hnTarget(feed.URL)
+	return err
+}
+
+func (s *HackerNewsSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	listing, threadID, err := hnTarget(feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(s.BaseURL, "/")
+
+	var ids []int64
+	var thread hnItem
+	if threadID != 0 {
+		if err := getJSON(ctx, fmt.Sprintf("%s/v0/item/%d.json", base, threadID), nil, &thread); err != nil {
+			return nil, err
+		}
+		ids = thread.Kids
+	} else if err := getJSON(ctx, fmt.Sprintf("%s/v0/%s.json", base, listing), nil, &ids); err != nil {
+		return nil, err
+	}
+	if len(ids) > hnLimit {
+		ids = ids[:hnLimit]
+	}
+
+	items, err := s.fetchItems(ctx, base, ids)
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]models.Post, 0, len(items))
+	for _, item := range items {
+		if item.Dead || item.Deleted {
+			continue
+		}
+		if threadID != 0 {
+			posts = append(posts, hnCommentPost(item, thread, feed.ID))
+		} else {
+			posts = append(posts, hnStoryPost(item, feed.ID))
+		}
+	}
+	return posts, nil
+}
+
+// fetchItems loads items concurrently, keeping their order
+func (s *HackerNewsSource) fetchItems(ctx context.Context, base string, ids []int64) ([]hnItem, error) {
+	items := make([]hnItem, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, hnWorkers)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = getJSON(ctx, fmt.Sprintf("%s/v0/item/%d.json", base, id), nil, &items[i])
+		}(i, id)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func hnStoryPost(item hnItem, feedID uint) models.Post {
+	// The discussion page is canonical; the article is in the content
+	content := item.Text
+	if item.URL != "" {
+		content = fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(item.URL), html.EscapeString(item.URL)) + content
+	}
+	return models.Post{
+		Title:     item.Title,
+		Link:      hnItemURL + strconv.FormatInt(item.ID, 10),
+		GUID:      strconv.FormatInt(item.ID, 10),
+		Content:   content,
+		Author:    item.By,
+		Published: time.Unix(item.Time, 0).UTC(),
+		FeedId:    feedID,
+		Metadata: map[string]interface{}{
+			"score":    item.Score,
+			"comments": item.Descendants,
+			"url":      item.URL,
+			"type":     item.Type,
+		},
+	}
+}
+
+func hnCommentPost(item, thread hnItem, feedID uint) models.Post {
+	return models.Post{
+		Title:     item.By + " on " + thread.Title,
+		Link:      hnItemURL + strconv.FormatInt(item.ID, 10),
+		GUID:      strconv.FormatInt(item.ID, 10),
+		Content:   item.Text,
+		Author:    item.By,
+		Published: time.Unix(item.Time, 0).UTC(),
+		FeedId:    feedID,
+		Metadata: map[string]interface{}{
+			"replies": len(item.Kids),
+			"thread":  thread.ID,
+		},
+	}
+}
+
+// hnTarget returns the API listing or the thread id a feed URL names
+func hnTarget(rawURL string) (string, int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Host, "news.ycombinator.com") {
+		return "", 0, fmt.Errorf("hacker news feeds need a https://news.ycombinator.com url, got %q", rawURL)
+	}
+	page := strings.Trim(u.Path, "/")
+	if page == "item" {
+		id, err := strconv.ParseInt(u.Query().Get("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return "", 0, fmt.Errorf("hacker news thread url has no valid id: %q", rawURL)
+		}
+		return "", id, nil
+	}
+	listing, ok := hnListings[page]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported hacker news page %q", page)
+	}
+	return listing, 0, nil
+}
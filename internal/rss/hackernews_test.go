@@ -0,0 +1,111 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hnFixtures is the recorded API: a best stories listing, its stories and
+// the comments on the first one
+var hnFixtures = map[string]string{
+	"/v0/beststories.json":   "hackernews/beststories.json",
+	"/v0/item/41000001.json": "hackernews/item_41000001.json",
+	"/v0/item/41000002.json": "hackernews/item_41000002.json",
+	"/v0/item/41000003.json": "hackernews/item_41000003.json",
+	"/v0/item/41000101.json": "hackernews/item_41000101.json",
+	"/v0/item/41000102.json": "hackernews/item_41000102.json",
+}
+
+func TestHackerNewsFetchListing(t *testing.T) {
+	srv := newFixtureServer(t, hnFixtures)
+	source := &HackerNewsSource{BaseURL: srv.URL}
+	feed := models.Feed{ID: 5, URL: "https://news.ycombinator.com/best", SourceType: models.FeedSourceHackerNews}
+	posts, err := source.Fetch(context.Background(), feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dead stories are skipped and the listing order is kept
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	story := posts[0]
+	if story.Title != "Show HN: A tiny feed reader" || story.Link != "https://news.ycombinator.com/item?id=41000001" ||
+		story.GUID != "41000001" || story.Author != "pg" || story.FeedId != 5 ||
+		!story.Published.Equal(time.Unix(1725523200, 0).UTC()) {
+		t.Fatalf("story = %+v", story)
+	}
+	if !strings.HasPrefix(story.Content, `<p><a href="https://example.com/reader?ref=hn&amp;x=1">`) {
+		t.Fatalf("content = %s", story.Content)
+	}
+	if story.Metadata["score"] != 512 || story.Metadata["comments"] != 120 || story.Metadata["type"] != "story" {
+		t.Fatalf("metadata = %v", story.Metadata)
+	}
+	// Text posts have no link, only their text
+	if ask := posts[1]; ask.Content != "What are you working on? <i>Share below</i>." || ask.Metadata["url"] != "" {
+		t.Fatalf("ask = %+v", ask)
+	}
+}
+
+func TestHackerNewsFetchThread(t *testing.T) {
+	srv := newFixtureServer(t, hnFixtures)
+	source := &HackerNewsSource{BaseURL: srv.URL}
+	feed := models.Feed{URL: "https://news.ycombinator.com/item?id=41000001", SourceType: models.FeedSourceHackerNews}
+	posts, err := source.Fetch(context.Background(), feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Top-level comments become posts; the deleted one is skipped
+	if len(posts) != 1 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	comment := posts[0]
+	if comment.Title != "alice on Show HN: A tiny feed reader" || comment.GUID != "41000101" ||
+		comment.Link != "https://news.ycombinator.com/item?id=41000101" || !strings.Contains(comment.Content, "docs") {
+		t.Fatalf("comment = %+v", comment)
+	}
+	if comment.Metadata["replies"] != 2 || comment.Metadata["thread"] != int64(41000001) {
+		t.Fatalf("metadata = %v", comment.Metadata)
+	}
+}
+
+func TestHackerNewsFetchFailsOnMissingItem(t *testing.T) {
+	fixtures := map[string]string{}
+	for path, file := range hnFixtures {
+		fixtures[path] = file
+	}
+	delete(fixtures, "/v0/item/41000002.json")
+	srv := newFixtureServer(t, fixtures)
+	source := &HackerNewsSource{BaseURL: srv.URL}
+	_, err := source.Fetch(context.Background(), models.Feed{URL: "https://news.ycombinator.com/best"})
+	if err == nil || !strings.Contains(err.Error(), "41000002") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestHNTarget(t *testing.T) {
+	tests := []struct {
+		url     string
+		listing string
+		thread  int64
+		ok      bool
+	}{
+		{"https://news.ycombinator.com/", "topstories", 0, true},
+		{"https://news.ycombinator.com/newest", "newstories", 0, true},
+		{"https://news.ycombinator.com/show", "showstories", 0, true},
+		{"https://news.ycombinator.com/item?id=41000001", "", 41000001, true},
+		{"https://news.ycombinator.com/item?id=abc", "", 0, false},
+		{"https://news.ycombinator.com/submit", "", 0, false},
+		{"https://example.com/best", "", 0, false},
+	}
+	for _, tt := range tests {
+		listing, thread, err := hnTarget(tt.url)
+		if (err == nil) != tt.ok || listing != tt.listing || thread != tt.thread {
+			t.Errorf("hnTarget(%q) = %q, %d, %v", tt.url, listing, thread, err)
+		}
+	}
+}
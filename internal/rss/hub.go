@@ -0,0 +1,113 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// discoverHub finds the WebSub hub and topic (self) URLs a feed advertises,
+// preferring Link headers over <link> elements in the document as the
+// WebSub spec does
+func discoverHub(header http.Header, body []byte) (hub, topic string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, rels, ok := parseLinkHeader(link)
+			if !ok {
+				continue
+			}
+			if hub == "" && rels["hub"] {
+				hub = target
+			}
+			if topic == "" && rels["self"] {
+				topic = target
+			}
+		}
+	}
+	if hub != "" && topic != "" {
+		return hub, topic
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		// Only feed level links matter; items start after this
+		if el.Name.Local == "item" || el.Name.Local == "entry" {
+			break
+		}
+		if el.Name.Local != "link" {
+			continue
+		}
+		var rel, href string
+		for _, attr := range el.Attr {
+			switch attr.Name.Local {
+			case "rel":
+				rel = attr.Value
+			case "href":
+				href = strings.TrimSpace(attr.Value)
+			}
+		}
+		for _, r := range strings.Fields(rel) {
+			if hub == "" && r == "hub" {
+				hub = href
+			}
+			if topic == "" && r == "self" {
+				topic = href
+			}
+		}
+	}
+	return hub, topic
+}
+
+// parseLinkHeader reads one `<url>; rel="a b"` entry of a Link header
+func parseLinkHeader(link string) (string, map[string]bool, bool) {
+	parts := strings.Split(link, ";")
+	target := strings.TrimSpace(parts[0])
+	if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+		return "", nil, false
+	}
+	rels := map[string]bool{}
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(key, "rel") {
+			continue
+		}
+		for _, r := range strings.Fields(strings.Trim(value, `"`)) {
+			rels[strings.ToLower(r)] = true
+		}
+	}
+	return target[1 : len(target)-1], rels, true
+}
+
+// recordHub keeps the feed's advertised hub up to date. Hubs must be
+// absolute http(s) URLs; a feed without a self link is its own topic.
+func recordHub(feed models.Feed, hub, topic string) {
+	if feed.ID == 0 {
+		return
+	}
+	if u, err := url.Parse(hub); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		hub = ""
+	}
+	if hub == "" {
+		topic = ""
+	} else if topic == "" {
+		topic = feed.URL
+	}
+	if hub == feed.HubURL && topic == feed.TopicURL {
+		return
+	}
+	database.DB.Model(&models.Feed{}).Where("id = ?", feed.ID).
+		Updates(map[string]interface{}{"hub_url": hub, "topic_url": topic})
+}
@@ -0,0 +1,197 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/sanitize"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// jsonPaths are a JSONConfig's compiled expressions
+type jsonPaths struct {
+	items, title, link, id, date, body jp.Expr
+}
+
+// jsonSource maps items of a JSON document with JSONPath expressions
+type jsonSource struct{}
+
+func (jsonSource) Validate(feed models.Feed) error {
+	if feed.SourceConfig == nil || feed.SourceConfig.JSON == nil {
+		return errors.New("source_config.json is required for json feeds")
+	}
+	if err := safehttp.CheckURL(feed.URL); err != nil {
+		return err
+	}
+	_, err := compileJSONPaths(*feed.SourceConfig.JSON)
+	return err
+}
+
+func compileJSONPaths(cfg models.JSONConfig) (jsonPaths, error) {
+	var paths jsonPaths
+	if cfg.ItemsPath == "" || cfg.TitlePath == "" {
+		return paths, errors.New("items_path and title_path are required")
+	}
+	for _, p := range []struct {
+		name string
+		expr string
+		dst  *jp.Expr
+	}{
+		{"items_path", cfg.ItemsPath, &paths.items},
+		{"title_path", cfg.TitlePath, &paths.title},
+		{"link_path", cfg.LinkPath, &paths.link},
+		{"id_path", cfg.IDPath, &paths.id},
+		{"date_path", cfg.DatePath, &paths.date},
+		{"body_path", cfg.BodyPath, &paths.body},
+	} {
+		if p.expr == "" {
+			continue
+		}
+		x, err := jp.ParseString(p.expr)
+		if err != nil {
+			return paths, fmt.Errorf("invalid %s: %w", p.name, err)
+		}
+		*p.dst = x
+	}
+	return paths, nil
+}
+
+func (jsonSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	if feed.SourceConfig == nil || feed.SourceConfig.JSON == nil {
+		return nil, errors.New("json feed has no mapping")
+	}
+	cfg := *feed.SourceConfig.JSON
+	paths, err := compileJSONPaths(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(feed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed url %s: %w", feed.URL, err)
+	}
+
+	resp, err := fetchURL(ctx, feed.URL, "application/json", feed.SourceHeaders)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := oj.Parse(resp.body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", feed.URL, err)
+	}
+	recordMove(feed, resp.movedTo)
+	return mapJSONItems(doc, paths, cfg.DateFormats, base, feed.ID), nil
+}
+
+func mapJSONItems(doc any, paths jsonPaths, dateFormats []string, base *url.URL, feedID uint) []models.Post {
+	var posts []models.Post
+	for _, item := range paths.items.Get(doc) {
+		title := collapseSpace(jsonString(item, paths.title))
+		id := jsonString(item, paths.id)
+		link := resolveLink(base, jsonString(item, paths.link))
+		if title == "" && id == "" && link == "" {
+			continue
+		}
+		if link == "" {
+			// Links are unique, so items without one get a synthetic link
+			key := id
+			if key == "" {
+				sum := sha256.Sum256([]byte(title))
+				key = hex.EncodeToString(sum[:8])
+			}
+			link = base.String() + "#" + url.PathEscape(key)
+		}
+		var published time.Time
+		if paths.date != nil {
+			if t, ok := jsonDate(first(paths.date.Get(item)), dateFormats); ok {
+				published = t
+			}
+		}
+		content := ""
+		if body := jsonString(item, paths.body); body != "" {
+			if strings.Contains(body, "<") {
+				content = body
+			} else {
+				content = sanitize.Text(body)
+			}
+		}
+		posts = append(posts, models.Post{
+			Title:     title,
+			Link:      link,
+			GUID:      id,
+			Content:   content,
+			Published: published,
+			FeedId:    feedID,
+		})
+	}
+	return posts
+}
+
+func first(values []any) any {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// jsonString renders the first match of x as text; empty when x is unset
+func jsonString(item any, x jp.Expr) string {
+	if x == nil {
+		return ""
+	}
+	switch v := first(x.Get(item)).(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// jsonDate accepts date strings and Unix timestamps; values too large to be
+// seconds are taken as milliseconds
+func jsonDate(v any, formats []string) (time.Time, bool) {
+	var n float64
+	switch v := v.(type) {
+	case string:
+		if t, ok := parseDate(v, formats); ok {
+			return t, true
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	case int64:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return time.Time{}, false
+	}
+	if n <= 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return time.Time{}, false
+	}
+	if n > 1e11 {
+		return time.UnixMilli(int64(n)).UTC(), true
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+}
@@ -0,0 +1,198 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+
+	xmlDeclPattern     = regexp.MustCompile(`^<\?xml[^>]*\?>`)
+	xmlEncodingPattern = regexp.MustCompile(`(encoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+	entityPattern      = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{0,31});`)
+
+	cdataStart   = []byte("<![CDATA[")
+	cdataEnd     = []byte("]]>")
+	commentStart = []byte("<!--")
+	commentEnd   = []byte("-->")
+)
+
+// xmlEntities are the entities XML defines without a DTD
+var xmlEntities = map[string]bool{"amp": true, "lt": true, "gt": true, "quot": true, "apos": true}
+
+// normalizeFeed prepares a fetched feed document for gofeed: it converts
+// the document to UTF-8 using the BOM, the Content-Type charset or the XML
+// declaration, and repairs errors that make XML parsers give up, like
+// control characters, bare ampersands and HTML entities. It returns the
+// repaired document and a description of each repair.
+func normalizeFeed(body []byte, contentType string) ([]byte, []string) {
+	body, fixes := toUTF8(body, contentType)
+
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		// JSON Feed; only the encoding applies
+		return trimmed, fixes
+	}
+	if start := bytes.IndexByte(trimmed, '<'); start > 0 {
+		trimmed = trimmed[start:]
+		fixes = append(fixes, "removed text before the XML document")
+	}
+
+	controls := 0
+	trimmed = bytes.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r') || r == 0xFFFE || r == 0xFFFF {
+			controls++
+			return -1
+		}
+		return r
+	}, trimmed)
+	if controls > 0 {
+		fixes = append(fixes, fmt.Sprintf("removed %d control characters", controls))
+	}
+
+	repaired, repairs := repairXML(trimmed)
+	return repaired, append(fixes, repairs...)
+}
+
+// toUTF8 decodes a document to UTF-8 and makes its XML declaration say so.
+// Undeclared documents, and ones wrongly declared as UTF-8, that are not
+// valid UTF-8 are read as Windows-1252, the usual culprit.
+func toUTF8(body []byte, contentType string) ([]byte, []string) {
+	label := ""
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		body, label = body[len(utf8BOM):], "utf-8"
+	case bytes.HasPrefix(body, utf16LEBOM):
+		body, label = body[len(utf16LEBOM):], "utf-16le"
+	case bytes.HasPrefix(body, utf16BEBOM):
+		body, label = body[len(utf16BEBOM):], "utf-16be"
+	}
+	if label == "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			label = params["charset"]
+		}
+	}
+	if label == "" {
+		if m := xmlEncodingPattern.FindSubmatch(xmlDeclPattern.Find(bytes.TrimLeft(body, " \t\r\n"))); m != nil {
+			label = string(m[2])
+		}
+	}
+
+	var fixes []string
+	enc, name := charset.Lookup(label)
+	if enc == nil || name == "utf-8" {
+		if utf8.Valid(body) {
+			return utf8Declaration(body), nil
+		}
+		enc, name = charset.Lookup("windows-1252")
+		fixes = append(fixes, "document is not valid UTF-8")
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return utf8Declaration(bytes.ToValidUTF8(body, []byte("�"))), append(fixes, "replaced invalid UTF-8 sequences")
+	}
+	return utf8Declaration(decoded), append(fixes, fmt.Sprintf("converted from %s to UTF-8", name))
+}
+
+// utf8Declaration rewrites the encoding in an XML declaration to UTF-8 so
+// the parser does not decode the document a second time
+func utf8Declaration(body []byte) []byte {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	decl := xmlDeclPattern.Find(trimmed)
+	m := xmlEncodingPattern.FindSubmatchIndex(decl)
+	if m == nil || bytes.EqualFold(decl[m[4]:m[5]], []byte("utf-8")) {
+		return body
+	}
+	offset := len(body) - len(trimmed)
+	out := make([]byte, 0, len(body))
+	out = append(out, body[:offset+m[4]]...)
+	out = append(out, "UTF-8"...)
+	return append(out, body[offset+m[5]:]...)
+}
+
+// repairXML escapes stray '&' and '<' and turns HTML entities, which XML
+// does not know, into character references. CDATA sections and comments
+// are left alone.
+func repairXML(doc []byte) ([]byte, []string) {
+	var out bytes.Buffer
+	out.Grow(len(doc))
+	amps, entities, lts := 0, 0, 0
+	for i := 0; i < len(doc); {
+		rest := doc[i:]
+		switch {
+		case bytes.HasPrefix(rest, cdataStart):
+			n := sectionLength(rest, cdataEnd)
+			out.Write(rest[:n])
+			i += n
+		case bytes.HasPrefix(rest, commentStart):
+			n := sectionLength(rest, commentEnd)
+			out.Write(rest[:n])
+			i += n
+		case rest[0] == '<':
+			if len(rest) > 1 && startsMarkup(rest[1]) {
+				out.WriteByte('<')
+			} else {
+				out.WriteString("&lt;")
+				lts++
+			}
+			i++
+		case rest[0] == '&':
+			m := entityPattern.FindSubmatch(rest)
+			switch {
+			case m != nil && (m[1][0] == '#' || xmlEntities[string(m[1])]):
+				out.Write(m[0])
+				i += len(m[0])
+			case m != nil && html.UnescapeString(string(m[0])) != string(m[0]):
+				for _, r := range html.UnescapeString(string(m[0])) {
+					fmt.Fprintf(&out, "&#%d;", r)
+				}
+				entities++
+				i += len(m[0])
+			default:
+				out.WriteString("&amp;")
+				amps++
+				i++
+			}
+		default:
+			out.WriteByte(rest[0])
+			i++
+		}
+	}
+
+	var fixes []string
+	if amps > 0 {
+		fixes = append(fixes, fmt.Sprintf("escaped %d stray '&'", amps))
+	}
+	if lts > 0 {
+		fixes = append(fixes, fmt.Sprintf("escaped %d stray '<'", lts))
+	}
+	if entities > 0 {
+		fixes = append(fixes, fmt.Sprintf("replaced %d HTML entities", entities))
+	}
+	return out.Bytes(), fixes
+}
+
+// sectionLength is the length of a section up to and including end, or of
+// all of doc when the section is not closed
+func sectionLength(doc, end []byte) int {
+	if n := bytes.Index(doc, end); n >= 0 {
+		return n + len(end)
+	}
+	return len(doc)
+}
+
+// startsMarkup reports whether c may follow '<' in a tag, declaration or
+// processing instruction
+func startsMarkup(c byte) bool {
+	return c == '/' || c == '!' || c == '?' || c == '_' || c == ':' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,108 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encode converts a UTF-8 test document to another charset
+func encode(t *testing.T, enc encoding.Encoding, s string) string {
+	t.Helper()
+	out, err := enc.NewEncoder().String(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestNormalizeFeedCharsets(t *testing.T) {
+	const latinDoc = `<?xml version="1.0" encoding="ISO-8859-1"?><rss><channel><title>Café</title></channel></rss>`
+	const utf8Doc = `<?xml version="1.0" encoding="UTF-8"?><rss><channel><title>Café</title></channel></rss>`
+	const sjisDoc = `<?xml version="1.0" encoding="Shift_JIS"?><rss><channel><title>日本語</title></channel></rss>`
+	const undeclared = `<rss><channel><title>“Café” €</title></channel></rss>`
+	const undeclaredLatin = `<rss><channel><title>Café</title></channel></rss>`
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        string
+		fixes       []string
+	}{
+		{"utf-8", utf8Doc, "application/rss+xml", utf8Doc, nil},
+		{"declared latin-1", encode(t, charmap.ISO8859_1, latinDoc), "", utf8Doc,
+			[]string{"converted from windows-1252 to UTF-8"}},
+		{"charset in the content type", encode(t, charmap.ISO8859_1, undeclaredLatin), "text/xml; charset=ISO-8859-1",
+			undeclaredLatin, []string{"converted from windows-1252 to UTF-8"}},
+		{"shift_jis", encode(t, japanese.ShiftJIS, sjisDoc), "",
+			`<?xml version="1.0" encoding="UTF-8"?><rss><channel><title>日本語</title></channel></rss>`,
+			[]string{"converted from shift_jis to UTF-8"}},
+		{"content type wins over the declaration", encode(t, japanese.ShiftJIS, sjisDoc), "application/xml; charset=Shift_JIS",
+			`<?xml version="1.0" encoding="UTF-8"?><rss><channel><title>日本語</title></channel></rss>`,
+			[]string{"converted from shift_jis to UTF-8"}},
+		{"undeclared windows-1252", encode(t, charmap.Windows1252, undeclared), "", undeclared,
+			[]string{"document is not valid UTF-8", "converted from windows-1252 to UTF-8"}},
+		{"windows-1252 declared as utf-8", encode(t, charmap.Windows1252, `<?xml version="1.0" encoding="utf-8"?>`+undeclared), "",
+			`<?xml version="1.0" encoding="utf-8"?>` + undeclared,
+			[]string{"document is not valid UTF-8", "converted from windows-1252 to UTF-8"}},
+		{"utf-8 BOM", "\xEF\xBB\xBF" + utf8Doc, "", utf8Doc, nil},
+		{"utf-16 BOM", encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), `<?xml version="1.0" encoding="UTF-16"?><rss/>`), "",
+			`<?xml version="1.0" encoding="UTF-8"?><rss/>`, []string{"converted from utf-16le to UTF-8"}},
+	}
+	for _, tt := range tests {
+		got, fixes := normalizeFeed([]byte(tt.body), tt.contentType)
+		if string(got) != tt.want || !reflect.DeepEqual(fixes, tt.fixes) {
+			t.Errorf("%s:\n got  %s %q\n want %s %q", tt.name, got, fixes, tt.want, tt.fixes)
+		}
+	}
+}
+
+func TestNormalizeFeedRepairsXML(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		want  string
+		fixes []string
+	}{
+		{"bare ampersands", `<title>Tom & Jerry &amp; &#38; &#x26; &unknown;</title>`,
+			`<title>Tom &amp; Jerry &amp; &#38; &#x26; &amp;unknown;</title>`, []string{"escaped 2 stray '&'"}},
+		{"html entities", `<title>a&nbsp;b &copy; &eacute;</title>`,
+			`<title>a&#160;b &#169; &#233;</title>`, []string{"replaced 3 HTML entities"}},
+		{"stray less-than", `<title>1 < 2</title>`, `<title>1 &lt; 2</title>`, []string{"escaped 1 stray '<'"}},
+		{"control characters", "<title>a\x00b\x01c\x0bd\x1f\tkept\r\n</title>", "<title>abcd\tkept\r\n</title>",
+			[]string{"removed 4 control characters"}},
+		{"text before the document", "\n  junk<rss/>", "<rss/>", []string{"removed text before the XML document"}},
+		{"cdata and comments left alone", `<d><![CDATA[x & y < z]]><!-- a & b --></d>`,
+			`<d><![CDATA[x & y < z]]><!-- a & b --></d>`, nil},
+		{"unclosed cdata", `<d><![CDATA[x & y`, `<d><![CDATA[x & y`, nil},
+		{"json feed", ` {"title": "x & y"}`, `{"title": "x & y"}`, nil},
+	}
+	for _, tt := range tests {
+		got, fixes := normalizeFeed([]byte(tt.body), "")
+		if string(got) != tt.want || !reflect.DeepEqual(fixes, tt.fixes) {
+			t.Errorf("%s:\n got  %q %q\n want %q %q", tt.name, got, fixes, tt.want, tt.fixes)
+		}
+	}
+}
+
+func TestParseFeedAfterRepairs(t *testing.T) {
+	doc := encode(t, charmap.Windows1252, "<?xml version=\"1.0\"?>\n<rss version=\"2.0\"><channel><title>Caf\u00e9 & Bar</title>"+
+		"<item><title>Fish &amp; Chips\x01 &mdash; “today”</title><link>https://example.com/1?a=1&b=2</link>"+
+		"<pubDate>Mon, 02 Jan 2006 15:04 GMT</pubDate></item></channel></rss>")
+	posts, err := parseFeed(models.Feed{ID: 4, URL: "https://example.com/feed"}, []byte(doc), "application/rss+xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	post := posts[0]
+	if post.Title != "Fish & Chips — “today”" || post.Link != "https://example.com/1?a=1&b=2" || post.FeedId != 4 {
+		t.Fatalf("post = %+v", post)
+	}
+}
@@ -0,0 +1,118 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redditSorts are the listings a subreddit feed may follow
+var redditSorts = map[string]bool{"hot": true, "new": true, "top": true, "rising": true}
+
+// RedditSource reads a subreddit listing from Reddit's JSON API. The feed
+// URL is the subreddit page, e.g. https://www.reddit.com/r/golang/new.
+type RedditSource struct {
+	BaseURL string
+}
+
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Data redditPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+type redditPost struct {
+	Name         string  `json:"name"`
+	Title        string  `json:"title"`
+	Author       string  `json:"author"`
+	Permalink    string  `json:"permalink"`
+	URL          string  `json:"url"`
+	SelftextHTML string  `json:"selftext_html"`
+	IsSelf       bool    `json:"is_self"`
+	Score        int     `json:"score"`
+	NumComments  int     `json:"num_comments"`
+	Subreddit    string  `json:"subreddit"`
+	Stickied     bool    `json:"stickied"`
+	Over18       bool    `json:"over_18"`
+	CreatedUTC   float64 `json:"created_utc"`
+}
+
+func (s *RedditSource) Validate(feed models.Feed) error {
+	_, _, err := redditListingPath(feed.URL)
+	return err
+}
+
+func (s *RedditSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	subreddit, sort, err := redditListingPath(feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	var listing redditListing
+	apiURL := fmt.Sprintf("%s/r/%s/%s.json?limit=25&raw_json=1", strings.TrimSuffix(s.BaseURL, "/"), url.PathEscape(subreddit), sort)
+	if err := getJSON(ctx, apiURL, feed.SourceHeaders, &listing); err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0, len(listing.Data.Children))
+	for _, child := range listing.Data.Children {
+		p := child.Data
+		if p.Stickied {
+			continue
+		}
+		// The permalink is the canonical page; link posts point elsewhere
+		link := "https://www.reddit.com" + p.Permalink
+		content := p.SelftextHTML
+		if !p.IsSelf && p.URL != "" {
+			content = fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(p.URL), html.EscapeString(p.URL)) + content
+		}
+		sec, frac := math.Modf(p.CreatedUTC)
+		posts = append(posts, models.Post{
+			Title:     p.Title,
+			Link:      link,
+			GUID:      p.Name,
+			Content:   content,
+			Author:    "u/" + p.Author,
+			Published: time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+			FeedId:    feed.ID,
+			Metadata: map[string]interface{}{
+				"score":     p.Score,
+				"comments":  p.NumComments,
+				"subreddit": p.Subreddit,
+				"url":       p.URL,
+				"nsfw":      p.Over18,
+			},
+		})
+	}
+	return posts, nil
+}
+
+// redditListingPath accepts https://www.reddit.com/r/<name>[/<sort>]
+func redditListingPath(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	host := ""
+	if err == nil {
+		host = strings.ToLower(u.Host)
+	}
+	if host != "reddit.com" && !strings.HasSuffix(host, ".reddit.com") {
+		return "", "", fmt.Errorf("reddit feeds need a https://www.reddit.com/r/name url, got %q", rawURL)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "r" || parts[1] == "" {
+		return "", "", fmt.Errorf("reddit feeds need a https://www.reddit.com/r/name url, got %q", rawURL)
+	}
+	sort := "hot"
+	if len(parts) > 2 {
+		sort = strings.TrimSuffix(parts[2], ".json")
+		if !redditSorts[sort] {
+			return "", "", fmt.Errorf("unsupported reddit listing %q", parts[2])
+		}
+	}
+	return parts[1], sort, nil
+}
@@ -0,0 +1,87 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedditFetchListing(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"/r/golang/new.json?limit=25&raw_json=1": "reddit/golang_new.json",
+	})
+	source := &RedditSource{BaseURL: srv.URL}
+	feed := models.Feed{ID: 4, URL: "https://www.reddit.com/r/golang/new/", SourceType: models.FeedSourceReddit}
+	posts, err := source.Fetch(context.Background(), feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ua := srv.request(t, "/r/golang/new.json").Header.Get("User-Agent"); !strings.HasPrefix(ua, "blogAggregator/") {
+		t.Fatalf("User-Agent = %q", ua)
+	}
+
+	// The stickied thread is skipped
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	link := posts[0]
+	if link.Title != "Range over func in practice" || link.GUID != "t3_1fabc02" || link.Author != "u/gopher42" ||
+		link.Link != "https://www.reddit.com/r/golang/comments/1fabc02/range_over_func_in_practice/" || link.FeedId != 4 {
+		t.Fatalf("link post = %+v", link)
+	}
+	if !link.Published.Equal(time.Unix(1725523200, 5e8).UTC()) {
+		t.Fatalf("published = %s", link.Published)
+	}
+	// Link posts lead with the linked article
+	if !strings.HasPrefix(link.Content, `<p><a href="https://go.dev/blog/range-functions">`) {
+		t.Fatalf("content = %s", link.Content)
+	}
+	if link.Metadata["score"] != 321 || link.Metadata["comments"] != 57 || link.Metadata["subreddit"] != "golang" ||
+		link.Metadata["nsfw"] != false {
+		t.Fatalf("metadata = %v", link.Metadata)
+	}
+
+	self := posts[1]
+	if self.Title != "How do you structure large services?" || !strings.Contains(self.Content, "the wiki") ||
+		strings.Contains(self.Content, "<p><a href=\"https://www.reddit.com/r/golang/comments") {
+		t.Fatalf("self post = %+v", self)
+	}
+}
+
+func TestRedditFetchResolvesRelativeLinks(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"/r/golang/new.json?limit=25&raw_json=1": "reddit/golang_new.json",
+	})
+	Register(models.FeedSourceReddit, &RedditSource{BaseURL: srv.URL})
+	t.Cleanup(func() { Register(models.FeedSourceReddit, &RedditSource{BaseURL: "https://www.reddit.com"}) })
+
+	posts, err := FetchPosts(models.Feed{URL: "https://www.reddit.com/r/golang/new", SourceType: models.FeedSourceReddit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(posts[1].Content, `href="https://www.reddit.com/r/golang/wiki"`) {
+		t.Fatalf("content = %s", posts[1].Content)
+	}
+}
+
+func TestRedditListingPath(t *testing.T) {
+	tests := []struct {
+		url, subreddit, sort string
+		ok                   bool
+	}{
+		{"https://www.reddit.com/r/golang", "golang", "hot", true},
+		{"https://reddit.com/r/golang/top/", "golang", "top", true},
+		{"https://old.reddit.com/r/golang/new.json", "golang", "new", true},
+		{"https://www.reddit.com/r/golang/controversial", "", "", false},
+		{"https://www.reddit.com/user/spez", "", "", false},
+		{"https://notreddit.com/r/golang", "", "", false},
+	}
+	for _, tt := range tests {
+		subreddit, sort, err := redditListingPath(tt.url)
+		if (err == nil) != tt.ok || subreddit != tt.subreddit || sort != tt.sort {
+			t.Errorf("redditListingPath(%q) = %q, %q, %v", tt.url, subreddit, sort, err)
+		}
+	}
+}
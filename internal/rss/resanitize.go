@@ -0,0 +1,54 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"log"
+)
+
+// resanitizeBatch is how many posts are loaded at a time
+const resanitizeBatch = 500
+
+// ResanitizeAll runs every stored post through the current sanitizer, for
+// content stored before sanitization or under an older policy. It returns
+// the number of posts that changed.
+func ResanitizeAll() (int, error) {
+	changed := 0
+	lastID := uint(0)
+	for {
+		var rows []struct {
+			models.Post `gorm:"embedded"`
+			FeedURL     string
+		}
+		err := database.DB.Table("posts").
+			Select("posts.*, feeds.url AS feed_url").
+			Joins("LEFT JOIN feeds ON feeds.id = posts.feed_id").
+			Where("posts.id > ?", lastID).
+			Order("posts.id").
+			Limit(resanitizeBatch).
+			Scan(&rows).Error
+		if err != nil {
+			return changed, err
+		}
+		if len(rows) == 0 {
+			return changed, nil
+		}
+		for _, row := range rows {
+			clean := []models.Post{row.Post}
+			cleanPosts(models.Feed{URL: row.FeedURL}, clean)
+			if clean[0].Content == row.Content && clean[0].ContentText == row.ContentText {
+				continue
+			}
+			err := database.DB.Model(&models.Post{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"content":      clean[0].Content,
+				"content_text": clean[0].ContentText,
+			}).Error
+			if err != nil {
+				return changed, err
+			}
+			changed++
+		}
+		lastID = rows[len(rows)-1].ID
+		log.Printf("resanitize: %d posts updated up to post %d", changed, lastID)
+	}
+}
@@ -2,38 +2,292 @@ package rss
 
 import (
 	"blogAggregator/internal/database"
+	"blogAggregator/internal/events"
 	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/webhooks"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/mmcdole/gofeed"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// Retry delays after failed fetches double from minBackoff up to maxBackoff
+	minBackoff = 5 * time.Minute
+	maxBackoff = 24 * time.Hour
+	// maxFeedBytes is the default cap on a fetched document
+	maxFeedBytes = 10 << 20
+)
+
+// FetchAndStoreFeed fetches a feed according to its source type and stores
+// posts that have not been seen before. Failures are recorded on the feed
+// and delay its next scheduled fetch.
 func FetchAndStoreFeed(feed models.Feed) error {
-	fp := gofeed.NewParser()
-	parsedFeed, err := fp.ParseURL(feed.URL)
+	// Newsletters arrive by mail; there is nothing to fetch
+	if feed.SourceType == models.FeedSourceNewsletter {
+		return nil
+	}
+	trace := &fetchTrace{}
+	started := time.Now().UTC()
+	posts, err := fetchPosts(withTrace(context.Background(), trace), feed)
+	var stored storeResult
+	if err == nil {
+		stored, err = storePosts(feed, posts)
+	}
+	logFetch(feed, started, trace, len(posts), stored, err)
+	if errors.Is(err, ErrGone) {
+		disableFeed(feed, err)
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("failed to parse feed %s:%w", feed.URL, err)
+		recordFailure(feed, err)
+		return err
 	}
-	now := time.Now().UTC()
-  database.DB.Model(&feed).Update("last_fetched", &now)
+	if feed.ErrorCount > 0 || feed.NextFetchAt != nil || feed.Disabled {
+		database.DB.Model(&feed).Updates(map[string]interface{}{
+			"error_count": 0, "last_error": "", "next_fetch_at": nil, "disabled": false,
+		})
+	}
+	return nil
+}
+
+// backoff is how long to wait after the given number of consecutive failures
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+func recordFailure(feed models.Feed, fetchErr error) {
+	// Count from the database, as callers may pass a partial feed
+	database.DB.Model(&models.Feed{}).Where("id = ?", feed.ID).Select("error_count").Scan(&feed.ErrorCount)
+	failures := feed.ErrorCount + 1
+	next := time.Now().UTC().Add(backoff(failures))
+	var limited *RateLimitError
+	if errors.As(fetchErr, &limited) && limited.RetryAt.After(next) {
+		next = limited.RetryAt
+	}
+	database.DB.Model(&feed).Updates(map[string]interface{}{
+		"error_count": failures, "last_error": fetchErr.Error(), "next_fetch_at": next,
+	})
+}
+
+// rssSource reads RSS, Atom and JSON Feed documents with gofeed
+type rssSource struct{}
+
+func (rssSource) Validate(feed models.Feed) error {
+	return safehttp.CheckURL(feed.URL)
+}
+
+func (rssSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	resp, err := fetchURL(ctx, feed.URL, feedAccept, nil)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := parseFeed(feed, resp.body, resp.header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	recordMove(feed, resp.movedTo)
+	hub, topic := discoverHub(resp.header, resp.body)
+	recordHub(feed, hub, topic)
+	return posts, nil
+}
+
+// parseFeed turns an RSS, Atom or JSON Feed document into posts. Items
+// without a usable date are left with a zero Published.
+func parseFeed(feed models.Feed, body []byte, contentType string) ([]models.Post, error) {
+	body, _ = normalizeFeed(body, contentType)
+	parsedFeed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s:%w", feed.URL, err)
+	}
+	posts := make([]models.Post, 0, len(parsedFeed.Items))
 	for _, item := range parsedFeed.Items {
-		published := time.Now().UTC()
-    if item.PublishedParsed!=nil{
-			published=*item.PublishedParsed
-		}
-		post := models.Post{
-			Title: item.Title,
-			Link: item.Link,
-			Content: item.Content,
+		published, _ := itemDate(item)
+		author := ""
+		if item.Author != nil {
+			author = item.Author.Name
+		}
+		posts = append(posts, models.Post{
+			Title:     item.Title,
+			Link:      item.Link,
+			GUID:      item.GUID,
+			Content:   item.Content,
+			Author:    author,
 			Published: published,
-			FeedId: feed.ID,
+			FeedId:    feed.ID,
+		})
+	}
+	return posts, nil
+}
+
+// IngestPush stores the items of a feed document a WebSub hub delivered
+// with the given Content-Type, the same way a fetch would
+func IngestPush(feed models.Feed, body []byte, contentType string) error {
+	posts, err := parseFeed(feed, body, contentType)
+	if err != nil {
+		return err
+	}
+	cleanPosts(feed, posts)
+	_, err = storePosts(feed, posts)
+	return err
+}
+
+// storeResult counts what storePosts did with a feed's items
+type storeResult struct {
+	created int
+	updated int
+}
+
+// storePosts records the fetch and inserts the posts, deduplicated by GUID
+// within the feed when the source has one and by link otherwise. Newly
+// inserted posts get their webhook deliveries and, when the feed wants full
+// articles, their extraction queued and are published on events.NewPosts;
+// known posts whose title or content changed are updated in place. Items
+// retention deleted are not stored again.
+func storePosts(feed models.Feed, posts []models.Post) (storeResult, error) {
+	now := time.Now().UTC()
+	database.DB.Model(&feed).Update("last_fetched", &now)
+	var result storeResult
+	var created []models.Post
+	defer func() {
+		if err := webhooks.QueuePosts(created); err != nil {
+			log.Printf("webhooks for feed %d: %v", feed.ID, err)
+		}
+		if len(created) == 0 || !WantsFullContent(feed) {
+			return
+		}
+		if err := QueueFullContent(created); err != nil {
+			log.Printf("full articles for feed %d: %v", feed.ID, err)
 		}
+	}()
+	for _, post := range posts {
+		post.FeedId = feed.ID
+		outcome, err := storePost(&post)
+		if err != nil {
+			return result, err
+		}
+		if outcome == postUpdated {
+			result.updated++
+		}
+		if outcome != postCreated {
+			continue
+		}
+		result.created++
+		created = append(created, post)
+		events.NewPosts.Publish(post)
+	}
+	return result, nil
+}
+
+type storeOutcome int
 
-		result:=database.DB.Where("link=?",post.Link).FirstOrCreate(&post)
-		if result.Error!=nil{
-			return result.Error
+const (
+	postUnchanged storeOutcome = iota
+	postCreated
+	postUpdated
+)
+
+func storePost(post *models.Post) (storeOutcome, error) {
+	existing, err := findPost(*post)
+	if err == nil {
+		return updatePost(existing, *post)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return postUnchanged, err
+	}
+	tombstone, err := findTombstone(*post)
+	if err == nil {
+		// Seen again, so the tombstone lasts while the feed lists the item
+		if now := time.Now().UTC(); now.Sub(tombstone.LastSeenAt) > 24*time.Hour {
+			database.DB.Model(&tombstone).Update("last_seen_at", now)
 		}
+		return postUnchanged, nil
 	}
-	return nil
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return postUnchanged, err
+	}
+	// Undated items keep the time they were first seen, so they do not move
+	// when the feed is fetched again
+	now := time.Now().UTC()
+	post.FirstSeenAt = &now
+	if post.Published.IsZero() {
+		post.Published = now
+	}
+	// A new id may still point at a link another post already has
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(post)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return postUnchanged, result.Error
+	}
+	return postCreated, nil
+}
+
+// findPost looks up the stored copy of an item the way storePosts
+// deduplicates it. Posts stored before GUIDs were recorded have none and
+// are still found by link.
+func findPost(post models.Post) (models.Post, error) {
+	var existing models.Post
+	if post.GUID != "" {
+		err := database.DB.Where("feed_id = ? AND guid = ?", post.FeedId, post.GUID).First(&existing).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return existing, err
+		}
+		err = database.DB.Where("link = ? AND COALESCE(guid, '') = ''", post.Link).First(&existing).Error
+		return existing, err
+	}
+	err := database.DB.Where("link = ?", post.Link).First(&existing).Error
+	return existing, err
+}
+
+// findTombstone looks up the tombstone retention left for an item, by
+// link for tombstones without a GUID as in findPost
+func findTombstone(post models.Post) (models.PostTombstone, error) {
+	var tombstone models.PostTombstone
+	if post.GUID != "" {
+		err := database.DB.Where("feed_id = ? AND guid = ?", post.FeedId, post.GUID).First(&tombstone).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return tombstone, err
+		}
+		err = database.DB.Where("feed_id = ? AND link = ? AND COALESCE(guid, '') = ''", post.FeedId, post.Link).First(&tombstone).Error
+		return tombstone, err
+	}
+	err := database.DB.Where("feed_id = ? AND link = ?", post.FeedId, post.Link).First(&tombstone).Error
+	return tombstone, err
+}
+
+// updatePost refreshes a known post of the same feed whose title or
+// content was edited at the source
+func updatePost(existing, post models.Post) (storeOutcome, error) {
+	if existing.FeedId != post.FeedId {
+		return postUnchanged, nil
+	}
+	if existing.GUID == "" && post.GUID != "" {
+		// Stored before GUIDs were recorded; later fetches find it by GUID
+		if err := database.DB.Model(&existing).Update("guid", post.GUID).Error; err != nil {
+			return postUnchanged, err
+		}
+	}
+	if existing.Title == post.Title && existing.Content == post.Content {
+		return postUnchanged, nil
+	}
+	err := database.DB.Model(&existing).Updates(map[string]interface{}{
+		"title": post.Title, "content": post.Content, "content_text": post.ContentText,
+	}).Error
+	if err != nil {
+		return postUnchanged, err
+	}
+	return postUpdated, nil
 }
@@ -0,0 +1,161 @@
+package rss
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/retention"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/testdb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseFeedKeepsGUID(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		guid string
+	}{
+		{"rss", `<rss version="2.0"><channel><title>t</title><item><title>a</title><link>https://example.com/a</link>` +
+			`<guid isPermaLink="false">rss-1</guid></item></channel></rss>`, "rss-1"},
+		{"atom", `<feed xmlns="http://www.w3.org/2005/Atom"><title>t</title><entry><title>a</title>` +
+			`<link href="https://example.com/a"/><id>tag:example.com,2024:1</id></entry></feed>`, "tag:example.com,2024:1"},
+		{"json feed", `{"version": "https://jsonfeed.org/version/1.1", "title": "t", "items": [` +
+			`{"id": "json-1", "url": "https://example.com/a", "title": "a"}]}`, "json-1"},
+		{"no guid", `<rss version="2.0"><channel><title>t</title><item><title>a</title><link>https://example.com/a</link>` +
+			`</item></channel></rss>`, ""},
+	}
+	for _, tt := range tests {
+		posts, err := parseFeed(models.Feed{URL: "https://example.com/feed"}, []byte(tt.doc), "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(posts) != 1 || posts[0].GUID != tt.guid {
+			t.Errorf("%s: posts = %+v", tt.name, posts)
+		}
+	}
+}
+
+// mutableFeed serves an RSS document that tests can replace
+type mutableFeed struct {
+	*httptest.Server
+	mu  sync.Mutex
+	doc string
+}
+
+func newMutableFeed(t *testing.T) *mutableFeed {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	f := &mutableFeed{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, f.doc)
+	}))
+	t.Cleanup(func() {
+		f.Close()
+		safehttp.Configure(nil, nil)
+	})
+	return f
+}
+
+// serve replaces the document with one old item
+func (f *mutableFeed) serve(guid, link string) {
+	item := "<title>Old news</title><link>" + link + "</link><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>"
+	if guid != "" {
+		item += `<guid isPermaLink="false">` + guid + "</guid>"
+	}
+	f.mu.Lock()
+	f.doc = `<rss version="2.0"><channel><title>Blog</title><item>` + item + `</item></channel></rss>`
+	f.mu.Unlock()
+}
+
+func countPosts(t *testing.T, feedID uint) int64 {
+	t.Helper()
+	var n int64
+	database.DB.Model(&models.Post{}).Where("feed_id = ?", feedID).Count(&n)
+	return n
+}
+
+func TestPrunedItemIsNotStoredAgain(t *testing.T) {
+	tests := []struct {
+		name string
+		// guid is the item's id when it was stored and pruned
+		guid string
+	}{
+		{"pruned with its guid", "item-1"},
+		// Items stored before GUIDs were recorded left tombstones without one
+		{"pruned without a guid", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testdb.Open(t)
+			feed := newMutableFeed(t)
+			days := 30
+			stored := models.Feed{Title: "Blog", URL: feed.URL, RetentionDays: &days}
+			database.DB.Create(&stored)
+
+			feed.serve(tt.guid, "https://example.com/1")
+			if err := FetchAndStoreFeed(stored); err != nil {
+				t.Fatal(err)
+			}
+			if n := countPosts(t, stored.ID); n != 1 {
+				t.Fatalf("%d posts stored", n)
+			}
+			if n, err := retention.Prune(); err != nil || n != 1 {
+				t.Fatalf("Prune = %d, %v", n, err)
+			}
+
+			// The feed lists the same item again, by now with a guid and,
+			// for the guid case, a new link
+			link := "https://example.com/1"
+			if tt.guid != "" {
+				link = "https://example.com/posts/1"
+			}
+			feed.serve("item-1", link)
+			if err := FetchAndStoreFeed(stored); err != nil {
+				t.Fatal(err)
+			}
+			if n := countPosts(t, stored.ID); n != 0 {
+				t.Fatalf("pruned item stored again: %d posts", n)
+			}
+		})
+	}
+}
+
+func TestPostWithoutGUIDGetsOne(t *testing.T) {
+	testdb.Open(t)
+	feed := newMutableFeed(t)
+	stored := models.Feed{Title: "Blog", URL: feed.URL}
+	database.DB.Create(&stored)
+
+	// Stored before GUIDs were recorded
+	feed.serve("", "https://example.com/1")
+	if err := FetchAndStoreFeed(stored); err != nil {
+		t.Fatal(err)
+	}
+	feed.serve("item-1", "https://example.com/1")
+	if err := FetchAndStoreFeed(stored); err != nil {
+		t.Fatal(err)
+	}
+	var posts []models.Post
+	database.DB.Where("feed_id = ?", stored.ID).Find(&posts)
+	if len(posts) != 1 || posts[0].GUID != "item-1" {
+		t.Fatalf("posts = %+v", posts)
+	}
+
+	// From now on the guid identifies it, even under a new link
+	feed.serve("item-1", "https://example.com/posts/1")
+	if err := FetchAndStoreFeed(stored); err != nil {
+		t.Fatal(err)
+	}
+	if n := countPosts(t, stored.ID); n != 1 {
+		t.Fatalf("%d posts after the link changed", n)
+	}
+}
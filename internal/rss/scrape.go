@@ -0,0 +1,195 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html/charset"
+)
+
+// scrapeSource extracts items from an HTML page with CSS selectors
+type scrapeSource struct{}
+
+func (scrapeSource) Validate(feed models.Feed) error {
+	if feed.SourceConfig == nil || feed.SourceConfig.Scrape == nil {
+		return errors.New("source_config.scrape is required for scrape feeds")
+	}
+	if err := safehttp.CheckURL(feed.URL); err != nil {
+		return err
+	}
+	return validateScrape(*feed.SourceConfig.Scrape)
+}
+
+func validateScrape(cfg models.ScrapeConfig) error {
+	if cfg.ItemSelector == "" {
+		return errors.New("item_selector is required")
+	}
+	for name, selector := range map[string]string{
+		"item_selector":    cfg.ItemSelector,
+		"title_selector":   cfg.TitleSelector,
+		"link_selector":    cfg.LinkSelector,
+		"date_selector":    cfg.DateSelector,
+		"content_selector": cfg.ContentSelector,
+	} {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (scrapeSource) Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	if feed.SourceConfig == nil || feed.SourceConfig.Scrape == nil {
+		return nil, errors.New("scrape feed has no selectors")
+	}
+	cfg := *feed.SourceConfig.Scrape
+	if err := validateScrape(cfg); err != nil {
+		return nil, err
+	}
+	resp, err := fetchURL(ctx, feed.URL, htmlAccept, nil)
+	if err != nil {
+		return nil, err
+	}
+	doc, base, err := parseDocument(resp)
+	if err != nil {
+		return nil, err
+	}
+	recordMove(feed, resp.movedTo)
+	return scrapeItems(doc, base, cfg, feed.ID), nil
+}
+
+const htmlAccept = "text/html, application/xhtml+xml;q=0.9, */*;q=0.8"
+
+// fetchDocument downloads and parses an HTML page, returning the URL
+// relative links resolve against
+func fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, *url.URL, error) {
+	resp, err := fetchURL(ctx, pageURL, htmlAccept, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseDocument(resp)
+}
+
+func parseDocument(resp *fetched) (*goquery.Document, *url.URL, error) {
+	body, err := charset.NewReader(bytes.NewReader(resp.body), resp.header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", resp.url, err)
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", resp.url, err)
+	}
+	base := resp.url
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(href); err == nil {
+			base = u
+		}
+	}
+	return doc, base, nil
+}
+
+func scrapeItems(doc *goquery.Document, base *url.URL, cfg models.ScrapeConfig, feedID uint) []models.Post {
+	var posts []models.Post
+	doc.Find(cfg.ItemSelector).Each(func(_ int, item *goquery.Selection) {
+		linkSel := item
+		if cfg.LinkSelector != "" {
+			linkSel = item.Find(cfg.LinkSelector).First()
+		}
+		if _, ok := linkSel.Attr("href"); !ok {
+			linkSel = linkSel.Find("a[href]").First()
+		}
+		link := resolveLink(base, linkSel.AttrOr("href", ""))
+
+		title := ""
+		if cfg.TitleSelector != "" {
+			title = collapseSpace(item.Find(cfg.TitleSelector).First().Text())
+		} else {
+			title = collapseSpace(linkSel.Text())
+		}
+		if title == "" && link == "" {
+			return
+		}
+		if link == "" {
+			// Items without their own page still need a unique link
+			sum := sha256.Sum256([]byte(title))
+			link = base.String() + "#" + hex.EncodeToString(sum[:8])
+		}
+
+		var published time.Time
+		if cfg.DateSelector != "" {
+			if t, ok := parseDate(dateValue(item.Find(cfg.DateSelector).First()), cfg.DateFormats); ok {
+				published = t
+			}
+		}
+
+		content := ""
+		if cfg.ContentSelector != "" {
+			contentSel := item.Find(cfg.ContentSelector).First()
+			absolutizeLinks(contentSel, base)
+			if h, err := contentSel.Html(); err == nil {
+				content = h
+			}
+		}
+
+		posts = append(posts, models.Post{
+			Title:     title,
+			Link:      link,
+			Content:   content,
+			Published: published,
+			FeedId:    feedID,
+		})
+	})
+	return posts
+}
+
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	u, err := base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// absolutizeLinks rewrites relative link and image URLs so content still
+// works away from the scraped page
+func absolutizeLinks(sel *goquery.Selection, base *url.URL) {
+	for attr, selector := range map[string]string{"href": "a[href]", "src": "img[src]"} {
+		sel.Find(selector).Each(func(_ int, el *goquery.Selection) {
+			if abs := resolveLink(base, el.AttrOr(attr, "")); abs != "" {
+				el.SetAttr(attr, abs)
+			}
+		})
+	}
+}
+
+// dateValue prefers machine readable attributes such as <time datetime>
+func dateValue(sel *goquery.Selection) string {
+	for _, attr := range []string{"datetime", "content", "title"} {
+		if v, ok := sel.Attr(attr); ok && strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return sel.Text()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
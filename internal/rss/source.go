@@ -0,0 +1,147 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/sanitize"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source fetches the items of one feed source type. FetchAndStoreFeed picks
+// the source by Feed.SourceType and stores what it returns.
+type Source interface {
+	// Validate checks a feed definition before it is saved or previewed
+	Validate(feed models.Feed) error
+	// Fetch returns the feed's current items
+	Fetch(ctx context.Context, feed models.Feed) ([]models.Post, error)
+}
+
+var sources = map[string]Source{
+	models.FeedSourceRSS:        rssSource{},
+	models.FeedSourceScrape:     scrapeSource{},
+	models.FeedSourceJSON:       jsonSource{},
+	models.FeedSourceGitHub:     &GitHubSource{BaseURL: "https://api.github.com"},
+	models.FeedSourceReddit:     &RedditSource{BaseURL: "https://www.reddit.com"},
+	models.FeedSourceHackerNews: &HackerNewsSource{BaseURL: "https://hacker-news.firebaseio.com"},
+}
+
+// Register installs the source for a type, replacing any existing one
+func Register(sourceType string, source Source) {
+	sources[sourceType] = source
+}
+
+func sourceFor(feed models.Feed) (Source, error) {
+	sourceType := feed.SourceType
+	if sourceType == "" {
+		sourceType = models.FeedSourceRSS
+	}
+	if sourceType == models.FeedSourceNewsletter {
+		return nil, errors.New("newsletter feeds are filled by mail")
+	}
+	source, ok := sources[sourceType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported source type %q", feed.SourceType)
+	}
+	return source, nil
+}
+
+// FetchPosts returns the feed's current items without storing them.
+// Undated items get the current time, as storing them now would.
+func FetchPosts(feed models.Feed) ([]models.Post, error) {
+	posts, err := fetchPosts(context.Background(), feed)
+	now := time.Now().UTC()
+	for i := range posts {
+		if posts[i].Published.IsZero() {
+			posts[i].Published = now
+		}
+	}
+	return posts, err
+}
+
+func fetchPosts(ctx context.Context, feed models.Feed) ([]models.Post, error) {
+	source, err := sourceFor(feed)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := source.Fetch(ctx, feed)
+	if err != nil {
+		return nil, err
+	}
+	cleanPosts(feed, posts)
+	return posts, nil
+}
+
+// cleanPosts sanitizes item content in place, resolving relative URLs
+// against the item link or, failing that, the feed URL
+func cleanPosts(feed models.Feed, posts []models.Post) {
+	for i := range posts {
+		base := posts[i].Link
+		if base == "" || !strings.HasPrefix(base, "http") {
+			base = feed.URL
+		}
+		posts[i].Content = sanitize.Content(posts[i].Content, base)
+		posts[i].ContentText = sanitize.PlainText(posts[i].Content)
+	}
+}
+
+// ValidateSource checks that a feed's source type and settings are usable
+// before it is saved or previewed
+func ValidateSource(feed models.Feed) error {
+	source, err := sourceFor(feed)
+	if err != nil {
+		return err
+	}
+	return source.Validate(feed)
+}
+
+// RateLimitError reports that a source asked us to slow down. The feed is
+// not fetched again before RetryAt.
+type RateLimitError struct {
+	URL     string
+	RetryAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s until %s", e.URL, e.RetryAt.Format(time.RFC3339))
+}
+
+// getJSON fetches url into v, turning rate limit responses into a
+// RateLimitError
+func getJSON(ctx context.Context, url string, headers map[string]string, v interface{}) error {
+	resp, err := fetchURL(ctx, url, "application/json", headers)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.body, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	return nil
+}
+
+// rateLimited understands Retry-After and the X-RateLimit headers used by
+// GitHub (reset as a Unix time) and Reddit (reset in seconds)
+func rateLimited(resp *http.Response) (time.Time, bool) {
+	now := time.Now().UTC()
+	exhausted := resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+		resp.Header.Get("X-Ratelimit-Remaining") == "0.0"
+	if resp.StatusCode != http.StatusTooManyRequests &&
+		!(resp.StatusCode == http.StatusForbidden && exhausted) {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	if reset, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset"), 64); err == nil {
+		if reset > 1e9 {
+			return time.Unix(int64(reset), 0).UTC(), true
+		}
+		return now.Add(time.Duration(reset) * time.Second), true
+	}
+	return now.Add(minBackoff), true
+}
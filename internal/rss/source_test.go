@@ -0,0 +1,181 @@
+package rss
+
+import (
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixtureServer serves recorded API responses from testdata. routes maps
+// a request path, with its query, to a file under testdata; other
+// requests get 404. The safe client is allowed to reach it.
+type fixtureServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newFixtureServer(t *testing.T, routes map[string]string) *fixtureServer {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := &fixtureServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+		file, ok := routes[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(func() {
+		s.Close()
+		safehttp.Configure(nil, nil)
+	})
+	return s
+}
+
+// request returns the first request made for path
+func (s *fixtureServer) request(t *testing.T, path string) *http.Request {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.requests {
+		if r.URL.Path == path {
+			return r
+		}
+	}
+	t.Fatalf("no request for %s", path)
+	return nil
+}
+
+func TestSourceFor(t *testing.T) {
+	tests := []struct {
+		sourceType string
+		want       Source
+		err        string
+	}{
+		{sourceType: "", want: rssSource{}},
+		{sourceType: models.FeedSourceRSS, want: rssSource{}},
+		{sourceType: models.FeedSourceGitHub, want: sources[models.FeedSourceGitHub]},
+		{sourceType: models.FeedSourceNewsletter, err: "filled by mail"},
+		{sourceType: "gopher", err: "unsupported source type"},
+	}
+	for _, tt := range tests {
+		source, err := sourceFor(models.Feed{SourceType: tt.sourceType})
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%q: error = %v, want %q", tt.sourceType, err, tt.err)
+		case tt.err == "" && (err != nil || source != tt.want):
+			t.Errorf("%q: source = %#v, %v", tt.sourceType, source, err)
+		}
+	}
+}
+
+// stubSource returns fixed posts
+type stubSource struct{ posts []models.Post }
+
+func (s stubSource) Validate(models.Feed) error { return nil }
+func (s stubSource) Fetch(context.Context, models.Feed) ([]models.Post, error) {
+	return append([]models.Post(nil), s.posts...), nil
+}
+
+func TestFetchPostsCleansContent(t *testing.T) {
+	Register("stub", stubSource{posts: []models.Post{
+		{
+			Title:   "Relative",
+			Link:    "https://blog.example.com/2024/post",
+			Content: `<p>See <a href="../about">about</a> <img src="/img/a.png"></p><script>alert(1)</script>`,
+		},
+		{Title: "No link", Content: `<p><a href="notes">notes</a></p>`},
+	}})
+	t.Cleanup(func() { delete(sources, "stub") })
+
+	before := time.Now().UTC()
+	posts, err := FetchPosts(models.Feed{URL: "https://feeds.example.com/main/", SourceType: "stub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts", len(posts))
+	}
+	first := posts[0].Content
+	if strings.Contains(first, "<script") || !strings.Contains(first, `href="https://blog.example.com/about"`) ||
+		!strings.Contains(first, `src="https://blog.example.com/img/a.png"`) {
+		t.Fatalf("content = %s", first)
+	}
+	if posts[0].ContentText != "See about" {
+		t.Fatalf("content text = %q", posts[0].ContentText)
+	}
+	// Without a usable link, URLs resolve against the feed
+	if !strings.Contains(posts[1].Content, `href="https://feeds.example.com/main/notes"`) {
+		t.Fatalf("content = %s", posts[1].Content)
+	}
+	if posts[0].Published.Before(before) {
+		t.Fatalf("undated post published at %s", posts[0].Published)
+	}
+}
+
+func TestGetJSONReportsRateLimits(t *testing.T) {
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { safehttp.Configure(nil, nil) })
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+		case "/retry-after":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/garbage":
+			w.Write([]byte("<html>"))
+		}
+	}))
+	defer srv.Close()
+
+	var v interface{}
+	var limited *RateLimitError
+	err := getJSON(context.Background(), srv.URL+"/github", nil, &v)
+	if !errors.As(err, &limited) || !limited.RetryAt.Equal(reset.UTC()) {
+		t.Fatalf("github limit: %v", err)
+	}
+	err = getJSON(context.Background(), srv.URL+"/retry-after", nil, &v)
+	if !errors.As(err, &limited) || time.Until(limited.RetryAt) < 110*time.Second {
+		t.Fatalf("retry-after: %v", err)
+	}
+	err = getJSON(context.Background(), srv.URL+"/forbidden", nil, &v)
+	if err == nil || errors.As(err, &limited) {
+		t.Fatalf("plain 403: %v", err)
+	}
+	err = getJSON(context.Background(), srv.URL+"/garbage", nil, &v)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+// Package safehttp is the HTTP client for every request made to a URL a
+// user supplied: feeds, scraped pages, articles, images, webhooks and
+// WebSub hubs. It only speaks http(s) on allowed ports and refuses to
+// connect to loopback, private, link-local and other reserved addresses,
+// unless they are allowlisted for intranet feeds. Addresses are checked on
+// every connection, after resolving the host ourselves, so redirects and
+// DNS rebinding cannot reach internal services.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBlocked reports a request to a scheme, port or address that is not
+// allowed
+var ErrBlocked = errors.New("destination not allowed")
+
+// ErrTooLarge reports a response body over the client's size limit
+var ErrTooLarge = errors.New("response too large")
+
+const maxRedirects = 5
+
+// DefaultPorts are the ports requests may use unless the host is
+// allowlisted
+var DefaultPorts = []int{80, 443, 8080, 8443}
+
+var (
+	mu           sync.RWMutex
+	allowedNets  []*net.IPNet
+	allowedHosts = map[string]bool{}
+	allowedPorts = portSet(DefaultPorts)
+)
+
+// Configure sets the allowlist and the allowed ports. Entries of allow are
+// CIDR ranges, IP addresses or host names. Allowlisted names and addresses
+// skip the address check, and so does a name resolving into an allowlisted
+// range; URLs naming an allowlisted host or address directly also skip the
+// port check. An empty ports list keeps the defaults.
+func Configure(allow []string, ports []int) error {
+	var nets []*net.IPNet
+	hosts := map[string]bool{}
+	for _, entry := range allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			_, n, err := net.ParseCIDR(entry)
+			if err != nil {
+				return fmt.Errorf("invalid allowlist range %q: %w", entry, err)
+			}
+			nets = append(nets, n)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			hosts[entry] = true
+		}
+	}
+	if len(ports) == 0 {
+		ports = DefaultPorts
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	allowedNets, allowedHosts, allowedPorts = nets, hosts, portSet(ports)
+	return nil
+}
+
+func portSet(ports []int) map[int]bool {
+	set := map[int]bool{}
+	for _, p := range ports {
+		set[p] = true
+	}
+	return set
+}
+
+func hostAllowed(host string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return allowedHosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+// allowlisted reports whether a URL host is an allowlisted name or an
+// address in an allowlisted range
+func allowlisted(host string) bool {
+	if hostAllowed(host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, n := range allowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowed reports whether connecting to ip is permitted
+func ipAllowed(ip net.IP) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, n := range allowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return PublicIP(ip)
+}
+
+// nonPublic lists reserved ranges net.IP has no predicate for
+var nonPublic = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "this" network
+		"100.64.0.0/10",   // carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"240.0.0.0/4",     // reserved and broadcast
+		"64:ff9b::/96",    // NAT64, may map to private IPv4
+		"64:ff9b:1::/48",  // local-use NAT64
+		"2001:db8::/32",   // documentation
+		"2002::/16",       // 6to4, embeds arbitrary IPv4
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// PublicIP reports whether ip is a globally routable unicast address
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublic {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL validates the scheme and port of a URL and that its host
+// resolves only to allowed addresses. It lets handlers reject a URL before
+// storing it; the client repeats the checks on every request.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if err := checkTarget(u); err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if hostAllowed(host) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = resolve(ctx, host)
+	return err
+}
+
+// checkTarget validates the scheme and port of a request URL
+func checkTarget(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: URL has no host", ErrBlocked)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in URL", ErrBlocked)
+	}
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if p := u.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return fmt.Errorf("%w: port %q", ErrBlocked, p)
+		}
+		port = n
+	}
+	if allowlisted(u.Hostname()) {
+		return nil
+	}
+	mu.RLock()
+	ok := allowedPorts[port]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: port %d", ErrBlocked, port)
+	}
+	return nil
+}
+
+// resolve looks up host and returns its addresses when all are allowed.
+// Any disallowed address fails the lookup, so a name mixing public and
+// private records cannot be used to reach the private one.
+func resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if !ipAllowed(ip) {
+			return nil, fmt.Errorf("%w: %s", ErrBlocked, ip)
+		}
+		return []net.IP{ip}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		if !ipAllowed(a.IP) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlocked, host, a.IP)
+		}
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// dialContext resolves and checks the host, then connects to one of the
+// checked addresses so a second lookup cannot return a different one
+func dialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if hostAllowed(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		ips, err := resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// transport checks each request, including redirects, and caps bodies
+type transport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkTarget(req.URL); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || t.maxBytes <= 0 {
+		return resp, err
+	}
+	if resp.ContentLength > t.maxBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBytes}
+	return resp, nil
+}
+
+// limitedBody fails reads past the limit instead of silently truncating
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Allow a clean EOF exactly at the limit
+		var probe [1]byte
+		if n, _ := b.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Options tunes a client. Zero durations fall back to the defaults used
+// by New.
+type Options struct {
+	// ConnectTimeout bounds dialing and the TLS handshake
+	ConnectTimeout time.Duration
+	// ReadTimeout is the longest wait for response headers or for the next
+	// chunk of the body
+	ReadTimeout time.Duration
+	// Timeout bounds the whole request, redirects and body included
+	Timeout time.Duration
+	// MaxBytes caps response bodies; 0 means no limit
+	MaxBytes int64
+}
+
+// New returns a client whose requests, including redirects, are limited to
+// timeout overall and whose response bodies are limited to maxBytes (no
+// limit when 0)
+func New(timeout time.Duration, maxBytes int64) *http.Client {
+	return NewClient(Options{Timeout: timeout, MaxBytes: maxBytes})
+}
+
+// NewClient returns a client with the given limits
+func NewClient(opts Options) *http.Client {
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = opts.Timeout
+	}
+	dialer := &net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+	dial := dialContext(dialer)
+	base := &http.Transport{
+		// Environment proxies would connect on our behalf, unchecked
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			return &idleTimeoutConn{Conn: conn, timeout: opts.ReadTimeout}, nil
+		},
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   opts.ConnectTimeout,
+		ResponseHeaderTimeout: opts.ReadTimeout,
+		MaxIdleConns:          50,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &transport{base: base, maxBytes: opts.MaxBytes},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// idleTimeoutConn fails a read that waits longer than timeout, so a server
+// trickling a body cannot hold a fetch until the overall timeout
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,299 @@
+// Package sanitize cleans untrusted HTML before it is stored as post
+// content, so it can be rendered by the frontend and API clients as is.
+// Content passes a strict allowlist: no scripts, styles, iframes, forms or
+// event handlers, only http(s) and mailto URLs, and no tracking pixels.
+package sanitize
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var policy = newPolicy()
+
+// trackerHosts serve pixels and redirect beacons found in feed content
+var trackerHosts = []string{
+	"feeds.feedburner.com",
+	"feedproxy.google.com",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"www.google-analytics.com",
+	"google-analytics.com",
+	"pixel.quantserve.com",
+	"sb.scorecardresearch.com",
+	"www.facebook.com/tr",
+	"ad.doubleclick.net",
+	"pi.feedsportal.com",
+	"feeds.wordpress.com/1.0/",
+	"rss.buysellads.com",
+	"counter.yadro.ru",
+}
+
+// trackingParams are dropped from link and image URLs; utm_* always are
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true,
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true,
+	"mkt_tok": true, "yclid": true, "igshid": true,
+}
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr", "div", "span",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"b", "strong", "i", "em", "u", "s", "del", "ins", "mark", "small", "sub", "sup",
+		"blockquote", "q", "cite", "abbr", "code", "pre", "kbd", "samp", "var",
+		"ul", "ol", "li", "dl", "dt", "dd",
+		"figure", "figcaption", "picture",
+		"table", "thead", "tbody", "tfoot", "tr", "caption", "colgroup", "col",
+	)
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("title").Globally()
+	p.AllowAttrs("src", "alt", "width", "height").OnElements("img")
+	// srcset URLs are not checked by the policy; rewrite keeps http(s) only
+	p.AllowAttrs("srcset").OnElements("img", "source")
+	p.AllowAttrs("type", "media").OnElements("source")
+	p.AllowElements("source")
+	p.AllowAttrs("colspan", "rowspan").OnElements("td", "th")
+	p.AllowElements("td", "th")
+	p.AllowAttrs("datetime").OnElements("del", "ins", "time")
+	p.AllowAttrs("cite").OnElements("blockquote", "q")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.AllowRelativeURLs(false)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Content cleans feed HTML for storage. Relative URLs are resolved against
+// base (the item or feed URL) first, as they would otherwise be dropped;
+// tracking pixels and tracking query parameters are removed, and links get
+// rel="noopener noreferrer".
+func Content(content, base string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	baseURL, _ := url.Parse(base)
+	return strings.TrimSpace(policy.Sanitize(rewrite(content, baseURL)))
+}
+
+// HTML cleans HTML that has no base URL
+func HTML(s string) string {
+	return Content(s, "")
+}
+
+// Text turns plain text into escaped HTML paragraphs, keeping line breaks
+func Text(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n")
+	var b strings.Builder
+	for _, para := range strings.Split(s, "\n\n") {
+		if para = strings.TrimSpace(para); para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// rewrite resolves URLs and drops tracking before the allowlist runs
+func rewrite(content string, base *url.URL) string {
+	nodes, err := nethtml.ParseFragment(strings.NewReader(content), &nethtml.Node{
+		Type: nethtml.ElementNode, Data: "div", DataAtom: atom.Div,
+	})
+	if err != nil {
+		return content
+	}
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		if rewriteNode(n, base) {
+			nethtml.Render(&buf, n)
+		}
+	}
+	return buf.String()
+}
+
+// rewriteNode fixes up n and its children, reporting whether n is kept
+func rewriteNode(n *nethtml.Node, base *url.URL) bool {
+	if n.Type == nethtml.ElementNode {
+		for i, attr := range n.Attr {
+			switch attr.Key {
+			case "href", "src", "cite":
+				n.Attr[i].Val = cleanURL(attr.Val, base)
+			case "srcset":
+				n.Attr[i].Val = cleanSrcset(attr.Val, base)
+			}
+		}
+		if n.Data == "img" && isTrackingPixel(n) {
+			return false
+		}
+	}
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if !rewriteNode(c, base) {
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+	return true
+}
+
+func cleanURL(raw string, base *url.URL) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if base != nil && base.IsAbs() {
+		u = base.ResolveReference(u)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		changed := false
+		for key := range q {
+			if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+				q.Del(key)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	return u.String()
+}
+
+// cleanSrcset resolves the candidates of an srcset list, dropping any that
+// are not http(s)
+func cleanSrcset(srcset string, base *url.URL) string {
+	var kept []string
+	for _, fields := range srcsetCandidates(srcset) {
+		fields[0] = cleanURL(fields[0], base)
+		if !strings.HasPrefix(fields[0], "http://") && !strings.HasPrefix(fields[0], "https://") {
+			continue
+		}
+		kept = append(kept, strings.Join(fields, " "))
+	}
+	return strings.Join(kept, ", ")
+}
+
+// srcsetCandidates splits srcset into URLs and their descriptors. As in
+// the HTML spec a URL runs to the next whitespace, so commas inside it, as
+// in data: URLs, do not end the candidate; in descriptors they do.
+func srcsetCandidates(srcset string) [][]string {
+	var candidates [][]string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			candidates = append(candidates, current)
+			current = nil
+		}
+	}
+	for _, field := range strings.Fields(srcset) {
+		for field != "" {
+			if len(current) == 0 {
+				// A URL, ended by whitespace or trailing commas
+				trimmed := strings.TrimRight(strings.TrimLeft(field, ","), ",")
+				if trimmed != "" {
+					current = append(current, trimmed)
+				}
+				if strings.HasSuffix(field, ",") {
+					flush()
+				}
+				break
+			}
+			descriptor, rest, found := strings.Cut(field, ",")
+			if descriptor != "" {
+				current = append(current, descriptor)
+			}
+			if !found {
+				break
+			}
+			flush()
+			field = rest
+		}
+	}
+	flush()
+	return candidates
+}
+
+// isTrackingPixel spots 1x1 (or hidden) images and known beacon hosts
+func isTrackingPixel(n *nethtml.Node) bool {
+	var src, width, height string
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "src":
+			src = attr.Val
+		case "width":
+			width = strings.TrimSpace(attr.Val)
+		case "height":
+			height = strings.TrimSpace(attr.Val)
+		}
+	}
+	tiny := func(v string) bool { return v == "0" || v == "1" || v == "0px" || v == "1px" }
+	if tiny(width) && tiny(height) {
+		return true
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return false
+	}
+	target := strings.ToLower(u.Host + u.Path)
+	for _, host := range trackerHosts {
+		if strings.HasPrefix(target, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlainText renders sanitized HTML as text for previews and search, with
+// blocks on their own lines
+func PlainText(content string) string {
+	doc, err := nethtml.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(*nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		switch n.Type {
+		case nethtml.TextNode:
+			b.WriteString(n.Data)
+		case nethtml.ElementNode:
+			if n.Data == "br" || blockElements[n.Data] {
+				b.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == nethtml.ElementNode && blockElements[n.Data] {
+			b.WriteString("\n")
+		}
+	}
+	walk(doc)
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "pre": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "dl": true, "dt": true, "dd": true, "table": true,
+	"figure": true, "figcaption": true, "hr": true,
+}
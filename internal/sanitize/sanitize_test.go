@@ -0,0 +1,93 @@
+package sanitize
+
+import "testing"
+
+const testBase = "https://blog.example.com/posts/1"
+
+func TestContent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Scripts and event handlers
+		{"script", `<p>Hi<script>alert(1)</script></p>`, `<p>Hi</p>`},
+		{"event handlers", `<p onclick="steal()" onmouseover="x">Hi</p>`, `<p>Hi</p>`},
+		{"image event handler", `<img src="/a.png" onerror="steal()">`, `<img src="https://blog.example.com/a.png"/>`},
+		{"iframe, style and form", `<iframe src="https://example.org"></iframe><style>p{}</style><form><input></form>ok`, `ok`},
+
+		// URL schemes
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `x`},
+		{"javascript href with spaces", `<a href=" JavaScript:alert(1)">x</a>`, `x`},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `x`},
+		{"data image", `<img src="data:image/png;base64,AAAA" alt="d">`, `<img alt="d"/>`},
+		{"srcset entries", `<img srcset="javascript:alert(1) 1x, /img/2x.png 2x, data:image/png;base64,AA 3x">`,
+			`<img srcset="https://blog.example.com/img/2x.png 2x"/>`},
+		{"srcset without spaces after commas", `<img srcset="/a.png 1x,/b.png 2x">`,
+			`<img srcset="https://blog.example.com/a.png 1x, https://blog.example.com/b.png 2x"/>`},
+		{"mailto", `<a href="mailto:a@example.com">m</a>`, `<a href="mailto:a@example.com" rel="noreferrer">m</a>`},
+
+		// Tracking
+		{"known beacon host", `<p>a<img src="https://pixel.wp.com/g.gif?blog=1">b</p>`, `<p>ab</p>`},
+		{"feedburner pixel", `<img src="http://feeds.feedburner.com/~r/blog/~4/abc">`, ``},
+		{"1x1 image", `<img src="/p.gif" width="1" height="1">`, ``},
+		{"0px image", `<img src="/p.gif" width="0px" height="0px">`, ``},
+		{"small image", `<img src="/icon.png" width="1" height="16">`, `<img src="https://blog.example.com/icon.png" width="1" height="16"/>`},
+		{"utm parameters", `<a href="/post?utm_source=rss&utm_medium=feed&id=3">x</a>`,
+			`<a href="https://blog.example.com/post?id=3" rel="noreferrer noopener" target="_blank">x</a>`},
+		{"tracker parameters", `<img src="https://cdn.example.com/a.png?fbclid=1&gclid=2&mc_eid=3&w=200">`,
+			`<img src="https://cdn.example.com/a.png?w=200"/>`},
+		{"upper case utm", `<a href="https://example.org/?UTM_Campaign=x">x</a>`,
+			`<a href="https://example.org/" rel="noreferrer noopener" target="_blank">x</a>`},
+
+		// Relative URLs
+		{"relative link", `<a href="other">x</a>`,
+			`<a href="https://blog.example.com/posts/other" rel="noreferrer noopener" target="_blank">x</a>`},
+		{"root relative image", `<img src="/img/a.png">`, `<img src="https://blog.example.com/img/a.png"/>`},
+		{"parent relative image", `<img src="../a.png">`, `<img src="https://blog.example.com/a.png"/>`},
+		{"protocol relative", `<img src="//cdn.example.com/a.png">`, `<img src="https://cdn.example.com/a.png"/>`},
+		{"blockquote cite", `<blockquote cite="/source">q</blockquote>`, `<blockquote cite="https://blog.example.com/source">q</blockquote>`},
+
+		// Link attributes
+		{"rel and target are forced", `<a href="https://example.org/" rel="opener" target="_self">x</a>`,
+			`<a href="https://example.org/" rel="noreferrer noopener" target="_blank">x</a>`},
+	}
+	for _, tt := range tests {
+		if got := Content(tt.in, testBase); got != tt.want {
+			t.Errorf("%s:\n got  %s\n want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContentWithoutBase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// Relative URLs cannot be resolved and are dropped
+		{`<a href="/post">x</a><img src="a.png" alt="a">`, `x<img alt="a"/>`},
+		{`<a href="https://example.org/?utm_source=x">x</a>`, `<a href="https://example.org/" rel="noreferrer noopener" target="_blank">x</a>`},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.in); got != tt.want {
+			t.Errorf("HTML(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	got := Text("Tom & <Jerry>\r\nline two\r\n\r\n\r\nSecond")
+	want := "<p>Tom &amp; &lt;Jerry&gt;<br>line two</p>\n<p>Second</p>"
+	if got != want {
+		t.Fatalf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText("<h1>Title</h1><p>First  <b>bold</b><br>line</p><ul><li>one</li><li>two</li></ul>")
+	want := "Title\nFirst bold\nline\none\ntwo"
+	if got != want {
+		t.Fatalf("PlainText = %q, want %q", got, want)
+	}
+}
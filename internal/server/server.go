@@ -1,8 +1,14 @@
 package server
 
 import (
+	"blogAggregator/internal/fever"
+	"blogAggregator/internal/gql"
+	"blogAggregator/internal/greader"
 	"blogAggregator/internal/handlers"
+	"blogAggregator/internal/imageproxy"
 	"blogAggregator/internal/middleware"
+	"blogAggregator/internal/miniflux"
+	"blogAggregator/internal/websub"
 	"net/http"
   swaggerFiles "github.com/swaggo/files"
   ginSwagger "github.com/swaggo/gin-swagger"
@@ -21,9 +27,13 @@ func NewRouter() *gin.Engine {
 	})
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
 
 	// Auth
 	r.POST("/login", handlers.Login)
+	r.POST("/login/2fa", handlers.LoginTwoFactor)
+	r.GET("/auth/oidc/login", handlers.OIDCLogin)
+	r.GET("/auth/oidc/callback", handlers.OIDCCallback)
 	//protected routes
 	authRoutes := r.Group("/")
 	authRoutes.Use(middleware.AuthMiddleware())
@@ -33,15 +43,134 @@ func NewRouter() *gin.Engine {
 	r.POST("/users", handlers.CreateUser)
 	authRoutes.POST("/subscriptions", handlers.SubscribeFeed)
 	authRoutes.DELETE("/subscriptions", handlers.UnsubscribeFeed)
+	authRoutes.PUT("/subscriptions", handlers.MoveSubscription)
+	authRoutes.PUT("/subscriptions/full-content", handlers.UpdateFullContentSetting)
 	authRoutes.GET("/users/:id/feed", handlers.GetUserFeed)
+	authRoutes.POST("/users/2fa/enroll", handlers.EnrollTOTP)
+	authRoutes.POST("/users/2fa/confirm", handlers.ConfirmTOTP)
+	authRoutes.POST("/users/2fa/disable", handlers.DisableTOTP)
+	authRoutes.POST("/users/oidc/link", handlers.LinkOIDC)
+	authRoutes.GET("/users/digest", handlers.GetDigestSetting)
+	authRoutes.PUT("/users/digest", handlers.UpdateDigestSetting)
+	authRoutes.PUT("/users/fever", handlers.SetFeverPassword)
+	authRoutes.DELETE("/users/fever", handlers.DeleteFeverPassword)
+	authRoutes.GET("/users/newsletter", handlers.GetNewsletterAddress)
+	authRoutes.POST("/users/newsletter", handlers.CreateNewsletterAddress)
+	authRoutes.DELETE("/users/newsletter", handlers.DeleteNewsletterAddress)
+	authRoutes.POST("/api-keys", handlers.CreateAPIKey)
+	authRoutes.GET("/api-keys", handlers.ListAPIKeys)
+	authRoutes.DELETE("/api-keys/:id", handlers.DeleteAPIKey)
 
 	//feeds
 	r.POST("/feeds", handlers.CreateFeed)
 	r.GET("/feeds", handlers.ListFeeds)
 	r.POST("/feeds/refresh", handlers.RefreshFeed)
+	authRoutes.POST("/feeds/preview", handlers.PreviewFeed)
+	authRoutes.GET("/feeds/:id/fetches", handlers.ListFeedFetches)
+	authRoutes.POST("/feeds/:id/diagnose", handlers.DiagnoseFeed)
+	authRoutes.PUT("/feeds/:id/retention", middleware.RequireAdmin(), handlers.UpdateFeedRetention)
 
 	//post
 	r.GET("/posts", handlers.ListPosts)
+	r.GET("/posts/stream", middleware.StreamAuthMiddleware(), handlers.StreamPosts)
+	authRoutes.POST("/posts/stream/ticket", handlers.CreateStreamTicket)
+	authRoutes.POST("/posts/:id/full-content", handlers.FetchPostFullContent)
+	authRoutes.GET("/extraction-rules", handlers.ListExtractionRules)
+	authRoutes.POST("/extraction-rules", middleware.RequireAdmin(), handlers.SaveExtractionRule)
+	authRoutes.DELETE("/extraction-rules/:id", middleware.RequireAdmin(), handlers.DeleteExtractionRule)
+
+	//graphql
+	authRoutes.POST("/graphql", gql.Handler)
+
+	//folders
+	authRoutes.POST("/folders", handlers.CreateFolder)
+	authRoutes.GET("/folders", handlers.ListFolders)
+	authRoutes.DELETE("/folders/:id", handlers.DeleteFolder)
+
+	//webhooks
+	authRoutes.POST("/webhooks", handlers.CreateWebhook)
+	authRoutes.GET("/webhooks", handlers.ListWebhooks)
+	authRoutes.PUT("/webhooks/:id", handlers.UpdateWebhook)
+	authRoutes.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+	authRoutes.GET("/webhooks/:id/deliveries", handlers.ListWebhookDeliveries)
+	authRoutes.POST("/webhooks/:id/test", handlers.TestWebhook)
+
+	//google reader api
+	r.POST("/accounts/ClientLogin", greader.ClientLogin)
+	reader := r.Group("/reader/api/0")
+	reader.Use(greader.AuthMiddleware())
+	reader.GET("/token", greader.Token)
+	reader.GET("/user-info", greader.UserInfo)
+	reader.GET("/subscription/list", greader.SubscriptionList)
+	reader.GET("/tag/list", greader.TagList)
+	reader.GET("/unread-count", greader.UnreadCount)
+	reader.GET("/stream/contents", greader.StreamContents)
+	reader.GET("/stream/contents/*stream", greader.StreamContents)
+	reader.POST("/stream/contents", greader.StreamContents)
+	reader.POST("/stream/contents/*stream", greader.StreamContents)
+	reader.GET("/stream/items/ids", greader.StreamItemIDs)
+	reader.POST("/stream/items/ids", greader.StreamItemIDs)
+	reader.GET("/stream/items/contents", greader.ItemsContents)
+	reader.POST("/stream/items/contents", greader.ItemsContents)
+	readerWrite := reader.Group("/")
+	readerWrite.Use(greader.RequireWriteToken())
+	readerWrite.POST("/subscription/edit", greader.SubscriptionEdit)
+	readerWrite.POST("/subscription/quickadd", greader.QuickAdd)
+	readerWrite.POST("/edit-tag", greader.EditTag)
+	readerWrite.POST("/mark-all-as-read", greader.MarkAllAsRead)
+	readerWrite.POST("/rename-tag", greader.RenameTag)
+	readerWrite.POST("/disable-tag", greader.DisableTag)
+
+	//image proxy
+	if imageproxy.Enabled() {
+		r.GET("/img/:sig/:url", imageproxy.Serve)
+	}
+
+	//websub callbacks
+	if websub.Enabled() {
+		r.GET("/websub/:token", websub.Verify)
+		r.POST("/websub/:token", websub.Receive)
+	}
+
+	//fever api
+	r.POST("/fever", fever.Handler)
+	r.POST("/fever/", fever.Handler)
+
+	//miniflux api
+	if miniflux.Enabled() {
+		v1 := r.Group("/v1")
+		v1.Use(miniflux.AuthMiddleware())
+		v1.GET("/version", miniflux.Version)
+		v1.GET("/me", miniflux.Me)
+		v1.POST("/discover", miniflux.Discover)
+		v1.GET("/feeds", miniflux.GetFeeds)
+		v1.POST("/feeds", miniflux.CreateFeed)
+		v1.PUT("/feeds/refresh", miniflux.RefreshAllFeeds)
+		v1.GET("/feeds/counters", miniflux.FeedCounters)
+		v1.GET("/feeds/:feedID", miniflux.GetFeed)
+		v1.PUT("/feeds/:feedID", miniflux.UpdateFeed)
+		v1.DELETE("/feeds/:feedID", miniflux.DeleteFeed)
+		v1.PUT("/feeds/:feedID/refresh", miniflux.RefreshFeed)
+		v1.GET("/feeds/:feedID/icon", miniflux.FeedIcon)
+		v1.PUT("/feeds/:feedID/mark-all-as-read", miniflux.MarkFeedAsRead)
+		v1.GET("/feeds/:feedID/entries", miniflux.GetEntries)
+		v1.GET("/feeds/:feedID/entries/:entryID", miniflux.GetEntry)
+		v1.GET("/categories", miniflux.GetCategories)
+		v1.POST("/categories", miniflux.CreateCategory)
+		v1.PUT("/categories/:categoryID", miniflux.UpdateCategory)
+		v1.DELETE("/categories/:categoryID", miniflux.DeleteCategory)
+		v1.GET("/categories/:categoryID/feeds", miniflux.GetCategoryFeeds)
+		v1.PUT("/categories/:categoryID/mark-all-as-read", miniflux.MarkCategoryAsRead)
+		v1.GET("/categories/:categoryID/entries", miniflux.GetEntries)
+		v1.GET("/categories/:categoryID/entries/:entryID", miniflux.GetEntry)
+		v1.GET("/entries", miniflux.GetEntries)
+		v1.PUT("/entries", miniflux.UpdateEntries)
+		v1.GET("/entries/:entryID", miniflux.GetEntry)
+		v1.PUT("/entries/:entryID/bookmark", miniflux.ToggleBookmark)
+		v1.PUT("/entries/:entryID/star", miniflux.ToggleBookmark)
+		// Anything else under /v1/ answers 501 instead of 404
+		r.NoRoute(miniflux.NoRoute)
+	}
 
 	return r
 }
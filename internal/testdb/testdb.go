@@ -0,0 +1,108 @@
+// Package testdb points database.DB at the Postgres database named by
+// TEST_DATABASE_DSN; tests that need it are skipped when it is not set.
+// Each test binary works in a schema of its own, migrated once and emptied
+// before every test, so packages can run in parallel against one
+// disposable database.
+package testdb
+
+import (
+	"blogAggregator/internal/database"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	once     sync.Once
+	setupErr error
+)
+
+var unsafeName = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Open connects database.DB to an empty, fully migrated schema
+func Open(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	once.Do(func() { setupErr = setup(dsn) })
+	if setupErr != nil {
+		t.Fatal(setupErr)
+	}
+	if err := truncate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// DSN returns the test database DSN with the schema of this test binary
+// first on the search path, for code that opens its own connections
+func DSN(t *testing.T) string {
+	t.Helper()
+	Open(t)
+	return withSearchPath(os.Getenv("TEST_DATABASE_DSN"), schemaName())
+}
+
+func schemaName() string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+	return "test_" + unsafeName.ReplaceAllString(strings.ToLower(name), "_")
+}
+
+func setup(dsn string) error {
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	schema := schemaName()
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		return err
+	}
+	err = admin.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %q CASCADE`, schema)).Error
+	if err == nil {
+		err = admin.Exec(fmt.Sprintf(`CREATE SCHEMA %q`, schema)).Error
+	}
+	if sqlDB, dbErr := admin.DB(); dbErr == nil {
+		sqlDB.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	database.DB, err = gorm.Open(postgres.Open(withSearchPath(dsn, schema)), config)
+	if err != nil {
+		return err
+	}
+	_, err = database.MigrateUp()
+	return err
+}
+
+// withSearchPath adds search_path to a URL or keyword/value DSN
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+func truncate() error {
+	var tables []string
+	err := database.DB.Raw(`SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`).Scan(&tables).Error
+	if err != nil || len(tables) == 0 {
+		return err
+	}
+	for i, table := range tables {
+		tables[i] = fmt.Sprintf("%q", table)
+	}
+	return database.DB.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error
+}
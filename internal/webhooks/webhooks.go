@@ -0,0 +1,302 @@
+// Package webhooks queues signed JSON notifications about new posts and
+// delivers them to user-managed endpoints with retries.
+package webhooks
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	EventPostCreated = "post.created"
+	EventPing        = "ping"
+
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// claimLease keeps a claimed delivery away from other workers while it is in flight
+	claimLease = 5 * time.Minute
+)
+
+var client = safehttp.New(10*time.Second, 1<<20)
+
+// Payload is the JSON body sent to webhook endpoints
+type Payload struct {
+	Event     string       `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+	Post      *models.Post `json:"post,omitempty"`
+	Feed      *models.Feed `json:"feed,omitempty"`
+}
+
+// GenerateSecret returns a random signing secret for a new webhook
+func GenerateSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign computes the X-Webhook-Signature value over "<timestamp>.<body>"
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// QueuePosts stores a delivery for every active webhook that matches one of
+// the newly inserted posts. Fetchers call it right after storing posts, so
+// deliveries survive bursts and restarts; sending happens in ProcessDue.
+func QueuePosts(posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	var hooks []models.Webhook
+	if err := database.DB.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	feeds := map[uint]*models.Feed{}
+	subs := map[uint][]models.Subscription{}
+	for i := range posts {
+		post := posts[i]
+		feed, ok := feeds[post.FeedId]
+		if !ok {
+			feed = &models.Feed{}
+			if err := database.DB.First(feed, post.FeedId).Error; err != nil {
+				return err
+			}
+			var feedSubs []models.Subscription
+			if err := database.DB.Where("feed_id = ?", post.FeedId).Find(&feedSubs).Error; err != nil {
+				return err
+			}
+			feeds[post.FeedId], subs[post.FeedId] = feed, feedSubs
+		}
+
+		for _, hook := range hooks {
+			// Another user's newsletter never reaches a webhook, whatever
+			// its feed_ids say
+			if !feed.VisibleTo(hook.UserID) || !Matches(hook, post, subs[post.FeedId]) {
+				continue
+			}
+			payload := Payload{Event: EventPostCreated, CreatedAt: time.Now().UTC(), Post: &post, Feed: feed}
+			if _, err := Enqueue(hook, payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a post passes the webhook's feed, folder and
+// keyword filters. subs are all subscriptions to the post's feed.
+func Matches(hook models.Webhook, post models.Post, subs []models.Subscription) bool {
+	var ownSub *models.Subscription
+	for i := range subs {
+		if subs[i].UserID == hook.UserID {
+			ownSub = &subs[i]
+			break
+		}
+	}
+
+	if len(hook.FeedIDs) > 0 {
+		if !containsUint(hook.FeedIDs, post.FeedId) {
+			return false
+		}
+	} else if ownSub == nil {
+		return false
+	}
+
+	if len(hook.FolderIDs) > 0 {
+		if ownSub == nil || ownSub.FolderID == nil || !containsUint(hook.FolderIDs, *ownSub.FolderID) {
+			return false
+		}
+	}
+
+	if len(hook.Keywords) > 0 {
+		text := strings.ToLower(post.Title + " " + post.Content)
+		found := false
+		for _, kw := range hook.Keywords {
+			if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" && strings.Contains(text, kw) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Enqueue stores a pending delivery for the webhook
+func Enqueue(hook models.Webhook, payload Payload) (*models.WebhookDelivery, error) {
+	return enqueueAt(hook, payload, time.Now().UTC())
+}
+
+// SendTest delivers a ping event right away. The delivery is queued with a
+// claim lease so the dispatcher does not send it a second time meanwhile.
+func SendTest(hook models.Webhook) (*models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	delivery, err := enqueueAt(hook, Payload{Event: EventPing, CreatedAt: now}, now.Add(claimLease))
+	if err != nil {
+		return nil, err
+	}
+	Deliver(hook, delivery)
+	return delivery, nil
+}
+
+func enqueueAt(hook models.Webhook, payload Payload, at time.Time) (*models.WebhookDelivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         payload.Event,
+		Payload:       string(body),
+		Status:        StatusPending,
+		NextAttemptAt: at,
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ProcessDue claims up to limit due deliveries and attempts them. Claiming
+// uses SKIP LOCKED so several replicas can run the dispatcher at once.
+func ProcessDue(limit int) (int, error) {
+	var due []models.WebhookDelivery
+	now := time.Now().UTC()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		var hook models.Webhook
+		if err := database.DB.First(&hook, due[i].WebhookID).Error; err != nil {
+			database.DB.Model(&due[i]).Updates(map[string]interface{}{"status": StatusFailed, "error": "webhook deleted"})
+			continue
+		}
+		Deliver(hook, &due[i])
+	}
+	return len(due), nil
+}
+
+// Deliver makes one attempt and records the outcome, scheduling a retry with
+// exponential backoff on failure.
+func Deliver(hook models.Webhook, d *models.WebhookDelivery) {
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.ResponseCode = 0
+	d.Error = ""
+
+	code, err := send(hook, d)
+	d.ResponseCode = code
+	switch {
+	case err == nil && code >= 200 && code < 300:
+		d.Status = StatusSucceeded
+	case d.Attempts >= maxAttempts:
+		d.Status = StatusFailed
+	default:
+		d.Status = StatusPending
+		d.NextAttemptAt = now.Add(backoff(d.Attempts))
+	}
+	if err != nil {
+		d.Error = err.Error()
+	} else if d.Status != StatusSucceeded {
+		d.Error = fmt.Sprintf("endpoint returned %d", code)
+	}
+
+	if err := database.DB.Save(d).Error; err != nil {
+		log.Printf("webhooks: could not record delivery %d: %v", d.ID, err)
+	}
+}
+
+func send(hook models.Webhook, d *models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BlogAggregator-Webhook/1.0")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the wait after each failed attempt, capped at maxBackoff
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff << (attempts - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+func containsUint(list []uint, v uint) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package webhooks
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"blogAggregator/internal/testdb"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignKnownVector(t *testing.T) {
+	got := Sign("whsec_test", "1700000000", []byte(`{"event":"ping"}`))
+	want := "sha256=aa8efe37b751e71157c508c5ac4acb1e9fe5225db98355dfc00f4b680afbc447"
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestSignCoversSecretTimestampAndBody(t *testing.T) {
+	base := Sign("secret", "1700000000", []byte("body"))
+	for name, sig := range map[string]string{
+		"secret":    Sign("other", "1700000000", []byte("body")),
+		"timestamp": Sign("secret", "1700000001", []byte("body")),
+		"body":      Sign("secret", "1700000000", []byte("body!")),
+		// The separator keeps timestamp and body from shifting into each other
+		"boundary": Sign("secret", "170000000", []byte("0.body")),
+	} {
+		if sig == base {
+			t.Errorf("signature does not change with the %s", name)
+		}
+	}
+}
+
+// allowLoopback lets the safe client reach httptest servers
+func allowLoopback(t *testing.T) {
+	t.Helper()
+	if err := safehttp.Configure([]string{"127.0.0.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { safehttp.Configure(nil, nil) })
+}
+
+func TestSendSignsRequest(t *testing.T) {
+	allowLoopback(t)
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{ID: 1, URL: srv.URL, Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: 42, Event: EventPing, Payload: `{"event":"ping"}`}
+	code, err := send(hook, delivery)
+	if err != nil || code != http.StatusAccepted {
+		t.Fatalf("send = %d, %v", code, err)
+	}
+
+	if string(gotBody) != delivery.Payload {
+		t.Fatalf("body = %s", gotBody)
+	}
+	if got.Header.Get("X-Webhook-Event") != EventPing || got.Header.Get("X-Webhook-Delivery") != "42" ||
+		got.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", got.Header)
+	}
+	// Verify the way a receiver would
+	timestamp := got.Header.Get("X-Webhook-Timestamp")
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Fatalf("timestamp = %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write([]byte(timestamp + "." + string(gotBody)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(got.Header.Get("X-Webhook-Signature")), []byte(want)) {
+		t.Fatalf("signature = %s, want %s", got.Header.Get("X-Webhook-Signature"), want)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback address")
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{URL: srv.URL, Secret: "s"}
+	if _, err := send(hook, &models.WebhookDelivery{Payload: "{}"}); !errors.Is(err, safehttp.ErrBlocked) {
+		t.Fatalf("send error = %v, want ErrBlocked", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		10: 256 * time.Minute,
+		11: maxBackoff,
+		80: maxBackoff,
+	} {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	folder := uint(7)
+	subs := []models.Subscription{
+		{UserID: 1, FeedID: 10, FolderID: &folder},
+		{UserID: 2, FeedID: 10},
+	}
+	post := models.Post{FeedId: 10, Title: "Go 1.24 released", Content: "<p>Generic type aliases</p>"}
+	tests := []struct {
+		name string
+		hook models.Webhook
+		want bool
+	}{
+		{"subscribed", models.Webhook{UserID: 2}, true},
+		{"not subscribed", models.Webhook{UserID: 3}, false},
+		{"listed feed", models.Webhook{UserID: 3, FeedIDs: []uint{10}}, true},
+		{"other feed", models.Webhook{UserID: 1, FeedIDs: []uint{11}}, false},
+		{"in folder", models.Webhook{UserID: 1, FolderIDs: []uint{7}}, true},
+		{"other folder", models.Webhook{UserID: 1, FolderIDs: []uint{8}}, false},
+		{"no folder", models.Webhook{UserID: 2, FolderIDs: []uint{7}}, false},
+		{"keyword in title", models.Webhook{UserID: 2, Keywords: []string{"RELEASED"}}, true},
+		{"keyword in content", models.Webhook{UserID: 2, Keywords: []string{"aliases"}}, true},
+		{"no keyword", models.Webhook{UserID: 2, Keywords: []string{"rust", " "}}, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.hook, post, subs); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueuePostsStoresEveryDelivery(t *testing.T) {
+	testdb.Open(t)
+	user := models.User{Username: "alice", Email: "alice@example.com"}
+	database.DB.Create(&user)
+	feed := models.Feed{Title: "Feed", URL: "https://example.com/feed"}
+	database.DB.Create(&feed)
+	database.DB.Create(&models.Subscription{UserID: user.ID, FeedID: feed.ID})
+	hook := models.Webhook{UserID: user.ID, URL: "https://hooks.example.com", Secret: "s", Active: true}
+	database.DB.Create(&hook)
+
+	// More posts than any in-memory buffer held, as in a large refresh
+	posts := make([]models.Post, 1500)
+	for i := range posts {
+		posts[i] = models.Post{
+			Title:     "Post " + strconv.Itoa(i),
+			Link:      "https://example.com/" + strconv.Itoa(i),
+			FeedId:    feed.ID,
+			Published: time.Now(),
+		}
+	}
+	if err := database.DB.CreateInBatches(&posts, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := QueuePosts(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	database.DB.Where("webhook_id = ?", hook.ID).Order("id").Find(&deliveries)
+	if len(deliveries) != len(posts) {
+		t.Fatalf("%d deliveries queued for %d posts", len(deliveries), len(posts))
+	}
+	var payload Payload
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != EventPostCreated || payload.Post.ID != posts[0].ID || payload.Feed.ID != feed.ID ||
+		deliveries[0].Status != StatusPending {
+		t.Fatalf("delivery = %+v, payload = %+v", deliveries[0], payload)
+	}
+}
+
+func TestQueuePostsSkipsOtherUsersNewsletters(t *testing.T) {
+	testdb.Open(t)
+	alice := models.User{Username: "alice", Email: "alice@example.com"}
+	bob := models.User{Username: "bob", Email: "bob@example.com"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	feed := models.Feed{
+		Title:      "Digest",
+		URL:        "mailto:digest@example.com?recipient=" + strconv.Itoa(int(alice.ID)),
+		SourceType: models.FeedSourceNewsletter,
+	}
+	database.DB.Create(&feed)
+	// A hook saved before feed_ids were checked still names alice's feed
+	hooks := []models.Webhook{
+		{UserID: alice.ID, URL: "https://hooks.example.com/alice", Secret: "s", FeedIDs: []uint{feed.ID}, Active: true},
+		{UserID: bob.ID, URL: "https://hooks.example.com/bob", Secret: "s", FeedIDs: []uint{feed.ID}, Active: true},
+	}
+	database.DB.Create(&hooks)
+	post := models.Post{Title: "Issue 1", Link: "mid:1", FeedId: feed.ID, Published: time.Now()}
+	database.DB.Create(&post)
+
+	if err := QueuePosts([]models.Post{post}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		hook       models.Webhook
+		deliveries int64
+	}{{hooks[0], 1}, {hooks[1], 0}} {
+		var count int64
+		database.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", want.hook.ID).Count(&count)
+		if count != want.deliveries {
+			t.Errorf("%s: %d deliveries, want %d", want.hook.URL, count, want.deliveries)
+		}
+	}
+}
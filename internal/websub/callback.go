@@ -0,0 +1,119 @@
+package websub
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/rss"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPushBytes caps pushed content, like a fetched feed
+const maxPushBytes = 10 << 20
+
+var signatureHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+func findSubscription(c *gin.Context) (models.WebSubSubscription, bool) {
+	var sub models.WebSubSubscription
+	err := database.DB.Where("callback_token = ?", c.Param("token")).First(&sub).Error
+	return sub, err == nil
+}
+
+// Verify answers the hub's intent verification and denial notices
+func Verify(c *gin.Context) {
+	sub, ok := findSubscription(c)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	switch c.Query("hub.mode") {
+	case "subscribe":
+		// Active subscriptions are verified again when renewed
+		if c.Query("hub.topic") != sub.Topic || (sub.State != models.WebSubPending && sub.State != models.WebSubActive) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		lease, err := strconv.Atoi(c.Query("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = leaseSeconds
+		}
+		expires := time.Now().UTC().Add(time.Duration(lease) * time.Second)
+		database.DB.Model(&sub).Updates(map[string]interface{}{
+			"state": models.WebSubActive, "lease_seconds": lease, "expires_at": expires, "last_error": "",
+		})
+		c.String(http.StatusOK, c.Query("hub.challenge"))
+	case "denied":
+		database.DB.Model(&sub).Updates(map[string]interface{}{
+			"state": models.WebSubDenied, "last_error": "denied by hub: " + c.Query("hub.reason"),
+		})
+		c.Status(http.StatusOK)
+	default:
+		// We never unsubscribe through a hub; refuse anyone else's attempt
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// Receive ingests content pushed by the hub. Unsigned or badly signed
+// bodies are acknowledged but ignored, as the spec requires.
+func Receive(c *gin.Context) {
+	sub, ok := findSubscription(c)
+	if !ok || sub.State != models.WebSubActive {
+		c.Status(http.StatusGone)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPushBytes))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if !validSignature(c.GetHeader("X-Hub-Signature"), sub.Secret, body) {
+		log.Printf("websub: ignoring push for feed %d with a bad signature", sub.FeedID)
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	var feed models.Feed
+	if err := database.DB.First(&feed, sub.FeedID).Error; err != nil {
+		c.Status(http.StatusGone)
+		return
+	}
+	if err := rss.IngestPush(feed, body, c.GetHeader("Content-Type")); err != nil {
+		log.Printf("websub: push for feed %d could not be stored: %v", feed.ID, err)
+	}
+	now := time.Now().UTC()
+	database.DB.Model(&sub).Update("last_push_at", &now)
+	c.Status(http.StatusAccepted)
+}
+
+// validSignature checks an X-Hub-Signature of the form "sha256=<hex>"
+func validSignature(header, secret string, body []byte) bool {
+	algo, sig, ok := strings.Cut(header, "=")
+	newHash, known := signatureHashes[strings.ToLower(algo)]
+	if !ok || !known {
+		return false
+	}
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
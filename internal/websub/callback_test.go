@@ -0,0 +1,200 @@
+package websub
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/testdb"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const pushedFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link>
+<item><title>Pushed</title><link>https://example.com/pushed</link><guid>pushed-1</guid></item>
+</channel></rss>`
+
+func sign(newHash func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(pushedFeed)
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{"sha256", "sha256=" + sign(sha256.New, "secret", body), true},
+		{"sha1", "sha1=" + sign(sha1.New, "secret", body), true},
+		{"upper case algorithm", "SHA256=" + sign(sha256.New, "secret", body), true},
+		{"missing", "", false},
+		{"no algorithm", sign(sha256.New, "secret", body), false},
+		{"wrong secret", "sha256=" + sign(sha256.New, "other", body), false},
+		{"other algorithm's digest", "sha256=" + sign(sha1.New, "secret", body), false},
+		{"unknown algorithm", "md5=" + sign(sha256.New, "secret", body), false},
+		{"not hex", "sha256=zz", false},
+	}
+	for _, tt := range tests {
+		if got := validSignature(tt.header, "secret", body); got != tt.valid {
+			t.Errorf("%s: validSignature = %v", tt.name, got)
+		}
+	}
+}
+
+func newCallbackRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/websub/:token", Verify)
+	r.POST("/websub/:token", Receive)
+	return r
+}
+
+// subscription stores a feed with a WebSub subscription in state
+func subscription(t *testing.T, state string) (models.Feed, models.WebSubSubscription) {
+	t.Helper()
+	feed := models.Feed{Title: "Blog", URL: "https://example.com/feed"}
+	if err := database.DB.Create(&feed).Error; err != nil {
+		t.Fatal(err)
+	}
+	sub := models.WebSubSubscription{
+		FeedID:        feed.ID,
+		HubURL:        "https://hub.example.com",
+		Topic:         feed.URL,
+		CallbackToken: "token",
+		Secret:        "secret",
+		State:         state,
+	}
+	if err := database.DB.Create(&sub).Error; err != nil {
+		t.Fatal(err)
+	}
+	return feed, sub
+}
+
+func TestReceiveChecksSignature(t *testing.T) {
+	testdb.Open(t)
+	feed, _ := subscription(t, models.WebSubActive)
+	r := newCallbackRouter()
+	body := []byte(pushedFeed)
+
+	push := func(signature string) int64 {
+		req := httptest.NewRequest(http.MethodPost, "/websub/token", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/rss+xml")
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature", signature)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		// Bad signatures are acknowledged all the same
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("push with %q: got %d", signature, w.Code)
+		}
+		var count int64
+		database.DB.Model(&models.Post{}).Where("feed_id = ?", feed.ID).Count(&count)
+		return count
+	}
+
+	for _, signature := range []string{
+		"",
+		"sha256=" + sign(sha256.New, "wrong", body),
+		"md5=" + sign(sha256.New, "secret", body),
+	} {
+		if n := push(signature); n != 0 {
+			t.Fatalf("push with %q stored %d posts", signature, n)
+		}
+	}
+	if n := push("sha256=" + sign(sha256.New, "secret", body)); n != 1 {
+		t.Fatalf("signed push stored %d posts", n)
+	}
+	var sub models.WebSubSubscription
+	database.DB.First(&sub)
+	if sub.LastPushAt == nil {
+		t.Fatal("last_push_at not set")
+	}
+}
+
+func TestReceiveRequiresActiveSubscription(t *testing.T) {
+	testdb.Open(t)
+	subscription(t, models.WebSubPending)
+	body := []byte(pushedFeed)
+	req := httptest.NewRequest(http.MethodPost, "/websub/token", bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature", "sha256="+sign(sha256.New, "secret", body))
+	w := httptest.NewRecorder()
+	newCallbackRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusGone {
+		t.Fatalf("got %d", w.Code)
+	}
+}
+
+func TestVerifyEchoesChallenge(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  string
+		token  string
+		mode   string
+		topic  string
+		echoed bool
+	}{
+		{"pending subscription", models.WebSubPending, "token", "subscribe", "https://example.com/feed", true},
+		{"renewal", models.WebSubActive, "token", "subscribe", "https://example.com/feed", true},
+		{"other topic", models.WebSubPending, "token", "subscribe", "https://example.com/other", false},
+		{"denied subscription", models.WebSubDenied, "token", "subscribe", "https://example.com/feed", false},
+		{"unknown token", models.WebSubPending, "other", "subscribe", "https://example.com/feed", false},
+		{"unsubscribe", models.WebSubActive, "token", "unsubscribe", "https://example.com/feed", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testdb.Open(t)
+			_, sub := subscription(t, tt.state)
+			query := url.Values{
+				"hub.mode":          {tt.mode},
+				"hub.topic":         {tt.topic},
+				"hub.challenge":     {"challenge-123"},
+				"hub.lease_seconds": {"3600"},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/websub/"+tt.token+"?"+query.Encode(), nil)
+			w := httptest.NewRecorder()
+			newCallbackRouter().ServeHTTP(w, req)
+
+			database.DB.First(&sub, sub.ID)
+			if !tt.echoed {
+				if w.Code != http.StatusNotFound || w.Body.String() == "challenge-123" {
+					t.Fatalf("got %d %q", w.Code, w.Body)
+				}
+				if sub.State != tt.state {
+					t.Fatalf("state changed to %s", sub.State)
+				}
+				return
+			}
+			if w.Code != http.StatusOK || w.Body.String() != "challenge-123" {
+				t.Fatalf("got %d %q", w.Code, w.Body)
+			}
+			if sub.State != models.WebSubActive || sub.LeaseSeconds != 3600 || sub.ExpiresAt == nil {
+				t.Fatalf("subscription = %+v", sub)
+			}
+		})
+	}
+}
+
+func TestVerifyRecordsDenial(t *testing.T) {
+	testdb.Open(t)
+	_, sub := subscription(t, models.WebSubPending)
+	req := httptest.NewRequest(http.MethodGet, "/websub/token?hub.mode=denied&hub.reason=spam", nil)
+	w := httptest.NewRecorder()
+	newCallbackRouter().ServeHTTP(w, req)
+	database.DB.First(&sub, sub.ID)
+	if w.Code != http.StatusOK || sub.State != models.WebSubDenied || sub.LastError != "denied by hub: spam" {
+		t.Fatalf("got %d, subscription = %+v", w.Code, sub)
+	}
+}
@@ -0,0 +1,170 @@
+// Package websub keeps WebSub (PubSubHubbub) push subscriptions for feeds
+// that advertise a hub, so new posts arrive when they are published rather
+// than on the next poll. Hubs call back on /websub/:token, where intent
+// verification is answered and signed content is ingested through rss.
+package websub
+
+import (
+	"blogAggregator/internal/database"
+	"blogAggregator/internal/models"
+	"blogAggregator/internal/safehttp"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// leaseSeconds is the lease we ask hubs for; they may grant another
+	leaseSeconds = 10 * 24 * 60 * 60
+	// pendingTimeout is how long a hub gets to verify a subscription
+	pendingTimeout = time.Hour
+	// retryAfter spaces out attempts with hubs that failed or denied us
+	retryAfter = 6 * time.Hour
+	// SafetyPollInterval is how often feeds with an active subscription
+	// are still polled, in case the hub misses an update
+	SafetyPollInterval = 6 * time.Hour
+)
+
+var (
+	callbackBase string
+	client       = safehttp.New(30*time.Second, 1<<20)
+)
+
+// Enable turns on WebSub with callbacks under baseURL, the server's public
+// address
+func Enable(baseURL string) {
+	callbackBase = strings.TrimSuffix(baseURL, "/")
+}
+
+// Enabled reports whether WebSub subscriptions and callbacks are on
+func Enabled() bool {
+	return callbackBase != ""
+}
+
+// ActiveFeedIDs selects feeds with a live push subscription, for use as a
+// subquery
+func ActiveFeedIDs() *gorm.DB {
+	return database.DB.Model(&models.WebSubSubscription{}).
+		Where("state = ? AND expires_at > ?", models.WebSubActive, time.Now().UTC()).
+		Select("feed_id")
+}
+
+// Reconcile subscribes feeds that advertise a hub and have no working
+// subscription, renews leases before they expire, and drops subscriptions
+// of feeds that no longer advertise a hub
+func Reconcile() error {
+	now := time.Now().UTC()
+
+	var feeds []models.Feed
+	err := database.DB.Where("hub_url <> ''").
+		Where("id NOT IN (?)", database.DB.Model(&models.WebSubSubscription{}).Select("feed_id")).
+		Find(&feeds).Error
+	if err != nil {
+		return err
+	}
+	for _, feed := range feeds {
+		subscribe(feed, nil)
+	}
+
+	var subs []models.WebSubSubscription
+	if err := database.DB.Find(&subs).Error; err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		var feed models.Feed
+		if err := database.DB.First(&feed, sub.FeedID).Error; err != nil {
+			continue
+		}
+		switch {
+		case feed.HubURL == "":
+			database.DB.Delete(&sub)
+		case feed.HubURL != sub.HubURL || feed.TopicURL != sub.Topic:
+			subscribe(feed, &sub)
+		case sub.State == models.WebSubActive && needsRenewal(sub, now) && sub.UpdatedAt.Before(now.Add(-pendingTimeout)):
+			subscribe(feed, &sub)
+		case sub.State == models.WebSubPending && sub.UpdatedAt.Before(now.Add(-pendingTimeout)):
+			subscribe(feed, &sub)
+		case (sub.State == models.WebSubFailed || sub.State == models.WebSubDenied) && sub.UpdatedAt.Before(now.Add(-retryAfter)):
+			subscribe(feed, &sub)
+		}
+	}
+	return nil
+}
+
+// needsRenewal is true in the last quarter of a lease
+func needsRenewal(sub models.WebSubSubscription, now time.Time) bool {
+	if sub.ExpiresAt == nil {
+		return true
+	}
+	lease := time.Duration(sub.LeaseSeconds) * time.Second
+	return sub.ExpiresAt.Sub(now) < lease/4
+}
+
+// subscribe asks the feed's hub for a subscription. Renewals keep the
+// callback and secret so pushes keep arriving meanwhile; otherwise new ones
+// are used, so stale hub state cannot push to us.
+func subscribe(feed models.Feed, sub *models.WebSubSubscription) {
+	renewal := sub != nil && sub.State == models.WebSubActive &&
+		sub.HubURL == feed.HubURL && sub.Topic == feed.TopicURL
+	if sub == nil {
+		sub = &models.WebSubSubscription{FeedID: feed.ID}
+	}
+	if !renewal {
+		token, err := randomHex(16)
+		if err != nil {
+			return
+		}
+		secret, err := randomHex(32)
+		if err != nil {
+			return
+		}
+		sub.HubURL = feed.HubURL
+		sub.Topic = feed.TopicURL
+		sub.CallbackToken = token
+		sub.Secret = secret
+		sub.State = models.WebSubPending
+	}
+	sub.LastError = ""
+	if err := database.DB.Save(sub).Error; err != nil {
+		return
+	}
+
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {sub.Topic},
+		"hub.callback":      {callbackBase + "/websub/" + sub.CallbackToken},
+		"hub.secret":        {sub.Secret},
+		"hub.lease_seconds": {strconv.Itoa(leaseSeconds)},
+	}
+	resp, err := client.PostForm(sub.HubURL, form)
+	if err != nil {
+		fail(sub, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		fail(sub, fmt.Sprintf("hub answered %s: %s", resp.Status, strings.TrimSpace(string(msg))))
+	}
+}
+
+func fail(sub *models.WebSubSubscription, reason string) {
+	database.DB.Model(sub).Updates(map[string]interface{}{
+		"state": models.WebSubFailed, "last_error": reason,
+	})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}